@@ -2,10 +2,13 @@ package main
 
 import (
 	"context"
+	"expvar"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"sync"
 	"syscall"
 	"time"
 
@@ -13,7 +16,10 @@ import (
 	chimiddleware "github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/ncapetillo/demo-fluida/internal/db"
+	"github.com/ncapetillo/demo-fluida/internal/events"
+	"github.com/ncapetillo/demo-fluida/internal/fx"
 	"github.com/ncapetillo/demo-fluida/internal/handlers"
+	"github.com/ncapetillo/demo-fluida/internal/leader"
 	"github.com/ncapetillo/demo-fluida/internal/middleware"
 	"github.com/ncapetillo/demo-fluida/internal/repository"
 	"github.com/ncapetillo/demo-fluida/internal/response"
@@ -45,50 +51,146 @@ func main() {
 	
 	// Initialize repository
 	invoiceRepo := repository.NewInvoiceRepository(db.DB)
+	creditNoteRepo := repository.NewCreditNoteRepository(db.DB)
+	recurringRepo := repository.NewRecurringScheduleRepository(db.DB)
+	numberSequenceRepo := repository.NewNumberSequenceRepository(db.DB)
+	batchJobRepo := repository.NewInvoiceBatchJobRepository(db.DB)
+	customerRepo := repository.NewCustomerRepository(db.DB)
+	reportRepo := repository.NewReportRepository(db.DB)
+	paymentRepo := repository.NewPaymentRepository(db.DB)
+	paymentLinkRepo := repository.NewPaymentLinkRepository(db.DB)
+	checkoutRepo := repository.NewCheckoutRepository(db.DB)
+	draftInvoiceRepo := repository.NewDraftInvoiceRepository(db.DB)
+	
+	// Exchange rates for invoices settled in a token of another currency
+	rateProvider, err := fx.NewProviderFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to configure exchange rates: %v", err)
+	}
+	
+	// Receiver wallets are vetted before invoices are issued to them
+	receiverInspector := solana.NewReceiverInspector()
+	
+	// Invoice status events for the payment page, fanned out to every replica
+	// since the watcher runs on the leader and payers may be connected to any
+	invoiceEvents := events.NewBroker(sqlDB)
+	if err := invoiceEvents.Start(); err != nil {
+		log.Printf("Warning: %v", err)
+		log.Println("Payment pages will only see events published by this replica")
+	}
+	
+	// Initialize Solana payment watcher; it is started by the leader, see below
+	solanaWatcher, err := solana.NewPaymentWatcher(invoiceEvents)
+	if err != nil {
+		log.Printf("Warning: Failed to initialize Solana payment watcher: %v", err)
+		log.Println("Automatic payment detection will not work")
+	}
 	
 	// Initialize services
-	invoiceService := services.NewInvoiceService(invoiceRepo)
+	invoiceService := services.NewInvoiceService(invoiceRepo, paymentLinkRepo, rateProvider, receiverInspector, invoiceEvents)
+	draftInvoiceService := services.NewDraftInvoiceService(draftInvoiceRepo)
+	creditNoteService := services.NewCreditNoteService(db.DB, creditNoteRepo, invoiceRepo)
+	recurringService := services.NewRecurringScheduleService(db.DB, recurringRepo, invoiceRepo)
+	numberSequenceService := services.NewNumberSequenceService(db.DB, numberSequenceRepo)
+	batchService := services.NewInvoiceBatchService(db.DB, batchJobRepo, invoiceService)
+	customerService := services.NewCustomerService(customerRepo)
+	reportService := services.NewReportService(reportRepo)
+	paymentService := services.NewPaymentService(db.DB, paymentRepo, invoiceEvents)
+	paymentLinkService := services.NewPaymentLinkService(db.DB, paymentLinkRepo, invoiceRepo)
+	checkoutService := services.NewCheckoutService(db.DB, checkoutRepo, invoiceService)
+	paymentRequestService := services.NewPaymentRequestService(invoiceService, solana.NewTransactionBuilder())
+	
+	// Payments missed while the watcher was down can be backfilled on demand
+	var rescanService *services.RescanService
+	if solanaWatcher != nil {
+		rescanService = services.NewRescanService(invoiceRepo, solanaWatcher)
+	}
 
 	// Initialize handlers
 	invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
-	draftInvoiceHandler := handlers.NewDraftInvoiceHandler()
+	publicInvoiceHandler := handlers.NewPublicInvoiceHandler(invoiceService)
+	draftInvoiceHandler := handlers.NewDraftInvoiceHandler(draftInvoiceService)
+	creditNoteHandler := handlers.NewCreditNoteHandler(creditNoteService)
+	recurringHandler := handlers.NewRecurringScheduleHandler(recurringService)
+	numberSequenceHandler := handlers.NewNumberSequenceHandler(numberSequenceService)
+	batchHandler := handlers.NewInvoiceBatchHandler(batchService)
+	customerHandler := handlers.NewCustomerHandler(customerService, invoiceService)
+	reportHandler := handlers.NewReportHandler(reportService)
+	paymentHandler := handlers.NewPaymentHandler(paymentService)
+	paymentLinkHandler := handlers.NewPaymentLinkHandler(paymentLinkService)
+	checkoutLinkHandler := handlers.NewCheckoutLinkHandler(checkoutService)
+	publicCheckoutHandler := handlers.NewPublicCheckoutHandler(checkoutService)
+	paymentRequestHandler := handlers.NewPaymentRequestHandler(paymentRequestService)
+	rescanHandler := handlers.NewRescanHandler(rescanService)
 
 	// Initialize router
 	r := chi.NewRouter()
 
-	// Initialize Solana payment watcher
-	solanaWatcher, err := solana.NewPaymentWatcher()
-	if err != nil {
-		log.Printf("Warning: Failed to initialize Solana payment watcher: %v", err)
-		log.Println("Automatic payment detection will not work")
-	} else {
-		// Start watching for payments in a separate goroutine
-		go solanaWatcher.WatchForPayments()
-		
-		// Ensure payment watcher is stopped on shutdown
-		defer solanaWatcher.Stop()
-	}
+	// Only one replica watches the chain, materializes recurring invoices,
+	// cancels abandoned checkouts and fails interrupted batch jobs; another
+	// takes over when the leader dies
+	recurringScheduler := services.NewRecurringScheduler(db.DB, invoiceService)
+	backgroundLeader := leader.NewElector(sqlDB, "fluida:background-workers", func(ctx context.Context) {
+		var wg sync.WaitGroup
+		if solanaWatcher != nil {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				solanaWatcher.Run(ctx)
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkoutService.Run(ctx)
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			batchService.Run(ctx)
+		}()
+		recurringScheduler.Run(ctx)
+		wg.Wait()
+	})
+	backgroundLeader.Start()
+	defer backgroundLeader.Stop()
+	
+	// Pick up batch jobs queued before the last shutdown
+	batchService.ResumeBatchJobs()
 
 	// Middleware
 	r.Use(chimiddleware.RequestID)
 	r.Use(middleware.RequestIDMiddleware)
 	
 	// Configure CORS - MUST be before BasicAuth for OPTIONS preflight requests
-	r.Use(cors.Handler(cors.Options{
+	restrictedCORS := cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"https://demo-fluida-production.up.railway.app", "http://localhost:3000"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", middleware.IdempotencyKeyHeader},
+		ExposedHeaders:   []string{"Link", middleware.IdempotentReplayHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
-	}))
+	})
+	// Public payer endpoints, such as Solana Pay, are called from any origin
+	publicCORS := cors.AllowAll().Handler
+	r.Use(func(next http.Handler) http.Handler {
+		restricted, public := restrictedCORS(next), publicCORS(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if middleware.IsPublicPath(r.URL.Path) {
+				public.ServeHTTP(w, r)
+				return
+			}
+			restricted.ServeHTTP(w, r)
+		})
+	})
 	
 	// Add Basic Authentication
 	r.Use(middleware.BasicAuth)
 	
 	r.Use(middleware.ErrorHandler)
 	r.Use(chimiddleware.Recoverer)
-	r.Use(chimiddleware.Timeout(30 * time.Second))
+	// Exports, rescans and event streams run for as long as they need and are exempt from the request timeout
+	r.Use(middleware.Timeout(30*time.Second, "/export", "/rescan", "/events"))
 	
 	// Configure rate limiter based on environment
 	var rateLimit int
@@ -100,14 +202,38 @@ func main() {
 		rateLimit = 60 // 60 requests per minute per IP in development
 	}
 	
+	// Public payer endpoints get their own, stricter budget, so anyone holding
+	// a payment link cannot use up the issuer's
+	publicRateLimit := 30
+	if limit, err := strconv.Atoi(os.Getenv("PUBLIC_RATE_LIMIT")); err == nil && limit > 0 {
+		publicRateLimit = limit
+	}
+	
 	// Add custom rate limiter
-	log.Printf("Configuring rate limiter with %d requests per minute per IP", rateLimit)
+	log.Printf("Configuring rate limiter with %d requests per minute per IP, %d on public endpoints", rateLimit, publicRateLimit)
 	rateLimiter := middleware.NewSimpleRateLimiter(rateLimit)
-	r.Use(rateLimiter.RateLimit)
+	publicRateLimiter := middleware.NewSimpleRateLimiter(publicRateLimit)
+	r.Use(func(next http.Handler) http.Handler {
+		limited, publicLimited := rateLimiter.RateLimit(next), publicRateLimiter.RateLimit(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if middleware.IsPublicPath(r.URL.Path) {
+				publicLimited.ServeHTTP(w, r)
+				return
+			}
+			limited.ServeHTTP(w, r)
+		})
+	})
 	
 	// Add our custom security middleware
 	r.Use(middleware.SecurityHeaders)
-	r.Use(middleware.ValidateContentType)
+	r.Use(middleware.ValidateContentType("/invoices/batch"))
+
+	// Idempotency-Key support for invoice creation
+	idempotencyTTL := 24 * time.Hour
+	if ttl, err := time.ParseDuration(os.Getenv("IDEMPOTENCY_KEY_TTL")); err == nil && ttl > 0 {
+		idempotencyTTL = ttl
+	}
+	idempotency := middleware.Idempotency(repository.NewIdempotencyRepository(db.DB), idempotencyTTL)
 
 	// Routes
 	r.Get("/", func(w http.ResponseWriter, r *http.Request) {
@@ -142,14 +268,26 @@ func main() {
 			return
 		}
 		
+		// Payment detection degrades, but the API keeps working, without RPC
+		rpcPool := solana.DefaultRPCPool()
+		rpcStatus := "connected"
+		if !rpcPool.Healthy() {
+			rpcStatus = "unavailable"
+		}
+		
 		// Add more detailed health check information
 		healthResponse := map[string]interface{}{
 			"status": "OK",
 			"version": "1.0.0",
 			"dependencies": map[string]string{
 				"database": "connected",
+				"solanaRpc": rpcStatus,
 			},
+			// Circuit state, latency and error rate of each Solana RPC endpoint
+			"rpc": rpcPool.Health(),
 			"timestamp": time.Now().Format(time.RFC3339),
+			// Whether this replica runs the payment watcher and schedulers
+			"leader": backgroundLeader.IsLeader(),
 		}
 		
 		response.JSON(w, http.StatusOK, healthResponse)
@@ -186,13 +324,54 @@ func main() {
 				})
 			})
 			
-			r.Mount("/invoices", invoiceHandler.Routes())
+			r.With(idempotency).Mount("/invoices", invoiceHandler.Routes())
+			
+			// Bulk invoice creation from JSON or CSV
+			r.With(idempotency).Mount("/invoices/batch", batchHandler.Routes())
+			
+			// Payment links of an invoice: extra channels, regeneration and revocation
+			r.Mount("/invoices/{id}/links", paymentLinkHandler.Routes())
 			
 			// Register draft invoice routes
 			r.Mount("/invoices/drafts", draftInvoiceHandler.Routes())
 			
-			// Mount invoice number check endpoint outside of drafts
-			r.Get("/invoices/check", draftInvoiceHandler.CheckInvoiceNumberExists)
+			// Credit notes and refunds for paid invoices
+			r.Mount("/credit-notes", creditNoteHandler.Routes())
+			
+			// Recurring invoice schedules
+			r.Mount("/recurring-schedules", recurringHandler.Routes())
+			
+			// Server-side invoice number sequences
+			r.Mount("/number-sequences", numberSequenceHandler.Routes())
+			
+			// Customer directory with reusable payer profiles
+			r.Mount("/customers", customerHandler.Routes())
+			
+			// Receivables aging and revenue analytics
+			r.Mount("/reports", reportHandler.Routes())
+			
+			// Reusable checkout links and the sessions started on them
+			r.Mount("/checkout-links", checkoutLinkHandler.Routes())
+			
+			// Inbound payments seen on-chain and the queue of unmatched ones to allocate
+			r.Mount("/payments", paymentHandler.Routes())
+			
+			// Payment watcher metrics, such as queue lag, and other expvar metrics
+			r.Handle("/admin/metrics", expvar.Handler())
+			
+			// Backfill of payments missed while the payment watcher was down
+			if rescanService != nil {
+				r.Mount("/admin/rescan", rescanHandler.Routes())
+			}
+			
+			// Solana Pay transaction requests; public, see middleware.PublicPathPrefixes
+			r.Mount("/pay", paymentRequestHandler.Routes())
+			
+			// The payment page's reduced invoice view, quotes and event stream; public
+			r.Mount("/public/invoices", publicInvoiceHandler.Routes())
+			
+			// The checkout page of a checkout link, which starts sessions; public
+			r.Mount("/public/checkout", publicCheckoutHandler.Routes())
 		})
 		
 		// Redirect legacy API calls to the versioned API
@@ -209,6 +388,10 @@ func main() {
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	
+	// Event streams never finish on their own; ending them lets shutdown
+	// complete and their clients reconnect to another replica
+	server.RegisterOnShutdown(invoiceEvents.Stop)
 
 	// Start server in a goroutine
 	serverCtx, serverStopCtx := context.WithCancel(context.Background())
@@ -8,7 +8,7 @@ import (
 	"strings"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/ncapetillo/demo-fluida/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -19,6 +19,9 @@ import (
 var (
 	DB  *gorm.DB
 	SQL *sql.DB
+	
+	// connString is kept to open listener connections to the same database
+	connString string
 )
 
 // Config represents database configuration
@@ -219,6 +222,7 @@ func Connect() (*sql.DB, error) {
 	// Set global DB variable
 	DB = gormDB
 	SQL = sqlDB
+	connString = dsn
 
 	// Auto-migrate the schema
 	if err := migrateSchema(); err != nil {
@@ -229,6 +233,13 @@ func Connect() (*sql.DB, error) {
 	return sqlDB, nil
 }
 
+// NewListener opens a dedicated connection to the database Connect connected
+// to for LISTEN/NOTIFY. It reconnects on its own, reporting connection events
+// to eventCallback, which may be nil.
+func NewListener(eventCallback pq.EventCallbackType) *pq.Listener {
+	return pq.NewListener(connString, 10*time.Second, time.Minute, eventCallback)
+}
+
 // migrateSchema automatically creates or updates the database tables
 func migrateSchema() error {
 	// Create custom enum type for invoice status if it doesn't exist
@@ -245,7 +256,24 @@ func migrateSchema() error {
 	DB.Exec("CREATE EXTENSION IF NOT EXISTS pgcrypto;")
 	
 	// Run auto migrations for all models
-	if err := DB.AutoMigrate(&models.Invoice{}, &models.DraftInvoice{}); err != nil {
+	if err := DB.AutoMigrate(
+		&models.Invoice{},
+		&models.DraftInvoice{},
+		&models.CreditNote{},
+		&models.RecurringSchedule{},
+		&models.RecurringInvoiceRun{},
+		&models.NumberSequence{},
+		&models.NumberSequenceCounter{},
+		&models.IdempotencyKey{},
+		&models.InvoiceBatchJob{},
+		&models.Customer{},
+		&models.Payment{},
+		&models.PaymentAllocation{},
+		&models.PaymentAuditEntry{},
+		&models.PaymentLink{},
+		&models.CheckoutLink{},
+		&models.CheckoutSession{},
+	); err != nil {
 		return fmt.Errorf("failed to migrate schema: %v", err)
 	}
 	
@@ -254,6 +282,46 @@ func migrateSchema() error {
 	DB.Exec("CREATE INDEX IF NOT EXISTS idx_invoices_link_token ON invoice(link_token);")
 	DB.Exec("CREATE INDEX IF NOT EXISTS idx_draft_invoice_user_id ON draft_invoice(user_id);")
 	
+	// Keyset pagination walks invoices by (created_at, id)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_invoice_created_at_id ON invoice(created_at, id);")
+	
+	// Full-text search over invoice number, description and sender/recipient details.
+	// The 'simple' configuration keeps names and emails unstemmed.
+	DB.Exec(`ALTER TABLE invoice ADD COLUMN IF NOT EXISTS search_vector tsvector
+		GENERATED ALWAYS AS (to_tsvector('simple'::regconfig,
+			coalesce(invoice_number, '') || ' ' ||
+			coalesce(description, '') || ' ' ||
+			coalesce(sender_details->>'name', '') || ' ' ||
+			coalesce(sender_details->>'email', '') || ' ' ||
+			coalesce(recipient_details->>'name', '') || ' ' ||
+			coalesce(recipient_details->>'email', ''))) STORED;`)
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_invoice_search ON invoice USING GIN (search_vector);")
+	
+	// Invoices created before settlement quotes are paid in their own token at face value
+	DB.Exec(`UPDATE invoice SET settlement_token = currency, settlement_amount = amount, exchange_rate = 1
+		WHERE (settlement_token IS NULL OR settlement_token = '') AND currency IN ('USDC', 'EURC', 'SOL');`)
+	
+	// Reports aggregate pending invoices by due date and paid invoices by payment date
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_invoice_status_due_date ON invoice(status, due_date);")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_invoice_status_paid_at ON invoice(status, paid_at);")
+	
+	// Invoices created before payment links were tracked keep their link token as their default link
+	DB.Exec(`INSERT INTO payment_link (invoice_id, token, channel, created_at)
+		SELECT id, link_token, ?, created_at FROM invoice
+		ON CONFLICT (token) DO NOTHING;`, models.DefaultLinkChannel)
+	
+	// Seed the sequence used when an invoice is created without a number
+	DB.Exec(`INSERT INTO number_sequence (name, pattern, description, created_at, updated_at)
+		VALUES (?, ?, 'Default invoice numbering', NOW(), NOW())
+		ON CONFLICT (name) DO NOTHING;`,
+		models.DefaultNumberSequence, getEnvOrDefault("INVOICE_NUMBER_PATTERN", "INV-{YYYY}-{SEQ:5}"))
+	
+	// Credit notes are numbered in a series of their own
+	DB.Exec(`INSERT INTO number_sequence (name, pattern, description, created_at, updated_at)
+		VALUES (?, ?, 'Credit note numbering', NOW(), NOW())
+		ON CONFLICT (name) DO NOTHING;`,
+		models.CreditNoteNumberSequence, getEnvOrDefault("CREDIT_NOTE_NUMBER_PATTERN", models.DefaultCreditNotePattern))
+	
 	log.Println("Database schema migrated successfully")
 	return nil
 } 
\ No newline at end of file
@@ -0,0 +1,239 @@
+// Package events delivers invoice status events to the clients watching an
+// invoice, such as the payment page, whichever API replica they are connected
+// to, using PostgreSQL LISTEN/NOTIFY
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/ncapetillo/demo-fluida/internal/db"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+const (
+	// The PostgreSQL notification channel events are sent on
+	channel = "invoice_events"
+
+	// Events a subscriber has not taken yet before newer ones are dropped
+	subscriberBuffer = 16
+
+	// How long publishing waits for the database before delivering locally
+	notifyTimeout = 3 * time.Second
+
+	// How often the listener connection is checked while no notifications arrive
+	pingInterval = 90 * time.Second
+)
+
+// Broker is an in-process pub/sub of invoice events keyed by invoice ID. Once
+// started, published events are sent through PostgreSQL NOTIFY and every
+// replica, this one included, delivers them to its own subscribers as they
+// come back through LISTEN. Delivery is best effort: a subscriber that falls
+// behind misses events rather than holding up the publisher, and events sent
+// while a listener reconnects are lost, so clients should treat events as
+// hints and read the invoice for its state.
+type Broker struct {
+	db       *sql.DB
+	listener *pq.Listener
+	done     chan struct{}
+
+	stopOnce sync.Once
+
+	mu          sync.Mutex
+	listening   bool
+	stopped     bool
+	subscribers map[int]map[chan models.InvoiceEvent]struct{}
+}
+
+// notification is the NOTIFY payload. The invoice ID routes the event but is
+// not part of what clients see.
+type notification struct {
+	InvoiceID int                 `json:"invoiceId"`
+	Event     models.InvoiceEvent `json:"event"`
+}
+
+// NewBroker creates a broker that fans events out across replicas through
+// sqlDB once started. With a nil sqlDB events stay in this process.
+func NewBroker(sqlDB *sql.DB) *Broker {
+	return &Broker{
+		db:          sqlDB,
+		done:        make(chan struct{}),
+		subscribers: make(map[int]map[chan models.InvoiceEvent]struct{}),
+	}
+}
+
+// Start listens for the events published by every replica. Until it is
+// called, and when it fails, events are only delivered in this process.
+func (b *Broker) Start() error {
+	if b.db == nil {
+		return nil
+	}
+
+	listener := db.NewListener(func(event pq.ListenerEventType, err error) {
+		switch event {
+		case pq.ListenerEventDisconnected:
+			log.Printf("Invoice event listener disconnected: %v", err)
+		case pq.ListenerEventReconnected:
+			log.Println("Invoice event listener reconnected; events sent meanwhile were missed")
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Printf("Invoice event listener failed to reconnect: %v", err)
+		}
+	})
+	if err := listener.Listen(channel); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to listen for invoice events: %w", err)
+	}
+
+	b.listener = listener
+	b.setListening(true)
+	go b.listen()
+
+	log.Printf("Listening for invoice events on %s", channel)
+	return nil
+}
+
+// Stop stops listening and closes the channels of all subscribers, so
+// streams to clients end and they reconnect, to another replica when this one
+// is shutting down. Later subscriptions are closed right away.
+func (b *Broker) Stop() {
+	b.stopOnce.Do(func() {
+		b.setListening(false)
+		if b.listener != nil {
+			b.listener.Close()
+			<-b.done
+		}
+
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.stopped = true
+		for invoiceID, subscribers := range b.subscribers {
+			for ch := range subscribers {
+				close(ch)
+			}
+			delete(b.subscribers, invoiceID)
+		}
+	})
+}
+
+// Publish delivers the event to the subscribers of its invoice on every replica
+func (b *Broker) Publish(event models.InvoiceEvent) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+
+	if b.isListening() {
+		err := b.notify(event)
+		if err == nil {
+			return
+		}
+		log.Printf("Failed to send %s event of invoice %d to other replicas: %v", event.Type, event.InvoiceID, err)
+	}
+
+	b.deliver(event)
+}
+
+// Subscribe returns the events of the invoice and a function that stops them;
+// it must be called once the caller is done. The channel is closed when the
+// broker stops.
+func (b *Broker) Subscribe(invoiceID int) (<-chan models.InvoiceEvent, func()) {
+	ch := make(chan models.InvoiceEvent, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.stopped {
+		b.mu.Unlock()
+		close(ch)
+		return ch, func() {}
+	}
+	if b.subscribers[invoiceID] == nil {
+		b.subscribers[invoiceID] = make(map[chan models.InvoiceEvent]struct{})
+	}
+	b.subscribers[invoiceID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			delete(b.subscribers[invoiceID], ch)
+			if len(b.subscribers[invoiceID]) == 0 {
+				delete(b.subscribers, invoiceID)
+			}
+		})
+	}
+}
+
+// notify sends the event to the listeners of every replica
+func (b *Broker) notify(event models.InvoiceEvent) error {
+	payload, err := json.Marshal(notification{InvoiceID: event.InvoiceID, Event: event})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	_, err = b.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, string(payload))
+	return err
+}
+
+// listen delivers the notifications of the listener until it is closed
+func (b *Broker) listen() {
+	defer close(b.done)
+
+	for {
+		select {
+		case n, ok := <-b.listener.Notify:
+			if !ok {
+				return
+			}
+			// A nil notification follows a reconnect
+			if n == nil {
+				continue
+			}
+
+			var payload notification
+			if err := json.Unmarshal([]byte(n.Extra), &payload); err != nil {
+				log.Printf("Ignoring malformed invoice event: %v", err)
+				continue
+			}
+			payload.Event.InvoiceID = payload.InvoiceID
+			b.deliver(payload.Event)
+		case <-time.After(pingInterval):
+			go b.listener.Ping()
+		}
+	}
+}
+
+// deliver hands the event to the subscribers of its invoice in this process,
+// skipping those whose buffer is full
+func (b *Broker) deliver(event models.InvoiceEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subscribers[event.InvoiceID] {
+		select {
+		case ch <- event:
+		default:
+			log.Printf("Dropped %s event of invoice %d for a slow subscriber", event.Type, event.InvoiceID)
+		}
+	}
+}
+
+func (b *Broker) isListening() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.listening
+}
+
+func (b *Broker) setListening(listening bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.listening = listening
+}
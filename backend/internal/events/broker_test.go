@@ -0,0 +1,90 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+func TestBrokerDeliversToSubscribersOfTheInvoice(t *testing.T) {
+	broker := NewBroker(nil)
+
+	first, stopFirst := broker.Subscribe(1)
+	defer stopFirst()
+	second, stopSecond := broker.Subscribe(1)
+	defer stopSecond()
+	other, stopOther := broker.Subscribe(2)
+	defer stopOther()
+
+	broker.Publish(models.InvoiceEvent{Type: models.InvoiceEventPaid, InvoiceID: 1, Status: models.StatusPaid})
+
+	for _, ch := range []<-chan models.InvoiceEvent{first, second} {
+		select {
+		case event := <-ch:
+			if event.Type != models.InvoiceEventPaid || event.At.IsZero() {
+				t.Errorf("Unexpected event: %+v", event)
+			}
+		default:
+			t.Error("Expected every subscriber of the invoice to receive the event")
+		}
+	}
+
+	select {
+	case event := <-other:
+		t.Errorf("Expected no event for another invoice, got %+v", event)
+	default:
+	}
+}
+
+func TestBrokerStopsDeliveringAfterUnsubscribe(t *testing.T) {
+	broker := NewBroker(nil)
+
+	ch, stop := broker.Subscribe(1)
+	stop()
+	stop()
+
+	broker.Publish(models.InvoiceEvent{Type: models.InvoiceEventDetected, InvoiceID: 1})
+
+	select {
+	case event := <-ch:
+		t.Errorf("Expected no event after unsubscribing, got %+v", event)
+	default:
+	}
+	if len(broker.subscribers) != 0 {
+		t.Errorf("Expected the invoice's subscriber set to be removed, got %d", len(broker.subscribers))
+	}
+}
+
+func TestBrokerDropsEventsForSlowSubscribers(t *testing.T) {
+	broker := NewBroker(nil)
+
+	ch, stop := broker.Subscribe(1)
+	defer stop()
+
+	// Publishing never blocks on a subscriber that stopped reading
+	for i := 0; i < subscriberBuffer+5; i++ {
+		broker.Publish(models.InvoiceEvent{Type: models.InvoiceEventConfirming, InvoiceID: 1})
+	}
+
+	if len(ch) != subscriberBuffer {
+		t.Errorf("Expected %d buffered events, got %d", subscriberBuffer, len(ch))
+	}
+}
+
+func TestBrokerStopClosesSubscriptions(t *testing.T) {
+	broker := NewBroker(nil)
+
+	ch, unsubscribe := broker.Subscribe(1)
+	broker.Stop()
+	broker.Stop()
+	unsubscribe()
+
+	if _, ok := <-ch; ok {
+		t.Error("Expected the subscription to be closed when the broker stops")
+	}
+
+	late, _ := broker.Subscribe(2)
+	if _, ok := <-late; ok {
+		t.Error("Expected subscriptions after stopping to be closed")
+	}
+}
@@ -0,0 +1,273 @@
+// Package export writes invoices in formats used for bookkeeping
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// Format identifies an export layout
+type Format string
+
+const (
+	FormatCSV        Format = "csv"
+	FormatNDJSON     Format = "ndjson"
+	FormatQuickBooks Format = "quickbooks"
+	FormatXero       Format = "xero"
+)
+
+// Formats lists the supported export formats
+var Formats = []Format{FormatCSV, FormatNDJSON, FormatQuickBooks, FormatXero}
+
+// InvoiceWriter writes invoices one at a time to an underlying stream
+type InvoiceWriter interface {
+	// WriteInvoice writes one invoice; the header, if any, is written before the first one
+	WriteInvoice(invoice models.Invoice) error
+	// Flush writes any buffered data, including the header of an empty export
+	Flush() error
+}
+
+// ParseFormat returns the format with the given name
+func ParseFormat(name string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == name {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported export format %q", name)
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	if f == FormatNDJSON {
+		return "application/x-ndjson"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// FileExtension returns the file extension used for downloads of the format
+func (f Format) FileExtension() string {
+	if f == FormatNDJSON {
+		return "ndjson"
+	}
+	return "csv"
+}
+
+// NewInvoiceWriter creates a writer for the format. The Xero layout reads its
+// account code and tax type from EXPORT_XERO_ACCOUNT_CODE (default "200") and
+// EXPORT_XERO_TAX_TYPE (default "Tax Exempt").
+func NewInvoiceWriter(format Format, w io.Writer) (InvoiceWriter, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w, csvHeader, csvRecord), nil
+	case FormatNDJSON:
+		return &ndjsonWriter{encoder: json.NewEncoder(w)}, nil
+	case FormatQuickBooks:
+		return newCSVWriter(w, quickBooksHeader, quickBooksRecord), nil
+	case FormatXero:
+		accountCode := getEnv("EXPORT_XERO_ACCOUNT_CODE", "200")
+		taxType := getEnv("EXPORT_XERO_TAX_TYPE", "Tax Exempt")
+		return newCSVWriter(w, xeroHeader, func(inv models.Invoice) []string {
+			return xeroRecord(inv, accountCode, taxType)
+		}), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// csvWriter writes a header line followed by one record per invoice
+type csvWriter struct {
+	writer        *csv.Writer
+	header        []string
+	record        func(models.Invoice) []string
+	headerWritten bool
+}
+
+func newCSVWriter(w io.Writer, header []string, record func(models.Invoice) []string) *csvWriter {
+	return &csvWriter{writer: csv.NewWriter(w), header: header, record: record}
+}
+
+func (c *csvWriter) writeHeader() error {
+	if c.headerWritten {
+		return nil
+	}
+	c.headerWritten = true
+	return c.writer.Write(c.header)
+}
+
+func (c *csvWriter) WriteInvoice(invoice models.Invoice) error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	record := c.record(invoice)
+	for i, cell := range record {
+		record[i] = escapeFormula(cell)
+	}
+	return c.writer.Write(record)
+}
+
+func (c *csvWriter) Flush() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// ndjsonWriter writes one JSON object per line
+type ndjsonWriter struct {
+	encoder *json.Encoder
+}
+
+func (n *ndjsonWriter) WriteInvoice(invoice models.Invoice) error {
+	return n.encoder.Encode(invoice)
+}
+
+func (n *ndjsonWriter) Flush() error {
+	return nil
+}
+
+var csvHeader = []string{
+	"invoice_number", "status", "amount", "currency", "description",
+	"settlement_token", "settlement_amount", "exchange_rate",
+	"created_at", "due_date", "paid_at", "payment_tx_signature", "receiver_addr",
+	"sender_name", "sender_email", "sender_address",
+	"recipient_name", "recipient_email", "recipient_address", "link_token",
+}
+
+func csvRecord(inv models.Invoice) []string {
+	return []string{
+		inv.InvoiceNumber,
+		string(inv.Status),
+		formatAmount(inv.Amount, inv.Currency),
+		inv.Currency,
+		inv.Description,
+		inv.SettlementToken,
+		strconv.FormatFloat(inv.SettlementAmount, 'f', -1, 64),
+		formatRate(inv.ExchangeRate),
+		inv.CreatedAt.UTC().Format(time.RFC3339),
+		inv.DueDate.UTC().Format(time.RFC3339),
+		formatOptionalTime(inv.PaidAt, time.RFC3339),
+		inv.PaymentTxSignature,
+		inv.ReceiverAddr,
+		inv.SenderDetails.Name,
+		inv.SenderDetails.Email,
+		inv.SenderDetails.Address,
+		inv.RecipientDetails.Name,
+		inv.RecipientDetails.Email,
+		inv.RecipientDetails.Address,
+		inv.LinkToken,
+	}
+}
+
+// quickBooksHeader follows the QuickBooks Online invoice import layout;
+// starred columns are mandatory there
+var quickBooksHeader = []string{
+	"*InvoiceNo", "*Customer", "*InvoiceDate", "*DueDate", "Terms", "Memo",
+	"Item(Product/Service)", "ItemDescription", "ItemQuantity", "ItemRate", "*ItemAmount", "Currency",
+}
+
+func quickBooksRecord(inv models.Invoice) []string {
+	amount := formatAmount(inv.Amount, inv.Currency)
+	return []string{
+		inv.InvoiceNumber,
+		inv.RecipientDetails.Name,
+		inv.CreatedAt.UTC().Format("01/02/2006"),
+		inv.DueDate.UTC().Format("01/02/2006"),
+		"",
+		paymentReference(inv),
+		"Services",
+		inv.Description,
+		"1",
+		amount,
+		amount,
+		inv.Currency,
+	}
+}
+
+// xeroHeader follows the Xero sales invoice import template; starred columns are mandatory there
+var xeroHeader = []string{
+	"*ContactName", "EmailAddress", "POAddressLine1", "*InvoiceNumber", "Reference",
+	"*InvoiceDate", "*DueDate", "*Description", "*Quantity", "*UnitAmount",
+	"*AccountCode", "*TaxType", "Currency",
+}
+
+func xeroRecord(inv models.Invoice, accountCode, taxType string) []string {
+	description := inv.Description
+	if description == "" {
+		description = "Invoice " + inv.InvoiceNumber
+	}
+
+	return []string{
+		inv.RecipientDetails.Name,
+		inv.RecipientDetails.Email,
+		inv.RecipientDetails.Address,
+		inv.InvoiceNumber,
+		inv.PaymentTxSignature,
+		inv.CreatedAt.UTC().Format("02/01/2006"),
+		inv.DueDate.UTC().Format("02/01/2006"),
+		description,
+		"1",
+		formatAmount(inv.Amount, inv.Currency),
+		accountCode,
+		taxType,
+		inv.Currency,
+	}
+}
+
+// paymentReference describes the on-chain payment of a paid invoice
+func paymentReference(inv models.Invoice) string {
+	if inv.PaymentTxSignature == "" {
+		return ""
+	}
+	return "Paid via Solana transaction " + inv.PaymentTxSignature
+}
+
+// escapeFormula stops spreadsheet applications from evaluating user-provided
+// text such as a description starting with "=" as a formula
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsRune("=+-@\t\r", rune(cell[0])) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// formatAmount writes amounts with two decimals, or with the quote precision
+// of tokens that need more, such as SOL
+func formatAmount(amount float64, currency string) string {
+	places := 2
+	if token, ok := models.SettlementTokens[currency]; ok && token.QuotePlaces > places {
+		places = token.QuotePlaces
+	}
+	return strconv.FormatFloat(amount, 'f', places, 64)
+}
+
+func formatRate(rate float64) string {
+	if rate == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+func formatOptionalTime(t *time.Time, layout string) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(layout)
+}
+
+func getEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
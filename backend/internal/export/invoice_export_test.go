@@ -0,0 +1,103 @@
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+func sampleInvoice() models.Invoice {
+	paidAt := time.Date(2026, 9, 15, 10, 0, 0, 0, time.UTC)
+	return models.Invoice{
+		InvoiceNumber:      "INV-2026-00001",
+		Amount:             1250.5,
+		Currency:           "USDC",
+		Description:        "=HYPERLINK(\"http://evil\")",
+		DueDate:            time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC),
+		Status:             models.StatusPaid,
+		PaymentTxSignature: "5sig",
+		PaidAt:             &paidAt,
+		RecipientDetails:   models.Person{Name: "Bob", Email: "bob@example.test"},
+		CreatedAt:          time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestCSVFormats(t *testing.T) {
+	tests := []struct {
+		format Format
+		column string
+		want   string
+	}{
+		{FormatCSV, "payment_tx_signature", "5sig"},
+		{FormatCSV, "amount", "1250.50"},
+		{FormatCSV, "description", "'=HYPERLINK(\"http://evil\")"},
+		{FormatQuickBooks, "Memo", "Paid via Solana transaction 5sig"},
+		{FormatQuickBooks, "*InvoiceDate", "09/01/2026"},
+		{FormatXero, "Reference", "5sig"},
+		{FormatXero, "*DueDate", "30/09/2026"},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format)+"/"+tt.column, func(t *testing.T) {
+			var buf bytes.Buffer
+			w, err := NewInvoiceWriter(tt.format, &buf)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := w.WriteInvoice(sampleInvoice()); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := w.Flush(); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			records, err := csv.NewReader(&buf).ReadAll()
+			if err != nil {
+				t.Fatalf("Output is not valid CSV: %v", err)
+			}
+			if len(records) != 2 {
+				t.Fatalf("Expected header and one record, got %d lines", len(records))
+			}
+
+			for i, name := range records[0] {
+				if name == tt.column {
+					if records[1][i] != tt.want {
+						t.Errorf("Expected %s to be %q, got %q", tt.column, tt.want, records[1][i])
+					}
+					return
+				}
+			}
+			t.Errorf("Column %s not found in %v", tt.column, records[0])
+		})
+	}
+}
+
+func TestEmptyExportHasHeader(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewInvoiceWriter(FormatCSV, &buf)
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), "invoice_number,") {
+		t.Errorf("Expected a header line, got %q", buf.String())
+	}
+}
+
+func TestNDJSON(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewInvoiceWriter(FormatNDJSON, &buf)
+	w.WriteInvoice(sampleInvoice())
+	w.WriteInvoice(sampleInvoice())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+	if !strings.Contains(lines[0], `"paymentTxSignature":"5sig"`) {
+		t.Errorf("Expected the payment reference in %s", lines[0])
+	}
+}
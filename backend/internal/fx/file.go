@@ -0,0 +1,103 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileProvider reads rates from a JSON file and reloads it whenever it changes,
+// so rates can be updated without a restart. The file looks like
+//
+//	{"asOf": "2026-10-01T00:00:00Z", "rates": {"EUR/USD": 1.08, "USD/MXN": 17.2}}
+//
+// where asOf is optional and defaults to the file's modification time.
+type FileProvider struct {
+	path string
+
+	mu       sync.Mutex
+	modTime  time.Time
+	rates    map[string]float64
+	asOf     time.Time
+	hasTable bool
+}
+
+// NewFileProvider creates a provider for the rate file at path. The file is
+// read on first use.
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+// Rate implements RateProvider
+func (p *FileProvider) Rate(ctx context.Context, base, quote string) (Rate, error) {
+	rates, asOf, err := p.load()
+	if err != nil {
+		return Rate{}, err
+	}
+
+	value, ok := lookup(rates, base, quote)
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s/%s", ErrRateUnavailable, base, quote)
+	}
+
+	return Rate{Base: base, Quote: quote, Value: value, AsOf: asOf, Source: "file"}, nil
+}
+
+// load returns the current rate table, rereading the file if it was modified.
+// If a reload fails the previous table keeps being served.
+func (p *FileProvider) load() (map[string]float64, time.Time, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return p.current(fmt.Errorf("failed to read rates file: %w", err))
+	}
+	if p.hasTable && info.ModTime().Equal(p.modTime) {
+		return p.rates, p.asOf, nil
+	}
+
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return p.current(fmt.Errorf("failed to read rates file: %w", err))
+	}
+
+	var file struct {
+		AsOf  *time.Time         `json:"asOf"`
+		Rates map[string]float64 `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return p.current(fmt.Errorf("invalid rates file %s: %w", p.path, err))
+	}
+
+	rates := make(map[string]float64, len(file.Rates))
+	for pair, rate := range file.Rates {
+		if rate <= 0 {
+			return p.current(fmt.Errorf("invalid rates file %s: rate for %s must be positive", p.path, pair))
+		}
+		rates[normalizePair(pair)] = rate
+	}
+
+	p.rates = rates
+	p.modTime = info.ModTime()
+	p.asOf = info.ModTime()
+	if file.AsOf != nil {
+		p.asOf = *file.AsOf
+	}
+	p.hasTable = true
+
+	return p.rates, p.asOf, nil
+}
+
+// current returns the last good table, or err if there is none
+func (p *FileProvider) current(err error) (map[string]float64, time.Time, error) {
+	if p.hasTable {
+		log.Printf("Serving previous exchange rates: %v", err)
+		return p.rates, p.asOf, nil
+	}
+	return nil, time.Time{}, err
+}
@@ -0,0 +1,118 @@
+// Package fx provides exchange rates used to quote fiat-denominated invoices
+// in the stablecoin they are settled in
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrRateUnavailable is returned when a provider has no rate for a currency pair
+var ErrRateUnavailable = errors.New("exchange rate unavailable")
+
+// Rate is the price of one unit of Base expressed in Quote
+type Rate struct {
+	Base   string    `json:"base"`
+	Quote  string    `json:"quote"`
+	Value  float64   `json:"value"`
+	AsOf   time.Time `json:"asOf"`
+	Source string    `json:"source"`
+}
+
+// RateProvider looks up exchange rates. Implementations must be safe for
+// concurrent use.
+type RateProvider interface {
+	Rate(ctx context.Context, base, quote string) (Rate, error)
+}
+
+// NewProviderFromEnv creates the provider selected by FX_PROVIDER:
+//
+//	static  rates from FX_STATIC_RATES, e.g. "EUR/USD=1.08,USD/MXN=17.2" (default)
+//	file    rates from the JSON file at FX_RATES_FILE, reloaded when it changes
+func NewProviderFromEnv() (RateProvider, error) {
+	switch provider := os.Getenv("FX_PROVIDER"); provider {
+	case "", "static":
+		rates, err := ParseRates(os.Getenv("FX_STATIC_RATES"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid FX_STATIC_RATES: %w", err)
+		}
+		return NewStaticProvider(rates), nil
+	case "file":
+		path := os.Getenv("FX_RATES_FILE")
+		if path == "" {
+			return nil, fmt.Errorf("FX_RATES_FILE is required when FX_PROVIDER=file")
+		}
+		return NewFileProvider(path), nil
+	default:
+		return nil, fmt.Errorf("unknown FX_PROVIDER %q", provider)
+	}
+}
+
+// ParseRates parses a comma-separated list of "BASE/QUOTE=value" pairs
+func ParseRates(value string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		pair, rawRate, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("rate %q must look like EUR/USD=1.08", entry)
+		}
+		base, quote, ok := strings.Cut(strings.TrimSpace(pair), "/")
+		if !ok || base == "" || quote == "" {
+			return nil, fmt.Errorf("currency pair %q must look like EUR/USD", pair)
+		}
+
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rawRate), 64)
+		if err != nil || rate <= 0 {
+			return nil, fmt.Errorf("rate for %s must be a positive number", pair)
+		}
+
+		rates[pairKey(base, quote)] = rate
+	}
+
+	return rates, nil
+}
+
+// lookup finds the rate of a pair in a table, using the inverse pair or a
+// cross rate through USD when the pair itself is not listed
+func lookup(rates map[string]float64, base, quote string) (float64, bool) {
+	base, quote = strings.ToUpper(base), strings.ToUpper(quote)
+	if base == quote {
+		return 1, true
+	}
+
+	if rate, ok := rates[pairKey(base, quote)]; ok {
+		return rate, true
+	}
+	if rate, ok := rates[pairKey(quote, base)]; ok {
+		return 1 / rate, true
+	}
+
+	if base != "USD" && quote != "USD" {
+		toUSD, okBase := lookup(rates, base, "USD")
+		fromUSD, okQuote := lookup(rates, "USD", quote)
+		if okBase && okQuote {
+			return toUSD * fromUSD, true
+		}
+	}
+
+	return 0, false
+}
+
+func pairKey(base, quote string) string {
+	return strings.ToUpper(base) + "/" + strings.ToUpper(quote)
+}
+
+func normalizePair(pair string) string {
+	return strings.ToUpper(strings.ReplaceAll(pair, " ", ""))
+}
@@ -0,0 +1,72 @@
+package fx
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticProviderInverseAndCrossRates(t *testing.T) {
+	rates, err := ParseRates("EUR/USD=1.25, usd/mxn=20")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	provider := NewStaticProvider(rates)
+
+	tests := []struct {
+		base, quote string
+		want        float64
+	}{
+		{"EUR", "USD", 1.25},
+		{"USD", "EUR", 0.8},
+		{"EUR", "MXN", 25},
+		{"MXN", "EUR", 0.04},
+		{"EUR", "EUR", 1},
+	}
+
+	for _, tt := range tests {
+		rate, err := provider.Rate(context.Background(), tt.base, tt.quote)
+		if err != nil {
+			t.Errorf("%s/%s: unexpected error: %v", tt.base, tt.quote, err)
+			continue
+		}
+		if math.Abs(rate.Value-tt.want) > 1e-9 {
+			t.Errorf("%s/%s: expected %v, got %v", tt.base, tt.quote, tt.want, rate.Value)
+		}
+	}
+
+	if _, err := provider.Rate(context.Background(), "GBP", "USD"); !errors.Is(err, ErrRateUnavailable) {
+		t.Errorf("Expected ErrRateUnavailable for an unknown pair, got %v", err)
+	}
+}
+
+func TestParseRatesRejectsMalformedEntries(t *testing.T) {
+	for _, value := range []string{"EUR=1.08", "EUR/USD", "EUR/USD=-1", "EUR/USD=abc"} {
+		if _, err := ParseRates(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+}
+
+func TestFileProviderKeepsLastGoodTable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	if err := os.WriteFile(path, []byte(`{"rates": {"EUR/USD": 1.1}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	provider := NewFileProvider(path)
+	rate, err := provider.Rate(context.Background(), "EUR", "USD")
+	if err != nil || rate.Value != 1.1 {
+		t.Fatalf("Expected 1.1, got %v (%v)", rate.Value, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if rate, err := provider.Rate(context.Background(), "EUR", "USD"); err != nil || rate.Value != 1.1 {
+		t.Errorf("Expected the previous rate after the file disappeared, got %v (%v)", rate.Value, err)
+	}
+}
@@ -0,0 +1,33 @@
+package fx
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// StaticProvider serves a fixed rate table, for local testing and for
+// deployments that set their rates by configuration
+type StaticProvider struct {
+	rates    map[string]float64
+	loadedAt time.Time
+}
+
+// NewStaticProvider creates a provider from "BASE/QUOTE" keyed rates
+func NewStaticProvider(rates map[string]float64) *StaticProvider {
+	normalized := make(map[string]float64, len(rates))
+	for pair, rate := range rates {
+		normalized[normalizePair(pair)] = rate
+	}
+	return &StaticProvider{rates: normalized, loadedAt: time.Now()}
+}
+
+// Rate implements RateProvider
+func (p *StaticProvider) Rate(ctx context.Context, base, quote string) (Rate, error) {
+	value, ok := lookup(p.rates, base, quote)
+	if !ok {
+		return Rate{}, fmt.Errorf("%w: %s/%s", ErrRateUnavailable, base, quote)
+	}
+
+	return Rate{Base: base, Quote: quote, Value: value, AsOf: p.loadedAt, Source: "static"}, nil
+}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// CheckoutLinkHandler handles HTTP requests for managing checkout links
+type CheckoutLinkHandler struct {
+	service *services.CheckoutService
+}
+
+// NewCheckoutLinkHandler creates a new checkout link handler
+func NewCheckoutLinkHandler(service *services.CheckoutService) *CheckoutLinkHandler {
+	return &CheckoutLinkHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all checkout link routes
+func (h *CheckoutLinkHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListLinks)
+	r.Post("/", h.CreateLink)
+	r.Get("/{id}", h.GetLink)
+	r.Put("/{id}", h.UpdateLink)
+	r.Get("/{id}/sessions", h.ListSessions)
+
+	return r
+}
+
+// ListLinks returns checkout links, newest first, with their number of uses
+func (h *CheckoutLinkHandler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	page, limit := parsePagination(r)
+
+	links, total, err := h.service.ListLinks(page, limit)
+	if err != nil {
+		log.Printf("Error listing checkout links: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.New().
+		WithData(links).
+		WithPagination(int(total), page, limit).
+		Send(w, http.StatusOK)
+}
+
+// CreateLink creates a checkout link
+func (h *CheckoutLinkHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCheckoutLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	link, err := h.service.CreateLink(req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, link)
+}
+
+// GetLink retrieves a single checkout link
+func (h *CheckoutLinkHandler) GetLink(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid checkout link ID")
+		return
+	}
+
+	link, err := h.service.GetLink(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, link)
+}
+
+// UpdateLink deactivates, reactivates or changes the limits of a checkout link
+func (h *CheckoutLinkHandler) UpdateLink(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid checkout link ID")
+		return
+	}
+
+	var req models.UpdateCheckoutLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	link, err := h.service.UpdateLink(id, req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, link)
+}
+
+// ListSessions returns the sessions visitors started on a checkout link, newest first
+func (h *CheckoutLinkHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid checkout link ID")
+		return
+	}
+
+	sessions, err := h.service.ListSessions(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, sessions)
+}
+
+// handleError maps checkout service errors to responses
+func (h *CheckoutLinkHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrCheckoutLinkNotFound):
+		response.NotFound(w, "Checkout link not found")
+	default:
+		log.Printf("Error managing checkout link: %v", err)
+		response.InternalServerError(w)
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// CreditNoteHandler handles HTTP requests related to credit notes and refunds
+type CreditNoteHandler struct {
+	service *services.CreditNoteService
+}
+
+// NewCreditNoteHandler creates a new credit note handler
+func NewCreditNoteHandler(service *services.CreditNoteService) *CreditNoteHandler {
+	return &CreditNoteHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all credit note-related routes
+func (h *CreditNoteHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListCreditNotes)
+	r.Post("/", h.CreateCreditNote)
+	r.Get("/{id}", h.GetCreditNote)
+	r.Post("/{id}/refund", h.RecordRefund)
+	r.Post("/{id}/void", h.VoidCreditNote)
+
+	return r
+}
+
+// ListCreditNotes returns credit notes, optionally restricted to one invoice via ?invoiceId=
+func (h *CreditNoteHandler) ListCreditNotes(w http.ResponseWriter, r *http.Request) {
+	if invoiceIDStr := r.URL.Query().Get("invoiceId"); invoiceIDStr != "" {
+		invoiceID, err := strconv.Atoi(invoiceIDStr)
+		if err != nil {
+			response.BadRequest(w, "Invalid invoice ID")
+			return
+		}
+
+		notes, err := h.service.ListCreditNotesForInvoice(invoiceID)
+		if err != nil {
+			log.Printf("Error listing credit notes for invoice %d: %v", invoiceID, err)
+			response.InternalServerError(w)
+			return
+		}
+
+		response.JSON(w, http.StatusOK, notes)
+		return
+	}
+
+	page, limit := parsePagination(r)
+
+	notes, total, err := h.service.ListCreditNotes(page, limit)
+	if err != nil {
+		log.Printf("Error listing credit notes: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.New().
+		WithData(notes).
+		WithPagination(int(total), page, limit).
+		Send(w, http.StatusOK)
+}
+
+// CreateCreditNote issues a new credit note against a paid invoice
+func (h *CreditNoteHandler) CreateCreditNote(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateCreditNoteRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	note, err := h.service.CreateCreditNote(req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	log.Printf("Credit note %s issued against invoice %d", note.CreditNoteNumber, note.InvoiceID)
+
+	response.JSON(w, http.StatusCreated, note)
+}
+
+// GetCreditNote retrieves a single credit note
+func (h *CreditNoteHandler) GetCreditNote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid credit note ID")
+		return
+	}
+
+	note, err := h.service.GetCreditNote(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, note)
+}
+
+// RecordRefund records the outbound Solana transaction that refunded a credit note
+func (h *CreditNoteHandler) RecordRefund(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid credit note ID")
+		return
+	}
+
+	var req models.RecordRefundRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	note, err := h.service.RecordRefund(id, req.Signature)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, note)
+}
+
+// VoidCreditNote cancels an issued credit note
+func (h *CreditNoteHandler) VoidCreditNote(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid credit note ID")
+		return
+	}
+
+	note, err := h.service.VoidCreditNote(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, note)
+}
+
+// handleError maps credit note service errors onto HTTP responses
+func (h *CreditNoteHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrCreditNoteNotFound):
+		response.NotFound(w, "Credit note not found")
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		response.NotFound(w, "Invoice not found")
+	case errors.Is(err, services.ErrInvoiceNotPaid),
+		errors.Is(err, services.ErrCreditExceedsBalance),
+		errors.Is(err, services.ErrCreditNoteNotIssued):
+		response.Error(w, http.StatusUnprocessableEntity, err.Error(), "invalid_state")
+	case errors.Is(err, services.ErrRefundAlreadyClaimed):
+		response.Error(w, http.StatusConflict, err.Error(), "duplicate_refund")
+	default:
+		log.Printf("Credit note error: %v", err)
+		response.InternalServerError(w)
+	}
+}
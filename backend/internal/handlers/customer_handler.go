@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// CustomerHandler handles HTTP requests related to the customer directory
+type CustomerHandler struct {
+	service        *services.CustomerService
+	invoiceService *services.InvoiceService
+}
+
+// NewCustomerHandler creates a new customer handler
+func NewCustomerHandler(service *services.CustomerService, invoiceService *services.InvoiceService) *CustomerHandler {
+	return &CustomerHandler{
+		service:        service,
+		invoiceService: invoiceService,
+	}
+}
+
+// Routes returns a router with all customer-related routes
+func (h *CustomerHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListCustomers)
+	r.Post("/", h.CreateCustomer)
+	r.Get("/{id}", h.GetCustomer)
+	r.Put("/{id}", h.UpdateCustomer)
+	r.Delete("/{id}", h.DeleteCustomer)
+	r.Get("/{id}/invoices", h.ListCustomerInvoices)
+	r.Get("/{id}/balance", h.GetCustomerBalance)
+
+	return r
+}
+
+// ListCustomers returns customers ordered by name; ?q= matches part of the name or email
+func (h *CustomerHandler) ListCustomers(w http.ResponseWriter, r *http.Request) {
+	page, limit := parsePagination(r)
+
+	customers, total, err := h.service.ListCustomers(r.URL.Query().Get("q"), page, limit)
+	if err != nil {
+		log.Printf("Error listing customers: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.New().
+		WithData(customers).
+		WithPagination(int(total), page, limit).
+		Send(w, http.StatusOK)
+}
+
+// CreateCustomer adds a customer to the directory
+func (h *CustomerHandler) CreateCustomer(w http.ResponseWriter, r *http.Request) {
+	var req models.CustomerRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	customer, err := h.service.CreateCustomer(req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, customer)
+}
+
+// GetCustomer retrieves a single customer
+func (h *CustomerHandler) GetCustomer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid customer ID")
+		return
+	}
+
+	customer, err := h.service.GetCustomer(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, customer)
+}
+
+// UpdateCustomer replaces a customer's details; existing invoices are not changed
+func (h *CustomerHandler) UpdateCustomer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid customer ID")
+		return
+	}
+
+	var req models.CustomerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	customer, err := h.service.UpdateCustomer(id, req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, customer)
+}
+
+// DeleteCustomer removes a customer from the directory
+func (h *CustomerHandler) DeleteCustomer(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid customer ID")
+		return
+	}
+
+	if err := h.service.DeleteCustomer(id); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListCustomerInvoices returns the customer's invoices. It accepts the same
+// filter, sort and pagination parameters as the invoice list.
+func (h *CustomerHandler) ListCustomerInvoices(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid customer ID")
+		return
+	}
+
+	if _, err := h.service.GetCustomer(id); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	page, limit := parsePagination(r)
+
+	filter, sort, cursor, validationErrors := parseInvoiceListQuery(r)
+	if len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+	filter.CustomerID = &id
+
+	result, err := h.invoiceService.ListInvoices(filter, sort, cursor, page, limit)
+	if err != nil {
+		log.Printf("Error listing invoices of customer %d: %v", id, err)
+		response.InternalServerError(w)
+		return
+	}
+
+	setLinkHeader(w, r, result.Next, result.Prev)
+	response.New().
+		WithData(result.Invoices).
+		WithPagination(int(result.Total), page, limit).
+		WithCursors(result.Next, result.Prev).
+		Send(w, http.StatusOK)
+}
+
+// GetCustomerBalance returns the customer's outstanding and overdue totals per currency
+func (h *CustomerHandler) GetCustomerBalance(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid customer ID")
+		return
+	}
+
+	balances, err := h.service.GetCustomerBalance(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, balances)
+}
+
+// handleError maps customer service errors onto HTTP responses
+func (h *CustomerHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrCustomerNotFound):
+		response.NotFound(w, "Customer not found")
+	default:
+		log.Printf("Customer error: %v", err)
+		response.InternalServerError(w)
+	}
+}
@@ -2,20 +2,35 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/ncapetillo/demo-fluida/internal/db"
 	"github.com/ncapetillo/demo-fluida/internal/models"
 	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
 )
 
+// DraftInvoiceService is the draft invoice logic the handler needs; it is
+// implemented by services.DraftInvoiceService
+type DraftInvoiceService interface {
+	SaveDraft(req models.CreateDraftInvoiceRequest) (models.DraftInvoice, bool, error)
+	GetDraftByUserID(userID string) (models.DraftInvoice, error)
+	UpdateDraft(id string, req models.UpdateDraftInvoiceRequest) (models.DraftInvoice, error)
+	DeleteDraft(id string) error
+}
+
 // DraftInvoiceHandler handles HTTP requests related to draft invoices
-type DraftInvoiceHandler struct{}
+type DraftInvoiceHandler struct {
+	service DraftInvoiceService
+}
 
 // NewDraftInvoiceHandler creates a new draft invoice handler
-func NewDraftInvoiceHandler() *DraftInvoiceHandler {
-	return &DraftInvoiceHandler{}
+func NewDraftInvoiceHandler(service DraftInvoiceService) *DraftInvoiceHandler {
+	return &DraftInvoiceHandler{
+		service: service,
+	}
 }
 
 // Routes returns a router with all draft invoice-related routes
@@ -26,12 +41,12 @@ func (h *DraftInvoiceHandler) Routes() chi.Router {
 	r.Get("/{userId}", h.GetDraftInvoiceByUserID)
 	r.Put("/{id}", h.UpdateDraftInvoice)
 	r.Delete("/{id}", h.DeleteDraftInvoice)
-	r.Get("/check", h.CheckInvoiceNumberExists)
 	
 	return r
 }
 
-// CreateDraftInvoice creates a new draft invoice
+// CreateDraftInvoice saves a user's draft invoice. A user has at most one
+// draft, so saving again updates it and answers 200 instead of 201.
 func (h *DraftInvoiceHandler) CreateDraftInvoice(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateDraftInvoiceRequest
 	
@@ -41,47 +56,29 @@ func (h *DraftInvoiceHandler) CreateDraftInvoice(w http.ResponseWriter, r *http.
 		return
 	}
 	
-	// Check if the user already has a draft invoice
-	existingDraft, err := models.GetDraftInvoiceByUserID(db.DB, req.UserID)
-	if err == nil {
-		// User already has a draft invoice, update it instead
-		updateReq := models.UpdateDraftInvoiceRequest{
-			InvoiceData: req.InvoiceData,
-		}
-		if err := models.UpdateDraftInvoice(db.DB, existingDraft.ID, updateReq); err != nil {
-			response.Error(w, http.StatusInternalServerError, "Failed to update existing draft invoice: "+err.Error(), "update_failed")
-			return
-		}
-		
-		// Get the updated draft
-		updatedDraft, _ := models.GetDraftInvoiceByID(db.DB, existingDraft.ID)
-		
-		response.JSON(w, http.StatusOK, updatedDraft)
+	if req.UserID == "" {
+		sendValidationErrors(w, map[string]string{"userId": "User ID is required"})
 		return
 	}
 	
-	// Create new draft invoice
-	draft := models.NewDraftInvoice(req)
-	if err := models.CreateDraftInvoice(db.DB, &draft); err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to create draft invoice: "+err.Error(), "creation_failed")
+	draft, created, err := h.service.SaveDraft(req)
+	if err != nil {
+		h.handleError(w, err)
 		return
 	}
 	
-	response.JSON(w, http.StatusCreated, draft)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	response.JSON(w, status, draft)
 }
 
 // GetDraftInvoiceByUserID retrieves a draft invoice by user ID
 func (h *DraftInvoiceHandler) GetDraftInvoiceByUserID(w http.ResponseWriter, r *http.Request) {
-	userID := chi.URLParam(r, "userId")
-	
-	if userID == "" {
-		response.BadRequest(w, "User ID is required")
-		return
-	}
-	
-	draft, err := models.GetDraftInvoiceByUserID(db.DB, userID)
+	draft, err := h.service.GetDraftByUserID(chi.URLParam(r, "userId"))
 	if err != nil {
-		response.NotFound(w, "No draft invoice found for this user")
+		h.handleError(w, err)
 		return
 	}
 	
@@ -90,75 +87,38 @@ func (h *DraftInvoiceHandler) GetDraftInvoiceByUserID(w http.ResponseWriter, r *
 
 // UpdateDraftInvoice updates an existing draft invoice
 func (h *DraftInvoiceHandler) UpdateDraftInvoice(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	
-	if id == "" {
-		response.BadRequest(w, "Draft invoice ID is required")
-		return
-	}
-	
-	// Check if the draft invoice exists
-	_, err := models.GetDraftInvoiceByID(db.DB, id)
-	if err != nil {
-		response.NotFound(w, "Draft invoice not found")
-		return
-	}
-	
 	var req models.UpdateDraftInvoiceRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		response.BadRequest(w, "Invalid request payload: "+err.Error())
 		return
 	}
 	
-	if err := models.UpdateDraftInvoice(db.DB, id, req); err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to update draft invoice: "+err.Error(), "update_failed")
+	draft, err := h.service.UpdateDraft(chi.URLParam(r, "id"), req)
+	if err != nil {
+		h.handleError(w, err)
 		return
 	}
 	
-	// Get the updated draft
-	updatedDraft, _ := models.GetDraftInvoiceByID(db.DB, id)
-	
-	response.JSON(w, http.StatusOK, updatedDraft)
+	response.JSON(w, http.StatusOK, draft)
 }
 
 // DeleteDraftInvoice deletes a draft invoice
 func (h *DraftInvoiceHandler) DeleteDraftInvoice(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	
-	if id == "" {
-		response.BadRequest(w, "Draft invoice ID is required")
-		return
-	}
-	
-	// Check if the draft invoice exists
-	_, err := models.GetDraftInvoiceByID(db.DB, id)
-	if err != nil {
-		response.NotFound(w, "Draft invoice not found")
-		return
-	}
-	
-	if err := models.DeleteDraftInvoice(db.DB, id); err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to delete draft invoice: "+err.Error(), "deletion_failed")
+	if err := h.service.DeleteDraft(chi.URLParam(r, "id")); err != nil {
+		h.handleError(w, err)
 		return
 	}
 	
 	response.Success(w, http.StatusOK, "Draft invoice deleted successfully")
 }
 
-// CheckInvoiceNumberExists checks if an invoice number already exists
-func (h *DraftInvoiceHandler) CheckInvoiceNumberExists(w http.ResponseWriter, r *http.Request) {
-	invoiceNumber := r.URL.Query().Get("invoice_number")
-	
-	if invoiceNumber == "" {
-		response.BadRequest(w, "Invoice number is required")
-		return
-	}
-	
-	var count int64
-	if err := db.DB.Model(&models.Invoice{}).Where("invoice_number = ?", invoiceNumber).Count(&count).Error; err != nil {
-		response.Error(w, http.StatusInternalServerError, "Failed to check invoice number: "+err.Error(), "database_error")
-		return
+// handleError maps draft invoice service errors to responses
+func (h *DraftInvoiceHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrDraftInvoiceNotFound):
+		response.NotFound(w, "Draft invoice not found")
+	default:
+		log.Printf("Error managing draft invoice: %v", err)
+		response.InternalServerError(w)
 	}
-	
-	response.JSON(w, http.StatusOK, map[string]bool{"exists": count > 0})
-} 
\ No newline at end of file
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// fakeDraftInvoiceService is an in-memory DraftInvoiceService for tests
+type fakeDraftInvoiceService struct {
+	drafts map[string]models.DraftInvoice
+}
+
+func (s *fakeDraftInvoiceService) SaveDraft(req models.CreateDraftInvoiceRequest) (models.DraftInvoice, bool, error) {
+	for id, draft := range s.drafts {
+		if draft.UserID == req.UserID {
+			draft.InvoiceData = req.InvoiceData
+			s.drafts[id] = draft
+			return draft, false, nil
+		}
+	}
+	draft := models.NewDraftInvoice(req)
+	draft.ID = "draft-" + req.UserID
+	s.drafts[draft.ID] = draft
+	return draft, true, nil
+}
+
+func (s *fakeDraftInvoiceService) GetDraftByUserID(userID string) (models.DraftInvoice, error) {
+	for _, draft := range s.drafts {
+		if draft.UserID == userID {
+			return draft, nil
+		}
+	}
+	return models.DraftInvoice{}, services.ErrDraftInvoiceNotFound
+}
+
+func (s *fakeDraftInvoiceService) UpdateDraft(id string, req models.UpdateDraftInvoiceRequest) (models.DraftInvoice, error) {
+	draft, ok := s.drafts[id]
+	if !ok {
+		return models.DraftInvoice{}, services.ErrDraftInvoiceNotFound
+	}
+	draft.InvoiceData = req.InvoiceData
+	s.drafts[id] = draft
+	return draft, nil
+}
+
+func (s *fakeDraftInvoiceService) DeleteDraft(id string) error {
+	if _, ok := s.drafts[id]; !ok {
+		return services.ErrDraftInvoiceNotFound
+	}
+	delete(s.drafts, id)
+	return nil
+}
+
+func TestDraftInvoiceHandler(t *testing.T) {
+	routes := NewDraftInvoiceHandler(&fakeDraftInvoiceService{drafts: map[string]models.DraftInvoice{}}).Routes()
+
+	steps := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		wantStatus int
+	}{
+		{"missing user", http.MethodPost, "/", `{"invoiceData": "{}"}`, http.StatusBadRequest},
+		{"create", http.MethodPost, "/", `{"userId": "u1", "invoiceData": "{}"}`, http.StatusCreated},
+		// A user has one draft, so saving again updates it
+		{"save again", http.MethodPost, "/", `{"userId": "u1", "invoiceData": "{\"amount\": 5}"}`, http.StatusOK},
+		{"get", http.MethodGet, "/u1", "", http.StatusOK},
+		{"get unknown user", http.MethodGet, "/u2", "", http.StatusNotFound},
+		{"update", http.MethodPut, "/draft-u1", `{"invoiceData": "{}"}`, http.StatusOK},
+		{"update unknown", http.MethodPut, "/draft-u2", `{"invoiceData": "{}"}`, http.StatusNotFound},
+		{"delete", http.MethodDelete, "/draft-u1", "", http.StatusOK},
+		{"delete again", http.MethodDelete, "/draft-u1", "", http.StatusNotFound},
+	}
+
+	for _, step := range steps {
+		status, resp := serve(t, routes, step.method, step.path, step.body)
+		if status != step.wantStatus {
+			t.Errorf("%s: expected %d, got %d", step.name, step.wantStatus, status)
+		}
+		if status >= http.StatusBadRequest && resp.Error == nil {
+			t.Errorf("%s: expected a JSON error body", step.name)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+)
+
+// sendValidationErrors converts a field->message map into the standard validation error response
+func sendValidationErrors(w http.ResponseWriter, validationErrors map[string]string) {
+	errors := make([]response.ValidationError, 0, len(validationErrors))
+	for field, message := range validationErrors {
+		errors = append(errors, response.ValidationError{
+			Field:   field,
+			Message: message,
+		})
+	}
+
+	// Keep the output stable for clients and tests
+	sort.Slice(errors, func(i, j int) bool { return errors[i].Field < errors[j].Field })
+
+	response.ValidationErrors(w, errors)
+}
+
+// parsePagination reads the page and limit query parameters, falling back to page 1 and 10 items
+func parsePagination(r *http.Request) (page, limit int) {
+	page = 1
+	limit = 10
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
+			limit = l
+		}
+	}
+
+	if limit > 100 {
+		limit = 100
+	}
+
+	return page, limit
+}
+
+// parseInvoiceListQuery reads the filter, sort and cursor parameters of an invoice list
+func parseInvoiceListQuery(r *http.Request) (models.InvoiceFilter, models.InvoiceSort, *models.InvoiceCursor, map[string]string) {
+	filter, validationErrors := parseInvoiceFilter(r)
+
+	sort, err := models.ParseInvoiceSort(r.URL.Query().Get("sort"))
+	if err != nil {
+		validationErrors["sort"] = err.Error()
+	}
+
+	var cursor *models.InvoiceCursor
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		if cursor, err = models.DecodeInvoiceCursor(c); err != nil {
+			validationErrors["cursor"] = "Invalid cursor"
+		} else if !sort.KeysetCompatible() {
+			validationErrors["cursor"] = "Cursor pagination requires sort=createdAt or sort=-createdAt"
+		}
+	}
+
+	return filter, sort, cursor, validationErrors
+}
+
+// parseInvoiceFilter reads the invoice filter query parameters:
+//
+//	createdFrom, createdTo   creation date range
+//	dueFrom, dueTo           due date range
+//	paidFrom, paidTo         payment date range
+//	from, to, month          shorthand for the range selected by dateField
+//	dateField                created (default), due or paid
+//	minAmount, maxAmount     inclusive amount range
+//	status, currency, receiver, customerId
+//	q                        full-text search
+//
+// Dates are YYYY-MM-DD or RFC 3339; a plain "to" date includes that whole day,
+// and month (e.g. 2026-09) covers one calendar month.
+func parseInvoiceFilter(r *http.Request) (models.InvoiceFilter, map[string]string) {
+	query := r.URL.Query()
+	errors := make(map[string]string)
+	var filter models.InvoiceFilter
+
+	filter.CreatedFrom, filter.CreatedTo = parseDateRange(query, "createdFrom", "createdTo", errors)
+	filter.DueFrom, filter.DueTo = parseDateRange(query, "dueFrom", "dueTo", errors)
+	filter.PaidFrom, filter.PaidTo = parseDateRange(query, "paidFrom", "paidTo", errors)
+
+	// from/to/month apply to the range chosen by dateField
+	from, to := parseDateRange(query, "from", "to", errors)
+	if v := query.Get("month"); v != "" {
+		month, err := time.Parse("2006-01", v)
+		if err != nil {
+			errors["month"] = "month must be formatted as YYYY-MM"
+		} else {
+			end := month.AddDate(0, 1, 0)
+			from, to = &month, &end
+		}
+	}
+	if from != nil || to != nil {
+		switch query.Get("dateField") {
+		case "", "created":
+			filter.CreatedFrom, filter.CreatedTo = from, to
+		case "due":
+			filter.DueFrom, filter.DueTo = from, to
+		case "paid":
+			filter.PaidFrom, filter.PaidTo = from, to
+		default:
+			errors["dateField"] = "dateField must be created, due or paid"
+		}
+	}
+
+	filter.MinAmount = parseAmountParam(query.Get("minAmount"), "minAmount", errors)
+	filter.MaxAmount = parseAmountParam(query.Get("maxAmount"), "maxAmount", errors)
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		errors["maxAmount"] = "maxAmount must not be less than minAmount"
+	}
+
+	if v := query.Get("status"); v != "" {
+		status := models.InvoiceStatus(strings.ToUpper(v))
+		switch status {
+		case models.StatusPending, models.StatusPaid, models.StatusCanceled:
+			filter.Status = status
+		default:
+			errors["status"] = "status must be PENDING, PAID or CANCELED"
+		}
+	}
+
+	filter.Currency = strings.ToUpper(query.Get("currency"))
+	filter.ReceiverAddr = query.Get("receiver")
+
+	if v := query.Get("customerId"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			errors["customerId"] = "customerId must be a positive integer"
+		} else {
+			filter.CustomerID = &id
+		}
+	}
+
+	filter.Search = strings.TrimSpace(query.Get("q"))
+
+	return filter, errors
+}
+
+// parseDateRange reads a pair of date parameters; errors are added to errs under the parameter name
+func parseDateRange(query url.Values, fromKey, toKey string, errs map[string]string) (from, to *time.Time) {
+	if v := query.Get(fromKey); v != "" {
+		t, _, err := parseDateParam(v)
+		if err != nil {
+			errs[fromKey] = fromKey + " must be a date (YYYY-MM-DD) or an RFC 3339 timestamp"
+		} else {
+			from = &t
+		}
+	}
+
+	if v := query.Get(toKey); v != "" {
+		t, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			errs[toKey] = toKey + " must be a date (YYYY-MM-DD) or an RFC 3339 timestamp"
+		} else {
+			if dateOnly {
+				t = t.AddDate(0, 0, 1)
+			}
+			to = &t
+		}
+	}
+
+	if from != nil && to != nil && !from.Before(*to) {
+		errs[toKey] = toKey + " must be after " + fromKey
+	}
+
+	return from, to
+}
+
+// parseAmountParam parses an optional non-negative amount parameter
+func parseAmountParam(value, name string, errs map[string]string) *float64 {
+	if value == "" {
+		return nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || amount < 0 {
+		errs[name] = name + " must be a non-negative number"
+		return nil
+	}
+	return &amount
+}
+
+// parseDateParam parses a YYYY-MM-DD date (as UTC midnight) or an RFC 3339 timestamp
+func parseDateParam(value string) (t time.Time, dateOnly bool, err error) {
+	if t, err = time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	t, err = time.Parse(time.RFC3339, value)
+	return t, false, err
+}
+
+// setLinkHeader advertises the neighbouring pages in an RFC 8288 Link header.
+// The links repeat the current query with the page parameter replaced by a cursor.
+func setLinkHeader(w http.ResponseWriter, r *http.Request, next, prev string) {
+	var links []string
+	for _, link := range []struct{ rel, cursor string }{{"next", next}, {"prev", prev}} {
+		if link.cursor == "" {
+			continue
+		}
+		query := r.URL.Query()
+		query.Del("page")
+		query.Set("cursor", link.cursor)
+		links = append(links, fmt.Sprintf("<%s?%s>; rel=%q", r.URL.Path, query.Encode(), link.rel))
+	}
+
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
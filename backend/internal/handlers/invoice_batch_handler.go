@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// maxBatchBodyBytes limits the size of an uploaded batch
+const maxBatchBodyBytes = 10 << 20
+
+// InvoiceBatchHandler handles HTTP requests for bulk invoice creation
+type InvoiceBatchHandler struct {
+	service *services.InvoiceBatchService
+}
+
+// NewInvoiceBatchHandler creates a new invoice batch handler
+func NewInvoiceBatchHandler(service *services.InvoiceBatchService) *InvoiceBatchHandler {
+	return &InvoiceBatchHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all batch-related routes
+func (h *InvoiceBatchHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", h.CreateBatch)
+	r.Get("/{jobId}", h.GetBatchJob)
+
+	return r
+}
+
+// CreateBatch creates invoices from a JSON body ({"mode": ..., "invoices": [...]})
+// or a text/csv upload with the mode in ?mode=. Batches above the async threshold,
+// or any batch sent with ?async=true, are accepted with 202 and processed as a job.
+func (h *InvoiceBatchHandler) CreateBatch(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	mode := models.BatchMode(r.URL.Query().Get("mode"))
+
+	var format models.BatchFormat
+	var rows []models.BatchRow
+
+	if mediaType == "text/csv" {
+		format = models.BatchFormatCSV
+
+		parsed, err := services.ParseInvoiceCSV(r.Body)
+		if err != nil {
+			response.BadRequest(w, err.Error())
+			return
+		}
+		rows = parsed
+	} else {
+		format = models.BatchFormatJSON
+
+		var req models.CreateInvoiceBatchRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadRequest(w, "Invalid request payload: "+err.Error())
+			return
+		}
+
+		if validationErrors := req.Validate(); len(validationErrors) > 0 {
+			sendValidationErrors(w, validationErrors)
+			return
+		}
+
+		if req.Mode != "" {
+			mode = req.Mode
+		}
+
+		rows = make([]models.BatchRow, len(req.Invoices))
+		for i, invoice := range req.Invoices {
+			rows[i] = models.BatchRow{Row: i, Request: invoice}
+		}
+	}
+
+	if mode == "" {
+		mode = models.BatchModeAllOrNothing
+	}
+	if !mode.IsValid() {
+		sendValidationErrors(w, map[string]string{"mode": "Mode must be all_or_nothing or best_effort"})
+		return
+	}
+
+	if r.URL.Query().Get("async") == "true" || h.service.ShouldRunAsync(len(rows)) {
+		job, err := h.service.EnqueueBatch(format, mode, rows)
+		if err != nil {
+			log.Printf("Error enqueueing invoice batch: %v", err)
+			response.InternalServerError(w)
+			return
+		}
+
+		log.Printf("Invoice batch job %s queued with %d invoices", job.ID, job.Total)
+		w.Header().Set("Location", fmt.Sprintf("/api/v1/invoices/batch/%s", job.ID))
+		response.JSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	// A batch below the threshold may still take longer than the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Could not lift write deadline for invoice batch: %v", err)
+	}
+
+	result, err := h.service.ProcessBatch(format, mode, rows)
+	if err != nil {
+		log.Printf("Error processing invoice batch: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	log.Printf("Invoice batch processed: %d created, %d failed", result.Succeeded, result.Failed)
+
+	switch {
+	case result.Failed == 0:
+		response.JSON(w, http.StatusCreated, result)
+	case result.Succeeded == 0:
+		response.New().
+			WithData(result).
+			WithError("No invoices were created; see the row errors", "batch_failed").
+			Send(w, http.StatusUnprocessableEntity)
+	default:
+		response.JSON(w, http.StatusOK, result)
+	}
+}
+
+// GetBatchJob returns the status of a batch job and, once finished, its result
+func (h *InvoiceBatchHandler) GetBatchJob(w http.ResponseWriter, r *http.Request) {
+	job, err := h.service.GetBatchJob(chi.URLParam(r, "jobId"))
+	if err != nil {
+		if errors.Is(err, services.ErrBatchJobNotFound) {
+			response.NotFound(w, "Batch job not found")
+			return
+		}
+		log.Printf("Error getting batch job: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// How often an idle event stream sends a comment, so proxies keep it open
+const eventStreamKeepAlive = 15 * time.Second
+
+// StreamInvoiceEvents streams the events of an invoice to the payment page as
+// Server-Sent Events. A status event with the invoice as it stands comes
+// first; the stream then follows the payment as it is detected, confirmed and
+// marked paid, as well as new quotes and their expiry, and ends once the
+// invoice is paid or canceled. The stream is public, like the payment link.
+func (h *PublicInvoiceHandler) StreamInvoiceEvents(w http.ResponseWriter, r *http.Request) {
+	invoice, events, stop, err := h.service.WatchInvoice(chi.URLParam(r, "token"))
+	if err != nil {
+		if sendLinkGone(w, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			response.NotFound(w, "Invoice not found")
+			return
+		}
+		log.Printf("Error watching invoice: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+	defer stop()
+
+	// The stream stays open far longer than the server's write timeout
+	controller := http.NewResponseController(w)
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Could not lift write deadline for event stream: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Keeps nginx and similar proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event models.InvoiceEvent) bool {
+		if err := writeServerSentEvent(w, event); err != nil {
+			return false
+		}
+		return controller.Flush() == nil
+	}
+
+	current := models.InvoiceEvent{
+		Type:      models.InvoiceEventStatus,
+		InvoiceID: invoice.ID,
+		Status:    invoice.Status,
+		Signature: invoice.PaymentTxSignature,
+		ExpiresAt: invoice.RateExpiresAt,
+		At:        time.Now(),
+	}
+	if !send(current) || current.Terminal() {
+		return
+	}
+
+	// A quote locked at payment time expires unless the invoice is paid or quoted again first
+	var expiresAt *time.Time
+	var expiryTimer *time.Timer
+	var expiry <-chan time.Time
+	watchExpiry := func(at *time.Time) {
+		if expiryTimer != nil {
+			expiryTimer.Stop()
+		}
+		expiresAt, expiry = at, nil
+		if at != nil {
+			expiryTimer = time.NewTimer(time.Until(*at))
+			expiry = expiryTimer.C
+		}
+	}
+	watchExpiry(invoice.RateExpiresAt)
+	defer watchExpiry(nil)
+
+	keepAlive := time.NewTicker(eventStreamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case event, ok := <-events:
+			// Closed when this replica shuts down; the client reconnects elsewhere
+			if !ok {
+				return
+			}
+			if !send(event) || event.Terminal() {
+				return
+			}
+			if event.Type == models.InvoiceEventQuoted {
+				watchExpiry(event.ExpiresAt)
+			}
+		case <-expiry:
+			expiry = nil
+			if !send(models.InvoiceEvent{
+				Type:      models.InvoiceEventExpired,
+				InvoiceID: invoice.ID,
+				Status:    models.StatusPending,
+				ExpiresAt: expiresAt,
+				At:        time.Now(),
+			}) {
+				return
+			}
+		case <-keepAlive.C:
+			if _, err := io.WriteString(w, ": keep-alive\n\n"); err != nil || controller.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeServerSentEvent writes the event in the text/event-stream format, named
+// after its type with its JSON as data
+func writeServerSentEvent(w io.Writer, event models.InvoiceEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
@@ -1,25 +1,44 @@
 package handlers
 
 import (
+	"bufio"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/export"
 	"github.com/ncapetillo/demo-fluida/internal/models"
 	"github.com/ncapetillo/demo-fluida/internal/response"
 	"github.com/ncapetillo/demo-fluida/internal/services"
 )
 
+// InvoiceService is the invoice logic the handler needs; it is implemented by
+// services.InvoiceService
+type InvoiceService interface {
+	ListInvoices(filter models.InvoiceFilter, sort models.InvoiceSort, cursor *models.InvoiceCursor, page, limit int) (models.InvoicePage, error)
+	GetInvoiceByToken(token string) (models.Invoice, error)
+	GetInvoiceByNumber(invoiceNumber string) (models.Invoice, error)
+	InvoiceNumberExists(invoiceNumber string) (bool, error)
+	CreateInvoice(req models.CreateInvoiceRequest) (models.Invoice, error)
+	UpdateInvoiceStatus(id int, status models.InvoiceStatus) (models.Invoice, error)
+	ExportInvoices(ctx context.Context, filter models.InvoiceFilter, after *models.InvoiceCursor, w export.InvoiceWriter) (int, string, error)
+}
+
 // InvoiceHandler handles HTTP requests related to invoices
 type InvoiceHandler struct {
-	service *services.InvoiceService
+	service InvoiceService
 }
 
 // NewInvoiceHandler creates a new invoice handler
-func NewInvoiceHandler(service *services.InvoiceService) *InvoiceHandler {
+func NewInvoiceHandler(service InvoiceService) *InvoiceHandler {
 	return &InvoiceHandler{
 		service: service,
 	}
@@ -31,56 +50,47 @@ func (h *InvoiceHandler) Routes() chi.Router {
 	
 	r.Get("/", h.GetAllInvoices)
 	r.Post("/", h.CreateInvoice)
+	r.Get("/export", h.ExportInvoices)
+	r.Get("/check", h.CheckInvoiceNumber)
+	r.Get("/number/{invoiceNumber}", h.GetInvoiceByNumber)
 	r.Get("/{token}", h.GetInvoiceByToken)
 	r.Put("/{id}/status", h.UpdateInvoiceStatus)
 	
 	return r
 }
 
-// GetAllInvoices returns all invoices
+// GetAllInvoices returns one page of invoices. It accepts the filters of
+// parseInvoiceFilter, full-text search in ?q= and ordering in ?sort=, e.g.
+// sort=-dueDate for the latest due date first. Pages are selected with ?page=
+// or, when sorted by creation time, with the opaque ?cursor= returned in the
+// pagination metadata and Link header, which stays stable while invoices are added.
 func (h *InvoiceHandler) GetAllInvoices(w http.ResponseWriter, r *http.Request) {
-	// Parse pagination parameters
-	page := 1
-	limit := 10
+	page, limit := parsePagination(r)
 	
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if p, err := strconv.Atoi(pageStr); err == nil && p > 0 {
-			page = p
-		}
-	}
+	filter, sort, cursor, validationErrors := parseInvoiceListQuery(r)
 	
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 {
-			limit = l
-		}
+	if len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
 	}
 	
-	invoices := h.service.GetAllInvoices()
-	total := len(invoices)
-	
-	// Calculate pagination
-	start := (page - 1) * limit
-	end := start + limit
-	if start >= total {
-		// Return empty result for out-of-range pages
-		response.New().
-			WithData([]models.Invoice{}).
-			WithPagination(total, page, limit).
-			Send(w, http.StatusOK)
+	result, err := h.service.ListInvoices(filter, sort, cursor, page, limit)
+	if err != nil {
+		log.Printf("Error listing invoices: %v", err)
+		response.InternalServerError(w)
 		return
 	}
-	if end > total {
-		end = total
-	}
 	
-	// Return paginated result
+	setLinkHeader(w, r, result.Next, result.Prev)
 	response.New().
-		WithData(invoices[start:end]).
-		WithPagination(total, page, limit).
+		WithData(result.Invoices).
+		WithPagination(int(result.Total), page, limit).
+		WithCursors(result.Next, result.Prev).
 		Send(w, http.StatusOK)
 }
 
-// GetInvoiceByToken retrieves an invoice by token
+// GetInvoiceByToken retrieves the full invoice by a payment link token. Payers
+// get the reduced view of PublicInvoiceHandler instead.
 func (h *InvoiceHandler) GetInvoiceByToken(w http.ResponseWriter, r *http.Request) {
 	token := chi.URLParam(r, "token")
 	
@@ -91,13 +101,57 @@ func (h *InvoiceHandler) GetInvoiceByToken(w http.ResponseWriter, r *http.Reques
 	
 	invoice, err := h.service.GetInvoiceByToken(token)
 	if err != nil {
-		response.NotFound(w, "Invoice not found")
+		if sendLinkGone(w, err) {
+			return
+		}
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			response.NotFound(w, "Invoice not found")
+			return
+		}
+		log.Printf("Error getting invoice by token: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+	
+	response.JSON(w, http.StatusOK, invoice)
+}
+
+// GetInvoiceByNumber retrieves the full invoice by its invoice number
+func (h *InvoiceHandler) GetInvoiceByNumber(w http.ResponseWriter, r *http.Request) {
+	invoice, err := h.service.GetInvoiceByNumber(chi.URLParam(r, "invoiceNumber"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			response.NotFound(w, "Invoice not found")
+			return
+		}
+		log.Printf("Error getting invoice by number: %v", err)
+		response.InternalServerError(w)
 		return
 	}
 	
 	response.JSON(w, http.StatusOK, invoice)
 }
 
+// CheckInvoiceNumber reports whether ?invoice_number= is already taken, so the
+// invoice form can warn before submitting
+func (h *InvoiceHandler) CheckInvoiceNumber(w http.ResponseWriter, r *http.Request) {
+	invoiceNumber := r.URL.Query().Get("invoice_number")
+	
+	if invoiceNumber == "" {
+		response.BadRequest(w, "Invoice number is required")
+		return
+	}
+	
+	exists, err := h.service.InvoiceNumberExists(invoiceNumber)
+	if err != nil {
+		log.Printf("Error checking invoice number: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+	
+	response.JSON(w, http.StatusOK, map[string]bool{"exists": exists})
+}
+
 // CreateInvoice creates a new invoice
 func (h *InvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateInvoiceRequest
@@ -114,15 +168,7 @@ func (h *InvoiceHandler) CreateInvoice(w http.ResponseWriter, r *http.Request) {
 	
 	// Validate the request
 	if validationErrors := req.Validate(); len(validationErrors) > 0 {
-		// Convert to response.ValidationError format
-		errors := make([]response.ValidationError, 0, len(validationErrors))
-		for field, message := range validationErrors {
-			errors = append(errors, response.ValidationError{
-				Field:   field,
-				Message: message,
-			})
-		}
-		response.ValidationErrors(w, errors)
+		sendValidationErrors(w, validationErrors)
 		return
 	}
 	
@@ -183,9 +229,106 @@ func (h *InvoiceHandler) UpdateInvoiceStatus(w http.ResponseWriter, r *http.Requ
 	// Update the invoice status
 	invoice, err := h.service.UpdateInvoiceStatus(id, req.Status)
 	if err != nil {
-		response.NotFound(w, "Invoice not found")
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			response.NotFound(w, "Invoice not found")
+			return
+		}
+		log.Printf("Error updating invoice status: %v", err)
+		response.InternalServerError(w)
 		return
 	}
 	
 	response.JSON(w, http.StatusOK, invoice)
-} 
\ No newline at end of file
+}
+
+// exportCursorTrailer is the HTTP trailer carrying the cursor after the last exported invoice
+const exportCursorTrailer = "X-Next-Cursor"
+
+// ExportInvoices streams the invoices matching the filter query parameters as a
+// file download. ?format= selects csv (default), ndjson, quickbooks or xero.
+// Incremental syncs pass the X-Next-Cursor trailer of the previous export as
+// ?cursor= to receive only invoices created since.
+func (h *InvoiceHandler) ExportInvoices(w http.ResponseWriter, r *http.Request) {
+	format := export.FormatCSV
+	if f := r.URL.Query().Get("format"); f != "" {
+		parsed, err := export.ParseFormat(f)
+		if err != nil {
+			response.BadRequest(w, "format must be csv, ndjson, quickbooks or xero")
+			return
+		}
+		format = parsed
+	}
+
+	filter, validationErrors := parseInvoiceFilter(r)
+
+	var after *models.InvoiceCursor
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := models.DecodeInvoiceCursor(c)
+		if err != nil {
+			validationErrors["cursor"] = "Invalid cursor"
+		}
+		after = cursor
+	}
+
+	if len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	// The export may take longer than the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Could not lift write deadline for export: %v", err)
+	}
+
+	filename := fmt.Sprintf("invoices-%s.%s", time.Now().UTC().Format("20060102"), format.FileExtension())
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	// Sent after the body: a cursor to resume a later export after the last invoice
+	w.Header().Set("Trailer", exportCursorTrailer)
+
+	// Buffer writes so nothing is sent until the first rows are ready; an
+	// immediate database error can then still be reported as JSON
+	sent := &countingWriter{w: w}
+	buffered := bufio.NewWriterSize(sent, 32*1024)
+	writer, err := export.NewInvoiceWriter(format, buffered)
+	if err != nil {
+		response.BadRequest(w, err.Error())
+		return
+	}
+
+	count, next, err := h.service.ExportInvoices(r.Context(), filter, after, writer)
+	if err != nil {
+		if sent.n == 0 {
+			log.Printf("Error exporting invoices: %v", err)
+			w.Header().Del("Content-Disposition")
+			response.InternalServerError(w)
+			return
+		}
+		// Part of the file was already sent, so the status can no longer change
+		log.Printf("Invoice export aborted after %d invoices: %v", count, err)
+		return
+	}
+
+	if err := buffered.Flush(); err != nil {
+		log.Printf("Error writing invoice export: %v", err)
+		return
+	}
+	
+	if next != "" {
+		w.Header().Set(exportCursorTrailer, next)
+	}
+
+	log.Printf("Exported %d invoices as %s", count, format)
+}
+
+// countingWriter counts the bytes passed through to the client
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ncapetillo/demo-fluida/internal/export"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// fakeInvoiceService is an in-memory InvoiceService for tests
+type fakeInvoiceService struct {
+	invoices  []models.Invoice
+	listPage  int
+	listLimit int
+	err       error
+}
+
+func (s *fakeInvoiceService) ListInvoices(filter models.InvoiceFilter, sort models.InvoiceSort, cursor *models.InvoiceCursor, page, limit int) (models.InvoicePage, error) {
+	s.listPage, s.listLimit = page, limit
+	if s.err != nil {
+		return models.InvoicePage{}, s.err
+	}
+	return models.InvoicePage{Invoices: s.invoices, Total: int64(len(s.invoices))}, nil
+}
+
+func (s *fakeInvoiceService) GetInvoiceByToken(token string) (models.Invoice, error) {
+	for _, invoice := range s.invoices {
+		if invoice.LinkToken == token {
+			return invoice, nil
+		}
+	}
+	return models.Invoice{}, services.ErrInvoiceNotFound
+}
+
+func (s *fakeInvoiceService) GetInvoiceByNumber(invoiceNumber string) (models.Invoice, error) {
+	if s.err != nil {
+		return models.Invoice{}, s.err
+	}
+	for _, invoice := range s.invoices {
+		if invoice.InvoiceNumber == invoiceNumber {
+			return invoice, nil
+		}
+	}
+	return models.Invoice{}, services.ErrInvoiceNotFound
+}
+
+func (s *fakeInvoiceService) InvoiceNumberExists(invoiceNumber string) (bool, error) {
+	_, err := s.GetInvoiceByNumber(invoiceNumber)
+	return err == nil, nil
+}
+
+func (s *fakeInvoiceService) CreateInvoice(req models.CreateInvoiceRequest) (models.Invoice, error) {
+	invoice := models.NewInvoice(req)
+	invoice.ID = len(s.invoices) + 1
+	s.invoices = append(s.invoices, invoice)
+	return invoice, nil
+}
+
+func (s *fakeInvoiceService) UpdateInvoiceStatus(id int, status models.InvoiceStatus) (models.Invoice, error) {
+	if s.err != nil {
+		return models.Invoice{}, s.err
+	}
+	for i := range s.invoices {
+		if s.invoices[i].ID == id {
+			s.invoices[i].Status = status
+			return s.invoices[i], nil
+		}
+	}
+	return models.Invoice{}, fmt.Errorf("failed to update invoice status: %w", services.ErrInvoiceNotFound)
+}
+
+func (s *fakeInvoiceService) ExportInvoices(ctx context.Context, filter models.InvoiceFilter, after *models.InvoiceCursor, w export.InvoiceWriter) (int, string, error) {
+	return 0, "", nil
+}
+
+func newFakeInvoiceService() *fakeInvoiceService {
+	return &fakeInvoiceService{invoices: []models.Invoice{{
+		ID:            1,
+		InvoiceNumber: "INV-001",
+		Amount:        100,
+		Currency:      "USDC",
+		Status:        models.StatusPending,
+		LinkToken:     "link-token",
+	}}}
+}
+
+// apiResponse is the envelope of every JSON response
+type apiResponse struct {
+	Data  json.RawMessage `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+		Code    string `json:"code"`
+		Details []struct {
+			Field string `json:"field"`
+		} `json:"details"`
+	} `json:"error"`
+	Meta struct {
+		Pagination struct {
+			Total int `json:"total"`
+			Page  int `json:"page"`
+			Limit int `json:"limit"`
+		} `json:"pagination"`
+	} `json:"meta"`
+}
+
+func serve(t *testing.T, handler http.Handler, method, path, body string) (int, apiResponse) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	var resp apiResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("%s %s: response is not JSON: %q", method, path, rec.Body.String())
+	}
+	return rec.Code, resp
+}
+
+func TestInvoiceHandlerGetInvoiceByNumber(t *testing.T) {
+	routes := NewInvoiceHandler(newFakeInvoiceService()).Routes()
+
+	status, resp := serve(t, routes, http.MethodGet, "/number/INV-001", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	var invoice models.Invoice
+	if err := json.Unmarshal(resp.Data, &invoice); err != nil || invoice.InvoiceNumber != "INV-001" {
+		t.Errorf("Expected invoice INV-001, got %s", resp.Data)
+	}
+
+	status, resp = serve(t, routes, http.MethodGet, "/number/INV-404", "")
+	if status != http.StatusNotFound || resp.Error == nil || resp.Error.Code != "not_found" {
+		t.Errorf("Expected a 404 not_found error, got %d %+v", status, resp.Error)
+	}
+}
+
+func TestInvoiceHandlerCheckInvoiceNumber(t *testing.T) {
+	routes := NewInvoiceHandler(newFakeInvoiceService()).Routes()
+
+	for number, want := range map[string]bool{"INV-001": true, "INV-002": false} {
+		status, resp := serve(t, routes, http.MethodGet, "/check?invoice_number="+number, "")
+		var result struct {
+			Exists bool `json:"exists"`
+		}
+		if err := json.Unmarshal(resp.Data, &result); err != nil || status != http.StatusOK || result.Exists != want {
+			t.Errorf("%s: expected exists=%v, got %d %s", number, want, status, resp.Data)
+		}
+	}
+
+	if status, _ := serve(t, routes, http.MethodGet, "/check", ""); status != http.StatusBadRequest {
+		t.Errorf("Expected 400 without an invoice number, got %d", status)
+	}
+}
+
+func TestInvoiceHandlerListPagination(t *testing.T) {
+	service := newFakeInvoiceService()
+	routes := NewInvoiceHandler(service).Routes()
+
+	status, resp := serve(t, routes, http.MethodGet, "/?page=3&limit=25", "")
+	if status != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", status)
+	}
+	if service.listPage != 3 || service.listLimit != 25 {
+		t.Errorf("Expected page 3 of 25 to be requested, got page %d of %d", service.listPage, service.listLimit)
+	}
+	if p := resp.Meta.Pagination; p.Total != 1 || p.Page != 3 || p.Limit != 25 {
+		t.Errorf("Unexpected pagination metadata %+v", p)
+	}
+
+	service.err = fmt.Errorf("connection refused")
+	status, resp = serve(t, routes, http.MethodGet, "/", "")
+	if status != http.StatusInternalServerError || resp.Error == nil {
+		t.Errorf("Expected a 500 error, got %d", status)
+	}
+}
+
+func TestInvoiceHandlerCreateInvoiceValidation(t *testing.T) {
+	routes := NewInvoiceHandler(newFakeInvoiceService()).Routes()
+
+	status, resp := serve(t, routes, http.MethodPost, "/", `{"amount": -5}`)
+	if status != http.StatusBadRequest || resp.Error == nil || resp.Error.Code != "validation_error" {
+		t.Fatalf("Expected a 400 validation_error, got %d %+v", status, resp.Error)
+	}
+
+	fields := map[string]bool{}
+	for _, detail := range resp.Error.Details {
+		fields[detail.Field] = true
+	}
+	if !fields["amount"] {
+		t.Errorf("Expected an error for amount, got %+v", resp.Error.Details)
+	}
+}
+
+func TestInvoiceHandlerUpdateInvoiceStatus(t *testing.T) {
+	service := newFakeInvoiceService()
+	routes := NewInvoiceHandler(service).Routes()
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		err        error
+		wantStatus int
+	}{
+		{"paid", "/1/status", `{"status": "PAID"}`, nil, http.StatusOK},
+		{"invalid ID", "/abc/status", `{"status": "PAID"}`, nil, http.StatusBadRequest},
+		{"invalid status", "/1/status", `{"status": "LOST"}`, nil, http.StatusBadRequest},
+		{"unknown invoice", "/42/status", `{"status": "PAID"}`, nil, http.StatusNotFound},
+		// Failures other than a missing invoice are not reported as 404
+		{"database error", "/1/status", `{"status": "PAID"}`, fmt.Errorf("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service.err = tt.err
+			status, resp := serve(t, routes, http.MethodPut, tt.path, tt.body)
+			if status != tt.wantStatus {
+				t.Errorf("Expected %d, got %d", tt.wantStatus, status)
+			}
+			if status != http.StatusOK && resp.Error == nil {
+				t.Errorf("Expected a JSON error body")
+			}
+		})
+	}
+}
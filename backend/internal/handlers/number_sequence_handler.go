@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// NumberSequenceHandler handles HTTP requests related to invoice number sequences
+type NumberSequenceHandler struct {
+	service *services.NumberSequenceService
+}
+
+// NewNumberSequenceHandler creates a new number sequence handler
+func NewNumberSequenceHandler(service *services.NumberSequenceService) *NumberSequenceHandler {
+	return &NumberSequenceHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all number sequence routes
+func (h *NumberSequenceHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListSequences)
+	r.Post("/", h.CreateSequence)
+	r.Get("/{name}", h.GetSequence)
+	r.Put("/{name}", h.UpdateSequence)
+	r.Get("/{name}/next", h.PreviewNextNumber)
+
+	return r
+}
+
+// ListSequences returns every number sequence
+func (h *NumberSequenceHandler) ListSequences(w http.ResponseWriter, r *http.Request) {
+	sequences, err := h.service.ListSequences()
+	if err != nil {
+		log.Printf("Error listing number sequences: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, sequences)
+}
+
+// CreateSequence creates a new number sequence
+func (h *NumberSequenceHandler) CreateSequence(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateNumberSequenceRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	sequence, err := h.service.CreateSequence(req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, sequence)
+}
+
+// GetSequence retrieves a single number sequence
+func (h *NumberSequenceHandler) GetSequence(w http.ResponseWriter, r *http.Request) {
+	sequence, err := h.service.GetSequence(chi.URLParam(r, "name"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, sequence)
+}
+
+// UpdateSequence changes the pattern of a number sequence
+func (h *NumberSequenceHandler) UpdateSequence(w http.ResponseWriter, r *http.Request) {
+	var req models.UpdateNumberSequenceRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	sequence, err := h.service.UpdateSequence(chi.URLParam(r, "name"), req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, sequence)
+}
+
+// PreviewNextNumber returns the number the next invoice of a sequence would receive
+func (h *NumberSequenceHandler) PreviewNextNumber(w http.ResponseWriter, r *http.Request) {
+	preview, err := h.service.PreviewNextNumber(chi.URLParam(r, "name"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, preview)
+}
+
+// handleError maps number sequence service errors onto HTTP responses
+func (h *NumberSequenceHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrNumberSequenceNotFound):
+		response.NotFound(w, "Number sequence not found")
+	case errors.Is(err, services.ErrNumberSequenceExists):
+		response.Error(w, http.StatusConflict, err.Error(), "duplicate_number_sequence")
+	default:
+		log.Printf("Number sequence error: %v", err)
+		response.InternalServerError(w)
+	}
+}
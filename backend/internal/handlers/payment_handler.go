@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// PaymentHandler handles HTTP requests for inbound payments seen by the payment watcher
+type PaymentHandler struct {
+	service *services.PaymentService
+}
+
+// NewPaymentHandler creates a new payment handler
+func NewPaymentHandler(service *services.PaymentService) *PaymentHandler {
+	return &PaymentHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all payment routes
+func (h *PaymentHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListPayments)
+	r.Get("/unmatched", h.ListUnmatchedPayments)
+	r.Get("/{id}", h.GetPayment)
+	r.Post("/{id}/allocations", h.AllocatePayment)
+
+	return r
+}
+
+// ListPayments returns recorded payments, newest first, optionally in one ?status=
+func (h *PaymentHandler) ListPayments(w http.ResponseWriter, r *http.Request) {
+	page, limit := parsePagination(r)
+
+	status := models.PaymentStatus(strings.ToUpper(r.URL.Query().Get("status")))
+	switch status {
+	case "", models.PaymentStatusMatched, models.PaymentStatusFlagged, models.PaymentStatusUnmatched, models.PaymentStatusAllocated:
+	default:
+		sendValidationErrors(w, map[string]string{"status": "Status must be MATCHED, FLAGGED, UNMATCHED or ALLOCATED"})
+		return
+	}
+
+	payments, total, err := h.service.ListPayments(status, page, limit)
+	if err != nil {
+		log.Printf("Error listing payments: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.New().
+		WithData(payments).
+		WithPagination(int(total), page, limit).
+		Send(w, http.StatusOK)
+}
+
+// ListUnmatchedPayments returns the queue of unmatched and flagged payments
+// that still have an unallocated amount, oldest first
+func (h *PaymentHandler) ListUnmatchedPayments(w http.ResponseWriter, r *http.Request) {
+	page, limit := parsePagination(r)
+
+	payments, total, err := h.service.ListUnmatchedPayments(page, limit)
+	if err != nil {
+		log.Printf("Error listing unmatched payments: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.New().
+		WithData(payments).
+		WithPagination(int(total), page, limit).
+		Send(w, http.StatusOK)
+}
+
+// GetPayment returns a payment with its allocations and audit trail
+func (h *PaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid payment ID")
+		return
+	}
+
+	detail, err := h.service.GetPayment(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, detail)
+}
+
+// AllocatePayment allocates a payment to one or more invoices. The
+// authenticated user is recorded as the actor in the payment's audit log.
+func (h *PaymentHandler) AllocatePayment(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid payment ID")
+		return
+	}
+
+	var req models.AllocatePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	actor, _, ok := r.BasicAuth()
+	if !ok || actor == "" {
+		actor = "anonymous"
+	}
+
+	detail, err := h.service.AllocatePayment(id, req, actor, chimiddleware.GetReqID(r.Context()))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, detail)
+}
+
+// handleError maps payment service errors to responses
+func (h *PaymentHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrPaymentNotFound):
+		response.NotFound(w, "Payment not found")
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		response.NotFound(w, err.Error())
+	case errors.Is(err, services.ErrPaymentNotAllocatable), errors.Is(err, services.ErrInvoiceNotPayable):
+		response.Error(w, http.StatusConflict, err.Error(), "invalid_state")
+	case errors.Is(err, services.ErrAllocationExceedsPayment),
+		errors.Is(err, services.ErrAllocationExceedsInvoice),
+		errors.Is(err, services.ErrAllocationTokenMismatch):
+		response.Error(w, http.StatusBadRequest, err.Error(), "invalid_allocation")
+	default:
+		log.Printf("Error handling payment request: %v", err)
+		response.InternalServerError(w)
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// PaymentLinkHandler handles HTTP requests for the payment links of an invoice
+type PaymentLinkHandler struct {
+	service *services.PaymentLinkService
+}
+
+// NewPaymentLinkHandler creates a new payment link handler
+func NewPaymentLinkHandler(service *services.PaymentLinkService) *PaymentLinkHandler {
+	return &PaymentLinkHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all payment link routes, mounted under an
+// invoice's {id}
+func (h *PaymentLinkHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListLinks)
+	r.Post("/", h.CreateLink)
+	r.Post("/{linkID}/regenerate", h.RegenerateLink)
+	r.Post("/{linkID}/revoke", h.RevokeLink)
+
+	return r
+}
+
+// ListLinks returns the payment links of an invoice with their status
+func (h *PaymentLinkHandler) ListLinks(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid invoice ID")
+		return
+	}
+
+	links, err := h.service.ListLinks(invoiceID)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, links)
+}
+
+// CreateLink issues another payment link to a pending invoice
+func (h *PaymentLinkHandler) CreateLink(w http.ResponseWriter, r *http.Request) {
+	invoiceID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid invoice ID")
+		return
+	}
+
+	var req models.CreatePaymentLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	link, err := h.service.CreateLink(invoiceID, req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, link)
+}
+
+// RegenerateLink replaces a payment link with a new token and revokes the old
+// one. The authenticated user is recorded as having revoked it.
+func (h *PaymentLinkHandler) RegenerateLink(w http.ResponseWriter, r *http.Request) {
+	invoiceID, linkID, ok := parseLinkPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.RegeneratePaymentLinkRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	link, err := h.service.RegenerateLink(invoiceID, linkID, req, linkActor(r))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, link)
+}
+
+// RevokeLink revokes a payment link. The authenticated user is recorded as
+// having revoked it.
+func (h *PaymentLinkHandler) RevokeLink(w http.ResponseWriter, r *http.Request) {
+	invoiceID, linkID, ok := parseLinkPath(w, r)
+	if !ok {
+		return
+	}
+
+	var req models.RevokePaymentLinkRequest
+	if err := decodeOptionalBody(r, &req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	link, err := h.service.RevokeLink(invoiceID, linkID, req, linkActor(r))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, link)
+}
+
+// handleError maps payment link service errors to responses
+func (h *PaymentLinkHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		response.NotFound(w, "Invoice not found")
+	case errors.Is(err, services.ErrPaymentLinkNotFound):
+		response.NotFound(w, "Payment link not found")
+	case errors.Is(err, services.ErrInvoiceNotPayable):
+		response.Error(w, http.StatusConflict, "Links can only be issued for pending invoices", "invalid_state")
+	default:
+		log.Printf("Error managing payment link: %v", err)
+		response.InternalServerError(w)
+	}
+}
+
+// sendLinkGone answers 410 Gone when err means the payment link was revoked
+// or has expired, and reports whether it did
+func sendLinkGone(w http.ResponseWriter, err error) bool {
+	switch {
+	case errors.Is(err, services.ErrPaymentLinkRevoked):
+		response.Error(w, http.StatusGone, "This payment link was revoked", "link_revoked")
+	case errors.Is(err, services.ErrPaymentLinkExpired):
+		response.Error(w, http.StatusGone, "This payment link has expired", "link_expired")
+	default:
+		return false
+	}
+	return true
+}
+
+// parseLinkPath reads the invoice and link IDs of a link route, answering 400
+// when either is not a number
+func parseLinkPath(w http.ResponseWriter, r *http.Request) (invoiceID, linkID int, ok bool) {
+	invoiceID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid invoice ID")
+		return 0, 0, false
+	}
+
+	linkID, err = strconv.Atoi(chi.URLParam(r, "linkID"))
+	if err != nil {
+		response.BadRequest(w, "Invalid payment link ID")
+		return 0, 0, false
+	}
+
+	return invoiceID, linkID, true
+}
+
+// decodeOptionalBody decodes a JSON body into v, leaving v as is when the
+// request has no body
+func decodeOptionalBody(r *http.Request, v interface{}) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	return nil
+}
+
+// linkActor returns the authenticated user recorded as revoking a link
+func linkActor(r *http.Request) string {
+	actor, _, ok := r.BasicAuth()
+	if !ok || actor == "" {
+		actor = "anonymous"
+	}
+	return actor
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/fx"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// PaymentRequestHandler implements the Solana Pay transaction request
+// protocol. Wallets expect the bare JSON objects of the specification, so
+// responses, errors included, are not wrapped in the API envelope.
+type PaymentRequestHandler struct {
+	service *services.PaymentRequestService
+}
+
+// NewPaymentRequestHandler creates a new Solana Pay transaction request handler
+func NewPaymentRequestHandler(service *services.PaymentRequestService) *PaymentRequestHandler {
+	return &PaymentRequestHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with the transaction request routes. A wallet is
+// pointed at an invoice with the URL solana:<url-encoded https URL of /{linkToken}>.
+func (h *PaymentRequestHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/{linkToken}", h.GetLabel)
+	r.Post("/{linkToken}", h.CreateTransaction)
+
+	return r
+}
+
+// GetLabel returns the label and icon the wallet shows to the payer
+func (h *PaymentRequestHandler) GetLabel(w http.ResponseWriter, r *http.Request) {
+	label, err := h.service.GetLabel(chi.URLParam(r, "linkToken"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	writeSolanaPay(w, http.StatusOK, label)
+}
+
+// CreateTransaction returns an unsigned transaction in which the posted
+// account pays the invoice
+func (h *PaymentRequestHandler) CreateTransaction(w http.ResponseWriter, r *http.Request) {
+	var req models.PaymentTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSolanaPayError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		writeSolanaPayError(w, http.StatusBadRequest, "A valid payer account is required")
+		return
+	}
+
+	tx, err := h.service.CreateTransaction(chi.URLParam(r, "linkToken"), req.Account)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	writeSolanaPay(w, http.StatusOK, tx)
+}
+
+// handleError maps service errors to Solana Pay error responses
+func (h *PaymentRequestHandler) handleError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		writeSolanaPayError(w, http.StatusNotFound, "Invoice not found")
+	case errors.Is(err, services.ErrPaymentLinkRevoked):
+		writeSolanaPayError(w, http.StatusGone, "This payment link was revoked")
+	case errors.Is(err, services.ErrPaymentLinkExpired):
+		writeSolanaPayError(w, http.StatusGone, "This payment link has expired")
+	case errors.Is(err, services.ErrInvoiceNotPayable):
+		writeSolanaPayError(w, http.StatusConflict, "This invoice is no longer awaiting payment")
+	case errors.Is(err, fx.ErrRateUnavailable):
+		writeSolanaPayError(w, http.StatusServiceUnavailable, "Exchange rate is currently unavailable")
+	default:
+		log.Printf("Error handling Solana Pay request: %v", err)
+		writeSolanaPayError(w, http.StatusInternalServerError, "The payment could not be prepared, please try again")
+	}
+}
+
+// writeSolanaPay writes a bare JSON response
+func writeSolanaPay(w http.ResponseWriter, statusCode int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(data); err != nil {
+		log.Printf("Error encoding Solana Pay response: %v", err)
+	}
+}
+
+// writeSolanaPayError writes an error with the message wallets show to the payer
+func writeSolanaPayError(w http.ResponseWriter, statusCode int, message string) {
+	writeSolanaPay(w, statusCode, map[string]string{"message": message})
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/fx"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// PublicCheckoutHandler handles the unauthenticated requests of the checkout
+// page, which visitors open from a checkout link
+type PublicCheckoutHandler struct {
+	service *services.CheckoutService
+}
+
+// NewPublicCheckoutHandler creates a new public checkout handler
+func NewPublicCheckoutHandler(service *services.CheckoutService) *PublicCheckoutHandler {
+	return &PublicCheckoutHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all public checkout routes, keyed by checkout link slug
+func (h *PublicCheckoutHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/{slug}", h.GetLink)
+	r.Post("/{slug}/sessions", h.StartSession)
+
+	return r
+}
+
+// GetLink returns the public view of an open checkout link
+func (h *PublicCheckoutHandler) GetLink(w http.ResponseWriter, r *http.Request) {
+	link, err := h.service.GetPublicLink(chi.URLParam(r, "slug"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, link)
+}
+
+// StartSession starts the visitor's checkout and returns the invoice to pay
+// together with the token of its payment page
+func (h *PublicCheckoutHandler) StartSession(w http.ResponseWriter, r *http.Request) {
+	var req models.StartCheckoutSessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	session, err := h.service.StartSession(chi.URLParam(r, "slug"), req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusCreated, session)
+}
+
+// handleError maps checkout service errors to responses for visitors
+func (h *PublicCheckoutHandler) handleError(w http.ResponseWriter, err error) {
+	var checkoutErr *services.CheckoutValidationError
+
+	switch {
+	case errors.As(err, &checkoutErr):
+		sendValidationErrors(w, checkoutErr.Fields)
+	case errors.Is(err, services.ErrCheckoutLinkNotFound):
+		response.NotFound(w, "Checkout link not found")
+	case errors.Is(err, services.ErrCheckoutLinkUnavailable):
+		response.Error(w, http.StatusGone, "This checkout link is no longer available", "checkout_unavailable")
+	case errors.Is(err, services.ErrCheckoutLinkSoldOut):
+		response.Error(w, http.StatusGone, "This checkout link has sold out", "checkout_sold_out")
+	case errors.Is(err, fx.ErrRateUnavailable):
+		response.Error(w, http.StatusServiceUnavailable, "Exchange rate is currently unavailable", "rate_unavailable")
+	default:
+		log.Printf("Error starting checkout: %v", err)
+		response.InternalServerError(w)
+	}
+}
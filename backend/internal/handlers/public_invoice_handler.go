@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/fx"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// PublicInvoiceHandler handles the unauthenticated requests of the payment
+// page, which payers open from a payment link. Invoices are returned as
+// models.PublicInvoice rather than in full.
+type PublicInvoiceHandler struct {
+	service *services.InvoiceService
+}
+
+// NewPublicInvoiceHandler creates a new public invoice handler
+func NewPublicInvoiceHandler(service *services.InvoiceService) *PublicInvoiceHandler {
+	return &PublicInvoiceHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all public invoice routes, keyed by payment link token
+func (h *PublicInvoiceHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/{token}", h.GetInvoice)
+	r.Get("/{token}/events", h.StreamInvoiceEvents)
+	r.Post("/{token}/quote", h.QuoteInvoice)
+
+	return r
+}
+
+// GetInvoice returns the public view of the invoice a payment link opens
+func (h *PublicInvoiceHandler) GetInvoice(w http.ResponseWriter, r *http.Request) {
+	invoice, err := h.service.GetInvoiceByToken(chi.URLParam(r, "token"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, models.NewPublicInvoice(invoice))
+}
+
+// QuoteInvoice returns the token amount to pay for an invoice right now. For
+// invoices whose exchange rate is locked at payment time this refreshes an
+// expired quote; the payment must arrive before rateExpiresAt to be matched.
+func (h *PublicInvoiceHandler) QuoteInvoice(w http.ResponseWriter, r *http.Request) {
+	invoice, err := h.service.QuoteInvoice(chi.URLParam(r, "token"))
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, models.NewPublicInvoice(invoice))
+}
+
+// handleError maps invoice service errors to responses for payers
+func (h *PublicInvoiceHandler) handleError(w http.ResponseWriter, err error) {
+	if sendLinkGone(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, services.ErrInvoiceNotFound):
+		response.NotFound(w, "Invoice not found")
+	case errors.Is(err, services.ErrInvoiceNotPayable):
+		response.Error(w, http.StatusConflict, err.Error(), "invalid_state")
+	case errors.Is(err, fx.ErrRateUnavailable):
+		response.Error(w, http.StatusServiceUnavailable, "Exchange rate is currently unavailable", "rate_unavailable")
+	default:
+		log.Printf("Error serving public invoice: %v", err)
+		response.InternalServerError(w)
+	}
+}
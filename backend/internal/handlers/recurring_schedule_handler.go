@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// RecurringScheduleHandler handles HTTP requests related to recurring invoice schedules
+type RecurringScheduleHandler struct {
+	service *services.RecurringScheduleService
+}
+
+// NewRecurringScheduleHandler creates a new recurring schedule handler
+func NewRecurringScheduleHandler(service *services.RecurringScheduleService) *RecurringScheduleHandler {
+	return &RecurringScheduleHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all recurring schedule routes
+func (h *RecurringScheduleHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/", h.ListSchedules)
+	r.Post("/", h.CreateSchedule)
+	r.Get("/{id}", h.GetSchedule)
+	r.Put("/{id}", h.UpdateSchedule)
+	r.Delete("/{id}", h.DeleteSchedule)
+	r.Get("/{id}/runs", h.ListRuns)
+
+	return r
+}
+
+// ListSchedules returns a page of recurring schedules
+func (h *RecurringScheduleHandler) ListSchedules(w http.ResponseWriter, r *http.Request) {
+	page, limit := parsePagination(r)
+
+	schedules, total, err := h.service.ListSchedules(page, limit)
+	if err != nil {
+		log.Printf("Error listing recurring schedules: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.New().
+		WithData(schedules).
+		WithPagination(int(total), page, limit).
+		Send(w, http.StatusOK)
+}
+
+// CreateSchedule creates a new recurring schedule
+func (h *RecurringScheduleHandler) CreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req models.CreateRecurringScheduleRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	schedule, err := h.service.CreateSchedule(req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	log.Printf("Recurring schedule %d (%s) created", schedule.ID, schedule.Name)
+
+	response.JSON(w, http.StatusCreated, schedule)
+}
+
+// GetSchedule retrieves a single recurring schedule
+func (h *RecurringScheduleHandler) GetSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid schedule ID")
+		return
+	}
+
+	schedule, err := h.service.GetSchedule(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, schedule)
+}
+
+// UpdateSchedule pauses, resumes or edits a recurring schedule
+func (h *RecurringScheduleHandler) UpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid schedule ID")
+		return
+	}
+
+	var req models.UpdateRecurringScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request payload: "+err.Error())
+		return
+	}
+
+	schedule, err := h.service.UpdateSchedule(id, req)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, schedule)
+}
+
+// DeleteSchedule removes a recurring schedule
+func (h *RecurringScheduleHandler) DeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid schedule ID")
+		return
+	}
+
+	if err := h.service.DeleteSchedule(id); err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.Success(w, http.StatusOK, "Recurring schedule deleted successfully")
+}
+
+// ListRuns returns the invoices materialized by a schedule
+func (h *RecurringScheduleHandler) ListRuns(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		response.BadRequest(w, "Invalid schedule ID")
+		return
+	}
+
+	runs, err := h.service.ListRuns(id)
+	if err != nil {
+		h.handleError(w, err)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, runs)
+}
+
+// handleError maps recurring schedule service errors onto HTTP responses
+func (h *RecurringScheduleHandler) handleError(w http.ResponseWriter, err error) {
+	var templateErr *services.TemplateValidationError
+
+	switch {
+	case errors.As(err, &templateErr):
+		sendValidationErrors(w, templateErr.Fields)
+	case errors.Is(err, services.ErrScheduleNotFound):
+		response.NotFound(w, "Recurring schedule not found")
+	case errors.Is(err, services.ErrTemplateNotFound):
+		response.NotFound(w, "Template invoice or draft not found")
+	case errors.Is(err, services.ErrNumberSequenceNotFound):
+		sendValidationErrors(w, map[string]string{"numberSequence": "Number sequence not found"})
+	default:
+		log.Printf("Recurring schedule error: %v", err)
+		response.InternalServerError(w)
+	}
+}
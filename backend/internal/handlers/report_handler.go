@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// ReportHandler handles HTTP requests for receivables and revenue analytics
+type ReportHandler struct {
+	service *services.ReportService
+}
+
+// NewReportHandler creates a new report handler
+func NewReportHandler(service *services.ReportService) *ReportHandler {
+	return &ReportHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all report routes. The app has no accounts, so
+// the receiving wallet is what separates one seller's invoices from another's:
+// every report requires ?receiver= and only covers invoices paid to it. The
+// other filters of the invoice list, e.g. ?customerId=, narrow it further; the
+// invoice status is fixed by the report and cannot be filtered on.
+func (h *ReportHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Get("/aging", h.GetAging)
+	r.Get("/revenue", h.GetRevenue)
+	r.Get("/days-to-pay", h.GetDaysToPay)
+	r.Get("/top-customers", h.GetTopCustomers)
+
+	return r
+}
+
+// GetAging returns the outstanding amount per currency in the current, 1-30,
+// 31-60, 61-90 and 90+ days past due buckets. ?asOf= moves the reference time;
+// a plain date means the end of that day.
+func (h *ReportHandler) GetAging(w http.ResponseWriter, r *http.Request) {
+	filter, validationErrors := parseReportFilter(r)
+
+	asOf := time.Now()
+	if v := r.URL.Query().Get("asOf"); v != "" {
+		t, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			validationErrors["asOf"] = "asOf must be YYYY-MM-DD or RFC 3339"
+		} else if dateOnly {
+			asOf = t.AddDate(0, 0, 1)
+		} else {
+			asOf = t
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	report, err := h.service.GetAging(filter, asOf)
+	if err != nil {
+		log.Printf("Error computing aging report: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, report)
+}
+
+// GetRevenue returns the revenue received per ?interval= (day, week or month)
+// and currency. Use paidFrom and paidTo to select the period covered.
+func (h *ReportHandler) GetRevenue(w http.ResponseWriter, r *http.Request) {
+	filter, validationErrors := parseReportFilter(r)
+
+	interval, err := models.ParseRevenueInterval(r.URL.Query().Get("interval"))
+	if err != nil {
+		validationErrors["interval"] = err.Error()
+	}
+
+	if len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	points, err := h.service.GetRevenue(filter, interval)
+	if err != nil {
+		log.Printf("Error computing revenue report: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, points)
+}
+
+// GetDaysToPay returns the average and median number of days between issuing and payment
+func (h *ReportHandler) GetDaysToPay(w http.ResponseWriter, r *http.Request) {
+	filter, validationErrors := parseReportFilter(r)
+	if len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	result, err := h.service.GetDaysToPay(filter)
+	if err != nil {
+		log.Printf("Error computing days to pay: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, result)
+}
+
+// GetTopCustomers returns the payers with the highest revenue, ?limit= entries
+// (default 10, at most 100). Amounts are not converted between currencies, so
+// pass ?currency= to rank within one.
+func (h *ReportHandler) GetTopCustomers(w http.ResponseWriter, r *http.Request) {
+	filter, validationErrors := parseReportFilter(r)
+
+	limit := 10
+	if v := r.URL.Query().Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l <= 0 || l > 100 {
+			validationErrors["limit"] = "limit must be between 1 and 100"
+		} else {
+			limit = l
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	customers, err := h.service.GetTopCustomers(filter, limit)
+	if err != nil {
+		log.Printf("Error computing top customers: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, customers)
+}
+
+// parseReportFilter reads the invoice filter of a report, which is scoped to
+// one receiving wallet and selects its own statuses
+func parseReportFilter(r *http.Request) (models.InvoiceFilter, map[string]string) {
+	filter, validationErrors := parseInvoiceFilter(r)
+	if filter.ReceiverAddr == "" {
+		validationErrors["receiver"] = "Reports are scoped to a receiving wallet; receiver is required"
+	}
+	if r.URL.Query().Get("status") != "" {
+		validationErrors["status"] = "Reports cannot be filtered by status"
+	}
+	return filter, validationErrors
+}
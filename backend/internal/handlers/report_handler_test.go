@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReportHandlerRequiresReceiver(t *testing.T) {
+	// Requests without a scope are rejected before the service is reached
+	router := NewReportHandler(nil).Routes()
+
+	for _, path := range []string{"/aging", "/revenue", "/days-to-pay", "/top-customers", "/top-customers?customerId=1"} {
+		req := httptest.NewRequest("GET", path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d without a receiver, got %d", path, http.StatusBadRequest, rr.Code)
+		}
+	}
+}
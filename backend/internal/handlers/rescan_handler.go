@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+	"github.com/ncapetillo/demo-fluida/internal/services"
+)
+
+// RescanHandler handles admin requests to re-scan on-chain history for missed payments
+type RescanHandler struct {
+	service *services.RescanService
+}
+
+// NewRescanHandler creates a new rescan handler
+func NewRescanHandler(service *services.RescanService) *RescanHandler {
+	return &RescanHandler{
+		service: service,
+	}
+}
+
+// Routes returns a router with all rescan routes
+func (h *RescanHandler) Routes() chi.Router {
+	r := chi.NewRouter()
+
+	r.Post("/", h.Rescan)
+
+	return r
+}
+
+// Rescan re-scans a receiver address or invoice over a slot or time range and
+// returns what was found. It runs until the scan is done or the client goes
+// away, so it is exempt from the request and write timeouts.
+func (h *RescanHandler) Rescan(w http.ResponseWriter, r *http.Request) {
+	var req models.RescanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if validationErrors := req.Validate(); len(validationErrors) > 0 {
+		sendValidationErrors(w, validationErrors)
+		return
+	}
+
+	// A long history may take longer to scan than the server's write timeout
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Could not lift write deadline for rescan: %v", err)
+	}
+
+	result, err := h.service.Rescan(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, services.ErrInvoiceNotFound) {
+			response.NotFound(w, "Invoice not found")
+			return
+		}
+		log.Printf("Error rescanning payments: %v", err)
+		response.InternalServerError(w)
+		return
+	}
+
+	response.JSON(w, http.StatusOK, result)
+}
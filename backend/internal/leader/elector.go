@@ -0,0 +1,216 @@
+// Package leader elects one process among the API replicas to run background
+// work, such as the payment watcher and schedulers, using PostgreSQL advisory
+// locks
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// How often a follower tries to take the lock
+	defaultRetryInterval = 15 * time.Second
+
+	// How often the leader renews its lease by using its lock session
+	defaultRenewInterval = 5 * time.Second
+
+	// How long a renewal may take before the leader steps down
+	renewTimeout = 3 * time.Second
+)
+
+// Elector runs a function in exactly one process at a time. The process that
+// holds a session-level advisory lock on a dedicated connection is the
+// leader. The lock is a lease: the leader renews it by using the session every
+// renew interval and the session is given an idle timeout of a few intervals,
+// so if the leader dies or loses the database, PostgreSQL ends the session,
+// the lock is released and a follower takes over on its next attempt.
+type Elector struct {
+	db            *sql.DB
+	name          string
+	key           int64
+	retryInterval time.Duration
+	renewInterval time.Duration
+	lead          func(ctx context.Context)
+	ctx           context.Context
+	cancel        context.CancelFunc
+	done          chan struct{}
+
+	mu      sync.Mutex
+	leading bool
+}
+
+// NewElector creates an elector for the lock called name. lead is called when
+// this process becomes the leader and must return once its context is done,
+// which happens when leadership is lost or the elector is stopped. Intervals
+// can be tuned with LEADER_RETRY_INTERVAL and LEADER_RENEW_INTERVAL.
+func NewElector(db *sql.DB, name string, lead func(ctx context.Context)) *Elector {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &Elector{
+		db:            db,
+		name:          name,
+		key:           lockKey(name),
+		retryInterval: durationFromEnv("LEADER_RETRY_INTERVAL", defaultRetryInterval),
+		renewInterval: durationFromEnv("LEADER_RENEW_INTERVAL", defaultRenewInterval),
+		lead:          lead,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+	}
+}
+
+// Start begins campaigning for leadership in a background goroutine
+func (e *Elector) Start() {
+	log.Printf("Campaigning for leadership of %s", e.name)
+
+	go func() {
+		defer close(e.done)
+
+		for {
+			if err := e.campaign(); err != nil {
+				log.Printf("Leader election for %s: %v", e.name, err)
+			}
+
+			select {
+			case <-e.ctx.Done():
+				return
+			case <-time.After(e.retryInterval):
+			}
+		}
+	}()
+}
+
+// Stop stops leading, waits for the lead function to return and releases the lock
+func (e *Elector) Stop() {
+	e.cancel()
+	<-e.done
+	log.Printf("Leader election for %s stopped", e.name)
+}
+
+// IsLeader reports whether this process currently holds the lock
+func (e *Elector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leading
+}
+
+// campaign tries to take the lock once and, if it gets it, leads until the
+// lease cannot be renewed or the elector is stopped
+func (e *Elector) campaign() error {
+	conn, err := e.db.Conn(e.ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get a connection: %w", err)
+	}
+	// The lock lives as long as the session, so the connection is never
+	// returned to the pool where it would keep holding the lock
+	defer discard(conn)
+
+	var acquired bool
+	if err := conn.QueryRowContext(e.ctx, "SELECT pg_try_advisory_lock($1)", e.key).Scan(&acquired); err != nil {
+		return fmt.Errorf("failed to try the lock: %w", err)
+	}
+	if !acquired {
+		return nil
+	}
+
+	// A leader that stops renewing is disconnected by the server. Servers
+	// before PostgreSQL 14 do not know the setting and rely on TCP timeouts.
+	idleTimeout := 3 * (e.renewInterval + renewTimeout)
+	if _, err := conn.ExecContext(e.ctx, fmt.Sprintf("SET idle_session_timeout = %d", idleTimeout.Milliseconds())); err != nil {
+		log.Printf("Leader lease for %s relies on TCP timeouts: %v", e.name, err)
+	}
+
+	log.Printf("This process is now the leader for %s", e.name)
+	e.setLeading(true)
+	defer e.setLeading(false)
+
+	leadCtx, stopLeading := context.WithCancel(e.ctx)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		e.lead(leadCtx)
+	}()
+
+	ticker := time.NewTicker(e.renewInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			stopLeading()
+			<-finished
+			log.Printf("Stepped down as leader for %s", e.name)
+			return nil
+		case <-finished:
+			stopLeading()
+			return fmt.Errorf("lead function returned while still leader")
+		case <-ticker.C:
+			if err := e.renew(conn); err != nil {
+				stopLeading()
+				<-finished
+				return fmt.Errorf("lost leadership: %w", err)
+			}
+		}
+	}
+}
+
+// renew uses the lock session, which keeps it from being timed out as idle
+// and confirms the lock is still held
+func (e *Elector) renew(conn *sql.Conn) error {
+	ctx, cancel := context.WithTimeout(e.ctx, renewTimeout)
+	defer cancel()
+
+	var held bool
+	err := conn.QueryRowContext(ctx,
+		`SELECT EXISTS (
+			SELECT 1 FROM pg_locks
+			WHERE locktype = 'advisory' AND pid = pg_backend_pid() AND granted AND objsubid = 1
+				AND classid = (($1::bigint >> 32) & 4294967295)::oid
+				AND objid = ($1::bigint & 4294967295)::oid
+		)`,
+		e.key).Scan(&held)
+	if err != nil {
+		return fmt.Errorf("failed to renew lease: %w", err)
+	}
+	if !held {
+		return fmt.Errorf("advisory lock is no longer held")
+	}
+	return nil
+}
+
+func (e *Elector) setLeading(leading bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.leading = leading
+}
+
+// discard closes the connection's session instead of returning it to the pool
+func discard(conn *sql.Conn) {
+	_ = conn.Raw(func(interface{}) error {
+		return driver.ErrBadConn
+	})
+	_ = conn.Close()
+}
+
+// lockKey derives the 64-bit advisory lock key from the lock name
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// durationFromEnv reads a duration such as "10s" from the environment
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	if v, err := time.ParseDuration(os.Getenv(key)); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,136 @@
+package leader
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/testdb"
+)
+
+// newTestElector creates an elector with short intervals on its own
+// connection pool, as if it ran in a process of its own
+func newTestElector(t *testing.T, name string, lead func(ctx context.Context)) *Elector {
+	t.Helper()
+
+	sqlDB, err := testdb.Connect(t).DB()
+	if err != nil {
+		t.Fatalf("Failed to get the connection pool: %v", err)
+	}
+
+	e := NewElector(sqlDB, name, lead)
+	e.retryInterval = 50 * time.Millisecond
+	e.renewInterval = 50 * time.Millisecond
+	return e
+}
+
+// eventually fails the test unless cond becomes true within timeout
+func eventually(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting until %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// uniqueLockName keeps concurrent test runs from competing for the same lock
+func uniqueLockName(t *testing.T) string {
+	return fmt.Sprintf("test:%s:%d", t.Name(), time.Now().UnixNano())
+}
+
+func TestElectorsCompeteForTheSameLock(t *testing.T) {
+	name := uniqueLockName(t)
+
+	var leaders int32
+	lead := func(ctx context.Context) {
+		if n := atomic.AddInt32(&leaders, 1); n > 1 {
+			t.Errorf("Expected a single leader, %d are leading", n)
+		}
+		<-ctx.Done()
+		atomic.AddInt32(&leaders, -1)
+	}
+
+	first := newTestElector(t, name, lead)
+	second := newTestElector(t, name, lead)
+	first.Start()
+	second.Start()
+	// Stopping an elector twice is harmless
+	defer first.Stop()
+	defer second.Stop()
+
+	eventually(t, 5*time.Second, "one elector leads", func() bool {
+		return first.IsLeader() || second.IsLeader()
+	})
+
+	// Several retries later there is still exactly one leader
+	time.Sleep(300 * time.Millisecond)
+	if first.IsLeader() == second.IsLeader() {
+		t.Fatalf("Expected exactly one leader, first=%v second=%v", first.IsLeader(), second.IsLeader())
+	}
+
+	leader, follower := first, second
+	if second.IsLeader() {
+		leader, follower = second, first
+	}
+
+	// The follower takes over once the leader steps down
+	leader.Stop()
+	eventually(t, 5*time.Second, "the follower takes over", follower.IsLeader)
+}
+
+func TestElectorLosesAndRetakesLockWhenConnectionIsKilled(t *testing.T) {
+	name := uniqueLockName(t)
+
+	var terms int32
+	lost := make(chan struct{}, 1)
+	e := newTestElector(t, name, func(ctx context.Context) {
+		atomic.AddInt32(&terms, 1)
+		<-ctx.Done()
+		select {
+		case lost <- struct{}{}:
+		default:
+		}
+	})
+	e.Start()
+	defer e.Stop()
+
+	eventually(t, 5*time.Second, "the elector leads", e.IsLeader)
+
+	// Kill the session holding the lock, as a network failure or a database
+	// restart would
+	admin, err := testdb.Connect(t).DB()
+	if err != nil {
+		t.Fatalf("Failed to get the connection pool: %v", err)
+	}
+	if err := terminateLockHolder(admin, e.key); err != nil {
+		t.Fatalf("Failed to terminate the leader's session: %v", err)
+	}
+
+	select {
+	case <-lost:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the lead function to be stopped once the lock was lost")
+	}
+
+	eventually(t, 5*time.Second, "the elector leads again", func() bool {
+		return atomic.LoadInt32(&terms) >= 2 && e.IsLeader()
+	})
+}
+
+// terminateLockHolder ends the session holding the advisory lock with key
+func terminateLockHolder(db *sql.DB, key int64) error {
+	var terminated bool
+	return db.QueryRow(
+		`SELECT pg_terminate_backend(pid) FROM pg_locks
+		WHERE locktype = 'advisory' AND granted AND objsubid = 1
+			AND classid = (($1::bigint >> 32) & 4294967295)::oid
+			AND objid = ($1::bigint & 4294967295)::oid`,
+		key).Scan(&terminated)
+}
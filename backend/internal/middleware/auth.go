@@ -5,10 +5,26 @@ import (
 	"crypto/subtle"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/ncapetillo/demo-fluida/internal/response"
 )
 
+// PublicPathPrefixes are paths served without authentication because they are
+// called by payers, such as the payment page and Solana Pay wallets, rather
+// than by the invoice issuer
+var PublicPathPrefixes = []string{"/api/v1/pay/", "/api/v1/public/"}
+
+// IsPublicPath reports whether a request path starts with one of PublicPathPrefixes
+func IsPublicPath(requestPath string) bool {
+	for _, prefix := range PublicPathPrefixes {
+		if strings.HasPrefix(requestPath, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
 // BasicAuth implements a simple Basic Auth middleware
 func BasicAuth(next http.Handler) http.Handler {
 	// Get credentials from environment, or use defaults if not set
@@ -22,7 +38,7 @@ func BasicAuth(next http.Handler) http.Handler {
 	
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		// Skip auth for health check and public APIs
-		if r.URL.Path == "/health" || r.URL.Path == "/api/health" {
+		if r.URL.Path == "/health" || r.URL.Path == "/api/health" || IsPublicPath(r.URL.Path) {
 			next.ServeHTTP(w, r)
 			return
 		}
@@ -59,6 +59,42 @@ func TestBasicAuth(t *testing.T) {
 			auth:       "",
 			wantStatus: http.StatusOK,
 		},
+		{
+			name:       "Solana Pay requests bypass auth",
+			path:       "/api/v1/pay/some-link-token",
+			auth:       "",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Public invoice views bypass auth",
+			path:       "/api/v1/public/invoices/some-link-token",
+			auth:       "",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Public invoice event streams bypass auth",
+			path:       "/api/v1/public/invoices/some-link-token/events",
+			auth:       "",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Public checkout pages bypass auth",
+			path:       "/api/v1/public/checkout/some-slug",
+			auth:       "",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "Checkout links need auth",
+			path:       "/api/v1/checkout-links",
+			auth:       "",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "Full invoices by link token need auth",
+			path:       "/api/v1/invoices/some-link-token",
+			auth:       "",
+			wantStatus: http.StatusUnauthorized,
+		},
 		{
 			name:       "Valid auth credentials",
 			path:       "/api/invoices",
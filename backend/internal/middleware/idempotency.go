@@ -0,0 +1,162 @@
+// Package middleware provides HTTP middleware functions
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/response"
+)
+
+// IdempotencyKeyHeader is the request header clients use to make a POST safe to retry
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayHeader is set on responses replayed from a stored result
+const IdempotentReplayHeader = "Idempotent-Replayed"
+
+// idempotencyLease is how long a key is reserved for a request still being
+// processed. It is far longer than any request may run, so only the
+// reservation of a request whose process crashed expires and can be taken
+// over by a retry.
+const idempotencyLease = 15 * time.Minute
+
+// IdempotencyStore persists idempotency keys and the responses they produced
+type IdempotencyStore interface {
+	Reserve(ctx context.Context, key, requestHash string, lease time.Duration) (*models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key, token string, statusCode int, contentType string, body []byte, ttl time.Duration) error
+	Release(ctx context.Context, key, token string) error
+}
+
+// Idempotency makes POST requests carrying an Idempotency-Key header safe to
+// retry. The first request is processed and, if it succeeds, its response is
+// stored for ttl. Retries with the same key and body get the stored response
+// replayed; reusing the key with a different body is rejected with 422.
+// Failed requests, panics included, release the key so the client can retry them.
+func Idempotency(store IdempotencyStore, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if len(key) > 255 {
+				response.BadRequest(w, "Idempotency-Key must be at most 255 characters")
+				return
+			}
+
+			body, err := io.ReadAll(r.Body)
+			if err != nil {
+				response.BadRequest(w, "Failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			requestHash := hashRequest(r, body)
+
+			record, reserved, err := store.Reserve(r.Context(), key, requestHash, idempotencyLease)
+			if err != nil {
+				log.Printf("Idempotency key lookup failed: %v", err)
+				response.Error(w, http.StatusServiceUnavailable,
+					"Could not verify Idempotency-Key, please retry", "idempotency_unavailable")
+				return
+			}
+
+			if !reserved {
+				switch {
+				case record.RequestHash != requestHash:
+					response.Error(w, http.StatusUnprocessableEntity,
+						"Idempotency-Key was already used with a different request", "idempotency_key_reused")
+				case record.InProgress():
+					response.Error(w, http.StatusConflict,
+						"A request with this Idempotency-Key is still being processed", "request_in_progress")
+				default:
+					if record.ContentType != "" {
+						w.Header().Set("Content-Type", record.ContentType)
+					}
+					w.Header().Set(IdempotentReplayHeader, "true")
+					w.WriteHeader(record.StatusCode)
+					w.Write(record.ResponseBody)
+				}
+				return
+			}
+
+			// A panicking handler must not leave the key reserved until its lease
+			// runs out; the panic is passed on to the recoverer
+			defer func() {
+				if p := recover(); p != nil {
+					releaseIdempotencyKey(store, record)
+					panic(p)
+				}
+			}()
+
+			recorder := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			if recorder.status < 200 || recorder.status >= 300 {
+				releaseIdempotencyKey(store, record)
+				return
+			}
+
+			// Store the outcome with a fresh context; the request may already be cancelled
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := store.Complete(ctx, key, record.Token, recorder.status, w.Header().Get("Content-Type"), recorder.body.Bytes(), ttl); err != nil {
+				log.Printf("Failed to store result for Idempotency-Key %s: %v", key, err)
+			}
+		})
+	}
+}
+
+// releaseIdempotencyKey forgets the reservation of a request that failed
+func releaseIdempotencyKey(store IdempotencyStore, reservation *models.IdempotencyKey) {
+	// Use a fresh context; the request may already be cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := store.Release(ctx, reservation.Key, reservation.Token); err != nil {
+		log.Printf("Failed to release Idempotency-Key %s: %v", reservation.Key, err)
+	}
+}
+
+// hashRequest fingerprints the parts of a request that must match on retry
+func hashRequest(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method))
+	h.Write([]byte{0})
+	h.Write([]byte(r.URL.Path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// responseRecorder passes the response through while keeping a copy of it
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (rr *responseRecorder) WriteHeader(status int) {
+	if !rr.wroteHeader {
+		rr.status = status
+		rr.wroteHeader = true
+	}
+	rr.ResponseWriter.WriteHeader(status)
+}
+
+func (rr *responseRecorder) Write(b []byte) (int, error) {
+	rr.wroteHeader = true
+	rr.body.Write(b)
+	return rr.ResponseWriter.Write(b)
+}
@@ -0,0 +1,204 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// memoryIdempotencyStore is an in-memory IdempotencyStore for tests
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]*models.IdempotencyKey
+	tokens  int
+}
+
+func newMemoryIdempotencyStore() *memoryIdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]*models.IdempotencyKey)}
+}
+
+func (s *memoryIdempotencyStore) Reserve(ctx context.Context, key, requestHash string, lease time.Duration) (*models.IdempotencyKey, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.records[key]; ok && existing.ExpiresAt.After(time.Now()) {
+		copied := *existing
+		return &copied, false, nil
+	}
+
+	s.tokens++
+	record := &models.IdempotencyKey{Key: key, Token: fmt.Sprint(s.tokens), RequestHash: requestHash, ExpiresAt: time.Now().Add(lease)}
+	s.records[key] = record
+	copied := *record
+	return &copied, true, nil
+}
+
+func (s *memoryIdempotencyStore) Complete(ctx context.Context, key, token string, statusCode int, contentType string, body []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok || record.Token != token || !record.InProgress() {
+		return errors.New("idempotency key reservation was taken over")
+	}
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.ResponseBody = append([]byte(nil), body...)
+	record.ExpiresAt = time.Now().Add(ttl)
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(ctx context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.Token == token && record.InProgress() {
+		delete(s.records, key)
+	}
+	return nil
+}
+
+func TestIdempotency(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+
+	// Handler that "creates" a resource and counts how often it ran
+	calls := 0
+	handler := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if strings.Contains(r.URL.Path, "fail") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"data":{"id":1}}`))
+	}))
+
+	send := func(path, key, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// First request is processed
+	first := send("/invoices", "key-1", `{"amount":100}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", http.StatusCreated, first.Code)
+	}
+
+	// Retry with the same key and body is replayed without calling the handler
+	retry := send("/invoices", "key-1", `{"amount":100}`)
+	if retry.Code != http.StatusCreated {
+		t.Errorf("Expected replayed status %d, got %d", http.StatusCreated, retry.Code)
+	}
+	if retry.Body.String() != first.Body.String() {
+		t.Errorf("Expected replayed body %q, got %q", first.Body.String(), retry.Body.String())
+	}
+	if retry.Header().Get(IdempotentReplayHeader) != "true" {
+		t.Error("Expected replayed response to carry the replay header")
+	}
+	if calls != 1 {
+		t.Errorf("Expected handler to be called once, got %d", calls)
+	}
+
+	// Same key with a different body is rejected
+	reused := send("/invoices", "key-1", `{"amount":200}`)
+	if reused.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected status %d for reused key, got %d", http.StatusUnprocessableEntity, reused.Code)
+	}
+
+	// Failed requests release the key so they can be retried
+	send("/fail", "key-2", `{}`)
+	send("/fail", "key-2", `{}`)
+	if calls != 3 {
+		t.Errorf("Expected failed requests to be retried, handler called %d times", calls)
+	}
+
+	// Requests without a key are not affected
+	send("/invoices", "", `{"amount":100}`)
+	send("/invoices", "", `{"amount":100}`)
+	if calls != 5 {
+		t.Errorf("Expected requests without a key to pass through, handler called %d times", calls)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+	store.records["busy"] = &models.IdempotencyKey{Key: "busy", ExpiresAt: time.Now().Add(time.Minute)}
+
+	handler := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Handler should not run while the original request is in progress")
+	}))
+
+	req, _ := http.NewRequest("POST", "/invoices", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, "busy")
+	store.records["busy"].RequestHash = hashRequest(req, []byte(`{}`))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("Expected status %d, got %d", http.StatusConflict, rr.Code)
+	}
+}
+
+func TestIdempotencyTakesOverExpiredReservation(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+
+	// The process handling the original request crashed and its lease ran out
+	store.records["crashed"] = &models.IdempotencyKey{Key: "crashed", ExpiresAt: time.Now().Add(-time.Second)}
+
+	calls := 0
+	handler := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	req, _ := http.NewRequest("POST", "/invoices", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, "crashed")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusCreated || calls != 1 {
+		t.Errorf("Expected the retry to be processed, got status %d after %d calls", rr.Code, calls)
+	}
+	if record := store.records["crashed"]; record == nil || record.ExpiresAt.Before(time.Now().Add(59*time.Minute)) {
+		t.Error("Expected the completed response to be kept for the TTL")
+	}
+}
+
+func TestIdempotencyReleasesKeyOnPanic(t *testing.T) {
+	store := newMemoryIdempotencyStore()
+
+	handler := Idempotency(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	req, _ := http.NewRequest("POST", "/invoices", strings.NewReader(`{}`))
+	req.Header.Set(IdempotencyKeyHeader, "panics")
+
+	func() {
+		defer func() {
+			if p := recover(); p != "boom" {
+				t.Errorf("Expected the panic to be passed on, got %v", p)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	if _, ok := store.records["panics"]; ok {
+		t.Error("Expected the key to be released after the handler panicked")
+	}
+}
@@ -3,7 +3,9 @@ package middleware
 
 import (
 	"log"
+	"mime"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
@@ -62,22 +64,43 @@ func RateLimiter(next http.Handler) http.Handler {
 	})
 }
 
-// ValidateContentType ensures the request content type is application/json for POST/PUT/PATCH
-func ValidateContentType(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Only check content type for methods that typically include a body
-		if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
-			contentType := r.Header.Get("Content-Type")
-			
-			// Check if Content-Type is application/json
-			if contentType != "application/json" {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusUnsupportedMediaType)
-				w.Write([]byte(`{"error":{"message":"Content-Type must be application/json","code":"unsupported_media_type"}}`))
-				return
+// ValidateContentType ensures the request content type is application/json for POST/PUT/PATCH.
+// text/csv is also accepted on the routes whose path ends in one of csvSuffixes,
+// such as the invoice batch import.
+func ValidateContentType(csvSuffixes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Only check content type for methods that typically include a body
+			if r.Method == http.MethodPost || r.Method == http.MethodPut || r.Method == http.MethodPatch {
+				// Parameters such as charset=utf-8 are allowed
+				mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+				
+				if mediaType == "text/csv" && acceptsCSV(r.URL.Path, csvSuffixes) {
+					next.ServeHTTP(w, r)
+					return
+				}
+				
+				// Check if Content-Type is application/json
+				if mediaType != "application/json" {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(http.StatusUnsupportedMediaType)
+					w.Write([]byte(`{"error":{"message":"Content-Type must be application/json","code":"unsupported_media_type"}}`))
+					return
+				}
 			}
+			
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// acceptsCSV reports whether the path ends in one of the CSV upload suffixes
+func acceptsCSV(path string, csvSuffixes []string) bool {
+	path = strings.TrimSuffix(path, "/")
+	for _, suffix := range csvSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
 		}
-		
-		next.ServeHTTP(w, r)
-	})
+	}
+	return false
 }
@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestValidateContentTypeAllowsCSVOnlyOnUploadPaths(t *testing.T) {
+	handler := ValidateContentType("/invoices/batch")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		path        string
+		contentType string
+		wantStatus  int
+	}{
+		{"/api/v1/invoices", "application/json; charset=utf-8", http.StatusOK},
+		{"/api/v1/invoices/batch", "text/csv", http.StatusOK},
+		{"/api/v1/invoices/batch/", "text/csv; charset=utf-8", http.StatusOK},
+		{"/api/v1/invoices", "text/csv", http.StatusUnsupportedMediaType},
+		{"/api/v1/customers", "text/csv", http.StatusUnsupportedMediaType},
+		{"/api/v1/invoices/batch", "text/plain", http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.contentType, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", tt.path, nil)
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("Expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+		})
+	}
+}
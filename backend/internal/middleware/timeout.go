@@ -0,0 +1,30 @@
+// Package middleware provides HTTP middleware functions
+package middleware
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Timeout cancels the request context after timeout, like chi's Timeout, except
+// for paths ending in one of the given suffixes. Those are long-running streams,
+// such as exports, that must not be cut off mid-response.
+func Timeout(timeout time.Duration, streamingSuffixes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		limited := middleware.Timeout(timeout)(next)
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, suffix := range streamingSuffixes {
+				if strings.HasSuffix(r.URL.Path, suffix) {
+					next.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			limited.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CheckoutSessionStatus is where a checkout session stands
+type CheckoutSessionStatus string
+
+const (
+	// CheckoutSessionOpen means the session's invoice is awaiting payment
+	CheckoutSessionOpen CheckoutSessionStatus = "open"
+	// CheckoutSessionCompleted means the session's invoice was paid
+	CheckoutSessionCompleted CheckoutSessionStatus = "completed"
+	// CheckoutSessionExpired means the session ran out before its invoice was paid
+	CheckoutSessionExpired CheckoutSessionStatus = "expired"
+)
+
+// CheckoutLink is a reusable link, such as a "pay with USDC" button on a
+// pricing page, that anyone can pay without an invoice being created for them
+// first. Every visitor starts a CheckoutSession, which materializes an invoice
+// to the visitor for Amount times the chosen quantity, or for the amount the
+// visitor enters when Amount is not set. A link with MaxUses is paid at most
+// that many times.
+type CheckoutLink struct {
+	ID          int    `json:"id" gorm:"primaryKey;autoIncrement"`
+	Slug        string `json:"slug" gorm:"uniqueIndex:idx_checkout_link_slug;not null;type:varchar(100)"`
+	Name        string `json:"name" gorm:"not null;type:varchar(100)"`
+	Description string `json:"description" gorm:"type:text"`
+	// Amount is the price of one unit; without it the visitor enters the
+	// amount, between MinAmount and MaxAmount when they are set
+	Amount          *float64 `json:"amount,omitempty" gorm:"type:decimal(20,9)"`
+	MinAmount       *float64 `json:"minAmount,omitempty" gorm:"type:decimal(20,9)"`
+	MaxAmount       *float64 `json:"maxAmount,omitempty" gorm:"type:decimal(20,9)"`
+	Currency        string   `json:"currency" gorm:"not null;default:USDC;type:varchar(10)"`
+	SettlementToken string   `json:"settlementToken,omitempty" gorm:"type:varchar(10)"`
+	RateLock        RateLock `json:"rateLock,omitempty" gorm:"type:varchar(10)"`
+	// MaxQuantity lets the visitor buy up to that many units; 0 means one
+	MaxQuantity  int        `json:"maxQuantity" gorm:"not null;default:0"`
+	MaxUses      *int       `json:"maxUses,omitempty"`
+	ReceiverAddr string     `json:"receiverAddr" gorm:"not null;type:varchar(100)"`
+	Seller       Person     `json:"seller" gorm:"type:jsonb;serializer:json"`
+	Active       bool       `json:"active" gorm:"not null;default:true"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt    time.Time  `json:"updatedAt" gorm:"autoUpdateTime"`
+	// Uses counts the link's completed sessions when it is returned; not stored
+	Uses int `json:"uses" gorm:"-"`
+}
+
+// TableName overrides the table name
+func (CheckoutLink) TableName() string {
+	return "checkout_link"
+}
+
+// BeforeCreate generates the link's slug unless one was given
+func (l *CheckoutLink) BeforeCreate(tx *gorm.DB) error {
+	if l.Slug == "" {
+		l.Slug = uuid.New().String()
+	}
+	if l.Currency == "" {
+		l.Currency = DefaultCurrency
+	}
+	return nil
+}
+
+// OpenAt reports whether visitors can start sessions on the link at now,
+// not counting its MaxUses
+func (l CheckoutLink) OpenAt(now time.Time) bool {
+	return l.Active && (l.ExpiresAt == nil || now.Before(*l.ExpiresAt))
+}
+
+// CheckoutSession is one visitor's checkout on a CheckoutLink and the invoice
+// it materialized. The payment watcher completes the session when it marks
+// the invoice paid.
+type CheckoutSession struct {
+	ID             int        `json:"id" gorm:"primaryKey;autoIncrement"`
+	CheckoutLinkID int        `json:"checkoutLinkId" gorm:"not null;index:idx_checkout_session_link"`
+	InvoiceID      int        `json:"invoiceId" gorm:"not null;uniqueIndex:idx_checkout_session_invoice"`
+	Quantity       int        `json:"quantity" gorm:"not null;default:1"`
+	Amount         float64    `json:"amount" gorm:"not null;type:decimal(20,9)"`
+	Payer          Person     `json:"payer" gorm:"type:jsonb;serializer:json"`
+	ExpiresAt      time.Time  `json:"expiresAt" gorm:"not null"`
+	CompletedAt    *time.Time `json:"completedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	// Status is derived from CompletedAt and ExpiresAt when the session is returned; not stored
+	Status CheckoutSessionStatus `json:"status" gorm:"-"`
+}
+
+// TableName overrides the table name
+func (CheckoutSession) TableName() string {
+	return "checkout_session"
+}
+
+// StatusAt returns where the session stands at now. A session paid after it
+// expired still completes.
+func (s CheckoutSession) StatusAt(now time.Time) CheckoutSessionStatus {
+	switch {
+	case s.CompletedAt != nil:
+		return CheckoutSessionCompleted
+	case !now.Before(s.ExpiresAt):
+		return CheckoutSessionExpired
+	default:
+		return CheckoutSessionOpen
+	}
+}
+
+// PublicCheckoutLink is the view of a checkout link served to visitors
+type PublicCheckoutLink struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Amount      *float64 `json:"amount,omitempty"`
+	MinAmount   *float64 `json:"minAmount,omitempty"`
+	MaxAmount   *float64 `json:"maxAmount,omitempty"`
+	Currency    string   `json:"currency"`
+	MaxQuantity int      `json:"maxQuantity"`
+	Seller      string   `json:"seller"`
+}
+
+// NewPublicCheckoutLink returns the public view of a checkout link
+func NewPublicCheckoutLink(link CheckoutLink) PublicCheckoutLink {
+	return PublicCheckoutLink{
+		Name:        link.Name,
+		Description: link.Description,
+		Amount:      link.Amount,
+		MinAmount:   link.MinAmount,
+		MaxAmount:   link.MaxAmount,
+		Currency:    link.Currency,
+		MaxQuantity: link.MaxQuantity,
+		Seller:      link.Seller.Name,
+	}
+}
+
+// PublicCheckoutSession is a started checkout session as returned to the
+// visitor. The visitor pays the invoice through the payment page of LinkToken
+// before ExpiresAt.
+type PublicCheckoutSession struct {
+	LinkToken string        `json:"linkToken"`
+	Quantity  int           `json:"quantity"`
+	ExpiresAt time.Time     `json:"expiresAt"`
+	Invoice   PublicInvoice `json:"invoice"`
+}
+
+// CreateCheckoutLinkRequest represents the data required to create a checkout link
+type CreateCheckoutLinkRequest struct {
+	Name            string     `json:"name"`
+	Description     string     `json:"description"`
+	Amount          *float64   `json:"amount"`
+	MinAmount       *float64   `json:"minAmount"`
+	MaxAmount       *float64   `json:"maxAmount"`
+	Currency        string     `json:"currency"`
+	SettlementToken string     `json:"settlementToken"`
+	RateLock        RateLock   `json:"rateLock"`
+	MaxQuantity     int        `json:"maxQuantity"`
+	MaxUses         *int       `json:"maxUses"`
+	ReceiverAddr    string     `json:"receiverAddr"`
+	Seller          Person     `json:"seller"`
+	ExpiresAt       *time.Time `json:"expiresAt"`
+}
+
+// Validate performs validation on the CreateCheckoutLinkRequest
+func (r *CreateCheckoutLinkRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	validateRequired("name", r.Name, errors)
+	validateMaxLength("name", r.Name, 100, errors)
+
+	if r.Amount != nil {
+		if *r.Amount <= 0 {
+			errors["amount"] = "Amount must be greater than zero"
+		}
+		if r.MinAmount != nil || r.MaxAmount != nil {
+			errors["amount"] = "Provide either a fixed amount or amount bounds, not both"
+		}
+	}
+	if r.MinAmount != nil && *r.MinAmount <= 0 {
+		errors["minAmount"] = "Minimum amount must be greater than zero"
+	}
+	if r.MinAmount != nil && r.MaxAmount != nil && *r.MinAmount > *r.MaxAmount {
+		errors["maxAmount"] = "Maximum amount must not be less than the minimum amount"
+	}
+
+	if r.Currency != "" && !IsSupportedCurrency(r.Currency) {
+		errors["currency"] = "Currency must be one of USD, EUR, MXN, USDC, EURC or SOL"
+	}
+	if r.SettlementToken != "" {
+		if _, ok := SettlementTokens[strings.ToUpper(r.SettlementToken)]; !ok {
+			errors["settlementToken"] = "Settlement token must be USDC, EURC or SOL"
+		}
+	}
+	if r.RateLock != "" && r.RateLock != RateLockIssue && r.RateLock != RateLockPayment {
+		errors["rateLock"] = "Rate lock must be issue or payment"
+	}
+
+	if r.MaxQuantity < 0 || r.MaxQuantity > 1000 {
+		errors["maxQuantity"] = "Maximum quantity must be between 0 and 1000"
+	}
+	if r.MaxUses != nil && *r.MaxUses <= 0 {
+		errors["maxUses"] = "Maximum uses must be greater than zero"
+	}
+
+	if r.ReceiverAddr == "" {
+		errors["receiverAddr"] = "Receiver wallet address is required"
+	} else {
+		validateSolanaAddress("receiverAddr", r.ReceiverAddr, errors)
+	}
+
+	if r.Seller.Name == "" {
+		errors["seller.name"] = "Seller name is required"
+	}
+	if r.Seller.Email == "" {
+		errors["seller.email"] = "Seller email is required"
+	} else {
+		validateEmail("seller.email", r.Seller.Email, errors)
+	}
+
+	if r.ExpiresAt != nil {
+		validateFutureDate("expiresAt", *r.ExpiresAt, errors)
+	}
+
+	return errors
+}
+
+// UpdateCheckoutLinkRequest represents the mutable fields of a checkout link
+type UpdateCheckoutLinkRequest struct {
+	Active    *bool      `json:"active"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+	MaxUses   *int       `json:"maxUses"`
+}
+
+// Validate performs validation on the UpdateCheckoutLinkRequest
+func (r *UpdateCheckoutLinkRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if r.ExpiresAt != nil {
+		validateFutureDate("expiresAt", *r.ExpiresAt, errors)
+	}
+	if r.MaxUses != nil && *r.MaxUses <= 0 {
+		errors["maxUses"] = "Maximum uses must be greater than zero"
+	}
+
+	return errors
+}
+
+// StartCheckoutSessionRequest is a visitor starting to pay a checkout link.
+// Amount is only given on links without a fixed amount; Quantity defaults to one.
+type StartCheckoutSessionRequest struct {
+	Amount   *float64 `json:"amount"`
+	Quantity int      `json:"quantity"`
+	Name     string   `json:"name"`
+	Email    string   `json:"email"`
+}
+
+// Validate performs validation on the StartCheckoutSessionRequest. Whether
+// the amount and quantity suit the link is checked with ValidateFor.
+func (r *StartCheckoutSessionRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	validateRequired("name", r.Name, errors)
+	validateMaxLength("name", r.Name, 100, errors)
+	validateRequired("email", r.Email, errors)
+	validateEmail("email", r.Email, errors)
+
+	if r.Quantity < 0 {
+		errors["quantity"] = "Quantity cannot be negative"
+	}
+
+	return errors
+}
+
+// ValidateFor checks the amount and quantity against the checkout link
+func (r *StartCheckoutSessionRequest) ValidateFor(link CheckoutLink) map[string]string {
+	errors := make(map[string]string)
+
+	switch {
+	case link.Amount != nil && r.Amount != nil:
+		errors["amount"] = "This link has a fixed amount"
+	case link.Amount == nil && r.Amount == nil:
+		errors["amount"] = "Amount is required"
+	case link.Amount == nil && *r.Amount <= 0:
+		errors["amount"] = "Amount must be greater than zero"
+	case link.Amount == nil && link.MinAmount != nil && *r.Amount < *link.MinAmount:
+		validateMinValue("amount", *r.Amount, *link.MinAmount, errors)
+	case link.Amount == nil && link.MaxAmount != nil && *r.Amount > *link.MaxAmount:
+		validateMaxValue("amount", *r.Amount, *link.MaxAmount, errors)
+	}
+
+	maxQuantity := link.MaxQuantity
+	if maxQuantity == 0 {
+		maxQuantity = 1
+	}
+	if r.Quantity > maxQuantity {
+		validateMaxValue("quantity", float64(r.Quantity), float64(maxQuantity), errors)
+	}
+
+	return errors
+}
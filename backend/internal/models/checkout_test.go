@@ -0,0 +1,89 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCheckoutLinkOpenAt(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	cases := []struct {
+		name string
+		link CheckoutLink
+		want bool
+	}{
+		{"active", CheckoutLink{Active: true}, true},
+		{"expires later", CheckoutLink{Active: true, ExpiresAt: &later}, true},
+		{"expired", CheckoutLink{Active: true, ExpiresAt: &earlier}, false},
+		{"deactivated", CheckoutLink{Active: false}, false},
+	}
+	for _, c := range cases {
+		if got := c.link.OpenAt(now); got != c.want {
+			t.Errorf("%s: OpenAt() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCheckoutSessionStatusAt(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	cases := []struct {
+		name    string
+		session CheckoutSession
+		want    CheckoutSessionStatus
+	}{
+		{"open", CheckoutSession{ExpiresAt: later}, CheckoutSessionOpen},
+		{"expires now", CheckoutSession{ExpiresAt: now}, CheckoutSessionExpired},
+		{"expired", CheckoutSession{ExpiresAt: earlier}, CheckoutSessionExpired},
+		// A session paid within the grace period after it expired completes
+		{"paid late", CheckoutSession{ExpiresAt: earlier, CompletedAt: &now}, CheckoutSessionCompleted},
+	}
+	for _, c := range cases {
+		if got := c.session.StatusAt(now); got != c.want {
+			t.Errorf("%s: StatusAt() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestStartCheckoutSessionRequestValidateFor(t *testing.T) {
+	price := 25.0
+	minAmount, maxAmount := 5.0, 100.0
+	fixed := CheckoutLink{Amount: &price, MaxQuantity: 3}
+	open := CheckoutLink{MinAmount: &minAmount, MaxAmount: &maxAmount}
+
+	amount := func(v float64) *float64 { return &v }
+
+	cases := []struct {
+		name      string
+		link      CheckoutLink
+		req       StartCheckoutSessionRequest
+		wantField string
+	}{
+		{"fixed amount", fixed, StartCheckoutSessionRequest{Quantity: 2}, ""},
+		{"amount on a fixed link", fixed, StartCheckoutSessionRequest{Amount: amount(10)}, "amount"},
+		{"too many units", fixed, StartCheckoutSessionRequest{Quantity: 4}, "quantity"},
+		{"entered amount", open, StartCheckoutSessionRequest{Amount: amount(50)}, ""},
+		{"missing amount", open, StartCheckoutSessionRequest{}, "amount"},
+		{"below the minimum", open, StartCheckoutSessionRequest{Amount: amount(1)}, "amount"},
+		{"above the maximum", open, StartCheckoutSessionRequest{Amount: amount(500)}, "amount"},
+		// Without MaxQuantity a link sells one unit at a time
+		{"quantity on a single-unit link", open, StartCheckoutSessionRequest{Amount: amount(50), Quantity: 2}, "quantity"},
+	}
+	for _, c := range cases {
+		errors := c.req.ValidateFor(c.link)
+		if c.wantField == "" {
+			if len(errors) > 0 {
+				t.Errorf("%s: expected no errors, got %v", c.name, errors)
+			}
+			continue
+		}
+		if _, ok := errors[c.wantField]; !ok {
+			t.Errorf("%s: expected an error for %s, got %v", c.name, c.wantField, errors)
+		}
+	}
+}
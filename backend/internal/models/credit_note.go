@@ -0,0 +1,102 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"gorm.io/gorm"
+)
+
+// CreditNoteStatus represents the possible states of a credit note
+type CreditNoteStatus string
+
+const (
+	// CreditNoteStatusIssued means the credit note exists but no refund has been recorded yet
+	CreditNoteStatusIssued CreditNoteStatus = "ISSUED"
+	// CreditNoteStatusRefunded means the outbound refund transaction has been recorded
+	CreditNoteStatusRefunded CreditNoteStatus = "REFUNDED"
+	// CreditNoteStatusVoid means the credit note was cancelled and no longer counts against the invoice
+	CreditNoteStatusVoid CreditNoteStatus = "VOID"
+)
+
+// CreditNote represents a full or partial credit against a paid invoice
+type CreditNote struct {
+	ID                int              `json:"id" gorm:"primaryKey;autoIncrement"`
+	CreditNoteNumber  string           `json:"creditNoteNumber" gorm:"uniqueIndex:idx_credit_note_number;not null;type:varchar(60)"`
+	InvoiceID         int              `json:"invoiceId" gorm:"not null;index:idx_credit_note_invoice"`
+	Amount            float64          `json:"amount" gorm:"not null;type:decimal(12,2)"`
+	Currency          string           `json:"currency" gorm:"not null;type:varchar(10)"`
+	Reason            string           `json:"reason" gorm:"type:text"`
+	Status            CreditNoteStatus `json:"status" gorm:"not null;default:ISSUED;type:varchar(20);index:idx_credit_note_status"`
+	RefundAddr        string           `json:"refundAddr,omitempty" gorm:"type:varchar(100)"`
+	RefundTxSignature string           `json:"refundTxSignature,omitempty" gorm:"type:varchar(100);uniqueIndex:idx_credit_note_refund_tx_signature,where:refund_tx_signature <> ''"`
+	RefundedAt        *time.Time       `json:"refundedAt,omitempty"`
+	CreatedAt         time.Time        `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time        `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt         gorm.DeletedAt   `json:"-" gorm:"index"`
+}
+
+// TableName overrides the table name
+func (CreditNote) TableName() string {
+	return "credit_note"
+}
+
+// CreateCreditNoteRequest represents the data required to issue a credit note
+type CreateCreditNoteRequest struct {
+	InvoiceID int     `json:"invoiceId"`
+	Amount    float64 `json:"amount"` // Zero credits the whole remaining balance
+	Reason    string  `json:"reason"`
+	// RefundAddr is the payer wallet the refund will be sent to. When set the
+	// payment watcher can detect the outbound transfer automatically.
+	RefundAddr string `json:"refundAddr"`
+}
+
+// Validate performs validation on the CreateCreditNoteRequest
+func (r *CreateCreditNoteRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if r.InvoiceID <= 0 {
+		errors["invoiceId"] = "Invoice ID is required"
+	}
+
+	if r.Amount < 0 {
+		errors["amount"] = "Amount cannot be negative"
+	}
+
+	if r.Reason == "" {
+		errors["reason"] = "Reason is required"
+	}
+
+	if r.RefundAddr != "" {
+		if _, err := solana.PublicKeyFromBase58(r.RefundAddr); err != nil {
+			errors["refundAddr"] = "Invalid Solana wallet address"
+		}
+	}
+
+	return errors
+}
+
+// RecordRefundRequest carries the outbound transaction signature of a refund
+type RecordRefundRequest struct {
+	Signature string `json:"signature"`
+}
+
+// Validate performs validation on the RecordRefundRequest
+func (r *RecordRefundRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if r.Signature == "" {
+		errors["signature"] = "Transaction signature is required"
+	} else if _, err := solana.SignatureFromBase58(r.Signature); err != nil {
+		errors["signature"] = "Invalid Solana transaction signature"
+	}
+
+	return errors
+}
+
+// RoundAmount rounds a monetary amount to cents so sums of decimal(12,2)
+// values can be compared without floating point noise
+func RoundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
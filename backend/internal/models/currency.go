@@ -0,0 +1,107 @@
+package models
+
+import (
+	"math"
+	"strings"
+)
+
+// NativeSOL is the symbol of native SOL, which is paid in lamports rather than
+// through a token mint
+const NativeSOL = "SOL"
+
+// SettlementToken is a token invoices can be paid in. Peg is the currency the
+// token tracks one to one, which is SOL itself for native SOL. Token amounts
+// are quoted with QuotePlaces decimals.
+type SettlementToken struct {
+	Symbol      string
+	Peg         string
+	Decimals    int
+	QuotePlaces int
+}
+
+// SettlementTokens lists the tokens the payment watcher can match, by symbol
+var SettlementTokens = map[string]SettlementToken{
+	"USDC":    {Symbol: "USDC", Peg: "USD", Decimals: 6, QuotePlaces: 2},
+	"EURC":    {Symbol: "EURC", Peg: "EUR", Decimals: 6, QuotePlaces: 2},
+	NativeSOL: {Symbol: NativeSOL, Peg: NativeSOL, Decimals: 9, QuotePlaces: 6},
+}
+
+// Round rounds an amount to the precision the token is quoted in
+func (t SettlementToken) Round(amount float64) float64 {
+	scale := math.Pow10(t.QuotePlaces)
+	return math.Round(amount*scale) / scale
+}
+
+// Tolerance is how far a received amount may be from the quote and still
+// match; it absorbs float rounding, never a whole quoted unit
+func (t SettlementToken) Tolerance() float64 {
+	return math.Pow10(-t.QuotePlaces - 1)
+}
+
+// FiatCurrencies lists the fiat currencies an invoice can be denominated in
+var FiatCurrencies = map[string]bool{
+	"USD": true,
+	"EUR": true,
+	"MXN": true,
+}
+
+// DefaultCurrency is used when an invoice does not name its currency
+const DefaultCurrency = "USDC"
+
+// RateLock selects when the exchange rate of a fiat-denominated invoice is fixed
+type RateLock string
+
+const (
+	// RateLockIssue fixes the token amount when the invoice is created
+	RateLockIssue RateLock = "issue"
+	// RateLockPayment quotes the token amount when the payer is about to pay;
+	// each quote is valid for a limited time
+	RateLockPayment RateLock = "payment"
+)
+
+// IsSupportedCurrency reports whether an invoice can be denominated in the currency
+func IsSupportedCurrency(currency string) bool {
+	currency = strings.ToUpper(currency)
+	_, isToken := SettlementTokens[currency]
+	return isToken || FiatCurrencies[currency]
+}
+
+// DefaultSettlementToken returns the token an invoice in the currency is paid
+// in when none is chosen: the currency itself if it is a token, otherwise the
+// token pegged to it, falling back to USDC
+func DefaultSettlementToken(currency string) string {
+	currency = strings.ToUpper(currency)
+	if _, ok := SettlementTokens[currency]; ok {
+		return currency
+	}
+	for symbol, token := range SettlementTokens {
+		if token.Peg == currency {
+			return symbol
+		}
+	}
+	return DefaultCurrency
+}
+
+// PricingCurrency returns the fiat currency used to look up exchange rates:
+// the peg of a token, or the currency itself
+func PricingCurrency(currency string) string {
+	currency = strings.ToUpper(currency)
+	if token, ok := SettlementTokens[currency]; ok {
+		return token.Peg
+	}
+	return currency
+}
+
+// Settlement returns the token and amount an invoice is paid with. Invoices
+// created before settlement quotes existed are paid in USDC at face value.
+func (i Invoice) Settlement() (string, float64) {
+	token := i.SettlementToken
+	if token == "" {
+		token = DefaultCurrency
+	}
+	amount := i.SettlementAmount
+	if amount == 0 {
+		amount = i.Amount
+	}
+	return token, amount
+}
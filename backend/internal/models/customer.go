@@ -0,0 +1,133 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// StringList is a list of strings stored as a jsonb array
+type StringList []string
+
+// Value implements the driver.Valuer interface for StringList
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return json.Marshal([]string{})
+	}
+	return json.Marshal([]string(l))
+}
+
+// Scan implements the sql.Scanner interface for StringList
+func (l *StringList) Scan(value interface{}) error {
+	return scanJSON(value, l)
+}
+
+// Contains reports whether the list holds the value
+func (l StringList) Contains(value string) bool {
+	for _, v := range l {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Customer is a reusable payer profile. Invoices issued to a customer copy its
+// details at issue time, so later edits never change existing invoices.
+// PayerWallets lists the Solana wallets the customer is known to pay from.
+type Customer struct {
+	ID               int            `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name             string         `json:"name" gorm:"not null;type:varchar(200);index:idx_customer_name"`
+	Email            string         `json:"email" gorm:"not null;type:varchar(254);index:idx_customer_email"`
+	AdditionalEmails StringList     `json:"additionalEmails" gorm:"type:jsonb"`
+	BillingAddress   string         `json:"billingAddress" gorm:"type:text"`
+	TaxID            string         `json:"taxId" gorm:"type:varchar(50)"`
+	DefaultCurrency  string         `json:"defaultCurrency" gorm:"type:varchar(10)"`
+	PayerWallets     StringList     `json:"payerWallets" gorm:"type:jsonb"`
+	Notes            string         `json:"notes" gorm:"type:text"`
+	CreatedAt        time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt        time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+}
+
+// TableName overrides the table name
+func (Customer) TableName() string {
+	return "customer"
+}
+
+// RecipientSnapshot returns the customer's details as they are copied onto an invoice
+func (c *Customer) RecipientSnapshot() Person {
+	return Person{
+		Name:    c.Name,
+		Email:   c.Email,
+		Address: c.BillingAddress,
+		TaxID:   c.TaxID,
+	}
+}
+
+// CustomerRequest represents the data required to create or replace a customer
+type CustomerRequest struct {
+	Name             string   `json:"name"`
+	Email            string   `json:"email"`
+	AdditionalEmails []string `json:"additionalEmails"`
+	BillingAddress   string   `json:"billingAddress"`
+	TaxID            string   `json:"taxId"`
+	DefaultCurrency  string   `json:"defaultCurrency"`
+	PayerWallets     []string `json:"payerWallets"`
+	Notes            string   `json:"notes"`
+}
+
+// Validate performs validation on the CustomerRequest
+func (r *CustomerRequest) Validate() map[string]string {
+	errors := make(ValidationErrors)
+
+	validateRequired("name", r.Name, errors)
+	validateMaxLength("name", r.Name, 200, errors)
+
+	validateRequired("email", r.Email, errors)
+	validateEmail("email", r.Email, errors)
+
+	for i, email := range r.AdditionalEmails {
+		field := fmt.Sprintf("additionalEmails[%d]", i)
+		validateRequired(field, email, errors)
+		validateEmail(field, email, errors)
+	}
+
+	validateMaxLength("taxId", r.TaxID, 50, errors)
+	if r.DefaultCurrency != "" && !IsSupportedCurrency(r.DefaultCurrency) {
+		errors["defaultCurrency"] = "Default currency must be one of USD, EUR, MXN, USDC, EURC or SOL"
+	}
+
+	for i, wallet := range r.PayerWallets {
+		field := fmt.Sprintf("payerWallets[%d]", i)
+		validateRequired(field, wallet, errors)
+		validateSolanaAddress(field, wallet, errors)
+	}
+
+	return errors
+}
+
+// Apply copies the request onto a customer
+func (r *CustomerRequest) Apply(c *Customer) {
+	c.Name = strings.TrimSpace(r.Name)
+	c.Email = strings.TrimSpace(r.Email)
+	c.AdditionalEmails = StringList(r.AdditionalEmails)
+	c.BillingAddress = r.BillingAddress
+	c.TaxID = r.TaxID
+	c.DefaultCurrency = strings.ToUpper(r.DefaultCurrency)
+	c.PayerWallets = StringList(r.PayerWallets)
+	c.Notes = r.Notes
+}
+
+// CustomerBalance is what a customer owes in one currency: the total of their
+// pending invoices, and the part of it that is past due
+type CustomerBalance struct {
+	Currency     string  `json:"currency"`
+	Outstanding  float64 `json:"outstanding"`
+	Overdue      float64 `json:"overdue"`
+	OpenInvoices int     `json:"openInvoices"`
+}
@@ -43,35 +43,3 @@ func NewDraftInvoice(req CreateDraftInvoiceRequest) DraftInvoice {
 		UpdatedAt:   now,
 	}
 }
-
-// CreateDraftInvoice creates a new draft invoice in the database
-func CreateDraftInvoice(db *gorm.DB, draft *DraftInvoice) error {
-	return db.Create(draft).Error
-}
-
-// GetDraftInvoiceByUserID fetches a draft invoice by user ID
-func GetDraftInvoiceByUserID(db *gorm.DB, userID string) (*DraftInvoice, error) {
-	var draft DraftInvoice
-	err := db.Where("user_id = ?", userID).First(&draft).Error
-	return &draft, err
-}
-
-// GetDraftInvoiceByID fetches a draft invoice by its ID
-func GetDraftInvoiceByID(db *gorm.DB, id string) (*DraftInvoice, error) {
-	var draft DraftInvoice
-	err := db.Where("id = ?", id).First(&draft).Error
-	return &draft, err
-}
-
-// UpdateDraftInvoice updates an existing draft invoice
-func UpdateDraftInvoice(db *gorm.DB, id string, req UpdateDraftInvoiceRequest) error {
-	return db.Model(&DraftInvoice{}).Where("id = ?", id).Updates(map[string]interface{}{
-		"invoice_data": req.InvoiceData,
-		"updated_at":   time.Now(),
-	}).Error
-}
-
-// DeleteDraftInvoice deletes a draft invoice
-func DeleteDraftInvoice(db *gorm.DB, id string) error {
-	return db.Delete(&DraftInvoice{}, "id = ?", id).Error
-} 
\ No newline at end of file
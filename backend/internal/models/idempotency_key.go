@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// IdempotencyKey stores the outcome of a request sent with an Idempotency-Key
+// header so retries can be answered without repeating the side effect.
+// A StatusCode of zero means the original request is still being processed.
+// Token identifies the reservation, so a request whose reservation was taken
+// over after its lease expired cannot complete or release the new one.
+type IdempotencyKey struct {
+	Key          string    `json:"key" gorm:"primaryKey;type:varchar(255)"`
+	Token        string    `json:"-" gorm:"not null;default:'';type:varchar(36)"`
+	RequestHash  string    `json:"requestHash" gorm:"not null;type:varchar(64)"`
+	StatusCode   int       `json:"statusCode" gorm:"not null;default:0"`
+	ContentType  string    `json:"contentType" gorm:"type:varchar(100)"`
+	ResponseBody []byte    `json:"-" gorm:"type:bytea"`
+	CreatedAt    time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	ExpiresAt    time.Time `json:"expiresAt" gorm:"not null;index:idx_idempotency_expires_at"`
+}
+
+// TableName overrides the table name
+func (IdempotencyKey) TableName() string {
+	return "idempotency_key"
+}
+
+// InProgress reports whether the original request has not finished yet
+func (k *IdempotencyKey) InProgress() bool {
+	return k.StatusCode == 0
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -33,11 +34,13 @@ type RecipientDetails struct {
 	Address string `json:"address,omitempty"`
 }
 
-// Person represents sender or recipient information
+// Person represents sender or recipient information. TaxID is snapshotted
+// from the customer an invoice is issued to.
 type Person struct {
 	Name    string `json:"name"`
 	Email   string `json:"email"`
 	Address string `json:"address,omitempty"`
+	TaxID   string `json:"taxId,omitempty"`
 }
 
 // Value implements the driver.Valuer interface for Person
@@ -67,20 +70,39 @@ func (p *Person) Scan(value interface{}) error {
 
 // Invoice represents a payment invoice in the system
 type Invoice struct {
-	ID               int            `json:"id" gorm:"primaryKey;autoIncrement"`
-	InvoiceNumber    string         `json:"invoiceNumber" gorm:"uniqueIndex:idx_invoice_number;not null;type:varchar(50)"`
-	Amount           float64        `json:"amount" gorm:"not null;type:decimal(12,2)"`
-	Currency         string         `json:"currency" gorm:"not null;default:USDC;type:varchar(10);index:idx_invoice_currency"`
-	Description      string         `json:"description" gorm:"type:text"`
-	DueDate          time.Time      `json:"dueDate" gorm:"not null;index:idx_invoice_due_date"`
-	Status           InvoiceStatus  `json:"status" gorm:"not null;default:PENDING;type:varchar(20);index:idx_invoice_status"`
-	ReceiverAddr     string         `json:"receiverAddr" gorm:"not null;type:varchar(100);index:idx_invoice_receiver"`
-	LinkToken        string         `json:"linkToken" gorm:"uniqueIndex:idx_invoice_link;not null;type:varchar(100)"`
-	SenderDetails    Person         `json:"senderDetails" gorm:"type:jsonb;serializer:json"`
-	RecipientDetails Person         `json:"recipientDetails" gorm:"type:jsonb;serializer:json"`
-	CreatedAt        time.Time      `json:"createdAt" gorm:"autoCreateTime;index:idx_invoice_created_at"`
-	UpdatedAt        time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
-	DeletedAt        gorm.DeletedAt `json:"-" gorm:"index"`
+	ID                 int            `json:"id" gorm:"primaryKey;autoIncrement"`
+	InvoiceNumber      string         `json:"invoiceNumber" gorm:"uniqueIndex:idx_invoice_number;not null;type:varchar(50)"`
+	Amount             float64        `json:"amount" gorm:"not null;type:decimal(20,9);index:idx_invoice_amount"`
+	Currency           string         `json:"currency" gorm:"not null;default:USDC;type:varchar(10);index:idx_invoice_currency"`
+	Description        string         `json:"description" gorm:"type:text"`
+	DueDate            time.Time      `json:"dueDate" gorm:"not null;index:idx_invoice_due_date"`
+	Status             InvoiceStatus  `json:"status" gorm:"not null;default:PENDING;type:varchar(20);index:idx_invoice_status"`
+	ReceiverAddr       string         `json:"receiverAddr" gorm:"not null;type:varchar(100);index:idx_invoice_receiver"`
+	// ReceiverTokenAccount is the receiver's associated token account for the
+	// settlement token's mint; native SOL is paid to ReceiverAddr itself
+	ReceiverTokenAccount string       `json:"receiverTokenAccount,omitempty" gorm:"type:varchar(100)"`
+	LinkToken          string         `json:"linkToken" gorm:"uniqueIndex:idx_invoice_link;not null;type:varchar(100)"`
+	SenderDetails      Person         `json:"senderDetails" gorm:"type:jsonb;serializer:json"`
+	RecipientDetails   Person         `json:"recipientDetails" gorm:"type:jsonb;serializer:json"`
+	// CustomerID links the invoice to a customer; RecipientDetails holds a snapshot of it
+	CustomerID         *int           `json:"customerId,omitempty" gorm:"index:idx_invoice_customer"`
+	// SettlementAmount of SettlementToken is what the payer sends; for an invoice
+	// in another currency it is quoted at ExchangeRate, locked as RateLock says
+	SettlementToken    string         `json:"settlementToken" gorm:"type:varchar(10)"`
+	SettlementAmount   float64        `json:"settlementAmount" gorm:"type:decimal(20,9)"`
+	ExchangeRate       float64        `json:"exchangeRate,omitempty" gorm:"type:decimal(20,10)"`
+	RateLock           RateLock       `json:"rateLock,omitempty" gorm:"type:varchar(10)"`
+	RateSource         string         `json:"rateSource,omitempty" gorm:"type:varchar(50)"`
+	RateLockedAt       *time.Time     `json:"rateLockedAt,omitempty"`
+	RateExpiresAt      *time.Time     `json:"rateExpiresAt,omitempty"`
+	// PaymentTxSignature is the Solana transaction that paid the invoice, if detected on-chain
+	PaymentTxSignature string         `json:"paymentTxSignature,omitempty" gorm:"type:varchar(100);index:idx_invoice_payment_tx"`
+	PaidAt             *time.Time     `json:"paidAt,omitempty" gorm:"index:idx_invoice_paid_at"`
+	CreatedAt          time.Time      `json:"createdAt" gorm:"autoCreateTime;index:idx_invoice_created_at"`
+	UpdatedAt          time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
+	// Warnings about the receiver found when the invoice was created; not stored
+	Warnings           []string       `json:"warnings,omitempty" gorm:"-"`
 }
 
 // TableName overrides the table name
@@ -102,18 +124,34 @@ func (i *Invoice) BeforeCreate(tx *gorm.DB) error {
 	
 	// Set default currency if not provided
 	if i.Currency == "" {
-		i.Currency = "USDC"
+		i.Currency = DefaultCurrency
 	}
 	
 	return nil
 }
 
+// AfterCreate issues the invoice's default payment link for its LinkToken, in
+// the same transaction as the invoice
+func (i *Invoice) AfterCreate(tx *gorm.DB) error {
+	return tx.Create(&PaymentLink{
+		InvoiceID: i.ID,
+		Token:     i.LinkToken,
+		Channel:   DefaultLinkChannel,
+	}).Error
+}
+
 // Validate validates the invoice data
 func (i *Invoice) Validate() error {
 	if i.InvoiceNumber == "" {
 		return fmt.Errorf("invoice number is required")
 	}
 	
+	return i.ValidateDetails()
+}
+
+// ValidateDetails validates everything but the invoice number, which may only
+// be allocated once the invoice is stored
+func (i *Invoice) ValidateDetails() error {
 	if i.Amount <= 0 {
 		return fmt.Errorf("amount must be greater than zero")
 	}
@@ -133,41 +171,11 @@ func (i *Invoice) Validate() error {
 	return nil
 }
 
-// CreateInvoice creates a new invoice in the database
-func CreateInvoice(db *gorm.DB, invoice *Invoice) error {
-	return db.Create(invoice).Error
-}
-
-// GetInvoiceByLinkToken fetches an invoice by its link token
-func GetInvoiceByLinkToken(db *gorm.DB, linkToken string) (*Invoice, error) {
-	var invoice Invoice
-	err := db.Where("link_token = ?", linkToken).First(&invoice).Error
-	return &invoice, err
-}
-
-// GetInvoiceByInvoiceNumber fetches an invoice by its invoice number
-func GetInvoiceByInvoiceNumber(db *gorm.DB, invoiceNumber string) (*Invoice, error) {
-	var invoice Invoice
-	err := db.Where("invoice_number = ?", invoiceNumber).First(&invoice).Error
-	return &invoice, err
-}
-
-// ListInvoices fetches all invoices with optional pagination
-func ListInvoices(db *gorm.DB, page, limit int) ([]Invoice, error) {
-	var invoices []Invoice
-	offset := (page - 1) * limit
-	err := db.Offset(offset).Limit(limit).Order("created_at desc").Find(&invoices).Error
-	return invoices, err
-}
-
-// UpdateInvoiceStatus updates the status of an invoice
-func UpdateInvoiceStatus(db *gorm.DB, id uint, status InvoiceStatus) error {
-	return db.Model(&Invoice{}).Where("id = ?", id).Update("status", status).Error
-}
-
 // CreateInvoiceRequest represents the data required to create a new invoice
 type CreateInvoiceRequest struct {
+	// InvoiceNumber is optional; when empty a number is allocated from NumberSequence
 	InvoiceNumber    string    `json:"invoiceNumber"`
+	NumberSequence   string    `json:"numberSequence,omitempty"`
 	Amount           float64   `json:"amount"`
 	Currency         string    `json:"currency"`
 	Description      string    `json:"description"`
@@ -175,29 +183,50 @@ type CreateInvoiceRequest struct {
 	ReceiverAddr     string    `json:"receiverAddr"`
 	SenderDetails    Person    `json:"senderDetails"`
 	RecipientDetails Person    `json:"recipientDetails"`
+	// CustomerID issues the invoice to a saved customer; empty recipient details
+	// and the currency are filled in from the customer at issue time
+	CustomerID       *int      `json:"customerId,omitempty"`
+	// SettlementToken is the stablecoin the invoice is paid in, by default the
+	// one pegged to Currency; RateLock applies when the two differ
+	SettlementToken  string    `json:"settlementToken,omitempty"`
+	RateLock         RateLock  `json:"rateLock,omitempty"`
 }
 
 // Validate performs validation on the CreateInvoiceRequest
 func (r *CreateInvoiceRequest) Validate() map[string]string {
 	errors := make(map[string]string)
 	
-	// Validate invoice number
-	if r.InvoiceNumber == "" {
-		errors["invoiceNumber"] = "Invoice number is required"
-	} else if len(r.InvoiceNumber) > 50 {
+	// Validate invoice number; an empty number is allocated from a sequence
+	if len(r.InvoiceNumber) > 50 {
 		errors["invoiceNumber"] = "Invoice number must be less than 50 characters"
 	}
+	if r.InvoiceNumber != "" && r.NumberSequence != "" {
+		errors["numberSequence"] = "Provide either an invoice number or a number sequence, not both"
+	}
 	
 	// Validate amount
 	if r.Amount <= 0 {
 		errors["amount"] = "Amount must be greater than zero"
 	}
 	
+	// Validate currency and settlement
+	if r.Currency != "" && !IsSupportedCurrency(r.Currency) {
+		errors["currency"] = "Currency must be one of USD, EUR, MXN, USDC, EURC or SOL"
+	}
+	if r.SettlementToken != "" {
+		if _, ok := SettlementTokens[strings.ToUpper(r.SettlementToken)]; !ok {
+			errors["settlementToken"] = "Settlement token must be USDC, EURC or SOL"
+		}
+	}
+	if r.RateLock != "" && r.RateLock != RateLockIssue && r.RateLock != RateLockPayment {
+		errors["rateLock"] = "Rate lock must be issue or payment"
+	}
+	
 	// Validate receiver address
 	if r.ReceiverAddr == "" {
 		errors["receiverAddr"] = "Receiver wallet address is required"
-	} else if len(r.ReceiverAddr) < 32 || len(r.ReceiverAddr) > 100 {
-		errors["receiverAddr"] = "Invalid Solana wallet address format"
+	} else {
+		validateSolanaAddress("receiverAddr", r.ReceiverAddr, errors)
 	}
 	
 	// Validate due date
@@ -215,17 +244,87 @@ func (r *CreateInvoiceRequest) Validate() map[string]string {
 		errors["senderDetails.email"] = "Sender email is required"
 	}
 	
-	// Validate recipient details
-	if r.RecipientDetails.Name == "" {
-		errors["recipientDetails.name"] = "Recipient name is required"
-	}
-	if r.RecipientDetails.Email == "" {
-		errors["recipientDetails.email"] = "Recipient email is required"
+	// Validate recipient details; a customer provides them when they are omitted
+	if r.CustomerID == nil {
+		if r.RecipientDetails.Name == "" {
+			errors["recipientDetails.name"] = "Recipient name is required"
+		}
+		if r.RecipientDetails.Email == "" {
+			errors["recipientDetails.email"] = "Recipient email is required"
+		}
+	} else if *r.CustomerID <= 0 {
+		errors["customerId"] = "Invalid customer ID"
 	}
 	
 	return errors
 }
 
+// InvoiceFilter restricts which invoices a query returns. Zero values mean no
+// restriction. Every From bound is inclusive and every To bound is exclusive.
+type InvoiceFilter struct {
+	CreatedFrom  *time.Time
+	CreatedTo    *time.Time
+	DueFrom      *time.Time
+	DueTo        *time.Time
+	PaidFrom     *time.Time
+	PaidTo       *time.Time
+	MinAmount    *float64
+	MaxAmount    *float64
+	Status       InvoiceStatus
+	Currency     string
+	ReceiverAddr string
+	CustomerID   *int
+	// Search matches words, or word prefixes, in the invoice number, description
+	// and the sender and recipient names and emails
+	Search string
+}
+
+// InvoiceSortFields maps the sortable API field names to their indexed columns
+var InvoiceSortFields = map[string]string{
+	"createdAt":     "created_at",
+	"dueDate":       "due_date",
+	"paidAt":        "paid_at",
+	"amount":        "amount",
+	"invoiceNumber": "invoice_number",
+	"status":        "status",
+	"currency":      "currency",
+	"receiverAddr":  "receiver_addr",
+}
+
+// InvoiceSort orders an invoice list; the zero value means newest first
+type InvoiceSort struct {
+	Column     string
+	Descending bool
+}
+
+// KeysetCompatible reports whether cursors can be used with this order,
+// which is the case when sorting by creation time
+func (s InvoiceSort) KeysetCompatible() bool {
+	return s.Column == "" || s.Column == "created_at"
+}
+
+// ParseInvoiceSort parses a sort parameter such as "dueDate" or "-amount",
+// where a leading "-" sorts in descending order
+func ParseInvoiceSort(value string) (InvoiceSort, error) {
+	if value == "" {
+		return InvoiceSort{Column: "created_at", Descending: true}, nil
+	}
+
+	sort := InvoiceSort{}
+	if strings.HasPrefix(value, "-") {
+		sort.Descending = true
+		value = value[1:]
+	}
+
+	column, ok := InvoiceSortFields[value]
+	if !ok {
+		return InvoiceSort{}, fmt.Errorf("cannot sort by %q", value)
+	}
+	sort.Column = column
+
+	return sort, nil
+}
+
 // UpdateInvoiceStatusRequest represents the data required to update an invoice status
 type UpdateInvoiceStatusRequest struct {
 	Status InvoiceStatus `json:"status" binding:"required"`
@@ -239,7 +338,7 @@ func NewInvoice(req CreateInvoiceRequest) Invoice {
 	return Invoice{
 		InvoiceNumber:    req.InvoiceNumber,
 		Amount:           req.Amount,
-		Currency:         req.Currency,
+		Currency:         strings.ToUpper(req.Currency),
 		Description:      req.Description,
 		DueDate:          req.DueDate,
 		Status:           StatusPending,
@@ -247,6 +346,9 @@ func NewInvoice(req CreateInvoiceRequest) Invoice {
 		LinkToken:        linkToken,
 		SenderDetails:    req.SenderDetails,
 		RecipientDetails: req.RecipientDetails,
+		CustomerID:       req.CustomerID,
+		SettlementToken:  strings.ToUpper(req.SettlementToken),
+		RateLock:         req.RateLock,
 		CreatedAt:        now,
 		UpdatedAt:        now,
 	}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// BatchMode controls what happens to a batch when some rows fail
+type BatchMode string
+
+const (
+	// BatchModeAllOrNothing creates every invoice in one transaction or none at all
+	BatchModeAllOrNothing BatchMode = "all_or_nothing"
+	// BatchModeBestEffort creates every valid invoice and reports the failed rows
+	BatchModeBestEffort BatchMode = "best_effort"
+)
+
+// BatchFormat is the input format a batch was submitted in
+type BatchFormat string
+
+const (
+	BatchFormatJSON BatchFormat = "json"
+	BatchFormatCSV  BatchFormat = "csv"
+)
+
+// MaxBatchRows is the largest number of invoices accepted in one batch
+const MaxBatchRows = 5000
+
+// BatchJobStatus represents the possible states of an asynchronous batch job
+type BatchJobStatus string
+
+const (
+	BatchJobQueued    BatchJobStatus = "QUEUED"
+	BatchJobRunning   BatchJobStatus = "RUNNING"
+	BatchJobCompleted BatchJobStatus = "COMPLETED"
+	BatchJobFailed    BatchJobStatus = "FAILED"
+)
+
+// CreateInvoiceBatchRequest represents a JSON batch of invoices to create
+type CreateInvoiceBatchRequest struct {
+	Mode     BatchMode              `json:"mode"`
+	Invoices []CreateInvoiceRequest `json:"invoices"`
+}
+
+// Validate performs validation on the batch envelope; rows are validated one by one when processed
+func (r *CreateInvoiceBatchRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if r.Mode != "" && !r.Mode.IsValid() {
+		errors["mode"] = "Mode must be all_or_nothing or best_effort"
+	}
+
+	if len(r.Invoices) == 0 {
+		errors["invoices"] = "At least one invoice is required"
+	} else if len(r.Invoices) > MaxBatchRows {
+		errors["invoices"] = fmt.Sprintf("A batch can contain at most %d invoices", MaxBatchRows)
+	}
+
+	return errors
+}
+
+// IsValid reports whether the mode is a known batch mode
+func (m BatchMode) IsValid() bool {
+	return m == BatchModeAllOrNothing || m == BatchModeBestEffort
+}
+
+// BatchRowError describes one problem with one row of a batch. Field is the
+// path of the offending value, e.g. "invoices[3].senderDetails.email" for JSON
+// input or the column name for CSV input.
+type BatchRowError struct {
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// BatchRowResult is the outcome of one row of a batch. Row is zero based for
+// JSON input and the 1-based line number for CSV input.
+type BatchRowResult struct {
+	Row           int             `json:"row"`
+	Created       bool            `json:"created"`
+	InvoiceID     int             `json:"invoiceId,omitempty"`
+	InvoiceNumber string          `json:"invoiceNumber,omitempty"`
+	LinkToken     string          `json:"linkToken,omitempty"`
+	Errors        []BatchRowError `json:"errors,omitempty"`
+}
+
+// BatchResult summarizes a processed batch
+type BatchResult struct {
+	Mode      BatchMode        `json:"mode"`
+	Total     int              `json:"total"`
+	Succeeded int              `json:"succeeded"`
+	Failed    int              `json:"failed"`
+	Rows      []BatchRowResult `json:"rows"`
+}
+
+// Value implements the driver.Valuer interface for BatchResult
+func (b BatchResult) Value() (driver.Value, error) {
+	return json.Marshal(b)
+}
+
+// Scan implements the sql.Scanner interface for BatchResult
+func (b *BatchResult) Scan(value interface{}) error {
+	return scanJSON(value, b)
+}
+
+// BatchRows holds the parsed rows of an asynchronous batch until it runs
+type BatchRows []BatchRow
+
+// BatchRow is one invoice request of a batch together with its row number
+type BatchRow struct {
+	Row     int                  `json:"row"`
+	Request CreateInvoiceRequest `json:"request"`
+	// Errors found while parsing the row, e.g. an unparsable CSV amount
+	Errors []BatchRowError `json:"errors,omitempty"`
+}
+
+// Value implements the driver.Valuer interface for BatchRows
+func (b BatchRows) Value() (driver.Value, error) {
+	return json.Marshal(b)
+}
+
+// Scan implements the sql.Scanner interface for BatchRows
+func (b *BatchRows) Scan(value interface{}) error {
+	return scanJSON(value, b)
+}
+
+// InvoiceBatchJob tracks a large batch that is processed in the background
+type InvoiceBatchJob struct {
+	ID          string         `json:"id" gorm:"primaryKey;type:uuid"`
+	Mode        BatchMode      `json:"mode" gorm:"not null;type:varchar(20)"`
+	Format      BatchFormat    `json:"format" gorm:"not null;type:varchar(10)"`
+	Status      BatchJobStatus `json:"status" gorm:"not null;type:varchar(20);index:idx_batch_job_status"`
+	Total       int            `json:"total" gorm:"not null"`
+	Rows        BatchRows      `json:"-" gorm:"type:jsonb"`
+	Result      *BatchResult   `json:"result,omitempty" gorm:"type:jsonb"`
+	Error       string         `json:"error,omitempty" gorm:"type:text"`
+	CreatedAt   time.Time      `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time      `json:"updatedAt" gorm:"autoUpdateTime"`
+	HeartbeatAt *time.Time     `json:"heartbeatAt,omitempty"`
+	CompletedAt *time.Time     `json:"completedAt,omitempty"`
+}
+
+// TableName overrides the table name
+func (InvoiceBatchJob) TableName() string {
+	return "invoice_batch_job"
+}
+
+// BeforeCreate hook assigns the job ID
+func (j *InvoiceBatchJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// scanJSON decodes a jsonb column into dest
+func scanJSON(value interface{}, dest interface{}) error {
+	if value == nil {
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("failed to scan %T: unexpected type %T", dest, value)
+	}
+
+	return json.Unmarshal(data, dest)
+}
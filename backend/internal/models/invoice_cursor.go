@@ -0,0 +1,60 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// ErrInvalidCursor is returned for cursors that were not produced by this API
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// InvoiceCursor marks a position in an invoice list ordered by (created_at, id).
+// Clients treat it as an opaque string.
+type InvoiceCursor struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"i"`
+	// Before selects the page preceding the position rather than the one following it
+	Before bool `json:"b,omitempty"`
+}
+
+// CursorAfter returns a cursor for the page that follows the invoice
+func CursorAfter(invoice Invoice) InvoiceCursor {
+	return InvoiceCursor{CreatedAt: invoice.CreatedAt, ID: invoice.ID}
+}
+
+// CursorBefore returns a cursor for the page that precedes the invoice
+func CursorBefore(invoice Invoice) InvoiceCursor {
+	return InvoiceCursor{CreatedAt: invoice.CreatedAt, ID: invoice.ID, Before: true}
+}
+
+// Encode returns the opaque string form of the cursor
+func (c InvoiceCursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeInvoiceCursor parses a cursor produced by Encode
+func DecodeInvoiceCursor(value string) (*InvoiceCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor InvoiceCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID <= 0 || cursor.CreatedAt.IsZero() {
+		return nil, ErrInvalidCursor
+	}
+
+	return &cursor, nil
+}
+
+// InvoicePage is one page of an invoice list. Next and Prev are encoded
+// cursors for the neighbouring pages and are empty when there is none.
+type InvoicePage struct {
+	Invoices []Invoice
+	Total    int64
+	Next     string
+	Prev     string
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInvoiceCursorRoundTrip(t *testing.T) {
+	invoice := Invoice{ID: 42, CreatedAt: time.Date(2026, 9, 1, 12, 30, 0, 123456000, time.UTC)}
+
+	for _, cursor := range []InvoiceCursor{CursorAfter(invoice), CursorBefore(invoice)} {
+		decoded, err := DecodeInvoiceCursor(cursor.Encode())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if decoded.ID != cursor.ID || !decoded.CreatedAt.Equal(cursor.CreatedAt) || decoded.Before != cursor.Before {
+			t.Errorf("Expected %+v, got %+v", cursor, *decoded)
+		}
+	}
+}
+
+func TestDecodeInvoiceCursorRejectsGarbage(t *testing.T) {
+	for _, value := range []string{"", "not base64!", "e30", "eyJpIjoxfQ"} {
+		if _, err := DecodeInvoiceCursor(value); err != ErrInvalidCursor {
+			t.Errorf("Expected ErrInvalidCursor for %q, got %v", value, err)
+		}
+	}
+}
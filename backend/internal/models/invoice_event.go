@@ -0,0 +1,43 @@
+package models
+
+import "time"
+
+// InvoiceEventType is the kind of change an invoice event announces to the payment page
+type InvoiceEventType string
+
+const (
+	// InvoiceEventStatus carries the invoice's current status, sent when a
+	// client connects and when an issuer sets the status by hand
+	InvoiceEventStatus InvoiceEventType = "status"
+	// InvoiceEventDetected means a transfer paying the invoice was confirmed
+	// on-chain and is waiting to be finalized
+	InvoiceEventDetected InvoiceEventType = "detected"
+	// InvoiceEventConfirming reports the confirmations of a detected transfer
+	// that is not finalized yet
+	InvoiceEventConfirming InvoiceEventType = "confirming"
+	// InvoiceEventPaid means the invoice was marked paid
+	InvoiceEventPaid InvoiceEventType = "paid"
+	// InvoiceEventQuoted means the invoice got a fresh quote, valid until ExpiresAt
+	InvoiceEventQuoted InvoiceEventType = "quoted"
+	// InvoiceEventExpired means the invoice's quote expired before it was paid
+	InvoiceEventExpired InvoiceEventType = "expired"
+)
+
+// InvoiceEvent announces a change of the invoice InvoiceID to the clients
+// watching it, whichever of its payment links they opened. Signature is the
+// transaction paying the invoice, once one was seen; Confirmations counts the
+// blocks confirming it until it is finalized.
+type InvoiceEvent struct {
+	Type          InvoiceEventType `json:"type"`
+	InvoiceID     int              `json:"-"`
+	Status        InvoiceStatus    `json:"status"`
+	Signature     string           `json:"signature,omitempty"`
+	Confirmations *uint64          `json:"confirmations,omitempty"`
+	ExpiresAt     *time.Time       `json:"expiresAt,omitempty"`
+	At            time.Time        `json:"at"`
+}
+
+// Terminal reports whether the invoice can no longer change once the event is seen
+func (e InvoiceEvent) Terminal() bool {
+	return e.Status == StatusPaid || e.Status == StatusCanceled
+}
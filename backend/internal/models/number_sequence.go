@@ -0,0 +1,93 @@
+package models
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/numbering"
+)
+
+// DefaultNumberSequence is used when an invoice is created without a number or a sequence name
+const DefaultNumberSequence = "default"
+
+// CreditNoteNumberSequence numbers credit notes in a series of their own. It
+// is created with DefaultCreditNotePattern unless it already exists.
+const CreditNoteNumberSequence = "credit-note"
+
+// DefaultCreditNotePattern is the initial pattern of CreditNoteNumberSequence
+const DefaultCreditNotePattern = "CN-{YYYY}-{SEQ:5}"
+
+// NumberSequence is a named invoice numbering pattern. Each organization or
+// business line can keep its own sequence, e.g. "INV-{YYYY}-{SEQ:5}".
+type NumberSequence struct {
+	ID          int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name        string    `json:"name" gorm:"uniqueIndex:idx_number_sequence_name;not null;type:varchar(50)"`
+	Pattern     string    `json:"pattern" gorm:"not null;type:varchar(60)"`
+	Description string    `json:"description" gorm:"type:text"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TableName overrides the table name
+func (NumberSequence) TableName() string {
+	return "number_sequence"
+}
+
+// NumberSequenceCounter holds the last allocated value of a sequence within one
+// scope, i.e. the pattern with its date placeholders rendered ("INV-2026-{SEQ}")
+type NumberSequenceCounter struct {
+	SequenceID int       `json:"sequenceId" gorm:"primaryKey;autoIncrement:false"`
+	Scope      string    `json:"scope" gorm:"primaryKey;type:varchar(60)"`
+	LastValue  int64     `json:"lastValue" gorm:"not null;default:0"`
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TableName overrides the table name
+func (NumberSequenceCounter) TableName() string {
+	return "number_sequence_counter"
+}
+
+// sequenceNamePattern restricts sequence names to URL-friendly identifiers
+var sequenceNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// CreateNumberSequenceRequest represents the data required to create a number sequence
+type CreateNumberSequenceRequest struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Description string `json:"description"`
+}
+
+// Validate performs validation on the CreateNumberSequenceRequest
+func (r *CreateNumberSequenceRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if !sequenceNamePattern.MatchString(r.Name) {
+		errors["name"] = "Name must be lowercase letters, digits, '-' or '_' (max 50 characters)"
+	}
+
+	validateNumberPattern(r.Pattern, errors)
+
+	return errors
+}
+
+// UpdateNumberSequenceRequest represents the mutable fields of a number sequence
+type UpdateNumberSequenceRequest struct {
+	Pattern     string `json:"pattern"`
+	Description string `json:"description"`
+}
+
+// Validate performs validation on the UpdateNumberSequenceRequest
+func (r *UpdateNumberSequenceRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+	validateNumberPattern(r.Pattern, errors)
+	return errors
+}
+
+// validateNumberPattern checks a pattern renders to a valid invoice number
+func validateNumberPattern(pattern string, errors ValidationErrors) {
+	if err := numbering.Validate(pattern); err != nil {
+		errors["pattern"] = "Invalid pattern: " + err.Error()
+		return
+	}
+	validateMaxLength("pattern", pattern, 60, errors)
+}
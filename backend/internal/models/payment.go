@@ -0,0 +1,161 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// PaymentStatus represents the possible states of an inbound payment
+type PaymentStatus string
+
+const (
+	// PaymentStatusMatched means the payment settled the invoice it is linked to
+	PaymentStatusMatched PaymentStatus = "MATCHED"
+	// PaymentStatusFlagged means the payment needs a person to look at it, see FlagReason
+	PaymentStatusFlagged PaymentStatus = "FLAGGED"
+	// PaymentStatusUnmatched means no invoice could be tied to the payment
+	PaymentStatusUnmatched PaymentStatus = "UNMATCHED"
+	// PaymentStatusAllocated means an operator allocated all of the payment to invoices
+	PaymentStatusAllocated PaymentStatus = "ALLOCATED"
+)
+
+// PaymentMatch records how the payment watcher tied a payment to an invoice
+type PaymentMatch string
+
+const (
+	// PaymentMatchReference means the transaction carried the invoice's Solana Pay reference key
+	PaymentMatchReference PaymentMatch = "reference"
+	// PaymentMatchMemo means a memo of the transaction named the invoice
+	PaymentMatchMemo PaymentMatch = "memo"
+	// PaymentMatchAmount means the amount received equals the invoice's quoted amount
+	PaymentMatchAmount PaymentMatch = "amount"
+)
+
+// Payment is an inbound transfer of a settlement token to a receiver address,
+// as seen by the payment watcher. Amount is what ReceiverAddr gained in Token;
+// Memo joins the memos of the transaction. InvoiceID is set when the payment
+// was tied to an invoice, including flagged payments that named one.
+// AllocatedAmount is the part an operator has allocated to invoices by hand.
+type Payment struct {
+	ID              int           `json:"id" gorm:"primaryKey;autoIncrement"`
+	Signature       string        `json:"signature" gorm:"not null;type:varchar(100);uniqueIndex:idx_payment_signature_receiver"`
+	ReceiverAddr    string        `json:"receiverAddr" gorm:"not null;type:varchar(100);uniqueIndex:idx_payment_signature_receiver"`
+	Token           string        `json:"token" gorm:"not null;type:varchar(10)"`
+	Amount          float64       `json:"amount" gorm:"not null;type:decimal(20,9)"`
+	Memo            string        `json:"memo,omitempty" gorm:"type:text"`
+	InvoiceID       *int          `json:"invoiceId,omitempty" gorm:"index:idx_payment_invoice"`
+	MatchedBy       PaymentMatch  `json:"matchedBy,omitempty" gorm:"type:varchar(20)"`
+	Status          PaymentStatus `json:"status" gorm:"not null;type:varchar(20);index:idx_payment_status"`
+	FlagReason      string        `json:"flagReason,omitempty" gorm:"type:text"`
+	AllocatedAmount float64       `json:"allocatedAmount" gorm:"not null;default:0;type:decimal(20,9)"`
+	BlockTime       time.Time     `json:"blockTime"`
+	CreatedAt       time.Time     `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time     `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// TableName overrides the table name
+func (Payment) TableName() string {
+	return "payment"
+}
+
+// Unallocated returns the part of the payment not yet allocated to an invoice
+func (p Payment) Unallocated() float64 {
+	return p.Amount - p.AllocatedAmount
+}
+
+// PaymentAllocation assigns part of a payment, in the payment's token, to an
+// invoice. An invoice whose allocations reach its settlement amount is paid.
+type PaymentAllocation struct {
+	ID        int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	PaymentID int       `json:"paymentId" gorm:"not null;index:idx_payment_allocation_payment"`
+	InvoiceID int       `json:"invoiceId" gorm:"not null;index:idx_payment_allocation_invoice"`
+	Amount    float64   `json:"amount" gorm:"not null;type:decimal(20,9)"`
+	Actor     string    `json:"actor" gorm:"not null;type:varchar(100)"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName overrides the table name
+func (PaymentAllocation) TableName() string {
+	return "payment_allocation"
+}
+
+// PaymentAuditDetails is the input and outcome of an operator action on a payment
+type PaymentAuditDetails struct {
+	Allocations    []AllocationLine `json:"allocations,omitempty"`
+	Note           string           `json:"note,omitempty"`
+	PaidInvoiceIDs []int            `json:"paidInvoiceIds,omitempty"`
+}
+
+// Value implements the driver.Valuer interface for PaymentAuditDetails
+func (d PaymentAuditDetails) Value() (driver.Value, error) {
+	return json.Marshal(d)
+}
+
+// Scan implements the sql.Scanner interface for PaymentAuditDetails
+func (d *PaymentAuditDetails) Scan(value interface{}) error {
+	return scanJSON(value, d)
+}
+
+// PaymentAuditEntry records an operator action on a payment
+type PaymentAuditEntry struct {
+	ID        int                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	PaymentID int                 `json:"paymentId" gorm:"not null;index:idx_payment_audit_payment"`
+	Action    string              `json:"action" gorm:"not null;type:varchar(50)"`
+	Actor     string              `json:"actor" gorm:"not null;type:varchar(100)"`
+	RequestID string              `json:"requestId,omitempty" gorm:"type:varchar(100)"`
+	Details   PaymentAuditDetails `json:"details" gorm:"type:jsonb"`
+	CreatedAt time.Time           `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName overrides the table name
+func (PaymentAuditEntry) TableName() string {
+	return "payment_audit_log"
+}
+
+// AllocationLine allocates an amount of a payment to one invoice
+type AllocationLine struct {
+	InvoiceID int     `json:"invoiceId"`
+	Amount    float64 `json:"amount"`
+}
+
+// AllocatePaymentRequest allocates a payment, fully or in part, to one or more invoices
+type AllocatePaymentRequest struct {
+	Allocations []AllocationLine `json:"allocations"`
+	Note        string           `json:"note"`
+}
+
+// Validate performs validation on the AllocatePaymentRequest
+func (r *AllocatePaymentRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if len(r.Allocations) == 0 {
+		errors["allocations"] = "At least one allocation is required"
+	}
+
+	seen := make(map[int]bool)
+	for i, line := range r.Allocations {
+		field := fmt.Sprintf("allocations[%d]", i)
+		if line.InvoiceID <= 0 {
+			errors[field+".invoiceId"] = "Invoice ID is required"
+		} else if seen[line.InvoiceID] {
+			errors[field+".invoiceId"] = "Each invoice can only be allocated to once per request"
+		}
+		seen[line.InvoiceID] = true
+		if line.Amount <= 0 {
+			errors[field+".amount"] = "Amount must be greater than zero"
+		}
+	}
+
+	validateMaxLength("note", r.Note, 500, errors)
+
+	return errors
+}
+
+// PaymentDetail is a payment with its allocations and audit trail
+type PaymentDetail struct {
+	Payment     Payment             `json:"payment"`
+	Allocations []PaymentAllocation `json:"allocations"`
+	AuditLog    []PaymentAuditEntry `json:"auditLog"`
+}
@@ -0,0 +1,123 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DefaultLinkChannel is the channel of the link every invoice is created with
+const DefaultLinkChannel = "default"
+
+// PaymentLinkStatus is whether a payment link still opens its invoice
+type PaymentLinkStatus string
+
+const (
+	// PaymentLinkActive means the link opens its invoice
+	PaymentLinkActive PaymentLinkStatus = "active"
+	// PaymentLinkExpired means the link's ExpiresAt has passed
+	PaymentLinkExpired PaymentLinkStatus = "expired"
+	// PaymentLinkRevoked means the link was revoked, by hand, by regenerating
+	// it or because its invoice was canceled
+	PaymentLinkRevoked PaymentLinkStatus = "revoked"
+)
+
+// PaymentLink is a link a payer opens to pay an invoice, identified by its
+// token. Every invoice is created with one link, whose token is the invoice's
+// LinkToken; more can be issued for different channels, such as email or
+// SMS, so each can be expired or revoked on its own. Revoked and expired
+// links are kept so their tokens can be told apart from unknown ones.
+type PaymentLink struct {
+	ID           int        `json:"id" gorm:"primaryKey"`
+	InvoiceID    int        `json:"invoiceId" gorm:"not null;index:idx_payment_link_invoice"`
+	Token        string     `json:"token" gorm:"uniqueIndex:idx_payment_link_token;not null;type:varchar(100)"`
+	Channel      string     `json:"channel" gorm:"not null;type:varchar(50)"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	RevokedAt    *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy    string     `json:"revokedBy,omitempty" gorm:"type:varchar(100)"`
+	RevokeReason string     `json:"revokeReason,omitempty" gorm:"type:text"`
+	CreatedAt    time.Time  `json:"createdAt" gorm:"autoCreateTime"`
+	// Status is derived from RevokedAt and ExpiresAt when the link is returned; not stored
+	Status PaymentLinkStatus `json:"status" gorm:"-"`
+}
+
+// TableName overrides the table name
+func (PaymentLink) TableName() string {
+	return "payment_link"
+}
+
+// BeforeCreate generates the link's token unless one was given
+func (l *PaymentLink) BeforeCreate(tx *gorm.DB) error {
+	if l.Token == "" {
+		l.Token = uuid.New().String()
+	}
+	if l.Channel == "" {
+		l.Channel = DefaultLinkChannel
+	}
+	return nil
+}
+
+// StatusAt returns whether the link opens its invoice at now
+func (l PaymentLink) StatusAt(now time.Time) PaymentLinkStatus {
+	switch {
+	case l.RevokedAt != nil:
+		return PaymentLinkRevoked
+	case l.ExpiresAt != nil && !now.Before(*l.ExpiresAt):
+		return PaymentLinkExpired
+	default:
+		return PaymentLinkActive
+	}
+}
+
+// CreatePaymentLinkRequest issues another link to an invoice, for example one
+// per channel it is shared on. A link without ExpiresAt never expires.
+type CreatePaymentLinkRequest struct {
+	Channel   string     `json:"channel"`
+	ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+// Validate performs validation on the CreatePaymentLinkRequest
+func (r *CreatePaymentLinkRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	validateMaxLength("channel", r.Channel, 50, errors)
+	if r.ExpiresAt != nil {
+		validateFutureDate("expiresAt", *r.ExpiresAt, errors)
+	}
+
+	return errors
+}
+
+// RegeneratePaymentLinkRequest replaces a link with a new token on the same
+// channel, revoking the old one for Reason
+type RegeneratePaymentLinkRequest struct {
+	ExpiresAt *time.Time `json:"expiresAt"`
+	Reason    string     `json:"reason"`
+}
+
+// Validate performs validation on the RegeneratePaymentLinkRequest
+func (r *RegeneratePaymentLinkRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if r.ExpiresAt != nil {
+		validateFutureDate("expiresAt", *r.ExpiresAt, errors)
+	}
+	validateMaxLength("reason", r.Reason, 500, errors)
+
+	return errors
+}
+
+// RevokePaymentLinkRequest revokes a link, for example one emailed to the wrong person
+type RevokePaymentLinkRequest struct {
+	Reason string `json:"reason"`
+}
+
+// Validate performs validation on the RevokePaymentLinkRequest
+func (r *RevokePaymentLinkRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	validateMaxLength("reason", r.Reason, 500, errors)
+
+	return errors
+}
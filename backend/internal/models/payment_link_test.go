@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPaymentLinkStatusAt(t *testing.T) {
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	cases := []struct {
+		name string
+		link PaymentLink
+		want PaymentLinkStatus
+	}{
+		{"no expiry", PaymentLink{}, PaymentLinkActive},
+		{"expires later", PaymentLink{ExpiresAt: &later}, PaymentLinkActive},
+		{"expires now", PaymentLink{ExpiresAt: &now}, PaymentLinkExpired},
+		{"expired", PaymentLink{ExpiresAt: &earlier}, PaymentLinkExpired},
+		// A revoked link reports revoked even once it would have expired
+		{"revoked", PaymentLink{ExpiresAt: &earlier, RevokedAt: &earlier}, PaymentLinkRevoked},
+	}
+	for _, c := range cases {
+		if got := c.link.StatusAt(now); got != c.want {
+			t.Errorf("%s: StatusAt() = %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCreatePaymentLinkRequestValidate(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	req := CreatePaymentLinkRequest{Channel: "email", ExpiresAt: &past}
+	if _, ok := req.Validate()["expiresAt"]; !ok {
+		t.Error("Expected an error for an expiry in the past")
+	}
+
+	future := time.Now().Add(24 * time.Hour)
+	req = CreatePaymentLinkRequest{Channel: "email", ExpiresAt: &future}
+	if errors := req.Validate(); len(errors) > 0 {
+		t.Errorf("Expected a link expiring tomorrow to be valid, got %v", errors)
+	}
+}
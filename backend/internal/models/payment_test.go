@@ -0,0 +1,27 @@
+package models
+
+import "testing"
+
+func TestAllocatePaymentRequestValidate(t *testing.T) {
+	req := AllocatePaymentRequest{Allocations: []AllocationLine{
+		{InvoiceID: 1, Amount: 40},
+		{InvoiceID: 1, Amount: 10},
+		{InvoiceID: 2, Amount: 0},
+	}}
+
+	errors := req.Validate()
+	if _, ok := errors["allocations[1].invoiceId"]; !ok {
+		t.Error("Expected an error for allocating to the same invoice twice")
+	}
+	if _, ok := errors["allocations[2].amount"]; !ok {
+		t.Error("Expected an error for a zero amount")
+	}
+	if _, ok := errors["allocations[0].invoiceId"]; ok {
+		t.Error("Expected the first allocation to be valid")
+	}
+
+	empty := AllocatePaymentRequest{}
+	if _, ok := empty.Validate()["allocations"]; !ok {
+		t.Error("Expected an error for a request without allocations")
+	}
+}
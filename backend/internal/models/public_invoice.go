@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// PublicParty is the part of an invoice's sender or recipient shown to payers
+type PublicParty struct {
+	Name  string `json:"name"`
+	Email string `json:"email,omitempty"`
+}
+
+// PublicInvoice is the view of an invoice served to anyone holding one of its
+// payment links: what to pay, to whom and whether it was paid. It leaves out
+// internal IDs and timestamps, the link token, the customer and the parties'
+// addresses, since a link can end up with someone other than the payer.
+type PublicInvoice struct {
+	InvoiceNumber    string        `json:"invoiceNumber"`
+	Amount           float64       `json:"amount"`
+	Currency         string        `json:"currency"`
+	Description      string        `json:"description"`
+	DueDate          time.Time     `json:"dueDate"`
+	Status           InvoiceStatus `json:"status"`
+	ReceiverAddr     string        `json:"receiverAddr"`
+	Sender           PublicParty   `json:"sender"`
+	Recipient        PublicParty   `json:"recipient"`
+	SettlementToken  string        `json:"settlementToken"`
+	SettlementAmount float64       `json:"settlementAmount"`
+	ExchangeRate     float64       `json:"exchangeRate,omitempty"`
+	RateExpiresAt    *time.Time    `json:"rateExpiresAt,omitempty"`
+	// PaymentTxSignature and PaidAt are set once the invoice is paid
+	PaymentTxSignature string     `json:"paymentTxSignature,omitempty"`
+	PaidAt             *time.Time `json:"paidAt,omitempty"`
+}
+
+// NewPublicInvoice returns the public view of an invoice. The payer sees who
+// the invoice is from and how to reach them, but only the recipient's name.
+func NewPublicInvoice(invoice Invoice) PublicInvoice {
+	return PublicInvoice{
+		InvoiceNumber:      invoice.InvoiceNumber,
+		Amount:             invoice.Amount,
+		Currency:           invoice.Currency,
+		Description:        invoice.Description,
+		DueDate:            invoice.DueDate,
+		Status:             invoice.Status,
+		ReceiverAddr:       invoice.ReceiverAddr,
+		Sender:             PublicParty{Name: invoice.SenderDetails.Name, Email: invoice.SenderDetails.Email},
+		Recipient:          PublicParty{Name: invoice.RecipientDetails.Name},
+		SettlementToken:    invoice.SettlementToken,
+		SettlementAmount:   invoice.SettlementAmount,
+		ExchangeRate:       invoice.ExchangeRate,
+		RateExpiresAt:      invoice.RateExpiresAt,
+		PaymentTxSignature: invoice.PaymentTxSignature,
+		PaidAt:             invoice.PaidAt,
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewPublicInvoiceLeavesOutPrivateDetails(t *testing.T) {
+	customerID := 7
+	invoice := Invoice{
+		ID:               42,
+		InvoiceNumber:    "INV-2026-00042",
+		Amount:           100,
+		Currency:         "USDC",
+		ReceiverAddr:     "receiver-address",
+		LinkToken:        "secret-link-token",
+		SenderDetails:    Person{Name: "Acme", Email: "billing@acme.test", Address: "1 Sender Street"},
+		RecipientDetails: Person{Name: "Jane", Email: "jane@example.test", Address: "2 Recipient Road"},
+		CustomerID:       &customerID,
+		Warnings:         []string{"receiver has no token account"},
+	}
+
+	public := NewPublicInvoice(invoice)
+	if public.InvoiceNumber != invoice.InvoiceNumber || public.ReceiverAddr != invoice.ReceiverAddr {
+		t.Errorf("Expected the payment details to be kept, got %+v", public)
+	}
+	if public.Sender.Email != "billing@acme.test" {
+		t.Errorf("Expected the sender's email to be shown, got %q", public.Sender.Email)
+	}
+
+	data, err := json.Marshal(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, private := range []string{"secret-link-token", "Sender Street", "Recipient Road", "jane@example.test", `"id"`, "customerId", "warnings", "createdAt"} {
+		if strings.Contains(string(data), private) {
+			t.Errorf("Expected %s to be left out of the public invoice, got %s", private, data)
+		}
+	}
+}
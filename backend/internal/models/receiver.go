@@ -0,0 +1,10 @@
+package models
+
+// ReceiverCheck is the outcome of checking that a receiver wallet can be paid
+// in an invoice's settlement token. TokenAccount is the wallet's associated
+// token account for the token's mint, empty for native SOL. Warnings describe
+// problems that do not prevent payment outright but are worth a second look.
+type ReceiverCheck struct {
+	TokenAccount string
+	Warnings     []string
+}
@@ -0,0 +1,282 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/numbering"
+	"gorm.io/gorm"
+)
+
+// RecurrenceFrequency is the unit of an RRULE-style recurrence interval
+type RecurrenceFrequency string
+
+const (
+	FrequencyDaily   RecurrenceFrequency = "DAILY"
+	FrequencyWeekly  RecurrenceFrequency = "WEEKLY"
+	FrequencyMonthly RecurrenceFrequency = "MONTHLY"
+	FrequencyYearly  RecurrenceFrequency = "YEARLY"
+)
+
+// InvoiceTemplate holds the invoice fields copied into every generated invoice.
+// When CustomerID is set, each invoice snapshots that customer's details at the
+// time it is issued instead of using RecipientDetails.
+type InvoiceTemplate struct {
+	Amount           float64  `json:"amount"`
+	Currency         string   `json:"currency"`
+	Description      string   `json:"description"`
+	ReceiverAddr     string   `json:"receiverAddr"`
+	SenderDetails    Person   `json:"senderDetails"`
+	RecipientDetails Person   `json:"recipientDetails"`
+	CustomerID       *int     `json:"customerId,omitempty"`
+	SettlementToken  string   `json:"settlementToken,omitempty"`
+	RateLock         RateLock `json:"rateLock,omitempty"`
+}
+
+// Value implements the driver.Valuer interface for InvoiceTemplate
+func (t InvoiceTemplate) Value() (driver.Value, error) {
+	return json.Marshal(t)
+}
+
+// Scan implements the sql.Scanner interface for InvoiceTemplate
+func (t *InvoiceTemplate) Scan(value interface{}) error {
+	if value == nil {
+		*t = InvoiceTemplate{}
+		return nil
+	}
+
+	var data []byte
+	switch v := value.(type) {
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("failed to scan InvoiceTemplate: unexpected type %T", value)
+	}
+
+	return json.Unmarshal(data, t)
+}
+
+// InvoiceTemplateFromInvoice copies the reusable fields of an existing invoice
+func InvoiceTemplateFromInvoice(invoice Invoice) InvoiceTemplate {
+	return InvoiceTemplate{
+		Amount:           invoice.Amount,
+		Currency:         invoice.Currency,
+		Description:      invoice.Description,
+		ReceiverAddr:     invoice.ReceiverAddr,
+		SenderDetails:    invoice.SenderDetails,
+		RecipientDetails: invoice.RecipientDetails,
+		CustomerID:       invoice.CustomerID,
+		SettlementToken:  invoice.SettlementToken,
+		RateLock:         invoice.RateLock,
+	}
+}
+
+// InvoiceTemplateFromDraft parses the form data saved in a draft invoice. The
+// frontend stores the amount either as a number or as the raw input string.
+func InvoiceTemplateFromDraft(draft DraftInvoice) (InvoiceTemplate, error) {
+	var data struct {
+		InvoiceTemplate
+		Amount json.RawMessage `json:"amount"`
+	}
+	if err := json.Unmarshal([]byte(draft.InvoiceData), &data); err != nil {
+		return InvoiceTemplate{}, fmt.Errorf("draft invoice data is not valid JSON: %w", err)
+	}
+
+	template := data.InvoiceTemplate
+	if len(data.Amount) > 0 {
+		var raw interface{}
+		if err := json.Unmarshal(data.Amount, &raw); err != nil {
+			return InvoiceTemplate{}, fmt.Errorf("draft invoice amount is invalid: %w", err)
+		}
+		switch v := raw.(type) {
+		case float64:
+			template.Amount = v
+		case string:
+			amount, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return InvoiceTemplate{}, fmt.Errorf("draft invoice amount is invalid: %w", err)
+			}
+			template.Amount = amount
+		}
+	}
+
+	return template, nil
+}
+
+// RecurringSchedule materializes a new invoice from a template at a fixed interval
+type RecurringSchedule struct {
+	ID                int                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Name              string              `json:"name" gorm:"not null;type:varchar(100)"`
+	Frequency         RecurrenceFrequency `json:"frequency" gorm:"not null;type:varchar(10)"`
+	Interval          int                 `json:"interval" gorm:"column:interval_count;not null;default:1"`
+	StartAt           time.Time           `json:"startAt" gorm:"not null"`
+	EndAt             *time.Time          `json:"endAt,omitempty"`
+	NumberPattern     string              `json:"numberPattern" gorm:"not null;type:varchar(60)"`
+	NumberSequence    string              `json:"numberSequence" gorm:"type:varchar(50)"`
+	DueDateOffsetDays int                 `json:"dueDateOffsetDays" gorm:"not null;default:30"`
+	Template          InvoiceTemplate     `json:"template" gorm:"type:jsonb;serializer:json"`
+	Active            bool                `json:"active" gorm:"not null;default:true"`
+	OccurrenceCount   int                 `json:"occurrenceCount" gorm:"not null;default:0"`
+	NextRunAt         *time.Time          `json:"nextRunAt,omitempty" gorm:"index:idx_recurring_next_run"`
+	LastRunAt         *time.Time          `json:"lastRunAt,omitempty"`
+	LastError         string              `json:"lastError,omitempty" gorm:"type:text"`
+	CreatedAt         time.Time           `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt         time.Time           `json:"updatedAt" gorm:"autoUpdateTime"`
+	DeletedAt         gorm.DeletedAt      `json:"-" gorm:"index"`
+}
+
+// TableName overrides the table name
+func (RecurringSchedule) TableName() string {
+	return "recurring_schedule"
+}
+
+// OccurrenceAt returns when the n-th (zero based) occurrence is due. Every
+// occurrence is computed from StartAt so month-end dates do not drift.
+func (s *RecurringSchedule) OccurrenceAt(n int) time.Time {
+	step := n * s.Interval
+
+	switch s.Frequency {
+	case FrequencyDaily:
+		return s.StartAt.AddDate(0, 0, step)
+	case FrequencyWeekly:
+		return s.StartAt.AddDate(0, 0, 7*step)
+	case FrequencyYearly:
+		return s.StartAt.AddDate(step, 0, 0)
+	default:
+		return s.StartAt.AddDate(0, step, 0)
+	}
+}
+
+// Schedule recomputes NextRunAt from OccurrenceCount, clearing it once EndAt has passed
+func (s *RecurringSchedule) Schedule() {
+	next := s.OccurrenceAt(s.OccurrenceCount)
+	if !s.Active || (s.EndAt != nil && next.After(*s.EndAt)) {
+		s.NextRunAt = nil
+		return
+	}
+	s.NextRunAt = &next
+}
+
+// SkipMissed moves OccurrenceCount past the occurrences due before now, so
+// they are never materialized. It is used when a paused schedule resumes.
+func (s *RecurringSchedule) SkipMissed(now time.Time) {
+	for s.OccurrenceAt(s.OccurrenceCount).Before(now) {
+		s.OccurrenceCount++
+	}
+}
+
+// RecurringInvoiceRun records one materialized occurrence of a schedule. The
+// unique (schedule, occurrence) pair is what makes materialization idempotent.
+type RecurringInvoiceRun struct {
+	ID            int       `json:"id" gorm:"primaryKey;autoIncrement"`
+	ScheduleID    int       `json:"scheduleId" gorm:"not null;uniqueIndex:idx_recurring_run_occurrence"`
+	Occurrence    int       `json:"occurrence" gorm:"not null;uniqueIndex:idx_recurring_run_occurrence"`
+	ScheduledFor  time.Time `json:"scheduledFor" gorm:"not null"`
+	InvoiceID     *int      `json:"invoiceId,omitempty"`
+	InvoiceNumber string    `json:"invoiceNumber" gorm:"type:varchar(50)"`
+	Error         string    `json:"error,omitempty" gorm:"type:text"`
+	CreatedAt     time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// TableName overrides the table name
+func (RecurringInvoiceRun) TableName() string {
+	return "recurring_invoice_run"
+}
+
+// CreateRecurringScheduleRequest represents the data required to create a recurring schedule.
+// Exactly one of TemplateInvoiceID, TemplateDraftID or Template must be provided.
+// Invoices are numbered from NumberSequence, or from a sequence of the schedule's
+// own with NumberPattern; exactly one of the two must be provided.
+type CreateRecurringScheduleRequest struct {
+	Name              string              `json:"name"`
+	Frequency         RecurrenceFrequency `json:"frequency"`
+	Interval          int                 `json:"interval"`
+	StartAt           time.Time           `json:"startAt"`
+	EndAt             *time.Time          `json:"endAt"`
+	NumberPattern     string              `json:"numberPattern"`
+	NumberSequence    string              `json:"numberSequence"`
+	DueDateOffsetDays *int                `json:"dueDateOffsetDays"`
+	TemplateInvoiceID *int                `json:"templateInvoiceId"`
+	TemplateDraftID   *string             `json:"templateDraftId"`
+	Template          *InvoiceTemplate    `json:"template"`
+}
+
+// Validate performs validation on the CreateRecurringScheduleRequest
+func (r *CreateRecurringScheduleRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	if r.Name == "" {
+		errors["name"] = "Name is required"
+	} else if len(r.Name) > 100 {
+		errors["name"] = "Name must be less than 100 characters"
+	}
+
+	switch r.Frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+	default:
+		errors["frequency"] = "Frequency must be one of DAILY, WEEKLY, MONTHLY, YEARLY"
+	}
+
+	if r.Interval < 0 {
+		errors["interval"] = "Interval must be positive"
+	}
+
+	if r.StartAt.IsZero() {
+		errors["startAt"] = "Start date is required"
+	}
+
+	if r.EndAt != nil && !r.EndAt.After(r.StartAt) {
+		errors["endAt"] = "End date must be after the start date"
+	}
+
+	switch {
+	case (r.NumberPattern == "") == (r.NumberSequence == ""):
+		errors["numberPattern"] = "Provide exactly one of numberPattern or numberSequence"
+	case r.NumberSequence != "":
+		if !sequenceNamePattern.MatchString(r.NumberSequence) {
+			errors["numberSequence"] = "Invalid number sequence name"
+		}
+	default:
+		if err := numbering.Validate(r.NumberPattern); err != nil {
+			errors["numberPattern"] = "Invalid number pattern: " + err.Error()
+		} else if len(r.NumberPattern) > 60 {
+			errors["numberPattern"] = "Number pattern must be less than 60 characters"
+		}
+	}
+
+	if r.DueDateOffsetDays != nil && *r.DueDateOffsetDays < 0 {
+		errors["dueDateOffsetDays"] = "Due date offset cannot be negative"
+	}
+
+	sources := 0
+	if r.TemplateInvoiceID != nil {
+		sources++
+	}
+	if r.TemplateDraftID != nil {
+		sources++
+	}
+	if r.Template != nil {
+		sources++
+	}
+	if sources != 1 {
+		errors["template"] = "Provide exactly one of templateInvoiceId, templateDraftId or template"
+	}
+
+	return errors
+}
+
+// UpdateRecurringScheduleRequest represents the mutable fields of a recurring schedule.
+// A paused schedule that is resumed skips the occurrences it missed while paused,
+// unless CatchUp asks for them to be invoiced as well.
+type UpdateRecurringScheduleRequest struct {
+	Active            *bool            `json:"active"`
+	CatchUp           bool             `json:"catchUp"`
+	EndAt             *time.Time       `json:"endAt"`
+	DueDateOffsetDays *int             `json:"dueDateOffsetDays"`
+	Template          *InvoiceTemplate `json:"template"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRecurringScheduleSkipMissed(t *testing.T) {
+	start := time.Date(2026, 1, 20, 9, 0, 0, 0, time.UTC)
+	schedule := RecurringSchedule{Frequency: FrequencyMonthly, Interval: 1, StartAt: start, OccurrenceCount: 1, Active: true}
+
+	// Paused after January's invoice and resumed in mid-May
+	schedule.SkipMissed(time.Date(2026, 5, 15, 0, 0, 0, 0, time.UTC))
+	if schedule.OccurrenceCount != 4 {
+		t.Fatalf("Expected February to April to be skipped, got occurrence %d", schedule.OccurrenceCount)
+	}
+
+	schedule.Schedule()
+	if want := start.AddDate(0, 4, 0); schedule.NextRunAt == nil || !schedule.NextRunAt.Equal(want) {
+		t.Errorf("Expected the next run on %s, got %v", want, schedule.NextRunAt)
+	}
+
+	// Nothing is skipped when the next occurrence is still ahead
+	schedule.SkipMissed(time.Date(2026, 5, 18, 0, 0, 0, 0, time.UTC))
+	if schedule.OccurrenceCount != 4 {
+		t.Errorf("Expected occurrence 4 to be kept, got %d", schedule.OccurrenceCount)
+	}
+}
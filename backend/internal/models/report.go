@@ -0,0 +1,122 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// AgingBuckets are the receivables aging buckets in order, by days past the due date
+var AgingBuckets = []string{"current", "1-30", "31-60", "61-90", "90+"}
+
+// AgingBucket is the pending amount in one aging bucket
+type AgingBucket struct {
+	Bucket   string  `json:"bucket"`
+	Amount   float64 `json:"amount"`
+	Invoices int     `json:"invoices"`
+}
+
+// AgingRow is one aggregate row of the aging query
+type AgingRow struct {
+	Currency string
+	Bucket   string
+	Amount   float64
+	Invoices int
+}
+
+// CurrencyAging breaks down what is outstanding in one currency. Buckets
+// always lists every bucket of AgingBuckets, in order.
+type CurrencyAging struct {
+	Currency string        `json:"currency"`
+	Total    float64       `json:"total"`
+	Invoices int           `json:"invoices"`
+	Buckets  []AgingBucket `json:"buckets"`
+}
+
+// AgingReport is the accounts receivable aging as of a point in time
+type AgingReport struct {
+	AsOf       time.Time       `json:"asOf"`
+	Currencies []CurrencyAging `json:"currencies"`
+}
+
+// NewAgingReport assembles aggregate rows into a report with every bucket
+// present for each currency, currencies in the order they first appear
+func NewAgingReport(asOf time.Time, rows []AgingRow) AgingReport {
+	report := AgingReport{AsOf: asOf, Currencies: []CurrencyAging{}}
+	index := make(map[string]int)
+
+	for _, row := range rows {
+		i, ok := index[row.Currency]
+		if !ok {
+			aging := CurrencyAging{Currency: row.Currency, Buckets: make([]AgingBucket, len(AgingBuckets))}
+			for b, name := range AgingBuckets {
+				aging.Buckets[b].Bucket = name
+			}
+			report.Currencies = append(report.Currencies, aging)
+			i = len(report.Currencies) - 1
+			index[row.Currency] = i
+		}
+
+		aging := &report.Currencies[i]
+		for b := range aging.Buckets {
+			if aging.Buckets[b].Bucket == row.Bucket {
+				aging.Buckets[b].Amount = RoundAmount(aging.Buckets[b].Amount + row.Amount)
+				aging.Buckets[b].Invoices += row.Invoices
+			}
+		}
+		aging.Total = RoundAmount(aging.Total + row.Amount)
+		aging.Invoices += row.Invoices
+	}
+
+	return report
+}
+
+// RevenueInterval is the period revenue is grouped by
+type RevenueInterval string
+
+const (
+	RevenueDaily   RevenueInterval = "day"
+	RevenueWeekly  RevenueInterval = "week"
+	RevenueMonthly RevenueInterval = "month"
+)
+
+// ParseRevenueInterval returns the interval with the given name, defaulting to month
+func ParseRevenueInterval(value string) (RevenueInterval, error) {
+	switch RevenueInterval(value) {
+	case "":
+		return RevenueMonthly, nil
+	case RevenueDaily, RevenueWeekly, RevenueMonthly:
+		return RevenueInterval(value), nil
+	default:
+		return "", fmt.Errorf("interval must be day, week or month")
+	}
+}
+
+// RevenuePoint is the revenue received in one currency during one period.
+// Period is the start of the period in UTC; weeks start on Monday.
+type RevenuePoint struct {
+	Period   time.Time `json:"period"`
+	Currency string    `json:"currency"`
+	Amount   float64   `json:"amount"`
+	Invoices int       `json:"invoices"`
+}
+
+// DaysToPay summarises how long paid invoices took to be paid, counted from
+// when they were issued
+type DaysToPay struct {
+	AverageDays float64 `json:"averageDays"`
+	MedianDays  float64 `json:"medianDays"`
+	PaidLate    int     `json:"paidLate"`
+	Invoices    int     `json:"invoices"`
+}
+
+// TopCustomer is the revenue received from one customer in one currency.
+// Invoices without a saved customer are grouped by recipient email, in which
+// case CustomerID is nil.
+type TopCustomer struct {
+	CustomerID *int    `json:"customerId,omitempty"`
+	Name       string  `json:"name"`
+	Email      string  `json:"email"`
+	Currency   string  `json:"currency"`
+	Revenue    float64 `json:"revenue"`
+	Invoices   int     `json:"invoices"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewAgingReport(t *testing.T) {
+	asOf := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	report := NewAgingReport(asOf, []AgingRow{
+		{Currency: "SOL", Bucket: "90+", Amount: 2, Invoices: 1},
+		{Currency: "USDC", Bucket: "1-30", Amount: 100.10, Invoices: 2},
+		{Currency: "USDC", Bucket: "current", Amount: 50.20, Invoices: 1},
+	})
+
+	if len(report.Currencies) != 2 {
+		t.Fatalf("Expected 2 currencies, got %d", len(report.Currencies))
+	}
+
+	usdc := report.Currencies[1]
+	if usdc.Currency != "USDC" || usdc.Total != 150.30 || usdc.Invoices != 3 {
+		t.Errorf("Unexpected USDC totals: %+v", usdc)
+	}
+	if len(usdc.Buckets) != len(AgingBuckets) {
+		t.Fatalf("Expected every bucket to be listed, got %+v", usdc.Buckets)
+	}
+	for i, bucket := range usdc.Buckets {
+		if bucket.Bucket != AgingBuckets[i] {
+			t.Errorf("Expected bucket %d to be %s, got %s", i, AgingBuckets[i], bucket.Bucket)
+		}
+	}
+	if usdc.Buckets[0].Amount != 50.20 || usdc.Buckets[1].Amount != 100.10 || usdc.Buckets[4].Amount != 0 {
+		t.Errorf("Unexpected USDC buckets: %+v", usdc.Buckets)
+	}
+}
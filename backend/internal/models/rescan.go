@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// RescanRequest asks the payment watcher to re-scan the transactions of a
+// receiver address, or of the receiver of one invoice, over a slot or time
+// range. Open bounds default to the receiver's first and latest transaction;
+// an invoice scan starts at the invoice's creation unless From is given.
+type RescanRequest struct {
+	ReceiverAddr string     `json:"receiverAddr,omitempty"`
+	InvoiceID    int        `json:"invoiceId,omitempty"`
+	From         *time.Time `json:"from,omitempty"`
+	To           *time.Time `json:"to,omitempty"`
+	FromSlot     uint64     `json:"fromSlot,omitempty"`
+	ToSlot       uint64     `json:"toSlot,omitempty"`
+}
+
+// Validate performs validation on the RescanRequest
+func (r *RescanRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	switch {
+	case r.ReceiverAddr == "" && r.InvoiceID == 0:
+		errors["receiverAddr"] = "Either a receiver address or an invoice ID is required"
+	case r.ReceiverAddr != "" && r.InvoiceID != 0:
+		errors["receiverAddr"] = "Give either a receiver address or an invoice ID, not both"
+	case r.ReceiverAddr != "":
+		validateSolanaAddress("receiverAddr", r.ReceiverAddr, errors)
+	case r.InvoiceID < 0:
+		errors["invoiceId"] = "Invoice ID must be positive"
+	}
+
+	if r.From != nil && r.To != nil && r.To.Before(*r.From) {
+		errors["to"] = "To must not be before from"
+	}
+	if r.ToSlot != 0 && r.ToSlot < r.FromSlot {
+		errors["toSlot"] = "To slot must not be below from slot"
+	}
+
+	return errors
+}
+
+// InRange reports whether a transaction in slot, at blockTime if known, falls
+// within the requested range
+func (r *RescanRequest) InRange(slot uint64, blockTime *time.Time) bool {
+	if slot < r.FromSlot || (r.ToSlot != 0 && slot > r.ToSlot) {
+		return false
+	}
+	if blockTime == nil {
+		return true
+	}
+	if r.From != nil && blockTime.Before(*r.From) {
+		return false
+	}
+	return r.To == nil || !blockTime.After(*r.To)
+}
+
+// BeforeRange reports whether a transaction in slot, at blockTime if known, is
+// older than the requested range, so paging further back can stop
+func (r *RescanRequest) BeforeRange(slot uint64, blockTime *time.Time) bool {
+	if slot < r.FromSlot {
+		return true
+	}
+	return blockTime != nil && r.From != nil && blockTime.Before(*r.From)
+}
+
+// RescanResult summarizes a rescan. Signatures counts the transactions in the
+// range, Recorded those already recorded by an earlier scan and skipped, and
+// Unfetched those whose details could not be fetched; rescanning the same range
+// again picks those up.
+type RescanResult struct {
+	ReceiverAddr string   `json:"receiverAddr"`
+	Signatures   int      `json:"signatures"`
+	Recorded     int      `json:"recorded"`
+	Transfers    int      `json:"transfers"`
+	Paid         []string `json:"paid"`
+	Flagged      int      `json:"flagged"`
+	Unmatched    int      `json:"unmatched"`
+	Unfetched    int      `json:"unfetched"`
+	OldestSlot   uint64   `json:"oldestSlot,omitempty"`
+	NewestSlot   uint64   `json:"newestSlot,omitempty"`
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRescanRequestValidate(t *testing.T) {
+	if _, ok := (&RescanRequest{}).Validate()["receiverAddr"]; !ok {
+		t.Error("Expected an error for a request without receiver or invoice")
+	}
+
+	both := RescanRequest{ReceiverAddr: "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU", InvoiceID: 1}
+	if _, ok := both.Validate()["receiverAddr"]; !ok {
+		t.Error("Expected an error for a request with both receiver and invoice")
+	}
+
+	from := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+	to := from.Add(-time.Hour)
+	backwards := RescanRequest{InvoiceID: 1, From: &from, To: &to, FromSlot: 10, ToSlot: 5}
+	errors := backwards.Validate()
+	if _, ok := errors["to"]; !ok {
+		t.Error("Expected an error for a time range ending before it starts")
+	}
+	if _, ok := errors["toSlot"]; !ok {
+		t.Error("Expected an error for a slot range ending before it starts")
+	}
+}
+
+func TestRescanRequestRange(t *testing.T) {
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	req := RescanRequest{From: &from, To: &to, FromSlot: 100, ToSlot: 200}
+
+	inside := from.Add(time.Hour)
+	after := to.Add(time.Second)
+	before := from.Add(-time.Second)
+
+	if !req.InRange(150, &inside) {
+		t.Error("Expected a transaction inside both ranges to be in range")
+	}
+	if !req.InRange(150, nil) {
+		t.Error("Expected a transaction without block time to be judged by slot alone")
+	}
+	if req.InRange(150, &after) || req.InRange(250, &inside) {
+		t.Error("Expected transactions after the range to be out of range")
+	}
+	if req.BeforeRange(150, &after) {
+		t.Error("Expected a transaction after the range not to end paging")
+	}
+	if !req.BeforeRange(150, &before) || !req.BeforeRange(99, nil) {
+		t.Error("Expected transactions before the range to end paging")
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// RPCCircuitState is the state of the circuit breaker of a Solana RPC endpoint
+type RPCCircuitState string
+
+const (
+	// RPCCircuitClosed means the endpoint is healthy and takes requests
+	RPCCircuitClosed RPCCircuitState = "closed"
+	// RPCCircuitOpen means the endpoint failed repeatedly and is skipped until RetryAt
+	RPCCircuitOpen RPCCircuitState = "open"
+	// RPCCircuitHalfOpen means one trial request is deciding whether the endpoint recovered
+	RPCCircuitHalfOpen RPCCircuitState = "half_open"
+	// RPCCircuitRateLimited means the endpoint answered 429 and is skipped until RetryAt
+	RPCCircuitRateLimited RPCCircuitState = "rate_limited"
+)
+
+// RPCEndpointHealth reports how a Solana RPC endpoint has been performing.
+// Endpoint is the scheme and host only, since providers put API keys in the
+// path or query. Latency and error rate are moving averages.
+type RPCEndpointHealth struct {
+	Endpoint  string          `json:"endpoint"`
+	Weight    int             `json:"weight"`
+	State     RPCCircuitState `json:"state"`
+	LatencyMs float64         `json:"latencyMs"`
+	ErrorRate float64         `json:"errorRate"`
+	Requests  int64           `json:"requests"`
+	Failures  int64           `json:"failures"`
+	LastError string          `json:"lastError,omitempty"`
+	RetryAt   *time.Time      `json:"retryAt,omitempty"`
+}
@@ -0,0 +1,31 @@
+package models
+
+// PaymentRequestLabel is the response to the GET of a Solana Pay transaction
+// request: the name and icon a wallet shows before asking for the payer's account
+type PaymentRequestLabel struct {
+	Label string `json:"label"`
+	Icon  string `json:"icon,omitempty"`
+}
+
+// PaymentTransactionRequest is the body a Solana Pay wallet POSTs with the
+// account that will sign and pay for the transaction
+type PaymentTransactionRequest struct {
+	Account string `json:"account"`
+}
+
+// Validate performs validation on the PaymentTransactionRequest
+func (r *PaymentTransactionRequest) Validate() map[string]string {
+	errors := make(map[string]string)
+
+	validateRequired("account", r.Account, errors)
+	validateSolanaAddress("account", r.Account, errors)
+
+	return errors
+}
+
+// PaymentTransaction is the response to the POST of a Solana Pay transaction
+// request: a base64 serialized transaction for the wallet to sign and send
+type PaymentTransaction struct {
+	Transaction string `json:"transaction"`
+	Message     string `json:"message,omitempty"`
+}
@@ -6,6 +6,8 @@ import (
 	"regexp"
 	"strings"
 	"time"
+
+	"github.com/gagliardetto/solana-go"
 )
 
 // ValidationErrors maps field names to error messages
@@ -83,10 +85,8 @@ func validateSolanaAddress(field, value string, errors ValidationErrors) {
 		return // Skip empty values, use validateRequired for required fields
 	}
 	
-	// Solana addresses are base58 encoded and typically 32-44 characters
-	// This is a simplified check - a real implementation would do more thorough validation
-	match, _ := regexp.MatchString(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`, value)
-	if !match {
+	// Solana addresses are base58 encoded 32 byte public keys
+	if _, err := solana.PublicKeyFromBase58(value); err != nil {
 		errors[field] = "Invalid Solana wallet address"
 	}
 }
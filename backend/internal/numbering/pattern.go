@@ -0,0 +1,109 @@
+// Package numbering renders document numbers from patterns such as
+// "INV-{YYYY}-{SEQ:5}"
+package numbering
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tokenPattern matches every placeholder of the form {NAME} or {NAME:WIDTH}
+var tokenPattern = regexp.MustCompile(`\{([A-Z]+)(?::(\d+))?\}`)
+
+// Format renders pattern for the given time and sequence number.
+//
+// Supported placeholders:
+//
+//	{YYYY}  four digit year
+//	{YY}    two digit year
+//	{MM}    two digit month
+//	{DD}    two digit day
+//	{SEQ}   the sequence number, optionally zero padded with {SEQ:5}
+func Format(pattern string, t time.Time, seq int64) (string, error) {
+	return render(pattern, t, func(width string) string {
+		if width == "" {
+			return strconv.FormatInt(seq, 10)
+		}
+		w, _ := strconv.Atoi(width)
+		return fmt.Sprintf("%0*d", w, seq)
+	})
+}
+
+// Scope renders every date placeholder of pattern but keeps the {SEQ}
+// placeholder, e.g. "INV-{YYYY}-{SEQ:5}" becomes "INV-2026-{SEQ}". Counters are
+// kept per scope, so a pattern containing {YYYY} restarts at 1 every year.
+func Scope(pattern string, t time.Time) (string, error) {
+	return render(pattern, t, func(string) string {
+		return "{SEQ}"
+	})
+}
+
+// render replaces the date placeholders of pattern and delegates {SEQ} to seq
+func render(pattern string, t time.Time, seq func(width string) string) (string, error) {
+	var renderErr error
+
+	out := tokenPattern.ReplaceAllStringFunc(pattern, func(token string) string {
+		parts := tokenPattern.FindStringSubmatch(token)
+		name, width := parts[1], parts[2]
+
+		switch name {
+		case "YYYY":
+			return fmt.Sprintf("%04d", t.Year())
+		case "YY":
+			return fmt.Sprintf("%02d", t.Year()%100)
+		case "MM":
+			return fmt.Sprintf("%02d", int(t.Month()))
+		case "DD":
+			return fmt.Sprintf("%02d", t.Day())
+		case "SEQ":
+			return seq(width)
+		default:
+			if renderErr == nil {
+				renderErr = fmt.Errorf("unknown placeholder %s", token)
+			}
+			return token
+		}
+	})
+
+	if renderErr != nil {
+		return "", renderErr
+	}
+
+	return out, nil
+}
+
+// Validate checks that a pattern only uses known placeholders and contains
+// exactly one {SEQ} placeholder, which is what keeps rendered numbers unique
+func Validate(pattern string) error {
+	if strings.TrimSpace(pattern) == "" {
+		return fmt.Errorf("pattern is required")
+	}
+
+	seqCount := 0
+	for _, parts := range tokenPattern.FindAllStringSubmatch(pattern, -1) {
+		switch parts[1] {
+		case "YYYY", "YY", "MM", "DD":
+			if parts[2] != "" {
+				return fmt.Errorf("placeholder {%s} does not take a width", parts[1])
+			}
+		case "SEQ":
+			seqCount++
+			if parts[2] != "" {
+				if width, _ := strconv.Atoi(parts[2]); width < 1 || width > 12 {
+					return fmt.Errorf("sequence width must be between 1 and 12")
+				}
+			}
+		default:
+			return fmt.Errorf("unknown placeholder {%s}", parts[1])
+		}
+	}
+
+	if seqCount != 1 {
+		return fmt.Errorf("pattern must contain exactly one {SEQ} placeholder")
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package numbering
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	date := time.Date(2026, time.March, 7, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		pattern  string
+		seq      int64
+		expected string
+	}{
+		{
+			name:     "Year and padded sequence",
+			pattern:  "INV-{YYYY}-{SEQ:5}",
+			seq:      42,
+			expected: "INV-2026-00042",
+		},
+		{
+			name:     "All date placeholders",
+			pattern:  "{YY}{MM}{DD}/{SEQ}",
+			seq:      7,
+			expected: "260307/7",
+		},
+		{
+			name:     "Sequence wider than padding",
+			pattern:  "R-{SEQ:2}",
+			seq:      1234,
+			expected: "R-1234",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := Format(tt.pattern, date, tt.seq)
+			if err != nil {
+				t.Fatalf("Format() returned error: %v", err)
+			}
+			if actual != tt.expected {
+				t.Errorf("Format() = %v, want %v", actual, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatUnknownPlaceholder(t *testing.T) {
+	if _, err := Format("INV-{FOO}-{SEQ}", time.Now(), 1); err == nil {
+		t.Error("Expected an error for an unknown placeholder")
+	}
+}
+
+func TestScope(t *testing.T) {
+	date := time.Date(2026, time.March, 7, 12, 0, 0, 0, time.UTC)
+
+	scope, err := Scope("INV-{YYYY}-{SEQ:5}", date)
+	if err != nil {
+		t.Fatalf("Scope() returned error: %v", err)
+	}
+	if scope != "INV-2026-{SEQ}" {
+		t.Errorf("Scope() = %v, want %v", scope, "INV-2026-{SEQ}")
+	}
+
+	nextYear, _ := Scope("INV-{YYYY}-{SEQ:5}", date.AddDate(1, 0, 0))
+	if nextYear == scope {
+		t.Error("Expected a yearly pattern to produce a new scope every year")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr bool
+	}{
+		{name: "Valid pattern", pattern: "INV-{YYYY}-{SEQ:5}", wantErr: false},
+		{name: "Empty pattern", pattern: "", wantErr: true},
+		{name: "Missing sequence", pattern: "INV-{YYYY}", wantErr: true},
+		{name: "Two sequences", pattern: "{SEQ}-{SEQ}", wantErr: true},
+		{name: "Unknown placeholder", pattern: "{NAME}-{SEQ}", wantErr: true},
+		{name: "Width on date placeholder", pattern: "{YYYY:2}-{SEQ}", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CheckoutRepository defines methods to interact with checkout links and their sessions in the database
+type CheckoutRepository interface {
+	CreateLink(ctx context.Context, link *models.CheckoutLink) error
+	FindLinkByID(ctx context.Context, id int) (*models.CheckoutLink, error)
+	FindLinkBySlug(ctx context.Context, slug string) (*models.CheckoutLink, error)
+	FindLinkBySlugForUpdate(ctx context.Context, slug string) (*models.CheckoutLink, error)
+	ListLinks(ctx context.Context, page, limit int) ([]models.CheckoutLink, int64, error)
+	UpdateLink(ctx context.Context, link *models.CheckoutLink) error
+	CountUses(ctx context.Context, linkIDs []int) (map[int]int, error)
+	CountHeld(ctx context.Context, linkID int, payableAfter time.Time) (int64, error)
+	CreateSession(ctx context.Context, session *models.CheckoutSession) error
+	ListSessions(ctx context.Context, linkID int) ([]models.CheckoutSession, error)
+	CompleteSession(ctx context.Context, invoiceID int, at time.Time) (bool, error)
+	FindAbandonedSessions(ctx context.Context, expiredBefore time.Time, limit int) ([]models.CheckoutSession, error)
+}
+
+// GORMCheckoutRepository implements CheckoutRepository using GORM
+type GORMCheckoutRepository struct {
+	db *gorm.DB
+}
+
+// NewCheckoutRepository creates a new checkout repository
+func NewCheckoutRepository(db *gorm.DB) CheckoutRepository {
+	return &GORMCheckoutRepository{db: db}
+}
+
+// CreateLink adds a new checkout link to the database
+func (r *GORMCheckoutRepository) CreateLink(ctx context.Context, link *models.CheckoutLink) error {
+	return r.db.WithContext(ctx).Create(link).Error
+}
+
+// FindLinkByID retrieves a checkout link by ID
+func (r *GORMCheckoutRepository) FindLinkByID(ctx context.Context, id int) (*models.CheckoutLink, error) {
+	var link models.CheckoutLink
+	if err := r.db.WithContext(ctx).First(&link, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// FindLinkBySlug retrieves a checkout link by the slug in its public URL
+func (r *GORMCheckoutRepository) FindLinkBySlug(ctx context.Context, slug string) (*models.CheckoutLink, error) {
+	var link models.CheckoutLink
+	if err := r.db.WithContext(ctx).Where("slug = ?", slug).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// FindLinkBySlugForUpdate retrieves a checkout link by slug and locks its row
+// until the surrounding transaction ends, so sessions are started on it one
+// at a time. It must be called on a transaction-scoped repository.
+func (r *GORMCheckoutRepository) FindLinkBySlugForUpdate(ctx context.Context, slug string) (*models.CheckoutLink, error) {
+	var link models.CheckoutLink
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("slug = ?", slug).
+		First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// ListLinks retrieves checkout links with pagination, newest first, and returns the total count
+func (r *GORMCheckoutRepository) ListLinks(ctx context.Context, page, limit int) ([]models.CheckoutLink, int64, error) {
+	var links []models.CheckoutLink
+	var total int64
+	offset := (page - 1) * limit
+
+	if err := r.db.WithContext(ctx).Model(&models.CheckoutLink{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.db.WithContext(ctx).
+		Offset(offset).
+		Limit(limit).
+		Order("created_at desc").
+		Find(&links).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return links, total, nil
+}
+
+// UpdateLink updates a checkout link
+func (r *GORMCheckoutRepository) UpdateLink(ctx context.Context, link *models.CheckoutLink) error {
+	return r.db.WithContext(ctx).Save(link).Error
+}
+
+// CountUses returns how many completed sessions each of the checkout links
+// has; links without any are left out
+func (r *GORMCheckoutRepository) CountUses(ctx context.Context, linkIDs []int) (map[int]int, error) {
+	var rows []struct {
+		CheckoutLinkID int
+		Uses           int
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.CheckoutSession{}).
+		Select("checkout_link_id, COUNT(*) AS uses").
+		Where("checkout_link_id IN ? AND completed_at IS NOT NULL", linkIDs).
+		Group("checkout_link_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	uses := make(map[int]int, len(rows))
+	for _, row := range rows {
+		uses[row.CheckoutLinkID] = row.Uses
+	}
+	return uses, nil
+}
+
+// CountHeld returns how many sessions of a checkout link count against its
+// MaxUses: the completed ones and the open ones whose invoice can still be
+// paid, that is, not canceled and expired no earlier than payableAfter
+func (r *GORMCheckoutRepository) CountHeld(ctx context.Context, linkID int, payableAfter time.Time) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&models.CheckoutSession{}).
+		Joins("JOIN invoice ON invoice.id = checkout_session.invoice_id").
+		Where("checkout_session.checkout_link_id = ?", linkID).
+		Where("(checkout_session.completed_at IS NOT NULL OR (checkout_session.expires_at > ? AND invoice.status <> ?))",
+			payableAfter, models.StatusCanceled).
+		Count(&count).Error
+	return count, err
+}
+
+// CreateSession adds a new checkout session to the database
+func (r *GORMCheckoutRepository) CreateSession(ctx context.Context, session *models.CheckoutSession) error {
+	return r.db.WithContext(ctx).Create(session).Error
+}
+
+// ListSessions retrieves the sessions of a checkout link, newest first
+func (r *GORMCheckoutRepository) ListSessions(ctx context.Context, linkID int) ([]models.CheckoutSession, error) {
+	var sessions []models.CheckoutSession
+	if err := r.db.WithContext(ctx).
+		Where("checkout_link_id = ?", linkID).
+		Order("created_at desc").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// CompleteSession completes the checkout session that materialized the
+// invoice, if there is one and it is not completed yet. It reports whether a
+// session was completed by this call.
+func (r *GORMCheckoutRepository) CompleteSession(ctx context.Context, invoiceID int, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.CheckoutSession{}).
+		Where("invoice_id = ? AND completed_at IS NULL", invoiceID).
+		Update("completed_at", at)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// FindAbandonedSessions retrieves up to limit sessions that expired before
+// expiredBefore while their invoice is still pending, oldest first
+func (r *GORMCheckoutRepository) FindAbandonedSessions(ctx context.Context, expiredBefore time.Time, limit int) ([]models.CheckoutSession, error) {
+	var sessions []models.CheckoutSession
+	if err := r.db.WithContext(ctx).
+		Select("checkout_session.*").
+		Joins("JOIN invoice ON invoice.id = checkout_session.invoice_id").
+		Where("checkout_session.completed_at IS NULL AND checkout_session.expires_at < ?", expiredBefore).
+		Where("invoice.status = ?", models.StatusPending).
+		Order("checkout_session.expires_at asc").
+		Limit(limit).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreditNoteRepository defines methods to interact with credit notes in the database
+type CreditNoteRepository interface {
+	Create(ctx context.Context, note *models.CreditNote) error
+	FindByID(ctx context.Context, id int) (*models.CreditNote, error)
+	FindByIDForUpdate(ctx context.Context, id int) (*models.CreditNote, error)
+	FindByRefundSignature(ctx context.Context, signature string) (*models.CreditNote, error)
+	ListByInvoice(ctx context.Context, invoiceID int) ([]models.CreditNote, error)
+	List(ctx context.Context, page, limit int) ([]models.CreditNote, int64, error)
+	NumberTaken(ctx context.Context, number string) (bool, error)
+	SumActiveByInvoice(ctx context.Context, invoiceID int) (float64, error)
+	FindAwaitingRefund(ctx context.Context) ([]models.CreditNote, error)
+	MarkRefunded(ctx context.Context, id int, signature string, refundedAt time.Time) (bool, error)
+	Update(ctx context.Context, note *models.CreditNote) error
+}
+
+// GORMCreditNoteRepository implements CreditNoteRepository using GORM
+type GORMCreditNoteRepository struct {
+	db *gorm.DB
+}
+
+// NewCreditNoteRepository creates a new credit note repository
+func NewCreditNoteRepository(db *gorm.DB) CreditNoteRepository {
+	return &GORMCreditNoteRepository{db: db}
+}
+
+// Create adds a new credit note to the database
+func (r *GORMCreditNoteRepository) Create(ctx context.Context, note *models.CreditNote) error {
+	return r.db.WithContext(ctx).Create(note).Error
+}
+
+// FindByID retrieves a credit note by ID
+func (r *GORMCreditNoteRepository) FindByID(ctx context.Context, id int) (*models.CreditNote, error) {
+	var note models.CreditNote
+	if err := r.db.WithContext(ctx).First(&note, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &note, nil
+}
+
+// FindByIDForUpdate retrieves a credit note by ID and locks its row until the
+// surrounding transaction ends. It must be called on a transaction-scoped repository.
+func (r *GORMCreditNoteRepository) FindByIDForUpdate(ctx context.Context, id int) (*models.CreditNote, error) {
+	var note models.CreditNote
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&note, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &note, nil
+}
+
+// FindByRefundSignature retrieves the credit note a refund transaction was recorded against
+func (r *GORMCreditNoteRepository) FindByRefundSignature(ctx context.Context, signature string) (*models.CreditNote, error) {
+	var note models.CreditNote
+	if err := r.db.WithContext(ctx).Where("refund_tx_signature = ?", signature).First(&note).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &note, nil
+}
+
+// ListByInvoice retrieves all credit notes issued against an invoice
+func (r *GORMCreditNoteRepository) ListByInvoice(ctx context.Context, invoiceID int) ([]models.CreditNote, error) {
+	var notes []models.CreditNote
+
+	if err := r.db.WithContext(ctx).
+		Where("invoice_id = ?", invoiceID).
+		Order("created_at asc").
+		Find(&notes).Error; err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// List retrieves credit notes with pagination and returns the total count
+func (r *GORMCreditNoteRepository) List(ctx context.Context, page, limit int) ([]models.CreditNote, int64, error) {
+	var notes []models.CreditNote
+	var total int64
+	offset := (page - 1) * limit
+
+	if err := r.db.WithContext(ctx).Model(&models.CreditNote{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.db.WithContext(ctx).
+		Offset(offset).
+		Limit(limit).
+		Order("created_at desc").
+		Find(&notes).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return notes, total, nil
+}
+
+// NumberTaken reports whether a credit note, voided or deleted ones included,
+// already has the number
+func (r *GORMCreditNoteRepository) NumberTaken(ctx context.Context, number string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Unscoped().
+		Model(&models.CreditNote{}).
+		Where("credit_note_number = ?", number).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// SumActiveByInvoice returns the total credited against an invoice, ignoring voided notes
+func (r *GORMCreditNoteRepository) SumActiveByInvoice(ctx context.Context, invoiceID int) (float64, error) {
+	var sum float64
+	err := r.db.WithContext(ctx).
+		Model(&models.CreditNote{}).
+		Select("COALESCE(SUM(amount), 0)").
+		Where("invoice_id = ? AND status <> ?", invoiceID, models.CreditNoteStatusVoid).
+		Scan(&sum).Error
+	return sum, err
+}
+
+// FindAwaitingRefund retrieves issued credit notes that have a refund address
+// but no recorded refund transaction yet
+func (r *GORMCreditNoteRepository) FindAwaitingRefund(ctx context.Context) ([]models.CreditNote, error) {
+	var notes []models.CreditNote
+
+	if err := r.db.WithContext(ctx).
+		Where("status = ? AND refund_addr <> '' AND refund_tx_signature = ''", models.CreditNoteStatusIssued).
+		Find(&notes).Error; err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
+
+// MarkRefunded records the refund transaction of a credit note that is still
+// ISSUED. It reports whether the note was marked by this call, so a note voided
+// or refunded concurrently is never overwritten.
+func (r *GORMCreditNoteRepository) MarkRefunded(ctx context.Context, id int, signature string, refundedAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.CreditNote{}).
+		Where("id = ? AND status = ?", id, models.CreditNoteStatusIssued).
+		Updates(map[string]interface{}{
+			"status":              models.CreditNoteStatusRefunded,
+			"refund_tx_signature": signature,
+			"refunded_at":         refundedAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Update updates a credit note
+func (r *GORMCreditNoteRepository) Update(ctx context.Context, note *models.CreditNote) error {
+	return r.db.WithContext(ctx).Save(note).Error
+}
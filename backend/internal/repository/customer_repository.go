@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+)
+
+// CustomerRepository defines methods to interact with customers in the database
+type CustomerRepository interface {
+	Create(ctx context.Context, customer *models.Customer) error
+	FindByID(ctx context.Context, id int) (*models.Customer, error)
+	List(ctx context.Context, search string, page, limit int) ([]models.Customer, int64, error)
+	Update(ctx context.Context, customer *models.Customer) error
+	Delete(ctx context.Context, id int) error
+	OutstandingBalances(ctx context.Context, customerID int, now time.Time) ([]models.CustomerBalance, error)
+}
+
+// GORMCustomerRepository implements CustomerRepository using GORM
+type GORMCustomerRepository struct {
+	db *gorm.DB
+}
+
+// NewCustomerRepository creates a new customer repository
+func NewCustomerRepository(db *gorm.DB) CustomerRepository {
+	return &GORMCustomerRepository{db: db}
+}
+
+// Create adds a new customer to the database
+func (r *GORMCustomerRepository) Create(ctx context.Context, customer *models.Customer) error {
+	return r.db.WithContext(ctx).Create(customer).Error
+}
+
+// FindByID retrieves a customer by ID
+func (r *GORMCustomerRepository) FindByID(ctx context.Context, id int) (*models.Customer, error) {
+	var customer models.Customer
+	if err := r.db.WithContext(ctx).First(&customer, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// List retrieves customers ordered by name with pagination and returns the
+// total count. A non-empty search matches part of the name or email.
+func (r *GORMCustomerRepository) List(ctx context.Context, search string, page, limit int) ([]models.Customer, int64, error) {
+	var customers []models.Customer
+	var total int64
+	offset := (page - 1) * limit
+
+	query := func() *gorm.DB {
+		q := r.db.WithContext(ctx).Model(&models.Customer{})
+		if search = strings.TrimSpace(search); search != "" {
+			pattern := "%" + escapeLike(search) + "%"
+			q = q.Where("name ILIKE ? OR email ILIKE ?", pattern, pattern)
+		}
+		return q
+	}
+
+	if err := query().Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := query().
+		Offset(offset).
+		Limit(limit).
+		Order("name asc, id asc").
+		Find(&customers).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return customers, total, nil
+}
+
+// Update updates a customer
+func (r *GORMCustomerRepository) Update(ctx context.Context, customer *models.Customer) error {
+	return r.db.WithContext(ctx).Save(customer).Error
+}
+
+// Delete soft-deletes a customer; invoices keep their snapshot of its details
+func (r *GORMCustomerRepository) Delete(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).Delete(&models.Customer{}, id).Error
+}
+
+// OutstandingBalances sums the customer's pending invoices per currency. The
+// overdue part covers invoices whose due date is before now.
+func (r *GORMCustomerRepository) OutstandingBalances(ctx context.Context, customerID int, now time.Time) ([]models.CustomerBalance, error) {
+	var balances []models.CustomerBalance
+
+	err := r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Select("currency, "+
+			"COALESCE(SUM(amount), 0) AS outstanding, "+
+			"COALESCE(SUM(CASE WHEN due_date < ? THEN amount ELSE 0 END), 0) AS overdue, "+
+			"COUNT(*) AS open_invoices", now).
+		Where("customer_id = ? AND status = ?", customerID, models.StatusPending).
+		Group("currency").
+		Order("currency asc").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+// escapeLike escapes the LIKE wildcards in user input so they match literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+)
+
+// DraftInvoiceRepository defines methods to interact with draft invoices in the database
+type DraftInvoiceRepository interface {
+	Create(ctx context.Context, draft *models.DraftInvoice) error
+	FindByID(ctx context.Context, id string) (*models.DraftInvoice, error)
+	FindByUserID(ctx context.Context, userID string) (*models.DraftInvoice, error)
+	UpdateData(ctx context.Context, id, invoiceData string) error
+	Delete(ctx context.Context, id string) error
+}
+
+// GORMDraftInvoiceRepository implements DraftInvoiceRepository using GORM
+type GORMDraftInvoiceRepository struct {
+	db *gorm.DB
+}
+
+// NewDraftInvoiceRepository creates a new draft invoice repository
+func NewDraftInvoiceRepository(db *gorm.DB) DraftInvoiceRepository {
+	return &GORMDraftInvoiceRepository{db: db}
+}
+
+// Create adds a new draft invoice to the database
+func (r *GORMDraftInvoiceRepository) Create(ctx context.Context, draft *models.DraftInvoice) error {
+	return r.db.WithContext(ctx).Create(draft).Error
+}
+
+// FindByID retrieves a draft invoice by ID. IDs that are not UUIDs match no
+// draft rather than failing the query.
+func (r *GORMDraftInvoiceRepository) FindByID(ctx context.Context, id string) (*models.DraftInvoice, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, nil
+	}
+
+	var draft models.DraftInvoice
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&draft).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// FindByUserID retrieves the draft invoice of a user
+func (r *GORMDraftInvoiceRepository) FindByUserID(ctx context.Context, userID string) (*models.DraftInvoice, error) {
+	var draft models.DraftInvoice
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&draft).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// UpdateData replaces the form data saved in a draft invoice
+func (r *GORMDraftInvoiceRepository) UpdateData(ctx context.Context, id, invoiceData string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.DraftInvoice{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"invoice_data": invoiceData,
+			"updated_at":   time.Now(),
+		}).Error
+}
+
+// Delete soft-deletes a draft invoice
+func (r *GORMDraftInvoiceRepository) Delete(ctx context.Context, id string) error {
+	return r.db.WithContext(ctx).Delete(&models.DraftInvoice{}, "id = ?", id).Error
+}
@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IdempotencyRepository stores idempotency keys and the responses they produced
+type IdempotencyRepository interface {
+	Reserve(ctx context.Context, key, requestHash string, lease time.Duration) (*models.IdempotencyKey, bool, error)
+	Complete(ctx context.Context, key, token string, statusCode int, contentType string, body []byte, ttl time.Duration) error
+	Release(ctx context.Context, key, token string) error
+}
+
+// GORMIdempotencyRepository implements IdempotencyRepository using GORM
+type GORMIdempotencyRepository struct {
+	db *gorm.DB
+}
+
+// NewIdempotencyRepository creates a new idempotency key repository
+func NewIdempotencyRepository(db *gorm.DB) IdempotencyRepository {
+	return &GORMIdempotencyRepository{db: db}
+}
+
+// Reserve claims a key for a new request. It returns reserved=true when the
+// caller owns the key and must process the request; otherwise it returns the
+// stored record of the earlier request. The reservation expires after lease
+// unless the request completes, and carries a new token the caller must pass
+// to Complete or Release. Expired keys are purged first, so a key can be
+// reused once its TTL has passed and a reservation left behind by a crashed
+// request can be taken over once its lease has.
+func (r *GORMIdempotencyRepository) Reserve(ctx context.Context, key, requestHash string, lease time.Duration) (*models.IdempotencyKey, bool, error) {
+	db := r.db.WithContext(ctx)
+	now := time.Now()
+
+	if err := db.Where("expires_at < ?", now).Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	record := models.IdempotencyKey{
+		Key:         key,
+		Token:       uuid.New().String(),
+		RequestHash: requestHash,
+		ExpiresAt:   now.Add(lease),
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return &record, true, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := db.Where("key = ?", key).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released between our insert attempt and the lookup; let the client retry
+			return nil, false, errors.New("idempotency key was released concurrently")
+		}
+		return nil, false, err
+	}
+
+	return &existing, false, nil
+}
+
+// Complete stores the response of the request holding the reservation token
+// and keeps it for ttl. It fails if the reservation was taken over.
+func (r *GORMIdempotencyRepository) Complete(ctx context.Context, key, token string, statusCode int, contentType string, body []byte, ttl time.Duration) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.IdempotencyKey{}).
+		Where("key = ? AND token = ? AND status_code = 0", key, token).
+		Updates(map[string]interface{}{
+			"status_code":   statusCode,
+			"content_type":  contentType,
+			"response_body": body,
+			"expires_at":    time.Now().Add(ttl),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errors.New("idempotency key reservation was taken over")
+	}
+	return nil
+}
+
+// Release forgets the reservation holding the token so a failed request can
+// be retried with its key. A reservation taken over by another request is kept.
+func (r *GORMIdempotencyRepository) Release(ctx context.Context, key, token string) error {
+	return r.db.WithContext(ctx).
+		Where("key = ? AND token = ? AND status_code = 0", key, token).
+		Delete(&models.IdempotencyKey{}).Error
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/testdb"
+)
+
+func TestIdempotencyTakenOverReservationIsKept(t *testing.T) {
+	db := testdb.Open(t, &models.IdempotencyKey{})
+	keys := NewIdempotencyRepository(db)
+	ctx := context.Background()
+
+	// The first request outlives its lease and a retry takes the key over
+	slow, reserved, err := keys.Reserve(ctx, "slow", "hash", -time.Second)
+	if err != nil || !reserved {
+		t.Fatalf("Expected the first request to reserve the key, got %v, %v", reserved, err)
+	}
+	retry, reserved, err := keys.Reserve(ctx, "slow", "hash", time.Minute)
+	if err != nil || !reserved {
+		t.Fatalf("Expected the retry to take over the expired reservation, got %v, %v", reserved, err)
+	}
+	if retry.Token == slow.Token {
+		t.Fatal("Expected each reservation to get its own token")
+	}
+
+	// When the first request finally finishes it must not touch the retry's reservation
+	if err := keys.Complete(ctx, "slow", slow.Token, http.StatusCreated, "", nil, time.Hour); err == nil {
+		t.Error("Expected completing a taken over reservation to fail")
+	}
+	if err := keys.Release(ctx, "slow", slow.Token); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	record, reserved, err := keys.Reserve(ctx, "slow", "hash", time.Minute)
+	if err != nil || reserved || record.Token != retry.Token || !record.InProgress() {
+		t.Fatalf("Expected the retry's reservation to be kept, got %+v, %v, %v", record, reserved, err)
+	}
+
+	if err := keys.Complete(ctx, "slow", retry.Token, http.StatusCreated, "", nil, time.Hour); err != nil {
+		t.Errorf("Expected the retry to complete its reservation, got %v", err)
+	}
+
+	// A completed key is not released by a late failure of the same request
+	if err := keys.Release(ctx, "slow", retry.Token); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	record, reserved, err = keys.Reserve(ctx, "slow", "hash", time.Minute)
+	if err != nil || reserved || record.StatusCode != http.StatusCreated {
+		t.Errorf("Expected the completed response to be kept, got %+v, %v, %v", record, reserved, err)
+	}
+}
@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+)
+
+// InvoiceBatchJobRepository defines methods to interact with asynchronous batch jobs in the database
+type InvoiceBatchJobRepository interface {
+	Create(ctx context.Context, job *models.InvoiceBatchJob) error
+	FindByID(ctx context.Context, id string) (*models.InvoiceBatchJob, error)
+	Claim(ctx context.Context, id string) (bool, error)
+	Heartbeat(ctx context.Context, id string, at time.Time) error
+	ListQueued(ctx context.Context) ([]models.InvoiceBatchJob, error)
+	FailStale(ctx context.Context, staleBefore time.Time, reason string) (int64, error)
+	Update(ctx context.Context, job *models.InvoiceBatchJob) error
+}
+
+// GORMInvoiceBatchJobRepository implements InvoiceBatchJobRepository using GORM
+type GORMInvoiceBatchJobRepository struct {
+	db *gorm.DB
+}
+
+// NewInvoiceBatchJobRepository creates a new batch job repository
+func NewInvoiceBatchJobRepository(db *gorm.DB) InvoiceBatchJobRepository {
+	return &GORMInvoiceBatchJobRepository{db: db}
+}
+
+// Create adds a new batch job to the database
+func (r *GORMInvoiceBatchJobRepository) Create(ctx context.Context, job *models.InvoiceBatchJob) error {
+	return r.db.WithContext(ctx).Create(job).Error
+}
+
+// FindByID retrieves a batch job by ID
+func (r *GORMInvoiceBatchJobRepository) FindByID(ctx context.Context, id string) (*models.InvoiceBatchJob, error) {
+	var job models.InvoiceBatchJob
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// Claim moves a queued job to RUNNING with a first heartbeat. It returns false
+// when the job was already claimed, so each job is processed by exactly one worker.
+func (r *GORMInvoiceBatchJobRepository) Claim(ctx context.Context, id string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.InvoiceBatchJob{}).
+		Where("id = ? AND status = ?", id, models.BatchJobQueued).
+		Updates(map[string]interface{}{
+			"status":       models.BatchJobRunning,
+			"heartbeat_at": time.Now(),
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Heartbeat records that the worker processing a RUNNING job is still alive
+func (r *GORMInvoiceBatchJobRepository) Heartbeat(ctx context.Context, id string, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.InvoiceBatchJob{}).
+		Where("id = ? AND status = ?", id, models.BatchJobRunning).
+		Update("heartbeat_at", at).Error
+}
+
+// ListQueued retrieves jobs that have not been picked up yet, oldest first
+func (r *GORMInvoiceBatchJobRepository) ListQueued(ctx context.Context) ([]models.InvoiceBatchJob, error) {
+	var jobs []models.InvoiceBatchJob
+
+	if err := r.db.WithContext(ctx).
+		Select("id").
+		Where("status = ?", models.BatchJobQueued).
+		Order("created_at asc").
+		Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+// FailStale marks RUNNING jobs whose last heartbeat is older than staleBefore
+// as FAILED. Such jobs were interrupted, e.g. by a restart, and are not retried
+// automatically because some of their invoices may already have been created.
+func (r *GORMInvoiceBatchJobRepository) FailStale(ctx context.Context, staleBefore time.Time, reason string) (int64, error) {
+	now := time.Now()
+
+	result := r.db.WithContext(ctx).
+		Model(&models.InvoiceBatchJob{}).
+		Where("status = ? AND COALESCE(heartbeat_at, updated_at) < ?", models.BatchJobRunning, staleBefore).
+		Updates(map[string]interface{}{
+			"status":       models.BatchJobFailed,
+			"error":        reason,
+			"completed_at": now,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// Update saves changes to an existing batch job
+func (r *GORMInvoiceBatchJobRepository) Update(ctx context.Context, job *models.InvoiceBatchJob) error {
+	return r.db.WithContext(ctx).Save(job).Error
+}
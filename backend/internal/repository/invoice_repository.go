@@ -3,21 +3,99 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/ncapetillo/demo-fluida/internal/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // InvoiceRepository defines methods to interact with invoices in the database
 type InvoiceRepository interface {
 	Create(ctx context.Context, invoice *models.Invoice) error
 	FindByID(ctx context.Context, id int) (*models.Invoice, error)
+	FindByIDForUpdate(ctx context.Context, id int) (*models.Invoice, error)
 	FindByLinkToken(ctx context.Context, linkToken string) (*models.Invoice, error)
 	FindByInvoiceNumber(ctx context.Context, invoiceNumber string) (*models.Invoice, error)
-	List(ctx context.Context, page, limit int) ([]models.Invoice, error)
+	FindByMemo(ctx context.Context, candidates []string) ([]models.Invoice, error)
+	List(ctx context.Context, filter models.InvoiceFilter, sort models.InvoiceSort, page, limit int) ([]models.Invoice, int64, error)
+	Count(ctx context.Context, filter models.InvoiceFilter) (int64, error)
 	UpdateStatus(ctx context.Context, id int, status models.InvoiceStatus) error
 	FindPendingInvoices(ctx context.Context) ([]models.Invoice, error)
+	FindRecentlySettledReceivers(ctx context.Context, since time.Time) ([]string, error)
+	MarkPaid(ctx context.Context, id int, txSignature string, paidAt time.Time) (bool, error)
+	ListByCursor(ctx context.Context, filter models.InvoiceFilter, descending bool, cursor *models.InvoiceCursor, limit int) ([]models.Invoice, bool, error)
+	Each(ctx context.Context, filter models.InvoiceFilter, after *models.InvoiceCursor, fn func(models.Invoice) error) error
 	Update(ctx context.Context, invoice *models.Invoice) error
+	SaveQuote(ctx context.Context, invoice *models.Invoice) (bool, error)
+	UpdateLinkToken(ctx context.Context, id int, linkToken string) error
+}
+
+// applyInvoiceFilter adds the filter conditions to a query
+func applyInvoiceFilter(query *gorm.DB, filter models.InvoiceFilter) *gorm.DB {
+	ranges := []struct {
+		column   string
+		from, to *time.Time
+	}{
+		{"created_at", filter.CreatedFrom, filter.CreatedTo},
+		{"due_date", filter.DueFrom, filter.DueTo},
+		{"paid_at", filter.PaidFrom, filter.PaidTo},
+	}
+	for _, rg := range ranges {
+		if rg.from != nil {
+			query = query.Where(rg.column+" >= ?", *rg.from)
+		}
+		if rg.to != nil {
+			query = query.Where(rg.column+" < ?", *rg.to)
+		}
+	}
+
+	if filter.MinAmount != nil {
+		query = query.Where("amount >= ?", *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		query = query.Where("amount <= ?", *filter.MaxAmount)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Currency != "" {
+		query = query.Where("currency = ?", filter.Currency)
+	}
+	if filter.ReceiverAddr != "" {
+		query = query.Where("receiver_addr = ?", filter.ReceiverAddr)
+	}
+	if filter.CustomerID != nil {
+		query = query.Where("customer_id = ?", *filter.CustomerID)
+	}
+	if tsQuery := prefixTSQuery(filter.Search); tsQuery != "" {
+		query = query.Where("search_vector @@ to_tsquery('simple', ?)", tsQuery)
+	}
+
+	return query
+}
+
+// prefixTSQuery turns free text into a tsquery that matches rows containing
+// every word as a prefix, e.g. "acme inv-2026" becomes "acme:* & inv-2026:*".
+// Characters with a meaning in tsquery syntax are dropped.
+func prefixTSQuery(search string) string {
+	var terms []string
+	for _, word := range strings.Fields(search) {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("@.-_", r) {
+				return r
+			}
+			return -1
+		}, word)
+		word = strings.Trim(word, ".-_")
+		if word != "" {
+			terms = append(terms, word+":*")
+		}
+	}
+	return strings.Join(terms, " & ")
 }
 
 // GORMInvoiceRepository implements InvoiceRepository using GORM
@@ -47,6 +125,21 @@ func (r *GORMInvoiceRepository) FindByID(ctx context.Context, id int) (*models.I
 	return &invoice, nil
 }
 
+// FindByIDForUpdate retrieves an invoice by ID and locks its row until the
+// surrounding transaction ends. It must be called on a transaction-scoped repository.
+func (r *GORMInvoiceRepository) FindByIDForUpdate(ctx context.Context, id int) (*models.Invoice, error) {
+	var invoice models.Invoice
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&invoice, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &invoice, nil
+}
+
 // FindByLinkToken retrieves an invoice by link token
 func (r *GORMInvoiceRepository) FindByLinkToken(ctx context.Context, linkToken string) (*models.Invoice, error) {
 	var invoice models.Invoice
@@ -71,20 +164,114 @@ func (r *GORMInvoiceRepository) FindByInvoiceNumber(ctx context.Context, invoice
 	return &invoice, nil
 }
 
-// List retrieves invoices with pagination
-func (r *GORMInvoiceRepository) List(ctx context.Context, page, limit int) ([]models.Invoice, error) {
+// FindByMemo retrieves the invoices, in any status, whose invoice number,
+// compared case-insensitively, or link token is one of the candidates taken
+// from a payment memo
+func (r *GORMInvoiceRepository) FindByMemo(ctx context.Context, candidates []string) ([]models.Invoice, error) {
 	var invoices []models.Invoice
-	offset := (page - 1) * limit
-	
+	if len(candidates) == 0 {
+		return invoices, nil
+	}
+	lowered := make([]string, len(candidates))
+	for i, candidate := range candidates {
+		lowered[i] = strings.ToLower(candidate)
+	}
 	if err := r.db.WithContext(ctx).
+		Where("lower(invoice_number) IN ? OR link_token IN ?", lowered, candidates).
+		Find(&invoices).Error; err != nil {
+		return nil, err
+	}
+	return invoices, nil
+}
+
+// List retrieves the invoices matching the filter in the given order, one page
+// at a time, and returns the total number of matching invoices
+func (r *GORMInvoiceRepository) List(ctx context.Context, filter models.InvoiceFilter, sort models.InvoiceSort, page, limit int) ([]models.Invoice, int64, error) {
+	var invoices []models.Invoice
+	
+	total, err := r.Count(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+	
+	if sort.Column == "" {
+		sort = models.InvoiceSort{Column: "created_at", Descending: true}
+	}
+	direction := "ASC"
+	if sort.Descending {
+		direction = "DESC"
+	}
+	// Nulls (unpaid invoices when sorting by paid_at) always go last;
+	// id breaks ties so pages never overlap
+	order := fmt.Sprintf("%s %s NULLS LAST, id %s", sort.Column, direction, direction)
+	
+	offset := (page - 1) * limit
+	if err := applyInvoiceFilter(r.db.WithContext(ctx), filter).
+		Order(order).
 		Offset(offset).
 		Limit(limit).
-		Order("created_at desc").
 		Find(&invoices).Error; err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 	
-	return invoices, nil
+	return invoices, total, nil
+}
+
+// Count returns the number of invoices matching the filter
+func (r *GORMInvoiceRepository) Count(ctx context.Context, filter models.InvoiceFilter) (int64, error) {
+	var total int64
+	err := applyInvoiceFilter(r.db.WithContext(ctx).Model(&models.Invoice{}), filter).Count(&total).Error
+	return total, err
+}
+
+// ListByCursor retrieves up to limit invoices ordered by (created_at, id) using
+// keyset pagination: the page starts right after the cursor, or ends right
+// before it for a Before cursor, so rows inserted meanwhile never shift pages.
+// hasMore reports whether more invoices exist in the direction of travel.
+// Invoices are always returned in list order.
+func (r *GORMInvoiceRepository) ListByCursor(ctx context.Context, filter models.InvoiceFilter, descending bool, cursor *models.InvoiceCursor, limit int) ([]models.Invoice, bool, error) {
+	var invoices []models.Invoice
+	
+	// A backwards page is read in the opposite order and reversed afterwards
+	scanDescending := descending
+	if cursor != nil && cursor.Before {
+		scanDescending = !scanDescending
+	}
+	
+	query := applyInvoiceFilter(r.db.WithContext(ctx), filter)
+	if cursor != nil {
+		operator := ">"
+		if scanDescending {
+			operator = "<"
+		}
+		query = query.Where("(created_at, id) "+operator+" (?, ?)", cursor.CreatedAt, cursor.ID)
+	}
+	
+	direction := "ASC"
+	if scanDescending {
+		direction = "DESC"
+	}
+	
+	// Fetch one extra row to learn whether another page exists
+	if err := query.
+		Order("created_at " + direction + ", id " + direction).
+		Limit(limit + 1).
+		Find(&invoices).Error; err != nil {
+		return nil, false, err
+	}
+	
+	hasMore := len(invoices) > limit
+	if hasMore {
+		invoices = invoices[:limit]
+	}
+	
+	if cursor != nil && cursor.Before {
+		for i, j := 0, len(invoices)-1; i < j; i, j = i+1, j-1 {
+			invoices[i], invoices[j] = invoices[j], invoices[i]
+		}
+	}
+	
+	return invoices, hasMore, nil
 }
 
 // UpdateStatus updates the status of an invoice
@@ -109,7 +296,103 @@ func (r *GORMInvoiceRepository) FindPendingInvoices(ctx context.Context) ([]mode
 	return invoices, nil
 }
 
+// FindRecentlySettledReceivers retrieves the distinct receiving wallets of
+// invoices paid or canceled since the given time
+func (r *GORMInvoiceRepository) FindRecentlySettledReceivers(ctx context.Context, since time.Time) ([]string, error) {
+	var receivers []string
+	
+	if err := r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Distinct("receiver_addr").
+		Where("(status = ? AND paid_at >= ?) OR (status = ? AND updated_at >= ?)",
+			models.StatusPaid, since, models.StatusCanceled, since).
+		Pluck("receiver_addr", &receivers).Error; err != nil {
+		return nil, err
+	}
+	
+	return receivers, nil
+}
+
 // Update updates an invoice
 func (r *GORMInvoiceRepository) Update(ctx context.Context, invoice *models.Invoice) error {
 	return r.db.WithContext(ctx).Save(invoice).Error
-} 
\ No newline at end of file
+}
+
+// SaveQuote stores a new settlement quote on a pending invoice. It returns
+// false when the invoice is no longer pending, e.g. because it was just paid.
+func (r *GORMInvoiceRepository) SaveQuote(ctx context.Context, invoice *models.Invoice) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Where("id = ? AND status = ?", invoice.ID, models.StatusPending).
+		Updates(map[string]interface{}{
+			"settlement_amount": invoice.SettlementAmount,
+			"exchange_rate":     invoice.ExchangeRate,
+			"rate_source":       invoice.RateSource,
+			"rate_locked_at":    invoice.RateLockedAt,
+			"rate_expires_at":   invoice.RateExpiresAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// MarkPaid sets a pending invoice to PAID and records the transaction that
+// paid it. It returns false when the invoice is no longer pending, e.g.
+// because it was canceled or paid by another transaction since it was read.
+func (r *GORMInvoiceRepository) MarkPaid(ctx context.Context, id int, txSignature string, paidAt time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Where("id = ? AND status = ?", id, models.StatusPending).
+		Updates(map[string]interface{}{
+			"status":               models.StatusPaid,
+			"payment_tx_signature": txSignature,
+			"paid_at":              paidAt,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Each calls fn for every invoice matching the filter, oldest first, starting
+// after the cursor when one is given. Rows are read from the database cursor
+// one at a time, so the result set is never held in memory. Iteration stops at
+// the first error returned by fn.
+func (r *GORMInvoiceRepository) Each(ctx context.Context, filter models.InvoiceFilter, after *models.InvoiceCursor, fn func(models.Invoice) error) error {
+	db := r.db.WithContext(ctx)
+
+	query := applyInvoiceFilter(db.Model(&models.Invoice{}), filter)
+	if after != nil {
+		query = query.Where("(created_at, id) > (?, ?)", after.CreatedAt, after.ID)
+	}
+
+	rows, err := query.
+		Order("created_at asc, id asc").
+		Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var invoice models.Invoice
+		if err := db.ScanRows(rows, &invoice); err != nil {
+			return err
+		}
+		if err := fn(invoice); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// UpdateLinkToken makes linkToken the invoice's own payment link token
+func (r *GORMInvoiceRepository) UpdateLinkToken(ctx context.Context, id int, linkToken string) error {
+	return r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Where("id = ?", id).
+		Update("link_token", linkToken).
+		Error
+}
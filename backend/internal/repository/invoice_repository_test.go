@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/testdb"
+)
+
+func TestPrefixTSQuery(t *testing.T) {
+	tests := []struct {
+		search string
+		want   string
+	}{
+		{"", ""},
+		{"acme", "acme:*"},
+		{"  Acme   INV-2026 ", "Acme:* & INV-2026:*"},
+		{"bob@example.com", "bob@example.com:*"},
+		{"a&b | !c", "ab:* & c:*"},
+		{"'); DROP TABLE invoice; --", "DROP:* & TABLE:* & invoice:*"},
+		{"...", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.search, func(t *testing.T) {
+			if got := prefixTSQuery(tt.search); got != tt.want {
+				t.Errorf("prefixTSQuery(%q) = %q, want %q", tt.search, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindRecentlySettledReceivers(t *testing.T) {
+	db := testdb.Open(t, &models.Invoice{}, &models.PaymentLink{})
+	invoices := NewInvoiceRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	create := func(number, receiver string, status models.InvoiceStatus, paidAt *time.Time) models.Invoice {
+		invoice := models.Invoice{
+			InvoiceNumber: number,
+			Amount:        100,
+			Currency:      "USDC",
+			DueDate:       now,
+			Status:        status,
+			ReceiverAddr:  receiver,
+			PaidAt:        paidAt,
+		}
+		if err := invoices.Create(ctx, &invoice); err != nil {
+			t.Fatalf("Failed to create invoice: %v", err)
+		}
+		return invoice
+	}
+
+	create("INV-1", "paid-recently", models.StatusPaid, ptrTime(now.Add(-time.Hour)))
+	create("INV-2", "paid-recently", models.StatusPaid, ptrTime(now.Add(-2*time.Hour)))
+	create("INV-3", "paid-long-ago", models.StatusPaid, ptrTime(now.AddDate(0, 0, -30)))
+	create("INV-4", "canceled-recently", models.StatusCanceled, nil)
+	create("INV-5", "pending-only", models.StatusPending, nil)
+
+	// Canceled long ago
+	old := create("INV-6", "canceled-long-ago", models.StatusCanceled, nil)
+	if err := db.Model(&old).UpdateColumn("updated_at", now.AddDate(0, 0, -30)).Error; err != nil {
+		t.Fatalf("Failed to backdate invoice: %v", err)
+	}
+
+	receivers, err := invoices.FindRecentlySettledReceivers(ctx, now.AddDate(0, 0, -7))
+	if err != nil {
+		t.Fatalf("FindRecentlySettledReceivers() error = %v", err)
+	}
+	sort.Strings(receivers)
+	if want := []string{"canceled-recently", "paid-recently"}; !reflect.DeepEqual(receivers, want) {
+		t.Errorf("Expected receivers %v, got %v", want, receivers)
+	}
+}
+
+func ptrTime(t time.Time) *time.Time {
+	return &t
+}
+
+func TestMarkPaidOnlyPendingInvoices(t *testing.T) {
+	db := testdb.Open(t, &models.Invoice{}, &models.PaymentLink{})
+	invoices := NewInvoiceRepository(db)
+	ctx := context.Background()
+	now := time.Now()
+
+	create := func(number string, status models.InvoiceStatus) models.Invoice {
+		invoice := models.Invoice{
+			InvoiceNumber: number,
+			Amount:        100,
+			Currency:      "USDC",
+			DueDate:       now,
+			Status:        status,
+			ReceiverAddr:  "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+		}
+		if err := invoices.Create(ctx, &invoice); err != nil {
+			t.Fatalf("Failed to create invoice: %v", err)
+		}
+		return invoice
+	}
+
+	pending := create("INV-1", models.StatusPending)
+	marked, err := invoices.MarkPaid(ctx, pending.ID, "sig-1", now)
+	if err != nil || !marked {
+		t.Fatalf("Expected a pending invoice to be marked paid, got %v, %v", marked, err)
+	}
+
+	// A second transfer does not replace the payment that settled the invoice
+	marked, err = invoices.MarkPaid(ctx, pending.ID, "sig-2", now.Add(time.Hour))
+	if err != nil || marked {
+		t.Errorf("Expected a paid invoice not to be marked again, got %v, %v", marked, err)
+	}
+	stored, err := invoices.FindByID(ctx, pending.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("FindByID() = %v, %v", stored, err)
+	}
+	if stored.PaymentTxSignature != "sig-1" {
+		t.Errorf("Expected the first payment to be kept, got %s", stored.PaymentTxSignature)
+	}
+
+	canceled := create("INV-2", models.StatusCanceled)
+	marked, err = invoices.MarkPaid(ctx, canceled.ID, "sig-3", now)
+	if err != nil || marked {
+		t.Errorf("Expected a canceled invoice not to be marked paid, got %v, %v", marked, err)
+	}
+}
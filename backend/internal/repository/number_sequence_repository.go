@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// NumberSequenceRepository defines methods to interact with number sequences in the database
+type NumberSequenceRepository interface {
+	Create(ctx context.Context, sequence *models.NumberSequence) error
+	CreateIfMissing(ctx context.Context, sequence *models.NumberSequence) error
+	FindByName(ctx context.Context, name string) (*models.NumberSequence, error)
+	List(ctx context.Context) ([]models.NumberSequence, error)
+	Update(ctx context.Context, sequence *models.NumberSequence) error
+	NextValue(ctx context.Context, sequenceID int, scope string) (int64, error)
+	PeekValue(ctx context.Context, sequenceID int, scope string) (int64, error)
+}
+
+// GORMNumberSequenceRepository implements NumberSequenceRepository using GORM
+type GORMNumberSequenceRepository struct {
+	db *gorm.DB
+}
+
+// NewNumberSequenceRepository creates a new number sequence repository
+func NewNumberSequenceRepository(db *gorm.DB) NumberSequenceRepository {
+	return &GORMNumberSequenceRepository{db: db}
+}
+
+// Create adds a new number sequence to the database
+func (r *GORMNumberSequenceRepository) Create(ctx context.Context, sequence *models.NumberSequence) error {
+	return r.db.WithContext(ctx).Create(sequence).Error
+}
+
+// CreateIfMissing adds a number sequence unless one with its name already
+// exists, in which case the existing sequence is left as it is
+func (r *GORMNumberSequenceRepository) CreateIfMissing(ctx context.Context, sequence *models.NumberSequence) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "name"}}, DoNothing: true}).
+		Create(sequence).Error
+}
+
+// FindByName retrieves a number sequence by name
+func (r *GORMNumberSequenceRepository) FindByName(ctx context.Context, name string) (*models.NumberSequence, error) {
+	var sequence models.NumberSequence
+	if err := r.db.WithContext(ctx).Where("name = ?", name).First(&sequence).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &sequence, nil
+}
+
+// List retrieves all number sequences
+func (r *GORMNumberSequenceRepository) List(ctx context.Context) ([]models.NumberSequence, error) {
+	var sequences []models.NumberSequence
+	if err := r.db.WithContext(ctx).Order("name asc").Find(&sequences).Error; err != nil {
+		return nil, err
+	}
+	return sequences, nil
+}
+
+// Update updates a number sequence
+func (r *GORMNumberSequenceRepository) Update(ctx context.Context, sequence *models.NumberSequence) error {
+	return r.db.WithContext(ctx).Save(sequence).Error
+}
+
+// NextValue increments the counter of a scope and returns the new value. The
+// upsert keeps the counter row locked until the surrounding transaction ends,
+// so concurrent allocations queue up and a rolled back invoice gives its number
+// back. This is what makes the sequence gap-free; call it inside the same
+// transaction that inserts the invoice.
+func (r *GORMNumberSequenceRepository) NextValue(ctx context.Context, sequenceID int, scope string) (int64, error) {
+	var value int64
+	err := r.db.WithContext(ctx).Raw(`
+		INSERT INTO number_sequence_counter (sequence_id, scope, last_value, updated_at)
+		VALUES (?, ?, 1, NOW())
+		ON CONFLICT (sequence_id, scope)
+		DO UPDATE SET last_value = number_sequence_counter.last_value + 1, updated_at = NOW()
+		RETURNING last_value`, sequenceID, scope).Scan(&value).Error
+	return value, err
+}
+
+// PeekValue returns the value the next allocation in a scope would receive without consuming it
+func (r *GORMNumberSequenceRepository) PeekValue(ctx context.Context, sequenceID int, scope string) (int64, error) {
+	var last int64
+	err := r.db.WithContext(ctx).
+		Model(&models.NumberSequenceCounter{}).
+		Select("COALESCE(MAX(last_value), 0)").
+		Where("sequence_id = ? AND scope = ?", sequenceID, scope).
+		Scan(&last).Error
+	return last + 1, err
+}
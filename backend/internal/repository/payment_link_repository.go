@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+)
+
+// PaymentLinkRepository defines methods to interact with invoice payment links in the database
+type PaymentLinkRepository interface {
+	Create(ctx context.Context, link *models.PaymentLink) error
+	FindByToken(ctx context.Context, token string) (*models.PaymentLink, error)
+	FindByID(ctx context.Context, invoiceID, id int) (*models.PaymentLink, error)
+	ListByInvoice(ctx context.Context, invoiceID int) ([]models.PaymentLink, error)
+	Revoke(ctx context.Context, id int, actor, reason string, at time.Time) (bool, error)
+	RevokeAllForInvoice(ctx context.Context, invoiceID int, actor, reason string, at time.Time) error
+	SetExpiry(ctx context.Context, token string, expiresAt *time.Time) error
+}
+
+// GORMPaymentLinkRepository implements PaymentLinkRepository using GORM
+type GORMPaymentLinkRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentLinkRepository creates a new payment link repository
+func NewPaymentLinkRepository(db *gorm.DB) PaymentLinkRepository {
+	return &GORMPaymentLinkRepository{db: db}
+}
+
+// Create issues a payment link
+func (r *GORMPaymentLinkRepository) Create(ctx context.Context, link *models.PaymentLink) error {
+	return r.db.WithContext(ctx).Create(link).Error
+}
+
+// FindByToken retrieves a payment link by its token, whether or not it still works
+func (r *GORMPaymentLinkRepository) FindByToken(ctx context.Context, token string) (*models.PaymentLink, error) {
+	var link models.PaymentLink
+	if err := r.db.WithContext(ctx).Where("token = ?", token).First(&link).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// FindByID retrieves a payment link of an invoice by ID
+func (r *GORMPaymentLinkRepository) FindByID(ctx context.Context, invoiceID, id int) (*models.PaymentLink, error) {
+	var link models.PaymentLink
+	if err := r.db.WithContext(ctx).Where("invoice_id = ?", invoiceID).First(&link, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// ListByInvoice retrieves the payment links of an invoice, oldest first
+func (r *GORMPaymentLinkRepository) ListByInvoice(ctx context.Context, invoiceID int) ([]models.PaymentLink, error) {
+	var links []models.PaymentLink
+	if err := r.db.WithContext(ctx).
+		Where("invoice_id = ?", invoiceID).
+		Order("id asc").
+		Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// Revoke revokes a payment link unless it was already revoked. It reports
+// whether the link was revoked by this call.
+func (r *GORMPaymentLinkRepository) Revoke(ctx context.Context, id int, actor, reason string, at time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.PaymentLink{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]interface{}{
+			"revoked_at":    at,
+			"revoked_by":    actor,
+			"revoke_reason": reason,
+		})
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// RevokeAllForInvoice revokes every payment link of an invoice that is not revoked yet
+func (r *GORMPaymentLinkRepository) RevokeAllForInvoice(ctx context.Context, invoiceID int, actor, reason string, at time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.PaymentLink{}).
+		Where("invoice_id = ? AND revoked_at IS NULL", invoiceID).
+		Updates(map[string]interface{}{
+			"revoked_at":    at,
+			"revoked_by":    actor,
+			"revoke_reason": reason,
+		}).Error
+}
+
+// SetExpiry sets when the payment link with the token stops working
+func (r *GORMPaymentLinkRepository) SetExpiry(ctx context.Context, token string, expiresAt *time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&models.PaymentLink{}).
+		Where("token = ?", token).
+		Update("expires_at", expiresAt).
+		Error
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PaymentRepository defines methods to interact with inbound payments in the database
+type PaymentRepository interface {
+	Create(ctx context.Context, payment *models.Payment) error
+	KnownSignatures(ctx context.Context, receiverAddr string, signatures []string) (map[string]bool, error)
+	List(ctx context.Context, status models.PaymentStatus, page, limit int) ([]models.Payment, int64, error)
+	ListUnmatched(ctx context.Context, page, limit int) ([]models.Payment, int64, error)
+	FindByID(ctx context.Context, id int) (*models.Payment, error)
+	FindByIDForUpdate(ctx context.Context, id int) (*models.Payment, error)
+	Update(ctx context.Context, payment *models.Payment) error
+	CreateAllocation(ctx context.Context, allocation *models.PaymentAllocation) error
+	ListAllocations(ctx context.Context, paymentID int) ([]models.PaymentAllocation, error)
+	SumAllocatedToInvoice(ctx context.Context, invoiceID int) (float64, error)
+	CreateAuditEntry(ctx context.Context, entry *models.PaymentAuditEntry) error
+	ListAuditEntries(ctx context.Context, paymentID int) ([]models.PaymentAuditEntry, error)
+}
+
+// GORMPaymentRepository implements PaymentRepository using GORM
+type GORMPaymentRepository struct {
+	db *gorm.DB
+}
+
+// NewPaymentRepository creates a new payment repository
+func NewPaymentRepository(db *gorm.DB) PaymentRepository {
+	return &GORMPaymentRepository{db: db}
+}
+
+// Create records a payment. A payment already recorded for the same
+// transaction and receiver is left as it is, so the watcher can record the
+// same transfer on every poll without duplicating it.
+func (r *GORMPaymentRepository) Create(ctx context.Context, payment *models.Payment) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(payment).Error
+}
+
+// KnownSignatures returns which of the signatures already have a payment
+// recorded for the receiver or are the recorded payment of an invoice, which
+// covers invoices paid before payments were recorded
+func (r *GORMPaymentRepository) KnownSignatures(ctx context.Context, receiverAddr string, signatures []string) (map[string]bool, error) {
+	known := make(map[string]bool)
+	if len(signatures) == 0 {
+		return known, nil
+	}
+
+	var found []string
+	if err := r.db.WithContext(ctx).
+		Model(&models.Payment{}).
+		Where("receiver_addr = ? AND signature IN ?", receiverAddr, signatures).
+		Pluck("signature", &found).Error; err != nil {
+		return nil, err
+	}
+
+	var paid []string
+	if err := r.db.WithContext(ctx).
+		Model(&models.Invoice{}).
+		Where("payment_tx_signature IN ?", signatures).
+		Pluck("payment_tx_signature", &paid).Error; err != nil {
+		return nil, err
+	}
+
+	for _, signature := range append(found, paid...) {
+		known[signature] = true
+	}
+	return known, nil
+}
+
+// List retrieves payments, newest first, optionally only those in one status
+func (r *GORMPaymentRepository) List(ctx context.Context, status models.PaymentStatus, page, limit int) ([]models.Payment, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Payment{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var payments []models.Payment
+	if err := query.
+		Order("block_time desc, id desc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&payments).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return payments, total, nil
+}
+
+// ListUnmatched retrieves the payments waiting for an operator, oldest first:
+// unmatched and flagged payments that are not fully allocated yet
+func (r *GORMPaymentRepository) ListUnmatched(ctx context.Context, page, limit int) ([]models.Payment, int64, error) {
+	query := r.db.WithContext(ctx).
+		Model(&models.Payment{}).
+		Where("status IN ?", []models.PaymentStatus{models.PaymentStatusUnmatched, models.PaymentStatusFlagged})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var payments []models.Payment
+	if err := query.
+		Order("block_time asc, id asc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&payments).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return payments, total, nil
+}
+
+// FindByID retrieves a payment by ID
+func (r *GORMPaymentRepository) FindByID(ctx context.Context, id int) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.WithContext(ctx).First(&payment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// FindByIDForUpdate retrieves a payment by ID and locks its row until the
+// surrounding transaction ends
+func (r *GORMPaymentRepository) FindByIDForUpdate(ctx context.Context, id int) (*models.Payment, error) {
+	var payment models.Payment
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&payment, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &payment, nil
+}
+
+// Update saves a payment
+func (r *GORMPaymentRepository) Update(ctx context.Context, payment *models.Payment) error {
+	return r.db.WithContext(ctx).Save(payment).Error
+}
+
+// CreateAllocation records the allocation of part of a payment to an invoice
+func (r *GORMPaymentRepository) CreateAllocation(ctx context.Context, allocation *models.PaymentAllocation) error {
+	return r.db.WithContext(ctx).Create(allocation).Error
+}
+
+// ListAllocations retrieves the allocations of a payment, oldest first
+func (r *GORMPaymentRepository) ListAllocations(ctx context.Context, paymentID int) ([]models.PaymentAllocation, error) {
+	var allocations []models.PaymentAllocation
+	if err := r.db.WithContext(ctx).
+		Where("payment_id = ?", paymentID).
+		Order("id asc").
+		Find(&allocations).Error; err != nil {
+		return nil, err
+	}
+	return allocations, nil
+}
+
+// SumAllocatedToInvoice returns how much of all payments has been allocated to an invoice
+func (r *GORMPaymentRepository) SumAllocatedToInvoice(ctx context.Context, invoiceID int) (float64, error) {
+	var total float64
+	if err := r.db.WithContext(ctx).
+		Model(&models.PaymentAllocation{}).
+		Where("invoice_id = ?", invoiceID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&total).Error; err != nil {
+		return 0, err
+	}
+	return total, nil
+}
+
+// CreateAuditEntry records an operator action on a payment
+func (r *GORMPaymentRepository) CreateAuditEntry(ctx context.Context, entry *models.PaymentAuditEntry) error {
+	return r.db.WithContext(ctx).Create(entry).Error
+}
+
+// ListAuditEntries retrieves the audit trail of a payment, oldest first
+func (r *GORMPaymentRepository) ListAuditEntries(ctx context.Context, paymentID int) ([]models.PaymentAuditEntry, error) {
+	var entries []models.PaymentAuditEntry
+	if err := r.db.WithContext(ctx).
+		Where("payment_id = ?", paymentID).
+		Order("id asc").
+		Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
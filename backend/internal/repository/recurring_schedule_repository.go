@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RecurringScheduleRepository defines methods to interact with recurring schedules in the database
+type RecurringScheduleRepository interface {
+	Create(ctx context.Context, schedule *models.RecurringSchedule) error
+	FindByID(ctx context.Context, id int) (*models.RecurringSchedule, error)
+	FindByIDForUpdate(ctx context.Context, id int) (*models.RecurringSchedule, error)
+	List(ctx context.Context, page, limit int) ([]models.RecurringSchedule, int64, error)
+	Update(ctx context.Context, schedule *models.RecurringSchedule) error
+	Delete(ctx context.Context, id int) error
+	ClaimNextDue(ctx context.Context, now time.Time) (*models.RecurringSchedule, error)
+	CreateRun(ctx context.Context, run *models.RecurringInvoiceRun) error
+	FindRun(ctx context.Context, scheduleID, occurrence int) (*models.RecurringInvoiceRun, error)
+	ListRuns(ctx context.Context, scheduleID int) ([]models.RecurringInvoiceRun, error)
+}
+
+// GORMRecurringScheduleRepository implements RecurringScheduleRepository using GORM
+type GORMRecurringScheduleRepository struct {
+	db *gorm.DB
+}
+
+// NewRecurringScheduleRepository creates a new recurring schedule repository
+func NewRecurringScheduleRepository(db *gorm.DB) RecurringScheduleRepository {
+	return &GORMRecurringScheduleRepository{db: db}
+}
+
+// Create adds a new recurring schedule to the database
+func (r *GORMRecurringScheduleRepository) Create(ctx context.Context, schedule *models.RecurringSchedule) error {
+	return r.db.WithContext(ctx).Create(schedule).Error
+}
+
+// FindByID retrieves a recurring schedule by ID
+func (r *GORMRecurringScheduleRepository) FindByID(ctx context.Context, id int) (*models.RecurringSchedule, error) {
+	var schedule models.RecurringSchedule
+	if err := r.db.WithContext(ctx).First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// FindByIDForUpdate retrieves a recurring schedule by ID and locks its row
+// until the surrounding transaction ends. It must be called on a
+// transaction-scoped repository.
+func (r *GORMRecurringScheduleRepository) FindByIDForUpdate(ctx context.Context, id int) (*models.RecurringSchedule, error) {
+	var schedule models.RecurringSchedule
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&schedule, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// List retrieves recurring schedules with pagination and returns the total count
+func (r *GORMRecurringScheduleRepository) List(ctx context.Context, page, limit int) ([]models.RecurringSchedule, int64, error) {
+	var schedules []models.RecurringSchedule
+	var total int64
+	offset := (page - 1) * limit
+
+	if err := r.db.WithContext(ctx).Model(&models.RecurringSchedule{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	if err := r.db.WithContext(ctx).
+		Offset(offset).
+		Limit(limit).
+		Order("created_at desc").
+		Find(&schedules).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return schedules, total, nil
+}
+
+// Update updates a recurring schedule
+func (r *GORMRecurringScheduleRepository) Update(ctx context.Context, schedule *models.RecurringSchedule) error {
+	return r.db.WithContext(ctx).Save(schedule).Error
+}
+
+// Delete soft-deletes a recurring schedule
+func (r *GORMRecurringScheduleRepository) Delete(ctx context.Context, id int) error {
+	return r.db.WithContext(ctx).Delete(&models.RecurringSchedule{}, id).Error
+}
+
+// ClaimNextDue locks the oldest due schedule that no other transaction holds.
+// SKIP LOCKED lets several instances run the scheduler without blocking on, or
+// double-processing, the same schedule. It must be called inside a transaction.
+func (r *GORMRecurringScheduleRepository) ClaimNextDue(ctx context.Context, now time.Time) (*models.RecurringSchedule, error) {
+	var schedule models.RecurringSchedule
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+		Where("active = ? AND next_run_at IS NOT NULL AND next_run_at <= ?", true, now).
+		Order("next_run_at asc").
+		First(&schedule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// CreateRun records a materialized occurrence
+func (r *GORMRecurringScheduleRepository) CreateRun(ctx context.Context, run *models.RecurringInvoiceRun) error {
+	return r.db.WithContext(ctx).Create(run).Error
+}
+
+// FindRun retrieves the run of a given occurrence, if it was already materialized
+func (r *GORMRecurringScheduleRepository) FindRun(ctx context.Context, scheduleID, occurrence int) (*models.RecurringInvoiceRun, error) {
+	var run models.RecurringInvoiceRun
+	if err := r.db.WithContext(ctx).
+		Where("schedule_id = ? AND occurrence = ?", scheduleID, occurrence).
+		First(&run).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListRuns retrieves every run of a schedule, newest first
+func (r *GORMRecurringScheduleRepository) ListRuns(ctx context.Context, scheduleID int) ([]models.RecurringInvoiceRun, error) {
+	var runs []models.RecurringInvoiceRun
+
+	if err := r.db.WithContext(ctx).
+		Where("schedule_id = ?", scheduleID).
+		Order("occurrence desc").
+		Find(&runs).Error; err != nil {
+		return nil, err
+	}
+
+	return runs, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"gorm.io/gorm"
+)
+
+// ReportRepository computes invoice analytics with SQL aggregates. Every
+// method takes an InvoiceFilter, so a report can be scoped to one receiving
+// wallet, customer or currency like the invoice list.
+type ReportRepository interface {
+	Aging(ctx context.Context, filter models.InvoiceFilter, asOf time.Time) ([]models.AgingRow, error)
+	Revenue(ctx context.Context, filter models.InvoiceFilter, interval models.RevenueInterval) ([]models.RevenuePoint, error)
+	DaysToPay(ctx context.Context, filter models.InvoiceFilter) (models.DaysToPay, error)
+	TopCustomers(ctx context.Context, filter models.InvoiceFilter, limit int) ([]models.TopCustomer, error)
+}
+
+// GORMReportRepository implements ReportRepository using GORM
+type GORMReportRepository struct {
+	db *gorm.DB
+}
+
+// NewReportRepository creates a new report repository
+func NewReportRepository(db *gorm.DB) ReportRepository {
+	return &GORMReportRepository{db: db}
+}
+
+func (r *GORMReportRepository) invoices(ctx context.Context, filter models.InvoiceFilter) *gorm.DB {
+	return applyInvoiceFilter(r.db.WithContext(ctx).Model(&models.Invoice{}), filter)
+}
+
+// Aging sums pending invoices per currency and aging bucket, by how many days
+// before asOf they fell due
+func (r *GORMReportRepository) Aging(ctx context.Context, filter models.InvoiceFilter, asOf time.Time) ([]models.AgingRow, error) {
+	var rows []models.AgingRow
+
+	err := r.invoices(ctx, filter).
+		Select(`currency,
+			CASE
+				WHEN due_date >= ? THEN 'current'
+				WHEN due_date >= ? THEN '1-30'
+				WHEN due_date >= ? THEN '31-60'
+				WHEN due_date >= ? THEN '61-90'
+				ELSE '90+'
+			END AS bucket,
+			SUM(amount) AS amount,
+			COUNT(*) AS invoices`,
+			asOf, asOf.AddDate(0, 0, -30), asOf.AddDate(0, 0, -60), asOf.AddDate(0, 0, -90)).
+		Where("status = ?", models.StatusPending).
+		Group("1, 2").
+		Order("1, 2").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// Revenue sums paid invoices per currency and period of their payment date
+func (r *GORMReportRepository) Revenue(ctx context.Context, filter models.InvoiceFilter, interval models.RevenueInterval) ([]models.RevenuePoint, error) {
+	var points []models.RevenuePoint
+
+	err := r.invoices(ctx, filter).
+		Select("date_trunc(?, paid_at AT TIME ZONE 'UTC') AS period, currency, SUM(amount) AS amount, COUNT(*) AS invoices", string(interval)).
+		Where("status = ? AND paid_at IS NOT NULL", models.StatusPaid).
+		Group("1, 2").
+		Order("1, 2").
+		Scan(&points).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return points, nil
+}
+
+// DaysToPay measures the time between issuing and payment of paid invoices
+func (r *GORMReportRepository) DaysToPay(ctx context.Context, filter models.InvoiceFilter) (models.DaysToPay, error) {
+	var result models.DaysToPay
+
+	err := r.invoices(ctx, filter).
+		Select(`COALESCE(AVG(EXTRACT(EPOCH FROM paid_at - created_at)), 0) / 86400 AS average_days,
+			COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM paid_at - created_at)), 0) / 86400 AS median_days,
+			COUNT(*) FILTER (WHERE paid_at > due_date) AS paid_late,
+			COUNT(*) AS invoices`).
+		Where("status = ? AND paid_at IS NOT NULL", models.StatusPaid).
+		Scan(&result).Error
+
+	return result, err
+}
+
+// TopCustomers ranks payers by revenue received. Invoices are grouped by
+// customer when one is linked and by recipient email otherwise. Amounts in
+// different currencies are ranked side by side without conversion.
+func (r *GORMReportRepository) TopCustomers(ctx context.Context, filter models.InvoiceFilter, limit int) ([]models.TopCustomer, error) {
+	var customers []models.TopCustomer
+
+	err := r.invoices(ctx, filter).
+		Select(`MIN(customer_id) AS customer_id,
+			MAX(recipient_details->>'name') AS name,
+			MAX(recipient_details->>'email') AS email,
+			currency,
+			SUM(amount) AS revenue,
+			COUNT(*) AS invoices`).
+		Where("status = ?", models.StatusPaid).
+		Group("COALESCE(customer_id::text, lower(recipient_details->>'email')), currency").
+		Order("revenue desc, invoices desc").
+		Limit(limit).
+		Scan(&customers).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return customers, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/testdb"
+	"gorm.io/gorm"
+)
+
+func newReportTestRepository(t *testing.T) (ReportRepository, *gorm.DB) {
+	t.Helper()
+
+	db := testdb.Open(t, &models.Invoice{}, &models.PaymentLink{})
+	return NewReportRepository(db), db
+}
+
+// createReportInvoice stores an invoice with the fields reports aggregate on
+func createReportInvoice(t *testing.T, db *gorm.DB, invoice models.Invoice) {
+	t.Helper()
+
+	var count int64
+	db.Model(&models.Invoice{}).Count(&count)
+	invoice.InvoiceNumber = fmt.Sprintf("RPT-%03d", count+1)
+	invoice.ReceiverAddr = "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU"
+	if invoice.Currency == "" {
+		invoice.Currency = "USDC"
+	}
+	if invoice.DueDate.IsZero() {
+		invoice.DueDate = time.Now()
+	}
+	if invoice.Status == models.StatusPaid && invoice.PaidAt == nil {
+		paidAt := time.Now()
+		invoice.PaidAt = &paidAt
+	}
+
+	if err := db.Create(&invoice).Error; err != nil {
+		t.Fatalf("Failed to create invoice: %v", err)
+	}
+}
+
+func TestAgingBucketBoundaries(t *testing.T) {
+	reports, db := newReportTestRepository(t)
+	asOf := time.Date(2026, 6, 30, 12, 0, 0, 0, time.UTC)
+
+	// Each invoice's amount identifies it in the bucket totals
+	dueDates := []struct {
+		due    time.Time
+		amount float64
+		bucket string
+	}{
+		{asOf.AddDate(0, 0, 1), 1, "current"},
+		{asOf, 2, "current"},
+		{asOf.Add(-time.Second), 4, "1-30"},
+		{asOf.AddDate(0, 0, -30), 8, "1-30"},
+		{asOf.AddDate(0, 0, -30).Add(-time.Second), 16, "31-60"},
+		{asOf.AddDate(0, 0, -60), 32, "31-60"},
+		{asOf.AddDate(0, 0, -60).Add(-time.Second), 64, "61-90"},
+		{asOf.AddDate(0, 0, -90), 128, "61-90"},
+		{asOf.AddDate(0, 0, -90).Add(-time.Second), 256, "90+"},
+	}
+	want := map[string]float64{}
+	for _, d := range dueDates {
+		createReportInvoice(t, db, models.Invoice{Amount: d.amount, DueDate: d.due, Status: models.StatusPending})
+		want[d.bucket] += d.amount
+	}
+
+	// Only pending invoices are outstanding
+	createReportInvoice(t, db, models.Invoice{Amount: 512, DueDate: asOf.AddDate(0, 0, -45), Status: models.StatusPaid})
+	createReportInvoice(t, db, models.Invoice{Amount: 1024, DueDate: asOf.AddDate(0, 0, -45), Status: models.StatusCanceled})
+
+	rows, err := reports.Aging(context.Background(), models.InvoiceFilter{}, asOf)
+	if err != nil {
+		t.Fatalf("Aging() error = %v", err)
+	}
+
+	got := map[string]float64{}
+	for _, row := range rows {
+		if row.Currency != "USDC" {
+			t.Errorf("Expected only USDC rows, got %+v", row)
+		}
+		got[row.Bucket] += row.Amount
+	}
+	for _, bucket := range models.AgingBuckets {
+		if got[bucket] != want[bucket] {
+			t.Errorf("Bucket %s: expected %.0f, got %.0f", bucket, want[bucket], got[bucket])
+		}
+	}
+}
+
+func TestTopCustomersGrouping(t *testing.T) {
+	reports, db := newReportTestRepository(t)
+	acme, globex := 1, 2
+
+	// A linked customer is one payer whatever email its invoices were sent to
+	createReportInvoice(t, db, models.Invoice{Amount: 100, Status: models.StatusPaid, CustomerID: &acme,
+		RecipientDetails: models.Person{Name: "Acme", Email: "billing@acme.test"}})
+	createReportInvoice(t, db, models.Invoice{Amount: 50, Status: models.StatusPaid, CustomerID: &acme,
+		RecipientDetails: models.Person{Name: "Acme", Email: "ap@acme.test"}})
+	// ...but is ranked once per currency
+	createReportInvoice(t, db, models.Invoice{Amount: 10, Currency: "EURC", Status: models.StatusPaid, CustomerID: &acme,
+		RecipientDetails: models.Person{Name: "Acme", Email: "billing@acme.test"}})
+
+	// Without a customer, invoices are grouped by email regardless of case
+	createReportInvoice(t, db, models.Invoice{Amount: 70, Status: models.StatusPaid,
+		RecipientDetails: models.Person{Name: "Bob", Email: "Bob@Example.test"}})
+	createReportInvoice(t, db, models.Invoice{Amount: 50, Status: models.StatusPaid,
+		RecipientDetails: models.Person{Name: "Bob", Email: "bob@example.test"}})
+
+	// A customer sharing that email is still a payer of its own
+	createReportInvoice(t, db, models.Invoice{Amount: 30, Status: models.StatusPaid, CustomerID: &globex,
+		RecipientDetails: models.Person{Name: "Globex", Email: "bob@example.test"}})
+
+	// Unpaid invoices bring no revenue
+	createReportInvoice(t, db, models.Invoice{Amount: 1000, Status: models.StatusPending, CustomerID: &globex,
+		RecipientDetails: models.Person{Name: "Globex", Email: "bob@example.test"}})
+
+	customers, err := reports.TopCustomers(context.Background(), models.InvoiceFilter{}, 10)
+	if err != nil {
+		t.Fatalf("TopCustomers() error = %v", err)
+	}
+
+	want := []struct {
+		customerID *int
+		currency   string
+		revenue    float64
+		invoices   int
+	}{
+		{&acme, "USDC", 150, 2},
+		{nil, "USDC", 120, 2},
+		{&globex, "USDC", 30, 1},
+		{&acme, "EURC", 10, 1},
+	}
+	if len(customers) != len(want) {
+		t.Fatalf("Expected %d payers, got %+v", len(want), customers)
+	}
+	for i, w := range want {
+		got := customers[i]
+		sameCustomer := (got.CustomerID == nil && w.customerID == nil) ||
+			(got.CustomerID != nil && w.customerID != nil && *got.CustomerID == *w.customerID)
+		if !sameCustomer || got.Currency != w.currency || got.Revenue != w.revenue || got.Invoices != w.invoices {
+			t.Errorf("Rank %d: expected customer %v %s %.0f over %d invoices, got %+v",
+				i+1, w.customerID, w.currency, w.revenue, w.invoices, got)
+		}
+	}
+
+	// The email group reports the address it was sent to
+	if email := customers[1].Email; email != "bob@example.test" && email != "Bob@Example.test" {
+		t.Errorf("Expected the email group to report bob's address, got %q", email)
+	}
+
+	// The limit keeps the highest ranked payers
+	top, err := reports.TopCustomers(context.Background(), models.InvoiceFilter{}, 1)
+	if err != nil {
+		t.Fatalf("TopCustomers() error = %v", err)
+	}
+	if len(top) != 1 || top[0].Revenue != 150 {
+		t.Errorf("Expected only the top payer, got %+v", top)
+	}
+}
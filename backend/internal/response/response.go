@@ -51,18 +51,39 @@ func (r *Response) WithMeta(meta interface{}) *Response {
 	return r
 }
 
+// Pagination describes the position of a page within a list. Next and Prev are
+// opaque cursors for the neighbouring pages on endpoints that support them.
+type Pagination struct {
+	Total int    `json:"total"`
+	Page  int    `json:"page"`
+	Limit int    `json:"limit"`
+	Next  string `json:"next,omitempty"`
+	Prev  string `json:"prev,omitempty"`
+}
+
 // WithPagination adds pagination metadata to the response
 func (r *Response) WithPagination(total, page, limit int) *Response {
 	r.Meta = map[string]interface{}{
-		"pagination": map[string]int{
-			"total": total,
-			"page":  page,
-			"limit": limit,
+		"pagination": &Pagination{
+			Total: total,
+			Page:  page,
+			Limit: limit,
 		},
 	}
 	return r
 }
 
+// WithCursors adds next and prev cursors to the pagination metadata set by WithPagination
+func (r *Response) WithCursors(next, prev string) *Response {
+	if meta, ok := r.Meta.(map[string]interface{}); ok {
+		if pagination, ok := meta["pagination"].(*Pagination); ok {
+			pagination.Next = next
+			pagination.Prev = prev
+		}
+	}
+	return r
+}
+
 // WithError adds an error to the response
 func (r *Response) WithError(message, code string) *Response {
 	r.Error = &ErrorResponse{
@@ -0,0 +1,446 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the checkout service
+var (
+	ErrCheckoutLinkNotFound    = errors.New("checkout link not found")
+	ErrCheckoutLinkUnavailable = errors.New("checkout link is no longer available")
+	ErrCheckoutLinkSoldOut     = errors.New("checkout link has reached its maximum uses")
+)
+
+const (
+	// How long a visitor has to pay a checkout session, unless CHECKOUT_SESSION_TTL says otherwise
+	defaultCheckoutSessionTTL = 30 * time.Minute
+
+	// How long after a session expires its invoice stays payable, so a
+	// transfer sent just before expiry still settles it
+	checkoutPaymentGrace = 10 * time.Minute
+
+	// How often abandoned sessions are looked for, and at most how many are
+	// canceled each time
+	checkoutSweepInterval = time.Minute
+	maxSweptSessions      = 100
+
+	// Reason recorded on the payment links of an abandoned session's invoice
+	checkoutExpiredReason = "Checkout session expired"
+)
+
+// CheckoutValidationError reports an amount or quantity that does not suit the checkout link
+type CheckoutValidationError struct {
+	Fields map[string]string
+}
+
+func (e *CheckoutValidationError) Error() string {
+	return "checkout session does not suit the checkout link"
+}
+
+// CheckoutService manages checkout links and the sessions visitors start on
+// them. Each session materializes an invoice, which is paid like any other
+// and completes the session once paid.
+type CheckoutService struct {
+	db         *gorm.DB
+	repository repository.CheckoutRepository
+	invoices   *InvoiceService
+	sessionTTL time.Duration
+}
+
+// NewCheckoutService creates a new checkout service. Sessions expire after
+// CHECKOUT_SESSION_TTL (default 30m).
+func NewCheckoutService(db *gorm.DB, repo repository.CheckoutRepository, invoices *InvoiceService) *CheckoutService {
+	sessionTTL := defaultCheckoutSessionTTL
+	if v, err := time.ParseDuration(os.Getenv("CHECKOUT_SESSION_TTL")); err == nil && v > 0 {
+		sessionTTL = v
+	}
+
+	return &CheckoutService{
+		db:         db,
+		repository: repo,
+		invoices:   invoices,
+		sessionTTL: sessionTTL,
+	}
+}
+
+// CreateLink creates a checkout link
+func (s *CheckoutService) CreateLink(req models.CreateCheckoutLinkRequest) (models.CheckoutLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	link := models.CheckoutLink{
+		Name:            req.Name,
+		Description:     req.Description,
+		Amount:          req.Amount,
+		MinAmount:       req.MinAmount,
+		MaxAmount:       req.MaxAmount,
+		Currency:        strings.ToUpper(req.Currency),
+		SettlementToken: strings.ToUpper(req.SettlementToken),
+		RateLock:        req.RateLock,
+		MaxQuantity:     req.MaxQuantity,
+		MaxUses:         req.MaxUses,
+		ReceiverAddr:    req.ReceiverAddr,
+		Seller:          req.Seller,
+		Active:          true,
+		ExpiresAt:       req.ExpiresAt,
+	}
+	if err := s.repository.CreateLink(ctx, &link); err != nil {
+		return models.CheckoutLink{}, fmt.Errorf("failed to create checkout link: %w", err)
+	}
+
+	return link, nil
+}
+
+// GetLink retrieves a checkout link by ID with its number of uses
+func (s *CheckoutService) GetLink(id int) (models.CheckoutLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	link, err := s.repository.FindLinkByID(ctx, id)
+	if err != nil {
+		return models.CheckoutLink{}, fmt.Errorf("failed to get checkout link: %w", err)
+	}
+	if link == nil {
+		return models.CheckoutLink{}, ErrCheckoutLinkNotFound
+	}
+
+	uses, err := s.repository.CountUses(ctx, []int{link.ID})
+	if err != nil {
+		return models.CheckoutLink{}, fmt.Errorf("failed to count checkout link uses: %w", err)
+	}
+	link.Uses = uses[link.ID]
+
+	return *link, nil
+}
+
+// ListLinks returns a page of checkout links with their number of uses, and the total count
+func (s *CheckoutService) ListLinks(page, limit int) ([]models.CheckoutLink, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	links, total, err := s.repository.ListLinks(ctx, page, limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list checkout links: %w", err)
+	}
+	if len(links) == 0 {
+		return links, total, nil
+	}
+
+	ids := make([]int, len(links))
+	for i, link := range links {
+		ids[i] = link.ID
+	}
+	uses, err := s.repository.CountUses(ctx, ids)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count checkout link uses: %w", err)
+	}
+	for i := range links {
+		links[i].Uses = uses[links[i].ID]
+	}
+
+	return links, total, nil
+}
+
+// UpdateLink deactivates, reactivates or changes the limits of a checkout
+// link. Sessions already started can still be paid.
+func (s *CheckoutService) UpdateLink(id int, req models.UpdateCheckoutLinkRequest) (models.CheckoutLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	link, err := s.repository.FindLinkByID(ctx, id)
+	if err != nil {
+		return models.CheckoutLink{}, fmt.Errorf("failed to get checkout link: %w", err)
+	}
+	if link == nil {
+		return models.CheckoutLink{}, ErrCheckoutLinkNotFound
+	}
+
+	if req.Active != nil {
+		link.Active = *req.Active
+	}
+	if req.ExpiresAt != nil {
+		link.ExpiresAt = req.ExpiresAt
+	}
+	if req.MaxUses != nil {
+		link.MaxUses = req.MaxUses
+	}
+
+	if err := s.repository.UpdateLink(ctx, link); err != nil {
+		return models.CheckoutLink{}, fmt.Errorf("failed to update checkout link: %w", err)
+	}
+
+	return s.GetLink(id)
+}
+
+// ListSessions returns the sessions started on a checkout link, newest first
+func (s *CheckoutService) ListSessions(linkID int) ([]models.CheckoutSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	link, err := s.repository.FindLinkByID(ctx, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get checkout link: %w", err)
+	}
+	if link == nil {
+		return nil, ErrCheckoutLinkNotFound
+	}
+
+	sessions, err := s.repository.ListSessions(ctx, linkID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkout sessions: %w", err)
+	}
+
+	now := time.Now()
+	for i := range sessions {
+		sessions[i].Status = sessions[i].StatusAt(now)
+	}
+	return sessions, nil
+}
+
+// GetPublicLink returns what a visitor sees of an open checkout link
+func (s *CheckoutService) GetPublicLink(slug string) (models.PublicCheckoutLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	link, err := s.repository.FindLinkBySlug(ctx, slug)
+	if err != nil {
+		return models.PublicCheckoutLink{}, fmt.Errorf("failed to get checkout link: %w", err)
+	}
+	if link == nil {
+		return models.PublicCheckoutLink{}, ErrCheckoutLinkNotFound
+	}
+	if !link.OpenAt(time.Now()) {
+		return models.PublicCheckoutLink{}, ErrCheckoutLinkUnavailable
+	}
+
+	return models.NewPublicCheckoutLink(*link), nil
+}
+
+// StartSession starts a visitor's checkout on the link with the slug and
+// materializes the invoice the visitor pays, due when the session expires.
+// The invoice's payment link expires with the session. The checkout link is
+// locked while the session is started, so a link with MaxUses never has more
+// completed sessions, or sessions whose invoice can still be paid, than that.
+// The invoice is quoted and its receiver checked before the lock is taken.
+func (s *CheckoutService) StartSession(slug string, req models.StartCheckoutSessionRequest) (models.PublicCheckoutSession, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	now := time.Now()
+	expiresAt := now.Add(s.sessionTTL)
+
+	quantity := req.Quantity
+	if quantity == 0 {
+		quantity = 1
+	}
+
+	link, err := s.repository.FindLinkBySlug(ctx, slug)
+	if err != nil {
+		return models.PublicCheckoutSession{}, fmt.Errorf("failed to get checkout link: %w", err)
+	}
+	if err := checkSessionAllowed(link, req, now); err != nil {
+		return models.PublicCheckoutSession{}, err
+	}
+
+	// A link's price and receiver never change, so the invoice can be
+	// prepared from this unlocked read
+	unitAmount := 0.0
+	if link.Amount != nil {
+		unitAmount = *link.Amount
+	} else {
+		unitAmount = *req.Amount
+	}
+
+	prepared, err := s.invoices.prepareInvoice(ctx, checkoutInvoiceRequest(*link, unitAmount, quantity, req, expiresAt))
+	if err != nil {
+		return models.PublicCheckoutSession{}, err
+	}
+
+	var invoice models.Invoice
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		txCheckout := repository.NewCheckoutRepository(tx)
+
+		// Its state and limits may have changed since, so check them again under the lock
+		link, err := txCheckout.FindLinkBySlugForUpdate(ctx, slug)
+		if err != nil {
+			return err
+		}
+		if err := checkSessionAllowed(link, req, now); err != nil {
+			return err
+		}
+
+		// A session's invoice stays payable for checkoutPaymentGrace after it
+		// expires, so it holds its use until then
+		if link.MaxUses != nil {
+			held, err := txCheckout.CountHeld(ctx, link.ID, now.Add(-checkoutPaymentGrace))
+			if err != nil {
+				return err
+			}
+			if held >= int64(*link.MaxUses) {
+				return ErrCheckoutLinkSoldOut
+			}
+		}
+
+		invoice, err = s.invoices.createInvoiceInTx(ctx, tx, prepared)
+		if err != nil {
+			return err
+		}
+
+		// The payment page and Solana Pay stop accepting the session once it expires
+		if err := repository.NewPaymentLinkRepository(tx).SetExpiry(ctx, invoice.LinkToken, &expiresAt); err != nil {
+			return err
+		}
+
+		return txCheckout.CreateSession(ctx, &models.CheckoutSession{
+			CheckoutLinkID: link.ID,
+			InvoiceID:      invoice.ID,
+			Quantity:       quantity,
+			Amount:         invoice.Amount,
+			Payer:          invoice.RecipientDetails,
+			ExpiresAt:      expiresAt,
+		})
+	})
+	if err != nil {
+		return models.PublicCheckoutSession{}, err
+	}
+
+	return models.PublicCheckoutSession{
+		LinkToken: invoice.LinkToken,
+		Quantity:  quantity,
+		ExpiresAt: expiresAt,
+		Invoice:   models.NewPublicInvoice(invoice),
+	}, nil
+}
+
+// checkSessionAllowed reports why a session cannot be started on the link, if at all
+func checkSessionAllowed(link *models.CheckoutLink, req models.StartCheckoutSessionRequest, now time.Time) error {
+	if link == nil {
+		return ErrCheckoutLinkNotFound
+	}
+	if !link.OpenAt(now) {
+		return ErrCheckoutLinkUnavailable
+	}
+	if fields := req.ValidateFor(*link); len(fields) > 0 {
+		return &CheckoutValidationError{Fields: fields}
+	}
+	return nil
+}
+
+// Run cancels the invoices of abandoned sessions until ctx is done, so they
+// neither linger as receivables nor match a later transfer. It is run by the
+// leader elector alongside the payment watcher.
+func (s *CheckoutService) Run(ctx context.Context) {
+	log.Println("Starting checkout session sweeper")
+
+	ticker := time.NewTicker(checkoutSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.cancelAbandoned(ctx); err != nil {
+			log.Printf("Error canceling abandoned checkout sessions: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Checkout session sweeper shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// cancelAbandoned cancels the still pending invoices of sessions that expired
+// more than checkoutPaymentGrace ago
+func (s *CheckoutService) cancelAbandoned(ctx context.Context) error {
+	findCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	sessions, err := s.repository.FindAbandonedSessions(findCtx, time.Now().Add(-checkoutPaymentGrace), maxSweptSessions)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if _, err := s.invoices.cancelPending(ctx, session.InvoiceID, checkoutExpiredReason); err != nil {
+			log.Printf("Failed to cancel invoice %d of abandoned checkout session %d: %v", session.InvoiceID, session.ID, err)
+		}
+	}
+	return nil
+}
+
+// checkoutInvoiceRequest builds the request for the invoice a checkout session
+// materializes. It is numbered from the default sequence and issued to the
+// visitor by the link's seller.
+func checkoutInvoiceRequest(link models.CheckoutLink, unitAmount float64, quantity int, req models.StartCheckoutSessionRequest, dueDate time.Time) models.CreateInvoiceRequest {
+	description := link.Name
+	if quantity > 1 {
+		description = fmt.Sprintf("%s × %d", link.Name, quantity)
+	}
+
+	return models.CreateInvoiceRequest{
+		Amount:           unitAmount * float64(quantity),
+		Currency:         link.Currency,
+		Description:      description,
+		DueDate:          dueDate,
+		ReceiverAddr:     link.ReceiverAddr,
+		SenderDetails:    link.Seller,
+		RecipientDetails: models.Person{Name: req.Name, Email: req.Email},
+		SettlementToken:  link.SettlementToken,
+		RateLock:         link.RateLock,
+	}
+}
+
+// cancelPending cancels an invoice that is still awaiting payment, such as the
+// invoice of an abandoned checkout session, and revokes its payment links for
+// reason. An invoice paid in the meantime is left alone. It reports whether
+// the invoice was canceled.
+func (s *InvoiceService) cancelPending(ctx context.Context, id int, reason string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var canceled *models.Invoice
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewInvoiceRepository(tx)
+
+		invoice, err := txRepo.FindByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if invoice == nil || invoice.Status != models.StatusPending {
+			return nil
+		}
+
+		invoice.Status = models.StatusCanceled
+		invoice.UpdatedAt = time.Now()
+		if err := repository.NewPaymentLinkRepository(tx).RevokeAllForInvoice(ctx, id, "", reason, invoice.UpdatedAt); err != nil {
+			return err
+		}
+		if err := txRepo.Update(ctx, invoice); err != nil {
+			return err
+		}
+
+		canceled = invoice
+		return nil
+	})
+	if err != nil || canceled == nil {
+		return false, err
+	}
+
+	s.publishStatus(*canceled)
+	return true, nil
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the credit note service
+var (
+	ErrCreditNoteNotFound   = errors.New("credit note not found")
+	ErrInvoiceNotFound      = errors.New("invoice not found")
+	ErrInvoiceNotPaid       = errors.New("credit notes can only be issued for paid invoices")
+	ErrCreditExceedsBalance = errors.New("credit note amount exceeds the remaining invoice balance")
+	ErrCreditNoteNotIssued  = errors.New("credit note is not in ISSUED status")
+	ErrRefundAlreadyClaimed = errors.New("refund transaction is already recorded on another credit note")
+)
+
+// CreditNoteService handles business logic for credit notes and refunds
+type CreditNoteService struct {
+	db                *gorm.DB
+	repository        repository.CreditNoteRepository
+	invoiceRepository repository.InvoiceRepository
+}
+
+// NewCreditNoteService creates a new credit note service
+func NewCreditNoteService(db *gorm.DB, repo repository.CreditNoteRepository, invoiceRepo repository.InvoiceRepository) *CreditNoteService {
+	return &CreditNoteService{
+		db:                db,
+		repository:        repo,
+		invoiceRepository: invoiceRepo,
+	}
+}
+
+// CreateCreditNote issues a credit note against a paid invoice, numbered from
+// the credit note sequence in the same transaction so the series stays
+// gap-free. The invoice row is locked for the duration of the transaction so
+// concurrent credit notes cannot exceed the invoice amount.
+func (s *CreditNoteService) CreateCreditNote(req models.CreateCreditNoteRequest) (models.CreditNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var note models.CreditNote
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txInvoices := repository.NewInvoiceRepository(tx)
+		txNotes := repository.NewCreditNoteRepository(tx)
+
+		invoice, err := txInvoices.FindByIDForUpdate(ctx, req.InvoiceID)
+		if err != nil {
+			return err
+		}
+		if invoice == nil {
+			return ErrInvoiceNotFound
+		}
+		if invoice.Status != models.StatusPaid {
+			return ErrInvoiceNotPaid
+		}
+
+		credited, err := txNotes.SumActiveByInvoice(ctx, invoice.ID)
+		if err != nil {
+			return err
+		}
+		remaining := models.RoundAmount(invoice.Amount - credited)
+
+		amount := models.RoundAmount(req.Amount)
+		if amount == 0 {
+			amount = remaining
+		}
+		if amount <= 0 || amount > remaining {
+			return fmt.Errorf("%w: %.2f remaining", ErrCreditExceedsBalance, remaining)
+		}
+
+		number, err := allocateCreditNoteNumber(ctx, tx, time.Now())
+		if err != nil {
+			return err
+		}
+
+		note = models.CreditNote{
+			CreditNoteNumber: number,
+			InvoiceID:        invoice.ID,
+			Amount:           amount,
+			Currency:         invoice.Currency,
+			Reason:           req.Reason,
+			Status:           models.CreditNoteStatusIssued,
+			RefundAddr:       req.RefundAddr,
+		}
+
+		return txNotes.Create(ctx, &note)
+	})
+
+	if err != nil {
+		return models.CreditNote{}, err
+	}
+
+	return note, nil
+}
+
+// GetCreditNote retrieves a credit note by ID
+func (s *CreditNoteService) GetCreditNote(id int) (models.CreditNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	note, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return models.CreditNote{}, fmt.Errorf("failed to get credit note: %w", err)
+	}
+	if note == nil {
+		return models.CreditNote{}, ErrCreditNoteNotFound
+	}
+
+	return *note, nil
+}
+
+// ListCreditNotes returns a page of credit notes and the total count
+func (s *CreditNoteService) ListCreditNotes(page, limit int) ([]models.CreditNote, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.List(ctx, page, limit)
+}
+
+// ListCreditNotesForInvoice returns every credit note issued against an invoice
+func (s *CreditNoteService) ListCreditNotesForInvoice(invoiceID int) ([]models.CreditNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.ListByInvoice(ctx, invoiceID)
+}
+
+// RecordRefund stores the outbound refund transaction signature and marks the credit note refunded
+func (s *CreditNoteService) RecordRefund(id int, signature string) (models.CreditNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var result models.CreditNote
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txNotes := repository.NewCreditNoteRepository(tx)
+
+		existing, err := txNotes.FindByRefundSignature(ctx, signature)
+		if err != nil {
+			return err
+		}
+		if existing != nil && existing.ID != id {
+			return ErrRefundAlreadyClaimed
+		}
+
+		note, err := txNotes.FindByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if note == nil {
+			return ErrCreditNoteNotFound
+		}
+		if note.Status != models.CreditNoteStatusIssued {
+			return ErrCreditNoteNotIssued
+		}
+
+		now := time.Now()
+		note.Status = models.CreditNoteStatusRefunded
+		note.RefundTxSignature = signature
+		note.RefundedAt = &now
+
+		if err := txNotes.Update(ctx, note); err != nil {
+			return err
+		}
+
+		result = *note
+		return nil
+	})
+
+	if err != nil {
+		return models.CreditNote{}, err
+	}
+
+	return result, nil
+}
+
+// VoidCreditNote cancels an issued credit note so its amount no longer counts
+// against the invoice. The note is locked so a refund recorded at the same time
+// cannot be overwritten.
+func (s *CreditNoteService) VoidCreditNote(id int) (models.CreditNote, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var result models.CreditNote
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txNotes := repository.NewCreditNoteRepository(tx)
+
+		note, err := txNotes.FindByIDForUpdate(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get credit note: %w", err)
+		}
+		if note == nil {
+			return ErrCreditNoteNotFound
+		}
+		if note.Status != models.CreditNoteStatusIssued {
+			return ErrCreditNoteNotIssued
+		}
+
+		note.Status = models.CreditNoteStatusVoid
+		if err := txNotes.Update(ctx, note); err != nil {
+			return fmt.Errorf("failed to void credit note: %w", err)
+		}
+
+		result = *note
+		return nil
+	})
+
+	if err != nil {
+		return models.CreditNote{}, err
+	}
+
+	return result, nil
+}
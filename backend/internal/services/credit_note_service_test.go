@@ -0,0 +1,226 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"github.com/ncapetillo/demo-fluida/internal/testdb"
+	"gorm.io/gorm"
+)
+
+func newCreditNoteTestService(t *testing.T) (*CreditNoteService, *gorm.DB) {
+	t.Helper()
+
+	db := testdb.Open(t,
+		&models.Invoice{}, &models.PaymentLink{}, &models.CreditNote{},
+		&models.NumberSequence{}, &models.NumberSequenceCounter{},
+	)
+	service := NewCreditNoteService(db, repository.NewCreditNoteRepository(db), repository.NewInvoiceRepository(db))
+	return service, db
+}
+
+func createTestInvoice(t *testing.T, db *gorm.DB, number string, status models.InvoiceStatus) models.Invoice {
+	t.Helper()
+
+	invoice := models.Invoice{
+		InvoiceNumber: number,
+		Amount:        100,
+		Currency:      "USDC",
+		DueDate:       time.Now().AddDate(0, 0, 30),
+		Status:        status,
+		ReceiverAddr:  "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+	}
+	if err := repository.NewInvoiceRepository(db).Create(context.Background(), &invoice); err != nil {
+		t.Fatalf("Failed to create invoice: %v", err)
+	}
+	return invoice
+}
+
+func TestCreateCreditNoteNumbering(t *testing.T) {
+	service, db := newCreditNoteTestService(t)
+	invoice := createTestInvoice(t, db, "INV-100", models.StatusPaid)
+	other := createTestInvoice(t, db, "INV-101", models.StatusPaid)
+	year := time.Now().Year()
+
+	first, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 10, Reason: "Damaged"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+	// Credit notes of every invoice share one series
+	second, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: other.ID, Amount: 10, Reason: "Late"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+	wantFirst, wantSecond := fmt.Sprintf("CN-%d-00001", year), fmt.Sprintf("CN-%d-00002", year)
+	if first.CreditNoteNumber != wantFirst || second.CreditNoteNumber != wantSecond {
+		t.Errorf("Expected %s and %s, got %s and %s", wantFirst, wantSecond, first.CreditNoteNumber, second.CreditNoteNumber)
+	}
+
+	// A rejected credit note does not use up a number
+	if _, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 1000, Reason: "Too much"}); !errors.Is(err, ErrCreditExceedsBalance) {
+		t.Fatalf("Expected ErrCreditExceedsBalance, got %v", err)
+	}
+
+	// A voided note keeps its number, so the next one does not reuse it
+	if _, err := service.VoidCreditNote(second.ID); err != nil {
+		t.Fatalf("VoidCreditNote() error = %v", err)
+	}
+	third, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 10, Reason: "Late"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+	if want := fmt.Sprintf("CN-%d-00003", year); third.CreditNoteNumber != want {
+		t.Errorf("Expected %s, got %s", want, third.CreditNoteNumber)
+	}
+}
+
+func TestCreateCreditNoteRejectsOverCredit(t *testing.T) {
+	service, db := newCreditNoteTestService(t)
+	invoice := createTestInvoice(t, db, "INV-200", models.StatusPaid)
+
+	if _, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 60, Reason: "Partial"}); err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+
+	_, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 40.01, Reason: "Too much"})
+	if !errors.Is(err, ErrCreditExceedsBalance) {
+		t.Errorf("Expected ErrCreditExceedsBalance, got %v", err)
+	}
+
+	// Zero credits whatever is left
+	rest, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Reason: "Rest"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+	if rest.Amount != 40 {
+		t.Errorf("Expected the remaining 40 to be credited, got %.2f", rest.Amount)
+	}
+
+	_, err = service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Reason: "Nothing left"})
+	if !errors.Is(err, ErrCreditExceedsBalance) {
+		t.Errorf("Expected ErrCreditExceedsBalance once fully credited, got %v", err)
+	}
+}
+
+func TestCreateCreditNoteRequiresPaidInvoice(t *testing.T) {
+	service, db := newCreditNoteTestService(t)
+	invoice := createTestInvoice(t, db, "INV-300", models.StatusPending)
+
+	_, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 10, Reason: "Early"})
+	if !errors.Is(err, ErrInvoiceNotPaid) {
+		t.Errorf("Expected ErrInvoiceNotPaid, got %v", err)
+	}
+}
+
+func TestVoidCreditNote(t *testing.T) {
+	service, db := newCreditNoteTestService(t)
+	invoice := createTestInvoice(t, db, "INV-400", models.StatusPaid)
+
+	note, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Reason: "Full"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+
+	voided, err := service.VoidCreditNote(note.ID)
+	if err != nil {
+		t.Fatalf("VoidCreditNote() error = %v", err)
+	}
+	if voided.Status != models.CreditNoteStatusVoid {
+		t.Errorf("Expected VOID, got %s", voided.Status)
+	}
+
+	if _, err := service.VoidCreditNote(note.ID); !errors.Is(err, ErrCreditNoteNotIssued) {
+		t.Errorf("Expected ErrCreditNoteNotIssued voiding twice, got %v", err)
+	}
+	if _, err := service.RecordRefund(note.ID, "sig-void"); !errors.Is(err, ErrCreditNoteNotIssued) {
+		t.Errorf("Expected ErrCreditNoteNotIssued refunding a void note, got %v", err)
+	}
+
+	// The voided amount can be credited again
+	if _, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 100, Reason: "Again"}); err != nil {
+		t.Errorf("Expected the voided amount to be available again, got %v", err)
+	}
+}
+
+func TestRecordRefund(t *testing.T) {
+	service, db := newCreditNoteTestService(t)
+	invoice := createTestInvoice(t, db, "INV-500", models.StatusPaid)
+
+	first, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 50, Reason: "Half"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+	second, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 50, Reason: "Other half"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+
+	refunded, err := service.RecordRefund(first.ID, "sig-1")
+	if err != nil {
+		t.Fatalf("RecordRefund() error = %v", err)
+	}
+	if refunded.Status != models.CreditNoteStatusRefunded || refunded.RefundTxSignature != "sig-1" || refunded.RefundedAt == nil {
+		t.Errorf("Expected a REFUNDED note with its signature, got %+v", refunded)
+	}
+
+	if _, err := service.RecordRefund(second.ID, "sig-1"); !errors.Is(err, ErrRefundAlreadyClaimed) {
+		t.Errorf("Expected ErrRefundAlreadyClaimed, got %v", err)
+	}
+	if _, err := service.RecordRefund(first.ID, "sig-2"); !errors.Is(err, ErrCreditNoteNotIssued) {
+		t.Errorf("Expected ErrCreditNoteNotIssued refunding twice, got %v", err)
+	}
+	if _, err := service.VoidCreditNote(first.ID); !errors.Is(err, ErrCreditNoteNotIssued) {
+		t.Errorf("Expected ErrCreditNoteNotIssued voiding a refunded note, got %v", err)
+	}
+}
+
+func TestMarkRefundedOnlyIssuedNotes(t *testing.T) {
+	service, db := newCreditNoteTestService(t)
+	invoice := createTestInvoice(t, db, "INV-600", models.StatusPaid)
+	notes := repository.NewCreditNoteRepository(db)
+	ctx := context.Background()
+
+	voided, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 50, Reason: "Voided"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+	if _, err := service.VoidCreditNote(voided.ID); err != nil {
+		t.Fatalf("VoidCreditNote() error = %v", err)
+	}
+
+	// The payment watcher read the note before it was voided
+	marked, err := notes.MarkRefunded(ctx, voided.ID, "sig-late", time.Now())
+	if err != nil {
+		t.Fatalf("MarkRefunded() error = %v", err)
+	}
+	if marked {
+		t.Error("Expected a voided note not to be marked refunded")
+	}
+
+	issued, err := service.CreateCreditNote(models.CreateCreditNoteRequest{InvoiceID: invoice.ID, Amount: 50, Reason: "Issued"})
+	if err != nil {
+		t.Fatalf("CreateCreditNote() error = %v", err)
+	}
+	marked, err = notes.MarkRefunded(ctx, issued.ID, "sig-watched", time.Now())
+	if err != nil || !marked {
+		t.Errorf("Expected an issued note to be marked refunded, got %v, %v", marked, err)
+	}
+
+	// The unique index rejects a second note claiming the same transaction
+	other := models.CreditNote{CreditNoteNumber: "CN-OTHER-01", InvoiceID: invoice.ID, Amount: 1, Currency: "USDC", Status: models.CreditNoteStatusIssued}
+	if err := notes.Create(ctx, &other); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		_, err := repository.NewCreditNoteRepository(tx).MarkRefunded(ctx, other.ID, "sig-watched", time.Now())
+		return err
+	})
+	if err == nil {
+		t.Error("Expected a refund signature to be claimable only once")
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+)
+
+var (
+	ErrCustomerNotFound = errors.New("customer not found")
+)
+
+// CustomerService handles business logic for the customer directory
+type CustomerService struct {
+	repository repository.CustomerRepository
+}
+
+// NewCustomerService creates a new customer service
+func NewCustomerService(repo repository.CustomerRepository) *CustomerService {
+	return &CustomerService{
+		repository: repo,
+	}
+}
+
+// CreateCustomer adds a customer to the directory
+func (s *CustomerService) CreateCustomer(req models.CustomerRequest) (models.Customer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var customer models.Customer
+	req.Apply(&customer)
+
+	if err := s.repository.Create(ctx, &customer); err != nil {
+		return models.Customer{}, fmt.Errorf("failed to create customer: %w", err)
+	}
+
+	return customer, nil
+}
+
+// GetCustomer retrieves a customer by ID
+func (s *CustomerService) GetCustomer(id int) (models.Customer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	customer, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return models.Customer{}, fmt.Errorf("failed to get customer: %w", err)
+	}
+	if customer == nil {
+		return models.Customer{}, ErrCustomerNotFound
+	}
+
+	return *customer, nil
+}
+
+// ListCustomers returns a page of customers, optionally matching a search term, and the total count
+func (s *CustomerService) ListCustomers(search string, page, limit int) ([]models.Customer, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.List(ctx, search, page, limit)
+}
+
+// UpdateCustomer replaces a customer's details. Invoices already issued to the
+// customer keep the details they were issued with.
+func (s *CustomerService) UpdateCustomer(id int, req models.CustomerRequest) (models.Customer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	customer, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return models.Customer{}, fmt.Errorf("failed to get customer: %w", err)
+	}
+	if customer == nil {
+		return models.Customer{}, ErrCustomerNotFound
+	}
+
+	req.Apply(customer)
+
+	if err := s.repository.Update(ctx, customer); err != nil {
+		return models.Customer{}, fmt.Errorf("failed to update customer: %w", err)
+	}
+
+	return *customer, nil
+}
+
+// DeleteCustomer removes a customer from the directory
+func (s *CustomerService) DeleteCustomer(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	customer, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return fmt.Errorf("failed to get customer: %w", err)
+	}
+	if customer == nil {
+		return ErrCustomerNotFound
+	}
+
+	return s.repository.Delete(ctx, id)
+}
+
+// GetCustomerBalance returns what the customer owes, per currency
+func (s *CustomerService) GetCustomerBalance(id int) ([]models.CustomerBalance, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	customer, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get customer: %w", err)
+	}
+	if customer == nil {
+		return nil, ErrCustomerNotFound
+	}
+
+	balances, err := s.repository.OutstandingBalances(ctx, id, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute customer balance: %w", err)
+	}
+	if balances == nil {
+		balances = []models.CustomerBalance{}
+	}
+
+	return balances, nil
+}
+
+// applyCustomerSnapshot fills the recipient details and currency the request
+// leaves empty from the customer, as they are at the time the invoice is issued
+func applyCustomerSnapshot(req *models.CreateInvoiceRequest, customer *models.Customer) {
+	snapshot := customer.RecipientSnapshot()
+	if req.RecipientDetails.Name == "" {
+		req.RecipientDetails.Name = snapshot.Name
+	}
+	if req.RecipientDetails.Email == "" {
+		req.RecipientDetails.Email = snapshot.Email
+	}
+	if req.RecipientDetails.Address == "" {
+		req.RecipientDetails.Address = snapshot.Address
+	}
+	if req.RecipientDetails.TaxID == "" {
+		req.RecipientDetails.TaxID = snapshot.TaxID
+	}
+	if req.Currency == "" {
+		req.Currency = customer.DefaultCurrency
+	}
+}
@@ -0,0 +1,32 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+func TestApplyCustomerSnapshot(t *testing.T) {
+	customer := &models.Customer{
+		Name:            "Acme Corp",
+		Email:           "ap@acme.test",
+		BillingAddress:  "1 Main St",
+		TaxID:           "DE123456789",
+		DefaultCurrency: "EURC",
+	}
+
+	req := models.CreateInvoiceRequest{
+		RecipientDetails: models.Person{Email: "finance@acme.test"},
+	}
+	applyCustomerSnapshot(&req, customer)
+
+	if req.RecipientDetails.Name != "Acme Corp" || req.RecipientDetails.Address != "1 Main St" || req.RecipientDetails.TaxID != "DE123456789" {
+		t.Errorf("Expected empty recipient fields to be copied, got %+v", req.RecipientDetails)
+	}
+	if req.RecipientDetails.Email != "finance@acme.test" {
+		t.Errorf("Expected the request email to take precedence, got %q", req.RecipientDetails.Email)
+	}
+	if req.Currency != "EURC" {
+		t.Errorf("Expected the customer's default currency, got %q", req.Currency)
+	}
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+)
+
+var (
+	ErrDraftInvoiceNotFound = errors.New("draft invoice not found")
+)
+
+// DraftInvoiceService handles business logic for the invoice form drafts users save
+type DraftInvoiceService struct {
+	repository repository.DraftInvoiceRepository
+}
+
+// NewDraftInvoiceService creates a new draft invoice service
+func NewDraftInvoiceService(repo repository.DraftInvoiceRepository) *DraftInvoiceService {
+	return &DraftInvoiceService{
+		repository: repo,
+	}
+}
+
+// SaveDraft saves a user's draft invoice. Each user has at most one draft, so
+// an existing draft is updated rather than a second one created; created
+// reports whether the draft is new.
+func (s *DraftInvoiceService) SaveDraft(req models.CreateDraftInvoiceRequest) (draft models.DraftInvoice, created bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	existing, err := s.repository.FindByUserID(ctx, req.UserID)
+	if err != nil {
+		return models.DraftInvoice{}, false, fmt.Errorf("failed to get draft invoice: %w", err)
+	}
+
+	if existing != nil {
+		if err := s.repository.UpdateData(ctx, existing.ID, req.InvoiceData); err != nil {
+			return models.DraftInvoice{}, false, fmt.Errorf("failed to update draft invoice: %w", err)
+		}
+		draft, err := s.getDraft(ctx, existing.ID)
+		return draft, false, err
+	}
+
+	draft = models.NewDraftInvoice(req)
+	if err := s.repository.Create(ctx, &draft); err != nil {
+		return models.DraftInvoice{}, false, fmt.Errorf("failed to create draft invoice: %w", err)
+	}
+
+	return draft, true, nil
+}
+
+// GetDraftByUserID retrieves the draft invoice of a user
+func (s *DraftInvoiceService) GetDraftByUserID(userID string) (models.DraftInvoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	draft, err := s.repository.FindByUserID(ctx, userID)
+	if err != nil {
+		return models.DraftInvoice{}, fmt.Errorf("failed to get draft invoice: %w", err)
+	}
+	if draft == nil {
+		return models.DraftInvoice{}, ErrDraftInvoiceNotFound
+	}
+
+	return *draft, nil
+}
+
+// UpdateDraft replaces the form data of a draft invoice
+func (s *DraftInvoiceService) UpdateDraft(id string, req models.UpdateDraftInvoiceRequest) (models.DraftInvoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.getDraft(ctx, id); err != nil {
+		return models.DraftInvoice{}, err
+	}
+
+	if err := s.repository.UpdateData(ctx, id, req.InvoiceData); err != nil {
+		return models.DraftInvoice{}, fmt.Errorf("failed to update draft invoice: %w", err)
+	}
+
+	return s.getDraft(ctx, id)
+}
+
+// DeleteDraft deletes a draft invoice
+func (s *DraftInvoiceService) DeleteDraft(id string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if _, err := s.getDraft(ctx, id); err != nil {
+		return err
+	}
+
+	if err := s.repository.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete draft invoice: %w", err)
+	}
+
+	return nil
+}
+
+// getDraft retrieves a draft invoice by ID, failing with ErrDraftInvoiceNotFound
+func (s *DraftInvoiceService) getDraft(ctx context.Context, id string) (models.DraftInvoice, error) {
+	draft, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return models.DraftInvoice{}, fmt.Errorf("failed to get draft invoice: %w", err)
+	}
+	if draft == nil {
+		return models.DraftInvoice{}, ErrDraftInvoiceNotFound
+	}
+
+	return *draft, nil
+}
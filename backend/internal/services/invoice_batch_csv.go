@@ -0,0 +1,167 @@
+package services
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// csvColumns maps the supported CSV header names to the request field they fill
+var csvColumns = map[string]string{
+	"invoice_number":    "invoiceNumber",
+	"number_sequence":   "numberSequence",
+	"amount":            "amount",
+	"currency":          "currency",
+	"description":       "description",
+	"due_date":          "dueDate",
+	"receiver_addr":     "receiverAddr",
+	"sender_name":       "senderDetails.name",
+	"sender_email":      "senderDetails.email",
+	"sender_address":    "senderDetails.address",
+	"recipient_name":    "recipientDetails.name",
+	"recipient_email":   "recipientDetails.email",
+	"recipient_address": "recipientDetails.address",
+	"customer_id":       "customerId",
+	"settlement_token":  "settlementToken",
+	"rate_lock":         "rateLock",
+}
+
+// csvDateLayouts are the accepted formats of the due_date column
+var csvDateLayouts = []string{time.RFC3339, "2006-01-02"}
+
+// ParseInvoiceCSV reads invoice rows from a CSV file with a header line.
+// Values that cannot be parsed, such as a malformed amount, are reported as
+// row errors rather than failing the whole file; an unreadable file or an
+// unknown column is returned as an error.
+func ParseInvoiceCSV(r io.Reader) ([]models.BatchRow, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("CSV file is empty")
+		}
+		return nil, fmt.Errorf("invalid CSV header: %w", err)
+	}
+
+	fields := make([]string, len(header))
+	for i, name := range header {
+		column := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		field, ok := csvColumns[column]
+		if !ok {
+			return nil, fmt.Errorf("unknown CSV column %q", name)
+		}
+		fields[i] = field
+	}
+
+	var rows []models.BatchRow
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(rows) == models.MaxBatchRows {
+			return nil, fmt.Errorf("a batch can contain at most %d invoices", models.MaxBatchRows)
+		}
+
+		rows = append(rows, parseCSVRecord(line, fields, record))
+	}
+
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV file contains no invoices")
+	}
+
+	return rows, nil
+}
+
+// parseCSVRecord converts one CSV record into an invoice request
+func parseCSVRecord(line int, fields, record []string) models.BatchRow {
+	row := models.BatchRow{Row: line}
+	req := &row.Request
+
+	for i, value := range record {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+
+		switch fields[i] {
+		case "invoiceNumber":
+			req.InvoiceNumber = value
+		case "numberSequence":
+			req.NumberSequence = value
+		case "amount":
+			amount, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				row.Errors = append(row.Errors, models.BatchRowError{Field: "amount", Message: "Amount must be a number"})
+				continue
+			}
+			req.Amount = amount
+		case "currency":
+			req.Currency = value
+		case "description":
+			req.Description = value
+		case "dueDate":
+			dueDate, err := parseCSVDate(value)
+			if err != nil {
+				row.Errors = append(row.Errors, models.BatchRowError{Field: "dueDate", Message: "Due date must be YYYY-MM-DD or RFC 3339"})
+				continue
+			}
+			req.DueDate = dueDate
+		case "receiverAddr":
+			req.ReceiverAddr = value
+		case "senderDetails.name":
+			req.SenderDetails.Name = value
+		case "senderDetails.email":
+			req.SenderDetails.Email = value
+		case "senderDetails.address":
+			req.SenderDetails.Address = value
+		case "recipientDetails.name":
+			req.RecipientDetails.Name = value
+		case "recipientDetails.email":
+			req.RecipientDetails.Email = value
+		case "recipientDetails.address":
+			req.RecipientDetails.Address = value
+		case "customerId":
+			customerID, err := strconv.Atoi(value)
+			if err != nil {
+				row.Errors = append(row.Errors, models.BatchRowError{Field: "customerId", Message: "Customer ID must be a whole number"})
+				continue
+			}
+			req.CustomerID = &customerID
+		case "settlementToken":
+			req.SettlementToken = value
+		case "rateLock":
+			req.RateLock = models.RateLock(strings.ToLower(value))
+		}
+	}
+
+	return row
+}
+
+// parseCSVDate parses a due date; plain dates are due at the end of that day in UTC
+func parseCSVDate(value string) (time.Time, error) {
+	for _, layout := range csvDateLayouts {
+		t, err := time.Parse(layout, value)
+		if err != nil {
+			continue
+		}
+		if layout == "2006-01-02" {
+			t = t.Add(24*time.Hour - time.Second)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
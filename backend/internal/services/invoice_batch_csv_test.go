@@ -0,0 +1,69 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+func TestParseInvoiceCSV(t *testing.T) {
+	input := "Invoice_Number,amount,due_date,receiver_addr,sender_name,sender_email,recipient_name,recipient_email\n" +
+		"INV-1,100.50,2030-01-31,8JQxYTKfELQhAJL4c3jQvnUuNwZWJxsJr7o8G6iTfEV9,Acme,billing@acme.test,Bob,bob@example.test\n" +
+		"INV-2,abc,31/01/2030,8JQxYTKfELQhAJL4c3jQvnUuNwZWJxsJr7o8G6iTfEV9,Acme,billing@acme.test,Eve,\n"
+
+	rows, err := ParseInvoiceCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("Expected 2 rows, got %d", len(rows))
+	}
+
+	first := rows[0]
+	if first.Row != 2 {
+		t.Errorf("Expected first row to be line 2, got %d", first.Row)
+	}
+	if first.Request.InvoiceNumber != "INV-1" || first.Request.Amount != 100.50 {
+		t.Errorf("Unexpected request: %+v", first.Request)
+	}
+	if first.Request.DueDate.Format("2006-01-02") != "2030-01-31" {
+		t.Errorf("Expected due date 2030-01-31, got %s", first.Request.DueDate)
+	}
+	if len(first.Errors) != 0 {
+		t.Errorf("Expected no parse errors, got %+v", first.Errors)
+	}
+
+	// Unparsable values are reported once, under the CSV column name
+	errs := validateBatchRow(models.BatchFormatCSV, rows[1])
+	got := make(map[string]bool)
+	for _, e := range errs {
+		if got[e.Field] {
+			t.Errorf("Field %s reported twice", e.Field)
+		}
+		got[e.Field] = true
+	}
+	for _, column := range []string{"amount", "due_date", "recipient_email"} {
+		if !got[column] {
+			t.Errorf("Expected an error for column %s, got %+v", column, errs)
+		}
+	}
+}
+
+func TestParseInvoiceCSVRejectsUnknownColumns(t *testing.T) {
+	if _, err := ParseInvoiceCSV(strings.NewReader("amount,colour\n1,red\n")); err == nil {
+		t.Error("Expected an error for an unknown column")
+	}
+	if _, err := ParseInvoiceCSV(strings.NewReader("amount\n")); err == nil {
+		t.Error("Expected an error for a file without rows")
+	}
+}
+
+func TestBatchFieldPath(t *testing.T) {
+	if got := batchFieldPath(models.BatchFormatJSON, 3, "senderDetails.email"); got != "invoices[3].senderDetails.email" {
+		t.Errorf("Unexpected JSON path %q", got)
+	}
+	if got := batchFieldPath(models.BatchFormatCSV, 3, "senderDetails.email"); got != "sender_email" {
+		t.Errorf("Unexpected CSV column %q", got)
+	}
+}
@@ -0,0 +1,406 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrBatchJobNotFound = errors.New("batch job not found")
+)
+
+const (
+	// Batches larger than this run as a background job unless configured
+	// otherwise. Every row is quoted and numbered, and may cost RPC calls to
+	// check its receiver, so a synchronous batch is kept small enough to answer
+	// well within the request timeout.
+	defaultBatchAsyncThreshold = 25
+
+	// A RUNNING job's worker heartbeats this often; a job without a heartbeat
+	// for batchJobStaleAfter is considered interrupted
+	batchJobHeartbeatInterval = time.Minute
+	batchJobStaleAfter        = 5 * time.Minute
+
+	// How often interrupted jobs are looked for
+	batchJobSweepInterval = time.Minute
+
+	// Bounds the transaction that stores an all_or_nothing batch
+	batchCommitTimeout = 2 * time.Minute
+)
+
+// InvoiceBatchService creates invoices in bulk, either inline or as a background job
+type InvoiceBatchService struct {
+	db             *gorm.DB
+	jobs           repository.InvoiceBatchJobRepository
+	invoices       *InvoiceService
+	asyncThreshold int
+}
+
+// NewInvoiceBatchService creates a new batch service. Batches with more rows
+// than BATCH_ASYNC_THRESHOLD (default 25) are processed in the background.
+func NewInvoiceBatchService(db *gorm.DB, jobs repository.InvoiceBatchJobRepository, invoices *InvoiceService) *InvoiceBatchService {
+	threshold := defaultBatchAsyncThreshold
+	if v, err := strconv.Atoi(os.Getenv("BATCH_ASYNC_THRESHOLD")); err == nil && v > 0 {
+		threshold = v
+	}
+
+	return &InvoiceBatchService{
+		db:             db,
+		jobs:           jobs,
+		invoices:       invoices,
+		asyncThreshold: threshold,
+	}
+}
+
+// ShouldRunAsync reports whether a batch of the given size is processed as a background job
+func (s *InvoiceBatchService) ShouldRunAsync(rows int) bool {
+	return rows > s.asyncThreshold
+}
+
+// ProcessBatch validates every row and creates the invoices. In all_or_nothing
+// mode a single invalid row or failed insert means nothing is created; in
+// best_effort mode every valid row is created independently. The returned
+// error is reserved for failures that cannot be attributed to a row.
+func (s *InvoiceBatchService) ProcessBatch(format models.BatchFormat, mode models.BatchMode, rows []models.BatchRow) (models.BatchResult, error) {
+	result := models.BatchResult{
+		Mode:  mode,
+		Total: len(rows),
+		Rows:  make([]models.BatchRowResult, len(rows)),
+	}
+
+	invalid := 0
+	for i, row := range rows {
+		result.Rows[i] = models.BatchRowResult{
+			Row:    row.Row,
+			Errors: validateBatchRow(format, row),
+		}
+		if len(result.Rows[i].Errors) > 0 {
+			invalid++
+		}
+	}
+
+	if mode == models.BatchModeAllOrNothing {
+		if invalid > 0 {
+			result.Failed = result.Total
+			return result, nil
+		}
+		return s.createAllOrNothing(result, rows)
+	}
+
+	for i, row := range rows {
+		if len(result.Rows[i].Errors) > 0 {
+			result.Failed++
+			continue
+		}
+
+		invoice, err := s.invoices.CreateInvoice(row.Request)
+		if err != nil {
+			result.Rows[i].Errors = []models.BatchRowError{{Message: err.Error()}}
+			result.Failed++
+			continue
+		}
+
+		markCreated(&result.Rows[i], invoice)
+		result.Succeeded++
+	}
+
+	return result, nil
+}
+
+// createAllOrNothing creates every row in one transaction. Every row is quoted
+// and has its receiver checked before the transaction opens, so the number
+// sequences it allocates from stay locked only while the invoices are stored.
+func (s *InvoiceBatchService) createAllOrNothing(result models.BatchResult, rows []models.BatchRow) (models.BatchResult, error) {
+	failedRow := -1
+	prepared := make([]preparedInvoice, len(rows))
+
+	err := func() error {
+		for i, row := range rows {
+			ctx, cancel := context.WithTimeout(context.Background(), invoiceCreateTimeout)
+			p, err := s.invoices.prepareInvoice(ctx, row.Request)
+			cancel()
+			if err != nil {
+				failedRow = i
+				return err
+			}
+			prepared[i] = p
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), batchCommitTimeout)
+		defer cancel()
+
+		return s.db.Transaction(func(tx *gorm.DB) error {
+			for i := range prepared {
+				invoice, err := s.invoices.createInvoiceInTx(ctx, tx, prepared[i])
+				if err != nil {
+					failedRow = i
+					return err
+				}
+				markCreated(&result.Rows[i], invoice)
+			}
+			return nil
+		})
+	}()
+
+	if err == nil {
+		result.Succeeded = result.Total
+		return result, nil
+	}
+
+	// Nothing was committed, so no row may report an invoice
+	for i := range result.Rows {
+		result.Rows[i].Created = false
+		result.Rows[i].InvoiceID = 0
+		result.Rows[i].InvoiceNumber = ""
+		result.Rows[i].LinkToken = ""
+	}
+	result.Failed = result.Total
+
+	if failedRow < 0 {
+		return result, fmt.Errorf("failed to commit batch: %w", err)
+	}
+
+	result.Rows[failedRow].Errors = []models.BatchRowError{{Message: err.Error()}}
+	return result, nil
+}
+
+// EnqueueBatch stores the rows as a background job and starts processing it
+func (s *InvoiceBatchService) EnqueueBatch(format models.BatchFormat, mode models.BatchMode, rows []models.BatchRow) (*models.InvoiceBatchJob, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job := &models.InvoiceBatchJob{
+		Mode:   mode,
+		Format: format,
+		Status: models.BatchJobQueued,
+		Total:  len(rows),
+		Rows:   rows,
+	}
+
+	if err := s.jobs.Create(ctx, job); err != nil {
+		return nil, fmt.Errorf("failed to create batch job: %w", err)
+	}
+
+	go s.runJob(job.ID)
+
+	return job, nil
+}
+
+// GetBatchJob retrieves a batch job with its result once it has finished
+func (s *InvoiceBatchService) GetBatchJob(id string) (*models.InvoiceBatchJob, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrBatchJobNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	job, err := s.jobs.FindByID(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get batch job: %w", err)
+	}
+	if job == nil {
+		return nil, ErrBatchJobNotFound
+	}
+
+	return job, nil
+}
+
+// ResumeBatchJobs is called at startup. Jobs still queued are started again;
+// jobs left RUNNING by a crashed process are marked FAILED, since some of their
+// invoices may already exist and rerunning them could create duplicates.
+func (s *InvoiceBatchService) ResumeBatchJobs() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.failStale(ctx)
+
+	queued, err := s.jobs.ListQueued(ctx)
+	if err != nil {
+		log.Printf("Error listing queued batch jobs: %v", err)
+		return
+	}
+
+	for _, job := range queued {
+		go s.runJob(job.ID)
+	}
+}
+
+// Run marks jobs whose worker stopped heartbeating as FAILED until ctx is done,
+// so a job interrupted by a restart shortly after it was claimed does not stay
+// RUNNING. It is run by the leader elector alongside the other background workers.
+func (s *InvoiceBatchService) Run(ctx context.Context) {
+	log.Println("Starting batch job sweeper")
+
+	ticker := time.NewTicker(batchJobSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Batch job sweeper shutting down")
+			return
+		case <-ticker.C:
+		}
+
+		sweepCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		s.failStale(sweepCtx)
+		cancel()
+	}
+}
+
+// failStale marks RUNNING jobs without a recent heartbeat as FAILED
+func (s *InvoiceBatchService) failStale(ctx context.Context) {
+	failed, err := s.jobs.FailStale(ctx, time.Now().Add(-batchJobStaleAfter),
+		"Job was interrupted; check the created invoices before resubmitting the remaining rows")
+	if err != nil {
+		log.Printf("Error failing interrupted batch jobs: %v", err)
+	} else if failed > 0 {
+		log.Printf("Marked %d interrupted batch jobs as failed", failed)
+	}
+}
+
+// heartbeat records every batchJobHeartbeatInterval that the job is still
+// being processed, until the returned function is called
+func (s *InvoiceBatchService) heartbeat(id string) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(batchJobHeartbeatInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			if err := s.jobs.Heartbeat(ctx, id, time.Now()); err != nil {
+				log.Printf("Error recording heartbeat of batch job %s: %v", id, err)
+			}
+			cancel()
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// runJob processes a queued job unless another worker claimed it first
+func (s *InvoiceBatchService) runJob(id string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	claimed, err := s.jobs.Claim(ctx, id)
+	cancel()
+	if err != nil {
+		log.Printf("Error claiming batch job %s: %v", id, err)
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	job, err := s.GetBatchJob(id)
+	if err != nil {
+		log.Printf("Error loading batch job %s: %v", id, err)
+		return
+	}
+
+	log.Printf("Processing batch job %s with %d invoices", job.ID, job.Total)
+
+	stopHeartbeat := s.heartbeat(job.ID)
+	result, err := s.ProcessBatch(job.Format, job.Mode, job.Rows)
+	stopHeartbeat()
+	now := time.Now()
+
+	job.Status = models.BatchJobCompleted
+	job.Result = &result
+	job.CompletedAt = &now
+	job.Rows = nil // the input is no longer needed once the result is stored
+	if err != nil {
+		job.Status = models.BatchJobFailed
+		job.Error = err.Error()
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.jobs.Update(ctx, job); err != nil {
+		log.Printf("Error saving result of batch job %s: %v", job.ID, err)
+		return
+	}
+
+	log.Printf("Batch job %s finished: %d created, %d failed", job.ID, result.Succeeded, result.Failed)
+}
+
+// validateBatchRow combines parse errors with request validation and
+// reports them with field paths in the format the batch was submitted in
+func validateBatchRow(format models.BatchFormat, row models.BatchRow) []models.BatchRowError {
+	seen := make(map[string]bool)
+	var errs []models.BatchRowError
+
+	for _, e := range row.Errors {
+		seen[e.Field] = true
+		errs = append(errs, e)
+	}
+
+	validationErrors := row.Request.Validate()
+	fields := make([]string, 0, len(validationErrors))
+	for field := range validationErrors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		// A value that failed to parse is also reported as missing; keep the parse error
+		if seen[field] {
+			continue
+		}
+		errs = append(errs, models.BatchRowError{Field: field, Message: validationErrors[field]})
+	}
+
+	for i := range errs {
+		errs[i].Field = batchFieldPath(format, row.Row, errs[i].Field)
+	}
+
+	return errs
+}
+
+// batchFieldPath turns a request field into a path the client can locate:
+// "invoices[3].senderDetails.email" for JSON, the column name for CSV
+func batchFieldPath(format models.BatchFormat, row int, field string) string {
+	if format == models.BatchFormatCSV {
+		for column, f := range csvColumns {
+			if f == field {
+				return column
+			}
+		}
+		return field
+	}
+	return fmt.Sprintf("invoices[%d].%s", row, field)
+}
+
+// markCreated records the created invoice on a row result
+func markCreated(row *models.BatchRowResult, invoice models.Invoice) {
+	row.Created = true
+	row.InvoiceID = invoice.ID
+	row.InvoiceNumber = invoice.InvoiceNumber
+	row.LinkToken = invoice.LinkToken
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// WatchInvoice subscribes to the events of the invoice a payment link token
+// opens and returns the invoice as it stands once subscribed, so no change
+// between reading it and subscribing is missed. Stop must be called once the
+// caller is done. Without an event broker only the invoice is returned and
+// the channel never delivers.
+func (s *InvoiceService) WatchInvoice(token string) (models.Invoice, <-chan models.InvoiceEvent, func(), error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invoice, err := s.invoiceByLink(ctx, token)
+	if err != nil {
+		return models.Invoice{}, nil, nil, err
+	}
+
+	var events <-chan models.InvoiceEvent
+	stop := func() {}
+	if s.events != nil {
+		events, stop = s.events.Subscribe(invoice.ID)
+	}
+
+	// Read the invoice again now that changes to it are being delivered
+	invoice, err = s.repository.FindByID(ctx, invoice.ID)
+	if err != nil {
+		stop()
+		return models.Invoice{}, nil, nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if invoice == nil {
+		stop()
+		return models.Invoice{}, nil, nil, ErrInvoiceNotFound
+	}
+
+	return *invoice, events, stop, nil
+}
+
+// publish announces the event to the clients watching its invoice, if events are enabled
+func (s *InvoiceService) publish(event models.InvoiceEvent) {
+	if s.events != nil {
+		s.events.Publish(event)
+	}
+}
+
+// publishStatus announces a status set by hand as paid or as a plain status change
+func (s *InvoiceService) publishStatus(invoice models.Invoice) {
+	eventType := models.InvoiceEventStatus
+	if invoice.Status == models.StatusPaid {
+		eventType = models.InvoiceEventPaid
+	}
+
+	s.publish(models.InvoiceEvent{
+		Type:      eventType,
+		InvoiceID: invoice.ID,
+		Status:    invoice.Status,
+		Signature: invoice.PaymentTxSignature,
+	})
+}
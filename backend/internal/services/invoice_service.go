@@ -2,31 +2,70 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"time"
 
 	"github.com/ncapetillo/demo-fluida/internal/db"
+	"github.com/ncapetillo/demo-fluida/internal/export"
+	"github.com/ncapetillo/demo-fluida/internal/fx"
 	"github.com/ncapetillo/demo-fluida/internal/models"
 	"github.com/ncapetillo/demo-fluida/internal/repository"
 	"gorm.io/gorm"
 )
 
+// invoiceCreateTimeout bounds creating one invoice, including its FX quote and
+// the on-chain check of its receiver
+const invoiceCreateTimeout = 15 * time.Second
+
+// ReceiverInspector checks that a receiver wallet can be paid in a settlement token
+type ReceiverInspector interface {
+	InspectReceiver(ctx context.Context, receiver, token string) (models.ReceiverCheck, error)
+}
+
+// InvoiceEvents delivers invoice status events to the clients watching an
+// invoice, such as the payment page
+type InvoiceEvents interface {
+	Publish(event models.InvoiceEvent)
+	Subscribe(invoiceID int) (<-chan models.InvoiceEvent, func())
+}
+
 // InvoiceService handles business logic for invoices
 type InvoiceService struct {
 	db         *gorm.DB
 	repository repository.InvoiceRepository
+	links      repository.PaymentLinkRepository
+	rates      fx.RateProvider
+	receivers  ReceiverInspector
+	events     InvoiceEvents
+	quoteTTL   time.Duration
 	mockMode   bool
 }
 
-// NewInvoiceService creates a new invoice service
-func NewInvoiceService(repo repository.InvoiceRepository) *InvoiceService {
+// NewInvoiceService creates a new invoice service. Links resolve the payment
+// link tokens payers open to their invoices. Rates quote invoices that
+// are denominated in a different currency than the token they are settled in;
+// quotes locked at payment time are valid for FX_QUOTE_TTL (default 15m).
+// Receivers, when set, vets the receiver wallet of every new invoice. Events,
+// when set, announces status changes and new quotes to the payment page.
+func NewInvoiceService(repo repository.InvoiceRepository, links repository.PaymentLinkRepository, rates fx.RateProvider, receivers ReceiverInspector, events InvoiceEvents) *InvoiceService {
 	// Check if we're in development mode with mock data
 	mockMode := false
 	
+	quoteTTL := defaultQuoteTTL
+	if v, err := time.ParseDuration(os.Getenv("FX_QUOTE_TTL")); err == nil && v > 0 {
+		quoteTTL = v
+	}
+	
 	service := &InvoiceService{
-		mockMode: mockMode,
+		rates:     rates,
+		receivers: receivers,
+		events:    events,
+		quoteTTL:  quoteTTL,
+		mockMode:  mockMode,
 	}
 	
 	if mockMode {
@@ -36,95 +75,164 @@ func NewInvoiceService(repo repository.InvoiceRepository) *InvoiceService {
 	} else {
 		service.db = db.DB
 		service.repository = repo
+		service.links = links
 	}
 	
 	return service
 }
 
-// GetAllInvoices returns all invoices
-func (s *InvoiceService) GetAllInvoices() []models.Invoice {
+// ListInvoices returns one page of the invoices matching the filter. With a
+// cursor the page is found by keyset pagination on (created_at, id); otherwise
+// page selects it by offset. Next and Prev cursors are returned whenever the
+// list is ordered by creation time, so offset clients can switch to cursors.
+func (s *InvoiceService) ListInvoices(filter models.InvoiceFilter, sort models.InvoiceSort, cursor *models.InvoiceCursor, page, limit int) (models.InvoicePage, error) {
 	if s.mockMode {
-		return createMockInvoices()
+		mockInvoices := createMockInvoices()
+		return models.InvoicePage{Invoices: mockInvoices, Total: int64(len(mockInvoices))}, nil
 	}
 	
 	// Use context with timeout for database operation
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
-	// Default pagination
-	invoices, err := s.repository.List(ctx, 1, 100)
-	if err != nil {
-		log.Printf("Error fetching invoices: %v", err)
-		return []models.Invoice{}
+	var result models.InvoicePage
+	var hasNext, hasPrev bool
+	
+	if cursor != nil {
+		if !sort.KeysetCompatible() {
+			return models.InvoicePage{}, fmt.Errorf("cursor pagination requires sorting by createdAt")
+		}
+		
+		descending := sort.Descending || sort.Column == ""
+		invoices, hasMore, err := s.repository.ListByCursor(ctx, filter, descending, cursor, limit)
+		if err != nil {
+			return models.InvoicePage{}, fmt.Errorf("failed to list invoices: %w", err)
+		}
+		
+		total, err := s.repository.Count(ctx, filter)
+		if err != nil {
+			return models.InvoicePage{}, fmt.Errorf("failed to count invoices: %w", err)
+		}
+		
+		result.Invoices, result.Total = invoices, total
+		
+		// The cursor came from a neighbouring page, so that side always exists
+		hasNext, hasPrev = hasMore, true
+		if cursor.Before {
+			hasNext, hasPrev = true, hasMore
+		}
+	} else {
+		invoices, total, err := s.repository.List(ctx, filter, sort, page, limit)
+		if err != nil {
+			return models.InvoicePage{}, fmt.Errorf("failed to list invoices: %w", err)
+		}
+		
+		result.Invoices, result.Total = invoices, total
+		hasNext = int64(page*limit) < total
+		hasPrev = page > 1
 	}
 	
-	return invoices
+	if sort.KeysetCompatible() && len(result.Invoices) > 0 {
+		if hasNext {
+			result.Next = models.CursorAfter(result.Invoices[len(result.Invoices)-1]).Encode()
+		}
+		if hasPrev {
+			result.Prev = models.CursorBefore(result.Invoices[0]).Encode()
+		}
+	}
+	
+	return result, nil
 }
 
-// GetInvoiceByToken retrieves an invoice by its payment link token
+// GetInvoiceByToken retrieves an invoice by the token of one of its payment
+// links, failing with ErrPaymentLinkRevoked or ErrPaymentLinkExpired when the
+// link no longer works
 func (s *InvoiceService) GetInvoiceByToken(token string) (models.Invoice, error) {
 	if s.mockMode {
 		mockInvoices := createMockInvoices()
 		if token == "demo-token" || len(mockInvoices) > 0 {
 			return mockInvoices[0], nil
 		}
-		return models.Invoice{}, fmt.Errorf("invoice not found: %s", token)
+		return models.Invoice{}, ErrInvoiceNotFound
 	}
 	
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	
-	invoice, err := s.repository.FindByLinkToken(ctx, token)
+	invoice, err := s.invoiceByLink(ctx, token)
 	if err != nil {
-		return models.Invoice{}, fmt.Errorf("failed to get invoice: %w", err)
+		return models.Invoice{}, err
+	}
+	
+	return *invoice, nil
+}
+
+// GetInvoiceByNumber retrieves an invoice by its invoice number
+func (s *InvoiceService) GetInvoiceByNumber(invoiceNumber string) (models.Invoice, error) {
+	if s.mockMode {
+		for _, inv := range createMockInvoices() {
+			if inv.InvoiceNumber == invoiceNumber {
+				return inv, nil
+			}
+		}
+		return models.Invoice{}, ErrInvoiceNotFound
 	}
 	
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	
+	invoice, err := s.repository.FindByInvoiceNumber(ctx, invoiceNumber)
+	if err != nil {
+		return models.Invoice{}, fmt.Errorf("failed to get invoice: %w", err)
+	}
 	if invoice == nil {
-		return models.Invoice{}, fmt.Errorf("invoice not found: %s", token)
+		return models.Invoice{}, ErrInvoiceNotFound
 	}
 	
 	return *invoice, nil
 }
 
+// InvoiceNumberExists reports whether an invoice with the invoice number exists
+func (s *InvoiceService) InvoiceNumberExists(invoiceNumber string) (bool, error) {
+	_, err := s.GetInvoiceByNumber(invoiceNumber)
+	if errors.Is(err, ErrInvoiceNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
 // CreateInvoice creates a new invoice
 func (s *InvoiceService) CreateInvoice(req models.CreateInvoiceRequest) (models.Invoice, error) {
-	// Create a new invoice from the request
-	newInvoice := models.NewInvoice(req)
-	
-	// Validate the invoice
-	if err := newInvoice.Validate(); err != nil {
-		return models.Invoice{}, fmt.Errorf("invalid invoice data: %w", err)
-	}
-	
 	if s.mockMode {
+		newInvoice := models.NewInvoice(req)
+		if err := newInvoice.Validate(); err != nil {
+			return models.Invoice{}, fmt.Errorf("invalid invoice data: %w", err)
+		}
 		mockInvoices := createMockInvoices()
 		newInvoice.ID = len(mockInvoices) + 1
 		return newInvoice, nil
 	}
 	
+	ctx, cancel := context.WithTimeout(context.Background(), invoiceCreateTimeout)
+	defer cancel()
+	
+	prepared, err := s.prepareInvoice(ctx, req)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	
+	var newInvoice models.Invoice
+	
 	// Use transaction for safe creation
-	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// Check if invoice number already exists
-		txRepo := repository.NewInvoiceRepository(tx)
-		ctx := context.Background()
-		
-		existing, err := txRepo.FindByInvoiceNumber(ctx, newInvoice.InvoiceNumber)
-		if err != nil {
-			return err
-		}
-		
-		if existing != nil {
-			// Return this error directly without additional wrapping
-			return fmt.Errorf("invoice number %s already exists. Please use a different invoice number", newInvoice.InvoiceNumber)
-		}
-		
-		// Create the invoice
-		return txRepo.Create(ctx, &newInvoice)
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		newInvoice, err = s.createInvoiceInTx(ctx, tx, prepared)
+		return err
 	})
 	
 	if err != nil {
 		// Don't wrap errors that already contain specific messages
-		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "failed to create invoice") {
+		if strings.Contains(err.Error(), "already exists") || strings.Contains(err.Error(), "failed to create invoice") || strings.Contains(err.Error(), "invalid invoice data") {
 			return models.Invoice{}, err
 		}
 		// Only wrap generic errors
@@ -134,6 +242,105 @@ func (s *InvoiceService) CreateInvoice(req models.CreateInvoiceRequest) (models.
 	return newInvoice, nil
 }
 
+// preparedInvoice is an invoice that has been validated, quoted and checked
+// against its receiver, ready to be numbered and stored by createInvoiceInTx
+type preparedInvoice struct {
+	invoice        models.Invoice
+	numberSequence string
+	// numberedAt fills the date placeholders of an allocated number
+	numberedAt time.Time
+}
+
+// prepareInvoice builds an invoice from the request and does everything that
+// involves other systems: the FX quote and the on-chain receiver check. It is
+// called before the transaction the invoice is stored in is opened, so no row
+// lock, such as a number sequence counter's, is held while they answer.
+func (s *InvoiceService) prepareInvoice(ctx context.Context, req models.CreateInvoiceRequest) (preparedInvoice, error) {
+	// Snapshot the customer's current details onto the invoice; fields given
+	// in the request take precedence
+	if req.CustomerID != nil {
+		customer, err := repository.NewCustomerRepository(s.db).FindByID(ctx, *req.CustomerID)
+		if err != nil {
+			return preparedInvoice{}, err
+		}
+		if customer == nil {
+			return preparedInvoice{}, fmt.Errorf("invalid invoice data: %w", ErrCustomerNotFound)
+		}
+		applyCustomerSnapshot(&req, customer)
+	}
+	
+	// Create a new invoice from the request
+	newInvoice := models.NewInvoice(req)
+	
+	// Validate the invoice; a missing number is allocated when it is stored
+	if err := newInvoice.ValidateDetails(); err != nil {
+		return preparedInvoice{}, fmt.Errorf("invalid invoice data: %w", err)
+	}
+	
+	// Work out the token amount the payer sends
+	if err := s.quoteSettlement(ctx, &newInvoice, time.Now()); err != nil {
+		return preparedInvoice{}, fmt.Errorf("invalid invoice data: %w", err)
+	}
+	
+	// Make sure the receiver can actually be paid in the settlement token
+	if s.receivers != nil {
+		checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		check, err := s.receivers.InspectReceiver(checkCtx, newInvoice.ReceiverAddr, newInvoice.SettlementToken)
+		cancel()
+		if err != nil {
+			return preparedInvoice{}, fmt.Errorf("invalid invoice data: %w", err)
+		}
+		newInvoice.ReceiverTokenAccount = check.TokenAccount
+		newInvoice.Warnings = check.Warnings
+	}
+	
+	return preparedInvoice{
+		invoice:        newInvoice,
+		numberSequence: req.NumberSequence,
+		numberedAt:     time.Now(),
+	}, nil
+}
+
+// createInvoiceInTx stores a prepared invoice inside the caller's transaction
+// so it can be committed atomically with other writes, such as a recurring
+// schedule run. An invoice without a number is numbered from its sequence.
+func (s *InvoiceService) createInvoiceInTx(ctx context.Context, tx *gorm.DB, prepared preparedInvoice) (models.Invoice, error) {
+	newInvoice := prepared.invoice
+	
+	// Allocation shares the transaction, so a failed insert returns the number
+	if newInvoice.InvoiceNumber == "" {
+		number, err := allocateInvoiceNumber(ctx, tx, prepared.numberSequence, prepared.numberedAt)
+		if err != nil {
+			return models.Invoice{}, fmt.Errorf("failed to create invoice: %w", err)
+		}
+		newInvoice.InvoiceNumber = number
+	}
+	
+	if err := newInvoice.Validate(); err != nil {
+		return models.Invoice{}, fmt.Errorf("invalid invoice data: %w", err)
+	}
+	
+	// Check if invoice number already exists
+	txRepo := repository.NewInvoiceRepository(tx)
+	
+	existing, err := txRepo.FindByInvoiceNumber(ctx, newInvoice.InvoiceNumber)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	
+	if existing != nil {
+		// Return this error directly without additional wrapping
+		return models.Invoice{}, fmt.Errorf("invoice number %s already exists. Please use a different invoice number", newInvoice.InvoiceNumber)
+	}
+	
+	// Create the invoice
+	if err := txRepo.Create(ctx, &newInvoice); err != nil {
+		return models.Invoice{}, err
+	}
+	
+	return newInvoice, nil
+}
+
 // UpdateInvoiceStatus updates the status of an invoice
 func (s *InvoiceService) UpdateInvoiceStatus(id int, status models.InvoiceStatus) (models.Invoice, error) {
 	if s.mockMode {
@@ -145,7 +352,7 @@ func (s *InvoiceService) UpdateInvoiceStatus(id int, status models.InvoiceStatus
 				return mockInvoices[i], nil
 			}
 		}
-		return models.Invoice{}, fmt.Errorf("invoice not found: %d", id)
+		return models.Invoice{}, fmt.Errorf("%w: %d", ErrInvoiceNotFound, id)
 	}
 	
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
@@ -164,13 +371,32 @@ func (s *InvoiceService) UpdateInvoiceStatus(id int, status models.InvoiceStatus
 		}
 		
 		if invoice == nil {
-			return fmt.Errorf("invoice not found: %d", id)
+			return fmt.Errorf("%w: %d", ErrInvoiceNotFound, id)
 		}
 		
 		// Update the status
 		invoice.Status = status
 		invoice.UpdatedAt = time.Now()
 		
+		// A canceled invoice can no longer be opened by any of its links
+		if status == models.StatusCanceled {
+			if err := repository.NewPaymentLinkRepository(tx).RevokeAllForInvoice(ctx, id, "", invoiceCanceledReason, invoice.UpdatedAt); err != nil {
+				return err
+			}
+		}
+		
+		// Keep the payment details consistent with the status
+		if status == models.StatusPaid && invoice.PaidAt == nil {
+			paidAt := invoice.UpdatedAt
+			invoice.PaidAt = &paidAt
+			if _, err := repository.NewCheckoutRepository(tx).CompleteSession(ctx, id, paidAt); err != nil {
+				return err
+			}
+		} else if status != models.StatusPaid {
+			invoice.PaidAt = nil
+			invoice.PaymentTxSignature = ""
+		}
+		
 		if err := txRepo.Update(ctx, invoice); err != nil {
 			return err
 		}
@@ -183,9 +409,39 @@ func (s *InvoiceService) UpdateInvoiceStatus(id int, status models.InvoiceStatus
 		return models.Invoice{}, fmt.Errorf("failed to update invoice status: %w", err)
 	}
 	
+	s.publishStatus(result)
+	
 	return result, nil
 }
 
+// ExportInvoices writes every invoice matching the filter to w, oldest first and
+// starting after the cursor when one is given. It returns how many invoices were
+// written and a cursor to resume after the last one. Unlike other service methods
+// it takes the caller's context: an export may run well past the usual 5 second
+// budget and must stop when the client goes away.
+func (s *InvoiceService) ExportInvoices(ctx context.Context, filter models.InvoiceFilter, after *models.InvoiceCursor, w export.InvoiceWriter) (int, string, error) {
+	count := 0
+	var last models.Invoice
+	
+	err := s.repository.Each(ctx, filter, after, func(invoice models.Invoice) error {
+		count++
+		last = invoice
+		return w.WriteInvoice(invoice)
+	})
+	if err != nil {
+		return count, "", fmt.Errorf("failed to export invoices: %w", err)
+	}
+	
+	if err := w.Flush(); err != nil {
+		return count, "", fmt.Errorf("failed to export invoices: %w", err)
+	}
+	
+	if count == 0 {
+		return 0, "", nil
+	}
+	return count, models.CursorAfter(last).Encode(), nil
+}
+
 // GetPendingInvoices returns all pending invoices
 func (s *InvoiceService) GetPendingInvoices() ([]models.Invoice, error) {
 	if s.mockMode {
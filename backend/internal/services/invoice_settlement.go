@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+var (
+	ErrInvoiceNotPayable = errors.New("invoice is not awaiting payment")
+)
+
+// Quotes locked at payment time are valid this long unless configured otherwise
+const defaultQuoteTTL = 15 * time.Minute
+
+// quoteSettlement sets the token and token amount the payer sends. An invoice
+// in a token, or in the currency its token is pegged to, is paid one to one;
+// any other pair, such as EUR settled in SOL, is converted at the provider's rate, which is fixed
+// for good or, when the rate is locked at payment time, until the quote expires.
+func (s *InvoiceService) quoteSettlement(ctx context.Context, invoice *models.Invoice, now time.Time) error {
+	if invoice.Currency == "" {
+		invoice.Currency = models.DefaultCurrency
+	}
+	if invoice.SettlementToken == "" {
+		invoice.SettlementToken = models.DefaultSettlementToken(invoice.Currency)
+	}
+
+	token, ok := models.SettlementTokens[invoice.SettlementToken]
+	if !ok {
+		return fmt.Errorf("unsupported settlement token %s", invoice.SettlementToken)
+	}
+
+	base := models.PricingCurrency(invoice.Currency)
+	if base == token.Peg {
+		invoice.SettlementAmount = invoice.Amount
+		invoice.ExchangeRate = 1
+		invoice.RateLock = ""
+		invoice.RateSource = ""
+		invoice.RateLockedAt = nil
+		invoice.RateExpiresAt = nil
+		return nil
+	}
+
+	if s.rates == nil {
+		return fmt.Errorf("no exchange rate provider configured for %s invoices settled in %s", invoice.Currency, token.Symbol)
+	}
+
+	rate, err := s.rates.Rate(ctx, base, token.Peg)
+	if err != nil {
+		return err
+	}
+
+	if invoice.RateLock == "" {
+		invoice.RateLock = models.RateLockIssue
+	}
+	invoice.ExchangeRate = rate.Value
+	invoice.SettlementAmount = token.Round(invoice.Amount * rate.Value)
+	invoice.RateSource = rate.Source
+	invoice.RateLockedAt = &now
+	invoice.RateExpiresAt = nil
+	if invoice.RateLock == models.RateLockPayment {
+		expiresAt := now.Add(s.quoteTTL)
+		invoice.RateExpiresAt = &expiresAt
+	}
+
+	return nil
+}
+
+// QuoteInvoice returns the invoice with a token amount the payer can send now.
+// Invoices whose rate is locked at payment time get a fresh quote once the
+// current one has expired; every other invoice keeps the amount it was issued with.
+func (s *InvoiceService) QuoteInvoice(token string) (models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invoice, err := s.invoiceByLink(ctx, token)
+	if err != nil {
+		return models.Invoice{}, err
+	}
+	if invoice.Status != models.StatusPending {
+		return models.Invoice{}, ErrInvoiceNotPayable
+	}
+
+	now := time.Now()
+	if invoice.RateLock != models.RateLockPayment || (invoice.RateExpiresAt != nil && invoice.RateExpiresAt.After(now)) {
+		return *invoice, nil
+	}
+
+	if err := s.quoteSettlement(ctx, invoice, now); err != nil {
+		return models.Invoice{}, fmt.Errorf("failed to quote invoice: %w", err)
+	}
+	saved, err := s.repository.SaveQuote(ctx, invoice)
+	if err != nil {
+		return models.Invoice{}, fmt.Errorf("failed to save quote: %w", err)
+	}
+	if !saved {
+		return models.Invoice{}, ErrInvoiceNotPayable
+	}
+
+	s.publish(models.InvoiceEvent{
+		Type:      models.InvoiceEventQuoted,
+		InvoiceID: invoice.ID,
+		Status:    invoice.Status,
+		ExpiresAt: invoice.RateExpiresAt,
+	})
+
+	return *invoice, nil
+}
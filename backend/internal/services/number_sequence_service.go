@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/numbering"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the number sequence service
+var (
+	ErrNumberSequenceNotFound = errors.New("number sequence not found")
+	ErrNumberSequenceExists   = errors.New("number sequence already exists")
+)
+
+// maxNumberAllocationAttempts bounds how many numbers already taken in a row
+// the allocator skips before giving up
+const maxNumberAllocationAttempts = 100
+
+// NumberSequenceService handles business logic for invoice number sequences
+type NumberSequenceService struct {
+	db         *gorm.DB
+	repository repository.NumberSequenceRepository
+}
+
+// NewNumberSequenceService creates a new number sequence service
+func NewNumberSequenceService(db *gorm.DB, repo repository.NumberSequenceRepository) *NumberSequenceService {
+	return &NumberSequenceService{
+		db:         db,
+		repository: repo,
+	}
+}
+
+// NumberPreview describes the number the next invoice of a sequence would receive
+type NumberPreview struct {
+	Sequence   string `json:"sequence"`
+	Pattern    string `json:"pattern"`
+	NextNumber string `json:"nextNumber"`
+}
+
+// ListSequences returns every number sequence
+func (s *NumberSequenceService) ListSequences() ([]models.NumberSequence, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.List(ctx)
+}
+
+// GetSequence retrieves a number sequence by name
+func (s *NumberSequenceService) GetSequence(name string) (models.NumberSequence, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sequence, err := s.repository.FindByName(ctx, name)
+	if err != nil {
+		return models.NumberSequence{}, fmt.Errorf("failed to get number sequence: %w", err)
+	}
+	if sequence == nil {
+		return models.NumberSequence{}, ErrNumberSequenceNotFound
+	}
+
+	return *sequence, nil
+}
+
+// CreateSequence creates a new named number sequence
+func (s *NumberSequenceService) CreateSequence(req models.CreateNumberSequenceRequest) (models.NumberSequence, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	existing, err := s.repository.FindByName(ctx, req.Name)
+	if err != nil {
+		return models.NumberSequence{}, fmt.Errorf("failed to check number sequence: %w", err)
+	}
+	if existing != nil {
+		return models.NumberSequence{}, ErrNumberSequenceExists
+	}
+
+	sequence := models.NumberSequence{
+		Name:        req.Name,
+		Pattern:     req.Pattern,
+		Description: req.Description,
+	}
+	if err := s.repository.Create(ctx, &sequence); err != nil {
+		return models.NumberSequence{}, fmt.Errorf("failed to create number sequence: %w", err)
+	}
+
+	return sequence, nil
+}
+
+// UpdateSequence changes the pattern of a sequence. Counters are kept per
+// rendered scope, so numbers already issued are never reused.
+func (s *NumberSequenceService) UpdateSequence(name string, req models.UpdateNumberSequenceRequest) (models.NumberSequence, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sequence, err := s.repository.FindByName(ctx, name)
+	if err != nil {
+		return models.NumberSequence{}, fmt.Errorf("failed to get number sequence: %w", err)
+	}
+	if sequence == nil {
+		return models.NumberSequence{}, ErrNumberSequenceNotFound
+	}
+
+	sequence.Pattern = req.Pattern
+	sequence.Description = req.Description
+	if err := s.repository.Update(ctx, sequence); err != nil {
+		return models.NumberSequence{}, fmt.Errorf("failed to update number sequence: %w", err)
+	}
+
+	return *sequence, nil
+}
+
+// PreviewNextNumber returns the number the next invoice would receive without allocating it
+func (s *NumberSequenceService) PreviewNextNumber(name string) (NumberPreview, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	sequence, err := s.repository.FindByName(ctx, name)
+	if err != nil {
+		return NumberPreview{}, fmt.Errorf("failed to get number sequence: %w", err)
+	}
+	if sequence == nil {
+		return NumberPreview{}, ErrNumberSequenceNotFound
+	}
+
+	now := time.Now()
+	scope, err := numbering.Scope(sequence.Pattern, now)
+	if err != nil {
+		return NumberPreview{}, err
+	}
+
+	next, err := s.repository.PeekValue(ctx, sequence.ID, scope)
+	if err != nil {
+		return NumberPreview{}, fmt.Errorf("failed to read number sequence counter: %w", err)
+	}
+
+	number, err := numbering.Format(sequence.Pattern, now, next)
+	if err != nil {
+		return NumberPreview{}, err
+	}
+
+	return NumberPreview{
+		Sequence:   sequence.Name,
+		Pattern:    sequence.Pattern,
+		NextNumber: number,
+	}, nil
+}
+
+// allocateInvoiceNumber draws the next number of a sequence inside tx. Numbers
+// already taken by manually numbered invoices are skipped, so the combined set
+// of invoice numbers stays gap-free.
+func allocateInvoiceNumber(ctx context.Context, tx *gorm.DB, sequenceName string, now time.Time) (string, error) {
+	if sequenceName == "" {
+		sequenceName = models.DefaultNumberSequence
+	}
+
+	sequence, err := repository.NewNumberSequenceRepository(tx).FindByName(ctx, sequenceName)
+	if err != nil {
+		return "", err
+	}
+	if sequence == nil {
+		return "", fmt.Errorf("%w: %s", ErrNumberSequenceNotFound, sequenceName)
+	}
+
+	invoices := repository.NewInvoiceRepository(tx)
+	return allocateNumber(ctx, tx, sequence, now, func(ctx context.Context, number string) (bool, error) {
+		existing, err := invoices.FindByInvoiceNumber(ctx, number)
+		return existing != nil, err
+	})
+}
+
+// allocateCreditNoteNumber draws the next number of the credit note sequence
+// inside tx. The sequence is seeded on startup; should it be missing it is
+// created with the default pattern.
+func allocateCreditNoteNumber(ctx context.Context, tx *gorm.DB, now time.Time) (string, error) {
+	sequences := repository.NewNumberSequenceRepository(tx)
+
+	sequence, err := sequences.FindByName(ctx, models.CreditNoteNumberSequence)
+	if err != nil {
+		return "", err
+	}
+	if sequence == nil {
+		if err := sequences.CreateIfMissing(ctx, &models.NumberSequence{
+			Name:        models.CreditNoteNumberSequence,
+			Pattern:     models.DefaultCreditNotePattern,
+			Description: "Credit note numbering",
+		}); err != nil {
+			return "", err
+		}
+		if sequence, err = sequences.FindByName(ctx, models.CreditNoteNumberSequence); err != nil {
+			return "", err
+		}
+	}
+
+	return allocateNumber(ctx, tx, sequence, now, repository.NewCreditNoteRepository(tx).NumberTaken)
+}
+
+// allocateNumber draws values from a sequence inside tx until one formats to a
+// number that is not taken
+func allocateNumber(ctx context.Context, tx *gorm.DB, sequence *models.NumberSequence, now time.Time, taken func(ctx context.Context, number string) (bool, error)) (string, error) {
+	sequences := repository.NewNumberSequenceRepository(tx)
+
+	scope, err := numbering.Scope(sequence.Pattern, now)
+	if err != nil {
+		return "", err
+	}
+
+	for attempt := 0; attempt < maxNumberAllocationAttempts; attempt++ {
+		value, err := sequences.NextValue(ctx, sequence.ID, scope)
+		if err != nil {
+			return "", err
+		}
+
+		number, err := numbering.Format(sequence.Pattern, now, value)
+		if err != nil {
+			return "", err
+		}
+
+		isTaken, err := taken(ctx, number)
+		if err != nil {
+			return "", err
+		}
+		if !isTaken {
+			return number, nil
+		}
+	}
+
+	return "", fmt.Errorf("failed to allocate a number from sequence %s", sequence.Name)
+}
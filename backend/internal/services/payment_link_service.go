@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Errors returned for payment links
+var (
+	ErrPaymentLinkNotFound = errors.New("payment link not found")
+	ErrPaymentLinkRevoked  = errors.New("payment link was revoked")
+	ErrPaymentLinkExpired  = errors.New("payment link has expired")
+)
+
+// Revoke reasons recorded when links are revoked other than by hand
+const (
+	linkRegeneratedReason = "Regenerated"
+	invoiceCanceledReason = "Invoice canceled"
+)
+
+// PaymentLinkService manages the payment links of invoices: issuing links for
+// more channels, regenerating a link under a new token and revoking links
+type PaymentLinkService struct {
+	db         *gorm.DB
+	repository repository.PaymentLinkRepository
+	invoices   repository.InvoiceRepository
+}
+
+// NewPaymentLinkService creates a new payment link service
+func NewPaymentLinkService(db *gorm.DB, repo repository.PaymentLinkRepository, invoices repository.InvoiceRepository) *PaymentLinkService {
+	return &PaymentLinkService{
+		db:         db,
+		repository: repo,
+		invoices:   invoices,
+	}
+}
+
+// ListLinks returns the payment links of an invoice, oldest first, including
+// expired and revoked ones
+func (s *PaymentLinkService) ListLinks(invoiceID int) ([]models.PaymentLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invoice, err := s.invoices.FindByID(ctx, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if invoice == nil {
+		return nil, ErrInvoiceNotFound
+	}
+
+	links, err := s.repository.ListByInvoice(ctx, invoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list payment links: %w", err)
+	}
+
+	now := time.Now()
+	for i := range links {
+		links[i].Status = links[i].StatusAt(now)
+	}
+	return links, nil
+}
+
+// CreateLink issues another payment link to a pending invoice, for example
+// one per channel the invoice is shared on
+func (s *PaymentLinkService) CreateLink(invoiceID int, req models.CreatePaymentLinkRequest) (models.PaymentLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	invoice, err := s.invoices.FindByID(ctx, invoiceID)
+	if err != nil {
+		return models.PaymentLink{}, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if invoice == nil {
+		return models.PaymentLink{}, ErrInvoiceNotFound
+	}
+	if invoice.Status != models.StatusPending {
+		return models.PaymentLink{}, ErrInvoiceNotPayable
+	}
+
+	link := models.PaymentLink{
+		InvoiceID: invoice.ID,
+		Channel:   req.Channel,
+		ExpiresAt: req.ExpiresAt,
+	}
+	if err := s.repository.Create(ctx, &link); err != nil {
+		return models.PaymentLink{}, fmt.Errorf("failed to create payment link: %w", err)
+	}
+
+	link.Status = link.StatusAt(time.Now())
+	return link, nil
+}
+
+// RegenerateLink replaces a link of a pending invoice with a new one on the
+// same channel and revokes the old one, so its token gets 410 Gone. When the
+// old link is the invoice's own LinkToken, the new token takes its place,
+// which also changes the invoice's Solana Pay reference; transfers built for
+// the old link are still matched by amount or memo. The invoice row is locked
+// so concurrent regenerations cannot both replace it.
+func (s *PaymentLinkService) RegenerateLink(invoiceID, linkID int, req models.RegeneratePaymentLinkRequest, actor string) (models.PaymentLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	reason := req.Reason
+	if reason == "" {
+		reason = linkRegeneratedReason
+	}
+
+	var link models.PaymentLink
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txLinks := repository.NewPaymentLinkRepository(tx)
+		txInvoices := repository.NewInvoiceRepository(tx)
+
+		invoice, err := txInvoices.FindByIDForUpdate(ctx, invoiceID)
+		if err != nil {
+			return err
+		}
+		if invoice == nil {
+			return ErrInvoiceNotFound
+		}
+		if invoice.Status != models.StatusPending {
+			return ErrInvoiceNotPayable
+		}
+
+		old, err := txLinks.FindByID(ctx, invoiceID, linkID)
+		if err != nil {
+			return err
+		}
+		if old == nil {
+			return ErrPaymentLinkNotFound
+		}
+
+		link = models.PaymentLink{
+			InvoiceID: invoice.ID,
+			Channel:   old.Channel,
+			ExpiresAt: req.ExpiresAt,
+		}
+		if err := txLinks.Create(ctx, &link); err != nil {
+			return err
+		}
+		if _, err := txLinks.Revoke(ctx, old.ID, actor, reason, time.Now()); err != nil {
+			return err
+		}
+
+		if invoice.LinkToken == old.Token {
+			return txInvoices.UpdateLinkToken(ctx, invoice.ID, link.Token)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.PaymentLink{}, err
+	}
+
+	link.Status = link.StatusAt(time.Now())
+	return link, nil
+}
+
+// RevokeLink revokes a payment link, so its token gets 410 Gone from then on.
+// Revoking a revoked link keeps its original reason.
+func (s *PaymentLinkService) RevokeLink(invoiceID, linkID int, req models.RevokePaymentLinkRequest, actor string) (models.PaymentLink, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	link, err := s.repository.FindByID(ctx, invoiceID, linkID)
+	if err != nil {
+		return models.PaymentLink{}, fmt.Errorf("failed to get payment link: %w", err)
+	}
+	if link == nil {
+		return models.PaymentLink{}, ErrPaymentLinkNotFound
+	}
+
+	if link.RevokedAt == nil {
+		now := time.Now()
+		revoked, err := s.repository.Revoke(ctx, link.ID, actor, req.Reason, now)
+		if err != nil {
+			return models.PaymentLink{}, fmt.Errorf("failed to revoke payment link: %w", err)
+		}
+		if revoked {
+			link.RevokedAt = &now
+			link.RevokedBy = actor
+			link.RevokeReason = req.Reason
+		}
+	}
+
+	link.Status = link.StatusAt(time.Now())
+	return *link, nil
+}
+
+// invoiceByLink returns the invoice a payment link token opens. Tokens of
+// revoked or expired links return ErrPaymentLinkRevoked or
+// ErrPaymentLinkExpired rather than ErrInvoiceNotFound, so payers can be told
+// the link they have no longer works.
+func (s *InvoiceService) invoiceByLink(ctx context.Context, token string) (*models.Invoice, error) {
+	link, err := s.links.FindByToken(ctx, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payment link: %w", err)
+	}
+	if link == nil {
+		return nil, ErrInvoiceNotFound
+	}
+
+	switch link.StatusAt(time.Now()) {
+	case models.PaymentLinkRevoked:
+		return nil, ErrPaymentLinkRevoked
+	case models.PaymentLinkExpired:
+		return nil, ErrPaymentLinkExpired
+	}
+
+	invoice, err := s.repository.FindByID(ctx, link.InvoiceID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoice: %w", err)
+	}
+	if invoice == nil {
+		return nil, ErrInvoiceNotFound
+	}
+	return invoice, nil
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// PaymentTransactionBuilder builds unsigned transactions that pay an invoice
+type PaymentTransactionBuilder interface {
+	BuildPaymentTransaction(ctx context.Context, invoice models.Invoice, payer string) ([]byte, error)
+}
+
+// PaymentRequestService answers Solana Pay transaction requests, so any
+// Solana Pay wallet can pay an invoice from its link token
+type PaymentRequestService struct {
+	invoices *InvoiceService
+	builder  PaymentTransactionBuilder
+	label    string
+	icon     string
+}
+
+// NewPaymentRequestService creates a new payment request service. Wallets show
+// the invoice sender's name, or SOLANA_PAY_LABEL when it has none, next to the
+// icon at SOLANA_PAY_ICON_URL.
+func NewPaymentRequestService(invoices *InvoiceService, builder PaymentTransactionBuilder) *PaymentRequestService {
+	label := os.Getenv("SOLANA_PAY_LABEL")
+	if label == "" {
+		label = "Fluida"
+	}
+
+	return &PaymentRequestService{
+		invoices: invoices,
+		builder:  builder,
+		label:    label,
+		icon:     os.Getenv("SOLANA_PAY_ICON_URL"),
+	}
+}
+
+// GetLabel returns the label and icon a wallet shows for an invoice
+func (s *PaymentRequestService) GetLabel(token string) (models.PaymentRequestLabel, error) {
+	invoice, err := s.invoices.GetInvoiceByToken(token)
+	if err != nil {
+		return models.PaymentRequestLabel{}, err
+	}
+
+	label := invoice.SenderDetails.Name
+	if label == "" {
+		label = s.label
+	}
+
+	return models.PaymentRequestLabel{Label: label, Icon: s.icon}, nil
+}
+
+// CreateTransaction returns a transaction in which account pays the invoice.
+// The invoice is quoted first, so an expired payment-time quote is renewed
+// and the transaction always carries an amount the watcher will match.
+func (s *PaymentRequestService) CreateTransaction(token, account string) (models.PaymentTransaction, error) {
+	invoice, err := s.invoices.QuoteInvoice(token)
+	if err != nil {
+		return models.PaymentTransaction{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	tx, err := s.builder.BuildPaymentTransaction(ctx, invoice, account)
+	if err != nil {
+		return models.PaymentTransaction{}, fmt.Errorf("failed to build payment transaction: %w", err)
+	}
+
+	tokenSymbol, amount := invoice.Settlement()
+	places := models.SettlementTokens[tokenSymbol].QuotePlaces
+
+	return models.PaymentTransaction{
+		Transaction: base64.StdEncoding.EncodeToString(tx),
+		Message:     fmt.Sprintf("Invoice %s: %s %s", invoice.InvoiceNumber, strconv.FormatFloat(amount, 'f', places, 64), tokenSymbol),
+	}, nil
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the payment service
+var (
+	ErrPaymentNotFound          = errors.New("payment not found")
+	ErrPaymentNotAllocatable    = errors.New("payment already settled an invoice or is fully allocated")
+	ErrAllocationExceedsPayment = errors.New("allocations exceed the unallocated amount of the payment")
+	ErrAllocationExceedsInvoice = errors.New("allocation exceeds the amount still due on the invoice")
+	ErrAllocationTokenMismatch  = errors.New("invoice is not settled in the payment's token")
+)
+
+// PaymentService handles business logic for inbound payments seen on-chain
+type PaymentService struct {
+	db         *gorm.DB
+	repository repository.PaymentRepository
+	events     InvoiceEvents
+}
+
+// NewPaymentService creates a new payment service. Events, when set, announces
+// invoices paid by allocations to the payment page.
+func NewPaymentService(db *gorm.DB, repo repository.PaymentRepository, events InvoiceEvents) *PaymentService {
+	return &PaymentService{
+		db:         db,
+		repository: repo,
+		events:     events,
+	}
+}
+
+// ListPayments returns one page of recorded payments, newest first, optionally only those in one status
+func (s *PaymentService) ListPayments(status models.PaymentStatus, page, limit int) ([]models.Payment, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.List(ctx, status, page, limit)
+}
+
+// ListUnmatchedPayments returns one page of the payments waiting for an
+// operator to allocate them, oldest first
+func (s *PaymentService) ListUnmatchedPayments(page, limit int) ([]models.Payment, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.ListUnmatched(ctx, page, limit)
+}
+
+// GetPayment returns a payment with its allocations and audit trail
+func (s *PaymentService) GetPayment(id int) (models.PaymentDetail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.paymentDetail(ctx, s.repository, id)
+}
+
+// AllocatePayment allocates a payment, in full or in part, to pending invoices
+// settled in the payment's token. Invoices whose allocations reach their
+// settlement amount are marked paid by the payment's transaction. The payment
+// and invoice rows are locked so concurrent allocations cannot over-allocate
+// either, and the action is written to the payment's audit log by actor.
+func (s *PaymentService) AllocatePayment(id int, req models.AllocatePaymentRequest, actor, requestID string) (models.PaymentDetail, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var detail models.PaymentDetail
+	var paidEvents []models.InvoiceEvent
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txPayments := repository.NewPaymentRepository(tx)
+		txInvoices := repository.NewInvoiceRepository(tx)
+
+		payment, err := txPayments.FindByIDForUpdate(ctx, id)
+		if err != nil {
+			return err
+		}
+		if payment == nil {
+			return ErrPaymentNotFound
+		}
+		if payment.Status == models.PaymentStatusMatched || payment.Status == models.PaymentStatusAllocated {
+			return ErrPaymentNotAllocatable
+		}
+
+		tolerance := models.SettlementTokens[payment.Token].Tolerance()
+
+		var total float64
+		for _, line := range req.Allocations {
+			total += line.Amount
+		}
+		if total > payment.Unallocated()+tolerance {
+			return ErrAllocationExceedsPayment
+		}
+
+		var paid []int
+		for _, line := range req.Allocations {
+			invoice, err := txInvoices.FindByIDForUpdate(ctx, line.InvoiceID)
+			if err != nil {
+				return err
+			}
+			if invoice == nil {
+				return fmt.Errorf("%w: %d", ErrInvoiceNotFound, line.InvoiceID)
+			}
+			if invoice.Status != models.StatusPending {
+				return fmt.Errorf("%w: invoice %s", ErrInvoiceNotPayable, invoice.InvoiceNumber)
+			}
+
+			token, expected := invoice.Settlement()
+			if token != payment.Token {
+				return fmt.Errorf("%w: invoice %s is settled in %s", ErrAllocationTokenMismatch, invoice.InvoiceNumber, token)
+			}
+
+			allocated, err := txPayments.SumAllocatedToInvoice(ctx, invoice.ID)
+			if err != nil {
+				return err
+			}
+			due := expected - allocated
+			if line.Amount > due+tolerance {
+				return fmt.Errorf("%w: invoice %s", ErrAllocationExceedsInvoice, invoice.InvoiceNumber)
+			}
+
+			if err := txPayments.CreateAllocation(ctx, &models.PaymentAllocation{
+				PaymentID: payment.ID,
+				InvoiceID: invoice.ID,
+				Amount:    line.Amount,
+				Actor:     actor,
+			}); err != nil {
+				return err
+			}
+
+			if line.Amount >= due-tolerance {
+				marked, err := txInvoices.MarkPaid(ctx, invoice.ID, payment.Signature, payment.BlockTime)
+				if err != nil {
+					return err
+				}
+				if !marked {
+					return fmt.Errorf("%w: invoice %s", ErrInvoiceNotPayable, invoice.InvoiceNumber)
+				}
+				if _, err := repository.NewCheckoutRepository(tx).CompleteSession(ctx, invoice.ID, payment.BlockTime); err != nil {
+					return err
+				}
+				paid = append(paid, invoice.ID)
+				paidEvents = append(paidEvents, models.InvoiceEvent{
+					Type:      models.InvoiceEventPaid,
+					InvoiceID: invoice.ID,
+					Status:    models.StatusPaid,
+					Signature: payment.Signature,
+				})
+			}
+		}
+
+		payment.AllocatedAmount += total
+		if payment.Unallocated() <= tolerance {
+			payment.Status = models.PaymentStatusAllocated
+		}
+		if err := txPayments.Update(ctx, payment); err != nil {
+			return err
+		}
+
+		if err := txPayments.CreateAuditEntry(ctx, &models.PaymentAuditEntry{
+			PaymentID: payment.ID,
+			Action:    "allocate",
+			Actor:     actor,
+			RequestID: requestID,
+			Details: models.PaymentAuditDetails{
+				Allocations:    req.Allocations,
+				Note:           req.Note,
+				PaidInvoiceIDs: paid,
+			},
+		}); err != nil {
+			return err
+		}
+
+		detail, err = s.paymentDetail(ctx, txPayments, payment.ID)
+		return err
+	})
+	if err != nil {
+		return models.PaymentDetail{}, err
+	}
+
+	if s.events != nil {
+		for _, event := range paidEvents {
+			s.events.Publish(event)
+		}
+	}
+
+	return detail, nil
+}
+
+// paymentDetail loads a payment with its allocations and audit trail
+func (s *PaymentService) paymentDetail(ctx context.Context, repo repository.PaymentRepository, id int) (models.PaymentDetail, error) {
+	payment, err := repo.FindByID(ctx, id)
+	if err != nil {
+		return models.PaymentDetail{}, fmt.Errorf("failed to get payment: %w", err)
+	}
+	if payment == nil {
+		return models.PaymentDetail{}, ErrPaymentNotFound
+	}
+
+	allocations, err := repo.ListAllocations(ctx, id)
+	if err != nil {
+		return models.PaymentDetail{}, fmt.Errorf("failed to get allocations: %w", err)
+	}
+	auditLog, err := repo.ListAuditEntries(ctx, id)
+	if err != nil {
+		return models.PaymentDetail{}, fmt.Errorf("failed to get audit log: %w", err)
+	}
+
+	return models.PaymentDetail{Payment: *payment, Allocations: allocations, AuditLog: auditLog}, nil
+}
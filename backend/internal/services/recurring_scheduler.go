@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"gorm.io/gorm"
+)
+
+const (
+	// How often the scheduler looks for due schedules
+	recurringPollInterval = time.Minute
+
+	// Upper bound of occurrences materialized per tick, so a long backlog
+	// cannot starve the rest of the process
+	maxRunsPerTick = 100
+
+	// How long an occurrence whose invoice could not be numbered or stored
+	// waits before it is tried again
+	recurringRetryDelay = 15 * time.Minute
+)
+
+// RecurringScheduler materializes invoices for due recurring schedules
+type RecurringScheduler struct {
+	db       *gorm.DB
+	invoices *InvoiceService
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// NewRecurringScheduler creates a new recurring invoice scheduler
+func NewRecurringScheduler(db *gorm.DB, invoices *InvoiceService) *RecurringScheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &RecurringScheduler{
+		db:       db,
+		invoices: invoices,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start begins polling for due schedules in a background goroutine
+func (s *RecurringScheduler) Start() {
+	go s.Run(s.ctx)
+}
+
+// Run polls for due schedules until ctx is done. It is used directly by
+// callers that decide when this process should schedule, such as the leader
+// elector.
+func (s *RecurringScheduler) Run(ctx context.Context) {
+	log.Println("Starting recurring invoice scheduler")
+
+	ticker := time.NewTicker(recurringPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.runDue(ctx); err != nil {
+			log.Printf("Error running recurring schedules: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Println("Recurring invoice scheduler shutting down")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Stop halts the scheduler
+func (s *RecurringScheduler) Stop() {
+	s.cancel()
+	log.Println("Recurring invoice scheduler stopped")
+}
+
+// runDue materializes due occurrences one at a time until none are left
+func (s *RecurringScheduler) runDue(ctx context.Context) error {
+	for i := 0; i < maxRunsPerTick; i++ {
+		select {
+		case <-ctx.Done():
+			return nil
+		default:
+		}
+
+		processed, err := s.runNext(ctx)
+		if err != nil {
+			return err
+		}
+		if !processed {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// runNext claims the next due schedule and materializes its current occurrence.
+// The invoice, the run record and the advanced schedule are committed in one
+// transaction, so an occurrence is either fully materialized or not at all.
+func (s *RecurringScheduler) runNext(ctx context.Context) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	processed := false
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewRecurringScheduleRepository(tx)
+
+		schedule, err := txRepo.ClaimNextDue(ctx, time.Now())
+		if err != nil {
+			return err
+		}
+		if schedule == nil {
+			return nil
+		}
+		processed = true
+
+		occurrence := schedule.OccurrenceCount
+		scheduledFor := schedule.OccurrenceAt(occurrence)
+
+		// The unique run record is the source of truth: never materialize an occurrence twice
+		existing, err := txRepo.FindRun(ctx, schedule.ID, occurrence)
+		if err != nil {
+			return err
+		}
+
+		schedule.LastError = ""
+		if existing == nil {
+			run, err := s.materialize(ctx, tx, schedule, occurrence, scheduledFor)
+			if err != nil {
+				// Keep the occurrence and try it again later; other due
+				// schedules are not held up meanwhile
+				log.Printf("Recurring schedule %d occurrence %d failed, retrying in %s: %v", schedule.ID, occurrence, recurringRetryDelay, err)
+				retryAt := time.Now().Add(recurringRetryDelay)
+				schedule.LastError = err.Error()
+				schedule.NextRunAt = &retryAt
+				return txRepo.Update(ctx, schedule)
+			}
+			if err := txRepo.CreateRun(ctx, &run); err != nil {
+				return err
+			}
+			schedule.LastError = run.Error
+		}
+
+		now := time.Now()
+		schedule.OccurrenceCount = occurrence + 1
+		schedule.LastRunAt = &now
+		schedule.Schedule()
+
+		return txRepo.Update(ctx, schedule)
+	})
+
+	if err != nil {
+		return false, fmt.Errorf("failed to materialize recurring invoice: %w", err)
+	}
+
+	return processed, nil
+}
+
+// materialize creates the invoice for one occurrence, numbered from the
+// schedule's sequence as of the occurrence's date. A template that does not
+// produce a valid invoice is recorded on the run, so a broken template does
+// not block the schedule forever. An invoice that could not be numbered or
+// stored is returned as an error instead, so the occurrence is retried rather
+// than skipped; the transaction stays usable to record that.
+func (s *RecurringScheduler) materialize(ctx context.Context, tx *gorm.DB, schedule *models.RecurringSchedule, occurrence int, scheduledFor time.Time) (models.RecurringInvoiceRun, error) {
+	run := models.RecurringInvoiceRun{
+		ScheduleID:   schedule.ID,
+		Occurrence:   occurrence,
+		ScheduledFor: scheduledFor,
+	}
+
+	dueDate := scheduledFor.AddDate(0, 0, schedule.DueDateOffsetDays)
+	req := templateRequest(schedule.Template, "", dueDate)
+
+	// Quote and check the receiver before any invoice number is taken
+	prepared, err := s.invoices.prepareInvoice(ctx, req)
+	if err != nil {
+		log.Printf("Recurring schedule %d occurrence %d failed: %v", schedule.ID, occurrence, err)
+		run.Error = err.Error()
+		return run, nil
+	}
+
+	sequence, err := scheduleNumberSequence(ctx, tx, schedule)
+	if err != nil {
+		return run, err
+	}
+	prepared.numberSequence = sequence
+	prepared.numberedAt = scheduledFor
+
+	// A savepoint rolls back a failed attempt without aborting the transaction
+	var invoice models.Invoice
+	err = tx.Transaction(func(tx *gorm.DB) error {
+		var err error
+		invoice, err = s.invoices.createInvoiceInTx(ctx, tx, prepared)
+		return err
+	})
+	if err != nil {
+		return run, err
+	}
+
+	log.Printf("Recurring schedule %d created invoice #%s", schedule.ID, invoice.InvoiceNumber)
+	run.InvoiceID = &invoice.ID
+	run.InvoiceNumber = invoice.InvoiceNumber
+	return run, nil
+}
+
+// scheduleNumberSequence returns the name of the sequence a schedule numbers
+// its invoices from. A schedule created with a number pattern gets a sequence
+// of its own the first time it needs one, so {SEQ} counts per period like any
+// other sequence and skips numbers already taken.
+func scheduleNumberSequence(ctx context.Context, tx *gorm.DB, schedule *models.RecurringSchedule) (string, error) {
+	if schedule.NumberSequence != "" {
+		return schedule.NumberSequence, nil
+	}
+
+	sequence := models.NumberSequence{
+		Name:        fmt.Sprintf("recurring-%d", schedule.ID),
+		Pattern:     schedule.NumberPattern,
+		Description: fmt.Sprintf("Numbering of recurring schedule %q", schedule.Name),
+	}
+	if err := repository.NewNumberSequenceRepository(tx).CreateIfMissing(ctx, &sequence); err != nil {
+		return "", fmt.Errorf("failed to create the number sequence of the schedule: %w", err)
+	}
+
+	schedule.NumberSequence = sequence.Name
+	return sequence.Name, nil
+}
@@ -0,0 +1,136 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/numbering"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"github.com/ncapetillo/demo-fluida/internal/testdb"
+)
+
+func TestRecurringSchedulerNumbersFromSequence(t *testing.T) {
+	db := testdb.Open(t,
+		&models.Invoice{}, &models.PaymentLink{},
+		&models.NumberSequence{}, &models.NumberSequenceCounter{},
+		&models.RecurringSchedule{}, &models.RecurringInvoiceRun{},
+	)
+	ctx := context.Background()
+
+	schedule := models.RecurringSchedule{
+		Name:          "Retainer",
+		Frequency:     models.FrequencyMonthly,
+		Interval:      1,
+		StartAt:       time.Now().AddDate(0, -2, 0),
+		NumberPattern: "R-{YYYY}{MM}-{SEQ:2}",
+		Template: models.InvoiceTemplate{
+			Amount:           100,
+			Currency:         "USDC",
+			ReceiverAddr:     "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+			SenderDetails:    models.Person{Name: "Seller", Email: "seller@example.com"},
+			RecipientDetails: models.Person{Name: "Buyer", Email: "buyer@example.com"},
+		},
+		DueDateOffsetDays: 30,
+		Active:            true,
+	}
+	schedule.Schedule()
+	schedules := repository.NewRecurringScheduleRepository(db)
+	if err := schedules.Create(ctx, &schedule); err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+
+	// The first period's first number was already issued by hand
+	first := schedule.OccurrenceAt(0)
+	taken, _ := numbering.Format(schedule.NumberPattern, first, 1)
+	createTestInvoice(t, db, taken, models.StatusPending)
+
+	scheduler := NewRecurringScheduler(db, &InvoiceService{db: db})
+	for i := 0; i < 2; i++ {
+		if processed, err := scheduler.runNext(ctx); err != nil || !processed {
+			t.Fatalf("runNext() = %v, %v", processed, err)
+		}
+	}
+
+	// The collision is skipped, and the counter starts over in the next period
+	wantFirst, _ := numbering.Format(schedule.NumberPattern, first, 2)
+	wantSecond, _ := numbering.Format(schedule.NumberPattern, schedule.OccurrenceAt(1), 1)
+
+	runs, err := schedules.ListRuns(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("ListRuns() error = %v", err)
+	}
+	got := map[int]string{}
+	for _, run := range runs {
+		if run.Error != "" || run.InvoiceID == nil {
+			t.Errorf("Expected occurrence %d to create an invoice, got error %q", run.Occurrence, run.Error)
+		}
+		got[run.Occurrence] = run.InvoiceNumber
+	}
+	if got[0] != wantFirst || got[1] != wantSecond {
+		t.Errorf("Expected invoices %s and %s, got %v", wantFirst, wantSecond, got)
+	}
+
+	stored, err := schedules.FindByID(ctx, schedule.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("FindByID() = %v, %v", stored, err)
+	}
+	if stored.NumberSequence == "" || stored.OccurrenceCount != 2 || stored.LastError != "" {
+		t.Errorf("Expected the schedule to keep its sequence and advance, got %+v", stored)
+	}
+}
+
+func TestRecurringSchedulerRetriesUnnumberedOccurrence(t *testing.T) {
+	db := testdb.Open(t,
+		&models.Invoice{}, &models.PaymentLink{},
+		&models.NumberSequence{}, &models.NumberSequenceCounter{},
+		&models.RecurringSchedule{}, &models.RecurringInvoiceRun{},
+	)
+	ctx := context.Background()
+
+	schedule := models.RecurringSchedule{
+		Name:           "Missing sequence",
+		Frequency:      models.FrequencyMonthly,
+		Interval:       1,
+		StartAt:        time.Now().Add(-time.Hour),
+		NumberSequence: "deleted",
+		Template: models.InvoiceTemplate{
+			Amount:           100,
+			Currency:         "USDC",
+			ReceiverAddr:     "7xKXtg2CW87d97TXJSDpbD5jBkheTqA83TZRuJosgAsU",
+			SenderDetails:    models.Person{Name: "Seller", Email: "seller@example.com"},
+			RecipientDetails: models.Person{Name: "Buyer", Email: "buyer@example.com"},
+		},
+		Active: true,
+	}
+	schedule.Schedule()
+	schedules := repository.NewRecurringScheduleRepository(db)
+	if err := schedules.Create(ctx, &schedule); err != nil {
+		t.Fatalf("Failed to create schedule: %v", err)
+	}
+
+	scheduler := NewRecurringScheduler(db, &InvoiceService{db: db})
+	if _, err := scheduler.runNext(ctx); err != nil {
+		t.Fatalf("runNext() error = %v", err)
+	}
+
+	stored, err := schedules.FindByID(ctx, schedule.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("FindByID() = %v, %v", stored, err)
+	}
+	if stored.OccurrenceCount != 0 || stored.LastError == "" {
+		t.Errorf("Expected the occurrence to be kept with the error surfaced, got %+v", stored)
+	}
+	if stored.NextRunAt == nil || !stored.NextRunAt.After(time.Now()) {
+		t.Errorf("Expected the occurrence to be retried later, got next run %v", stored.NextRunAt)
+	}
+
+	runs, err := schedules.ListRuns(ctx, schedule.ID)
+	if err != nil {
+		t.Fatalf("ListRuns() error = %v", err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("Expected no run to be recorded for a failed occurrence, got %+v", runs)
+	}
+}
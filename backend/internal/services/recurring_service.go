@@ -0,0 +1,272 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+	"gorm.io/gorm"
+)
+
+// Errors returned by the recurring schedule service
+var (
+	ErrScheduleNotFound = errors.New("recurring schedule not found")
+	ErrTemplateNotFound = errors.New("template invoice or draft not found")
+)
+
+// TemplateValidationError reports template fields that would produce invalid invoices
+type TemplateValidationError struct {
+	Fields map[string]string
+}
+
+func (e *TemplateValidationError) Error() string {
+	return "template would not produce a valid invoice"
+}
+
+// RecurringScheduleService handles business logic for recurring invoice schedules
+type RecurringScheduleService struct {
+	db                *gorm.DB
+	repository        repository.RecurringScheduleRepository
+	invoiceRepository repository.InvoiceRepository
+}
+
+// NewRecurringScheduleService creates a new recurring schedule service
+func NewRecurringScheduleService(db *gorm.DB, repo repository.RecurringScheduleRepository, invoiceRepo repository.InvoiceRepository) *RecurringScheduleService {
+	return &RecurringScheduleService{
+		db:                db,
+		repository:        repo,
+		invoiceRepository: invoiceRepo,
+	}
+}
+
+// CreateSchedule creates a schedule, copying the template from an invoice, a draft or the request itself
+func (s *RecurringScheduleService) CreateSchedule(req models.CreateRecurringScheduleRequest) (models.RecurringSchedule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	template, err := s.resolveTemplate(ctx, req)
+	if err != nil {
+		return models.RecurringSchedule{}, err
+	}
+
+	if err := validateTemplate(template); err != nil {
+		return models.RecurringSchedule{}, err
+	}
+
+	if req.NumberSequence != "" {
+		sequence, err := repository.NewNumberSequenceRepository(s.db).FindByName(ctx, req.NumberSequence)
+		if err != nil {
+			return models.RecurringSchedule{}, fmt.Errorf("failed to get number sequence: %w", err)
+		}
+		if sequence == nil {
+			return models.RecurringSchedule{}, fmt.Errorf("%w: %s", ErrNumberSequenceNotFound, req.NumberSequence)
+		}
+	}
+
+	interval := req.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	dueDateOffset := 30
+	if req.DueDateOffsetDays != nil {
+		dueDateOffset = *req.DueDateOffsetDays
+	}
+
+	schedule := models.RecurringSchedule{
+		Name:              req.Name,
+		Frequency:         req.Frequency,
+		Interval:          interval,
+		StartAt:           req.StartAt,
+		EndAt:             req.EndAt,
+		NumberPattern:     req.NumberPattern,
+		NumberSequence:    req.NumberSequence,
+		DueDateOffsetDays: dueDateOffset,
+		Template:          template,
+		Active:            true,
+	}
+	schedule.Schedule()
+
+	if err := s.repository.Create(ctx, &schedule); err != nil {
+		return models.RecurringSchedule{}, fmt.Errorf("failed to create recurring schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+// GetSchedule retrieves a recurring schedule by ID
+func (s *RecurringScheduleService) GetSchedule(id int) (models.RecurringSchedule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	schedule, err := s.repository.FindByID(ctx, id)
+	if err != nil {
+		return models.RecurringSchedule{}, fmt.Errorf("failed to get recurring schedule: %w", err)
+	}
+	if schedule == nil {
+		return models.RecurringSchedule{}, ErrScheduleNotFound
+	}
+
+	return *schedule, nil
+}
+
+// ListSchedules returns a page of recurring schedules and the total count
+func (s *RecurringScheduleService) ListSchedules(page, limit int) ([]models.RecurringSchedule, int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.List(ctx, page, limit)
+}
+
+// UpdateSchedule pauses, resumes or edits a schedule and recomputes its next
+// run. Resuming skips the occurrences missed while paused unless the request
+// asks to catch up. The row is locked so progress the scheduler makes in the
+// meantime is not overwritten.
+func (s *RecurringScheduleService) UpdateSchedule(id int, req models.UpdateRecurringScheduleRequest) (models.RecurringSchedule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if req.Template != nil {
+		if err := validateTemplate(*req.Template); err != nil {
+			return models.RecurringSchedule{}, err
+		}
+	}
+
+	var schedule *models.RecurringSchedule
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		txRepo := repository.NewRecurringScheduleRepository(tx)
+
+		var err error
+		schedule, err = txRepo.FindByIDForUpdate(ctx, id)
+		if err != nil {
+			return fmt.Errorf("failed to get recurring schedule: %w", err)
+		}
+		if schedule == nil {
+			return ErrScheduleNotFound
+		}
+
+		if req.Template != nil {
+			schedule.Template = *req.Template
+		}
+		if req.Active != nil {
+			if *req.Active && !schedule.Active && !req.CatchUp {
+				schedule.SkipMissed(time.Now())
+			}
+			schedule.Active = *req.Active
+		}
+		if req.EndAt != nil {
+			schedule.EndAt = req.EndAt
+		}
+		if req.DueDateOffsetDays != nil {
+			schedule.DueDateOffsetDays = *req.DueDateOffsetDays
+		}
+		schedule.Schedule()
+
+		if err := txRepo.Update(ctx, schedule); err != nil {
+			return fmt.Errorf("failed to update recurring schedule: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.RecurringSchedule{}, err
+	}
+
+	return *schedule, nil
+}
+
+// DeleteSchedule removes a schedule; invoices it already generated are kept
+func (s *RecurringScheduleService) DeleteSchedule(id int) error {
+	if _, err := s.GetSchedule(id); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.Delete(ctx, id)
+}
+
+// ListRuns returns the materialized occurrences of a schedule
+func (s *RecurringScheduleService) ListRuns(id int) ([]models.RecurringInvoiceRun, error) {
+	if _, err := s.GetSchedule(id); err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return s.repository.ListRuns(ctx, id)
+}
+
+// resolveTemplate returns the invoice template referenced by the request
+func (s *RecurringScheduleService) resolveTemplate(ctx context.Context, req models.CreateRecurringScheduleRequest) (models.InvoiceTemplate, error) {
+	switch {
+	case req.Template != nil:
+		return *req.Template, nil
+
+	case req.TemplateInvoiceID != nil:
+		invoice, err := s.invoiceRepository.FindByID(ctx, *req.TemplateInvoiceID)
+		if err != nil {
+			return models.InvoiceTemplate{}, err
+		}
+		if invoice == nil {
+			return models.InvoiceTemplate{}, ErrTemplateNotFound
+		}
+		return models.InvoiceTemplateFromInvoice(*invoice), nil
+
+	default:
+		draft, err := repository.NewDraftInvoiceRepository(s.db).FindByID(ctx, *req.TemplateDraftID)
+		if err != nil {
+			return models.InvoiceTemplate{}, err
+		}
+		if draft == nil {
+			return models.InvoiceTemplate{}, ErrTemplateNotFound
+		}
+		template, err := models.InvoiceTemplateFromDraft(*draft)
+		if err != nil {
+			return models.InvoiceTemplate{}, &TemplateValidationError{Fields: map[string]string{"templateDraftId": err.Error()}}
+		}
+		return template, nil
+	}
+}
+
+// validateTemplate runs the regular invoice validation against the template
+func validateTemplate(template models.InvoiceTemplate) error {
+	req := templateRequest(template, "TEMPLATE", time.Now().AddDate(0, 0, 1))
+
+	validationErrors := req.Validate()
+	if len(validationErrors) == 0 {
+		return nil
+	}
+
+	fields := make(map[string]string, len(validationErrors))
+	for field, message := range validationErrors {
+		fields["template."+field] = message
+	}
+	return &TemplateValidationError{Fields: fields}
+}
+
+// templateRequest builds the invoice creation request for one occurrence
+func templateRequest(template models.InvoiceTemplate, invoiceNumber string, dueDate time.Time) models.CreateInvoiceRequest {
+	// Invoices for a saved customer take the customer's current details
+	if template.CustomerID != nil {
+		template.RecipientDetails = models.Person{}
+	}
+
+	return models.CreateInvoiceRequest{
+		InvoiceNumber:    invoiceNumber,
+		Amount:           template.Amount,
+		Currency:         template.Currency,
+		Description:      template.Description,
+		DueDate:          dueDate,
+		ReceiverAddr:     template.ReceiverAddr,
+		SenderDetails:    template.SenderDetails,
+		RecipientDetails: template.RecipientDetails,
+		CustomerID:       template.CustomerID,
+		SettlementToken:  template.SettlementToken,
+		RateLock:         template.RateLock,
+	}
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+)
+
+// Reports aggregate over many invoices, so they get more time than single-row lookups
+const reportTimeout = 15 * time.Second
+
+// ReportService computes receivables and revenue analytics over invoices
+type ReportService struct {
+	repository repository.ReportRepository
+}
+
+// NewReportService creates a new report service
+func NewReportService(repo repository.ReportRepository) *ReportService {
+	return &ReportService{
+		repository: repo,
+	}
+}
+
+// GetAging returns the pending invoices bucketed by how long they are past due as of asOf
+func (s *ReportService) GetAging(filter models.InvoiceFilter, asOf time.Time) (models.AgingReport, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	rows, err := s.repository.Aging(ctx, filter, asOf)
+	if err != nil {
+		return models.AgingReport{}, fmt.Errorf("failed to compute aging report: %w", err)
+	}
+
+	return models.NewAgingReport(asOf.UTC(), rows), nil
+}
+
+// GetRevenue returns the revenue received per period and currency, oldest period first
+func (s *ReportService) GetRevenue(filter models.InvoiceFilter, interval models.RevenueInterval) ([]models.RevenuePoint, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	points, err := s.repository.Revenue(ctx, filter, interval)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute revenue report: %w", err)
+	}
+
+	for i := range points {
+		points[i].Period = points[i].Period.UTC()
+		points[i].Amount = models.RoundAmount(points[i].Amount)
+	}
+	if points == nil {
+		points = []models.RevenuePoint{}
+	}
+
+	return points, nil
+}
+
+// GetDaysToPay returns how long paid invoices took to be paid
+func (s *ReportService) GetDaysToPay(filter models.InvoiceFilter) (models.DaysToPay, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	result, err := s.repository.DaysToPay(ctx, filter)
+	if err != nil {
+		return models.DaysToPay{}, fmt.Errorf("failed to compute days to pay: %w", err)
+	}
+
+	result.AverageDays = math.Round(result.AverageDays*10) / 10
+	result.MedianDays = math.Round(result.MedianDays*10) / 10
+
+	return result, nil
+}
+
+// GetTopCustomers returns the customers that paid the most, up to limit entries
+func (s *ReportService) GetTopCustomers(filter models.InvoiceFilter, limit int) ([]models.TopCustomer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), reportTimeout)
+	defer cancel()
+
+	customers, err := s.repository.TopCustomers(ctx, filter, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute top customers: %w", err)
+	}
+
+	for i := range customers {
+		customers[i].Revenue = models.RoundAmount(customers[i].Revenue)
+	}
+	if customers == nil {
+		customers = []models.TopCustomer{}
+	}
+
+	return customers, nil
+}
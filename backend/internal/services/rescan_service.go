@@ -0,0 +1,52 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+	"github.com/ncapetillo/demo-fluida/internal/repository"
+)
+
+// PaymentRescanner re-scans a receiver's on-chain history for payments
+type PaymentRescanner interface {
+	Rescan(ctx context.Context, req models.RescanRequest) (models.RescanResult, error)
+}
+
+// RescanService backfills payments the payment watcher missed, e.g. while the
+// server was down
+type RescanService struct {
+	invoices repository.InvoiceRepository
+	scanner  PaymentRescanner
+}
+
+// NewRescanService creates a new rescan service
+func NewRescanService(invoices repository.InvoiceRepository, scanner PaymentRescanner) *RescanService {
+	return &RescanService{
+		invoices: invoices,
+		scanner:  scanner,
+	}
+}
+
+// Rescan re-scans a receiver address, or the receiver of an invoice, over the
+// requested range. An invoice scan starts when the invoice was created unless
+// the request gives a start. The scan can take minutes for a busy receiver, so
+// it runs for as long as ctx allows.
+func (s *RescanService) Rescan(ctx context.Context, req models.RescanRequest) (models.RescanResult, error) {
+	if req.InvoiceID != 0 {
+		invoice, err := s.invoices.FindByID(ctx, req.InvoiceID)
+		if err != nil {
+			return models.RescanResult{}, fmt.Errorf("failed to get invoice: %w", err)
+		}
+		if invoice == nil {
+			return models.RescanResult{}, ErrInvoiceNotFound
+		}
+
+		req.ReceiverAddr = invoice.ReceiverAddr
+		if req.From == nil && req.FromSlot == 0 {
+			req.From = &invoice.CreatedAt
+		}
+	}
+
+	return s.scanner.Rescan(ctx, req)
+}
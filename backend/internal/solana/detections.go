@@ -0,0 +1,166 @@
+package solana
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// EventPublisher announces invoice events to the clients watching an invoice
+type EventPublisher interface {
+	Publish(event models.InvoiceEvent)
+}
+
+// detectionTracker remembers the transfers seen before they were finalized
+// and which invoice each pays, by signature, so each is fetched and announced
+// as detected once. An invoice ID of zero marks a transfer that pays no invoice.
+type detectionTracker struct {
+	mu   sync.Mutex
+	seen map[string]detection
+}
+
+type detection struct {
+	receiver  string
+	invoiceID int
+}
+
+func newDetectionTracker() *detectionTracker {
+	return &detectionTracker{seen: make(map[string]detection)}
+}
+
+// lookup returns what was learned about the transfer when it was first seen
+func (t *detectionTracker) lookup(signature string) (detection, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	d, ok := t.seen[signature]
+	return d, ok
+}
+
+func (t *detectionTracker) remember(signature string, d detection) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.seen[signature] = d
+}
+
+// retain forgets the receiver's transfers that are no longer waiting to be
+// finalized, because they were finalized or dropped
+func (t *detectionTracker) retain(receiver string, unfinalized map[string]bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for signature, d := range t.seen {
+		if d.receiver == receiver && !unfinalized[signature] {
+			delete(t.seen, signature)
+		}
+	}
+}
+
+// isFinalized reports whether a signature returned at confirmed commitment is
+// already finalized
+func isFinalized(sig *rpc.TransactionSignature) bool {
+	return sig.ConfirmationStatus != rpc.ConfirmationStatusConfirmed && sig.ConfirmationStatus != rpc.ConfirmationStatusProcessed
+}
+
+// announceUnfinalized tells the clients watching pending invoices about
+// transfers paying them that are confirmed but not finalized. Such transfers
+// are never recorded: they are recorded, and the invoice marked paid, once a
+// later poll sees them finalized. Until then the first sighting is announced
+// as detected and later ones as confirming, with the confirmations so far.
+func (pw *PaymentWatcher) announceUnfinalized(ctx context.Context, receiver string, signatures []*rpc.TransactionSignature, pending []models.Invoice) {
+	unfinalized := make(map[string]bool)
+	var confirming []solana.Signature
+	invoices := make(map[solana.Signature]int)
+
+	for _, sig := range signatures {
+		if sig.Err != nil {
+			continue
+		}
+		unfinalized[sig.Signature.String()] = true
+
+		if d, ok := pw.detections.lookup(sig.Signature.String()); ok {
+			if d.invoiceID != 0 {
+				confirming = append(confirming, sig.Signature)
+				invoices[sig.Signature] = d.invoiceID
+			}
+			continue
+		}
+
+		invoice, ok := pw.unfinalizedPayment(ctx, receiver, sig, pending)
+		if ctx.Err() != nil {
+			break
+		}
+		if !ok {
+			pw.detections.remember(sig.Signature.String(), detection{receiver: receiver})
+			continue
+		}
+
+		pw.detections.remember(sig.Signature.String(), detection{receiver: receiver, invoiceID: invoice.ID})
+		pw.publish(models.InvoiceEvent{
+			Type:      models.InvoiceEventDetected,
+			InvoiceID: invoice.ID,
+			Status:    invoice.Status,
+			Signature: sig.Signature.String(),
+		})
+		log.Printf("Detected payment %s for invoice %s, waiting for it to be finalized", sig.Signature, invoice.InvoiceNumber)
+	}
+	pw.detections.retain(receiver, unfinalized)
+
+	if len(confirming) == 0 {
+		return
+	}
+
+	statuses, err := pw.rpcClient.GetSignatureStatuses(ctx, false, confirming...)
+	if err != nil {
+		log.Printf("Failed to get confirmations of payments to %s: %v", receiver, err)
+		return
+	}
+	for i, status := range statuses.Value {
+		if status == nil || status.Err != nil || i >= len(confirming) {
+			continue
+		}
+		pw.publish(models.InvoiceEvent{
+			Type:          models.InvoiceEventConfirming,
+			InvoiceID:     invoices[confirming[i]],
+			Status:        models.StatusPending,
+			Signature:     confirming[i].String(),
+			Confirmations: status.Confirmations,
+		})
+	}
+}
+
+// unfinalizedPayment returns the pending invoice a confirmed transaction would
+// pay, if it pays one outright
+func (pw *PaymentWatcher) unfinalizedPayment(ctx context.Context, receiver string, sig *rpc.TransactionSignature, pending []models.Invoice) (models.Invoice, bool) {
+	tx, err := pw.rpcClient.GetParsedTransaction(ctx, sig.Signature, &rpc.GetParsedTransactionOpts{
+		Commitment:                     rpc.CommitmentConfirmed,
+		MaxSupportedTransactionVersion: ptr[uint64](0),
+	})
+	if err != nil || tx == nil {
+		return models.Invoice{}, false
+	}
+	if _, _, ok := inboundTransfer(tx, receiver); !ok {
+		return models.Invoice{}, false
+	}
+
+	paidAt := time.Now()
+	if sig.BlockTime != nil {
+		paidAt = sig.BlockTime.Time()
+	}
+
+	decision, matched := matchPayment(tx, memoCandidates(transactionMemos(tx)), pending, paidAt)
+	if !matched || decision.FlagReason != "" {
+		return models.Invoice{}, false
+	}
+	return decision.Invoice, true
+}
+
+// publish announces the event to the clients watching its invoice, if events are enabled
+func (pw *PaymentWatcher) publish(event models.InvoiceEvent) {
+	if pw.events != nil {
+		pw.events.Publish(event)
+	}
+}
@@ -0,0 +1,42 @@
+package solana
+
+import (
+	"testing"
+
+	"github.com/gagliardetto/solana-go/rpc"
+)
+
+func TestDetectionTrackerForgetsFinalizedTransfers(t *testing.T) {
+	tracker := newDetectionTracker()
+	tracker.remember("sig-paid", detection{receiver: "a", invoiceID: 1})
+	tracker.remember("sig-other", detection{receiver: "a"})
+	tracker.remember("sig-b", detection{receiver: "b", invoiceID: 2})
+
+	// sig-paid was finalized since the last poll of a
+	tracker.retain("a", map[string]bool{"sig-other": true})
+
+	if _, ok := tracker.lookup("sig-paid"); ok {
+		t.Error("Expected a transfer that is no longer unfinalized to be forgotten")
+	}
+	if d, ok := tracker.lookup("sig-other"); !ok || d.invoiceID != 0 {
+		t.Errorf("Expected a transfer paying no invoice to be remembered as such, got %+v", d)
+	}
+	if d, ok := tracker.lookup("sig-b"); !ok || d.invoiceID != 2 {
+		t.Errorf("Expected transfers to other receivers to be kept, got %+v", d)
+	}
+}
+
+func TestIsFinalized(t *testing.T) {
+	cases := map[rpc.ConfirmationStatusType]bool{
+		rpc.ConfirmationStatusFinalized: true,
+		rpc.ConfirmationStatusConfirmed: false,
+		rpc.ConfirmationStatusProcessed: false,
+		// Nodes that omit the status only return finalized signatures by default
+		"": true,
+	}
+	for status, want := range cases {
+		if got := isFinalized(&rpc.TransactionSignature{ConfirmationStatus: status}); got != want {
+			t.Errorf("isFinalized(%q) = %v, want %v", status, got, want)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package solana
+
+import (
+	"encoding/json"
+	"strings"
+	"unicode"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// memoPrograms are the SPL Memo program and its legacy v1 deployment
+var memoPrograms = map[string]bool{
+	memoProgramID.String():                        true,
+	"Memo1UhkJRfHyvLMcVucJwxXeuD728EqVDDwQDxFMNo": true,
+}
+
+// transactionMemos returns the memos of a transaction, including memos
+// written by other programs through inner instructions
+func transactionMemos(tx *rpc.GetParsedTransactionResult) []string {
+	if tx == nil {
+		return nil
+	}
+
+	var instructions []*rpc.ParsedInstruction
+	if tx.Transaction != nil {
+		instructions = append(instructions, tx.Transaction.Message.Instructions...)
+	}
+	if tx.Meta != nil {
+		for _, inner := range tx.Meta.InnerInstructions {
+			instructions = append(instructions, inner.Instructions...)
+		}
+	}
+
+	var memos []string
+	for _, instruction := range instructions {
+		if memo, ok := parsedMemo(instruction); ok {
+			memos = append(memos, memo)
+		}
+	}
+	return memos
+}
+
+// parsedMemo returns the text of a memo instruction. With jsonParsed encoding
+// the parsed field of a memo instruction is the memo itself, as a string.
+func parsedMemo(instruction *rpc.ParsedInstruction) (string, bool) {
+	if instruction == nil || instruction.Parsed == nil {
+		return "", false
+	}
+	if instruction.Program != "spl-memo" && !memoPrograms[instruction.ProgramId.String()] {
+		return "", false
+	}
+
+	raw, err := json.Marshal(instruction.Parsed)
+	if err != nil {
+		return "", false
+	}
+	var memo string
+	if err := json.Unmarshal(raw, &memo); err != nil {
+		return "", false
+	}
+	return memo, true
+}
+
+// memoInvoicePrefix marks an explicit invoice reference in a memo, e.g.
+// "INV:42", for invoice numbers too plain to be recognized within a sentence
+const memoInvoicePrefix = "inv:"
+
+// minMemoWordLength is the shortest word of a memo taken as an invoice number
+const minMemoWordLength = 4
+
+// memoCandidates returns the strings in memos that could be an invoice number
+// or link token: each whole memo, as the payment page writes it, and each word
+// of it that looks like an invoice number, since payers often write something
+// like "Payment for INV-2024-0001". Words such as "42" in "Order 42 refund"
+// are too plain to identify an invoice unless written as "INV:42".
+func memoCandidates(memos []string) []string {
+	seen := make(map[string]bool)
+	var candidates []string
+
+	add := func(value string) {
+		value = trimMemoWord(value)
+		if value == "" || seen[value] {
+			return
+		}
+		seen[value] = true
+		candidates = append(candidates, value)
+	}
+
+	for _, memo := range memos {
+		add(memo)
+		for _, word := range strings.Fields(memo) {
+			word = trimMemoWord(word)
+			if len(word) > len(memoInvoicePrefix) && strings.EqualFold(word[:len(memoInvoicePrefix)], memoInvoicePrefix) {
+				add(word[len(memoInvoicePrefix):])
+			} else if identifyingMemoWord(word) {
+				add(word)
+			}
+		}
+	}
+	return candidates
+}
+
+// trimMemoWord strips the whitespace and punctuation around a memo or a word of it
+func trimMemoWord(value string) string {
+	return strings.Trim(value, " \t\r\n.,;:#\"'()[]")
+}
+
+// identifyingMemoWord reports whether a word of a memo looks like an invoice
+// number or link token: long enough, with both letters and digits
+func identifyingMemoWord(word string) bool {
+	if len(word) < minMemoWordLength {
+		return false
+	}
+	return strings.ContainsAny(word, "0123456789") &&
+		strings.IndexFunc(word, unicode.IsLetter) >= 0
+}
+
+// memoNamesInvoice reports whether one of the memo candidates is the invoice's
+// number, compared case-insensitively, or its link token
+func memoNamesInvoice(candidates []string, invoice models.Invoice) bool {
+	for _, candidate := range candidates {
+		if strings.EqualFold(candidate, invoice.InvoiceNumber) || candidate == invoice.LinkToken {
+			return true
+		}
+	}
+	return false
+}
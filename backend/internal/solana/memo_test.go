@@ -0,0 +1,91 @@
+package solana
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// memoTransaction returns a transaction in which the receiver gains amount USDC
+// and that carries the memo
+func memoTransaction(t *testing.T, memo, amount string) *rpc.GetParsedTransactionResult {
+	var parsed rpc.InstructionInfoEnvelope
+	if err := json.Unmarshal([]byte(`"`+memo+`"`), &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	return &rpc.GetParsedTransactionResult{
+		Transaction: &rpc.ParsedTransaction{Message: rpc.ParsedMessage{
+			Instructions: []*rpc.ParsedInstruction{{Program: "spl-memo", ProgramId: memoProgramID, Parsed: &parsed}},
+		}},
+		Meta: &rpc.ParsedTransactionMeta{
+			PostTokenBalances: []rpc.TokenBalance{tokenBalance(1, testReceiver, USDCDevnetMint, amount)},
+		},
+	}
+}
+
+func TestMatchPaymentByMemoWhenAmountDiffers(t *testing.T) {
+	pending := []models.Invoice{
+		{ID: 1, InvoiceNumber: "INV-6", Amount: 10, ReceiverAddr: testReceiver},
+		{ID: 2, InvoiceNumber: "INV-7", Amount: 10, ReceiverAddr: testReceiver},
+	}
+
+	tx := memoTransaction(t, "Payment for inv-7.", "10.5")
+	memos := transactionMemos(tx)
+	if len(memos) != 1 || memos[0] != "Payment for inv-7." {
+		t.Fatalf("Expected the memo to be parsed, got %v", memos)
+	}
+
+	decision, ok := matchPayment(tx, memoCandidates(memos), pending, time.Now())
+	if !ok || decision.Invoice.ID != 2 || decision.MatchedBy != models.PaymentMatchMemo || decision.FlagReason != "" {
+		t.Errorf("Expected an overpayment of INV-7 to pay it by memo, got %+v (%v)", decision, ok)
+	}
+
+	tx = memoTransaction(t, "INV-7", "9.5")
+	decision, ok = matchPayment(tx, memoCandidates(transactionMemos(tx)), pending, time.Now())
+	if !ok || decision.Invoice.ID != 2 || decision.FlagReason == "" {
+		t.Errorf("Expected an underpayment of INV-7 to be flagged, got %+v (%v)", decision, ok)
+	}
+}
+
+func TestMatchPaymentByReference(t *testing.T) {
+	invoice := models.Invoice{ID: 3, InvoiceNumber: "INV-8", LinkToken: "link", Amount: 25, ReceiverAddr: testReceiver}
+
+	tx := memoTransaction(t, "unrelated", "25")
+	tx.Transaction.Message.AccountKeys = []rpc.ParsedMessageAccount{
+		{PublicKey: solana.NewWallet().PublicKey()},
+		{PublicKey: PaymentReference(invoice)},
+	}
+
+	decision, ok := matchPayment(tx, memoCandidates(transactionMemos(tx)), []models.Invoice{invoice}, time.Now())
+	if !ok || decision.MatchedBy != models.PaymentMatchReference || decision.FlagReason != "" {
+		t.Errorf("Expected the reference key to match, got %+v (%v)", decision, ok)
+	}
+}
+
+func TestMemoCandidatesIgnorePlainWords(t *testing.T) {
+	pending := []models.Invoice{
+		{ID: 1, InvoiceNumber: "42", Amount: 10, ReceiverAddr: testReceiver},
+		{ID: 2, InvoiceNumber: "1", Amount: 10, ReceiverAddr: testReceiver},
+	}
+
+	for _, memo := range []string{"Order 42 refund", "batch 1"} {
+		tx := memoTransaction(t, memo, "20")
+		if decision, ok := matchPayment(tx, memoCandidates(transactionMemos(tx)), pending, time.Now()); ok {
+			t.Errorf("Expected memo %q not to name an invoice, got %+v", memo, decision)
+		}
+	}
+
+	// The whole memo, as the payment page writes it, and the explicit form do
+	for _, memo := range []string{"42", "Payment for INV:42"} {
+		tx := memoTransaction(t, memo, "20")
+		decision, ok := matchPayment(tx, memoCandidates(transactionMemos(tx)), pending, time.Now())
+		if !ok || decision.Invoice.ID != 1 || decision.MatchedBy != models.PaymentMatchMemo {
+			t.Errorf("Expected memo %q to name invoice 42, got %+v (%v)", memo, decision, ok)
+		}
+	}
+}
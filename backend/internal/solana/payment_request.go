@@ -0,0 +1,142 @@
+package solana
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+var (
+	// memoProgramID is the SPL Memo program (v2)
+	memoProgramID = solana.MustPublicKeyFromBase58("MemoSq4gqABAXKb96qQH8TysNcWxMyWCqXgDLGmfcHr")
+)
+
+// Instruction indexes of the programs payment transactions call
+const (
+	systemTransferInstruction       = 2
+	tokenTransferCheckedInstruction = 12
+	ataCreateIdempotentInstruction  = 1
+)
+
+// TransactionBuilder builds the unsigned payment transactions handed to
+// Solana Pay wallets
+type TransactionBuilder struct {
+	rpcClient *rpc.Client
+}
+
+// NewTransactionBuilder creates a transaction builder using the default RPC endpoints
+func NewTransactionBuilder() *TransactionBuilder {
+	return &TransactionBuilder{
+		rpcClient: DefaultRPCPool().Client(),
+	}
+}
+
+// BuildPaymentTransaction returns a serialized, unsigned transaction in which
+// payer pays the invoice's quoted amount of its settlement token to the
+// receiver. The payer is the fee payer and the only signer; its signature slot
+// is left empty for the wallet to fill in.
+func (tb *TransactionBuilder) BuildPaymentTransaction(ctx context.Context, invoice models.Invoice, payer string) ([]byte, error) {
+	payerKey, err := solana.PublicKeyFromBase58(payer)
+	if err != nil {
+		return nil, fmt.Errorf("invalid payer account: %w", err)
+	}
+
+	instructions, err := paymentInstructions(invoice, payerKey)
+	if err != nil {
+		return nil, err
+	}
+
+	blockhash, err := tb.rpcClient.GetLatestBlockhash(ctx, rpc.CommitmentFinalized)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest blockhash: %w", err)
+	}
+
+	tx, err := solana.NewTransaction(instructions, blockhash.Value.Blockhash, solana.TransactionPayer(payerKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build transaction: %w", err)
+	}
+	tx.Signatures = make([]solana.Signature, tx.Message.Header.NumRequiredSignatures)
+
+	return tx.MarshalBinary()
+}
+
+// PaymentReference returns the Solana Pay reference key of an invoice. It is
+// derived from the link token, so every transaction requested for the invoice
+// carries the same key and it never needs to be stored.
+func PaymentReference(invoice models.Invoice) solana.PublicKey {
+	sum := sha256.Sum256([]byte("fluida:reference:" + invoice.LinkToken))
+	return solana.PublicKeyFromBytes(sum[:])
+}
+
+// paymentInstructions returns the instructions of an invoice payment: for an
+// SPL token, creating the receiver's associated token account if it does not
+// exist yet, then the memo, then the transfer. The reference key is added to
+// the transfer as a read-only account so the payment can be found by it.
+func paymentInstructions(invoice models.Invoice, payer solana.PublicKey) ([]solana.Instruction, error) {
+	receiver, err := solana.PublicKeyFromBase58(invoice.ReceiverAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid receiver address: %w", err)
+	}
+
+	symbol, amount := invoice.Settlement()
+	token, ok := models.SettlementTokens[symbol]
+	if !ok {
+		return nil, fmt.Errorf("unsupported settlement token %s", symbol)
+	}
+	units := uint64(math.Round(amount * math.Pow10(token.Decimals)))
+	reference := PaymentReference(invoice)
+	memo := solana.NewInstruction(memoProgramID, solana.AccountMetaSlice{}, []byte(invoice.InvoiceNumber))
+
+	if symbol == models.NativeSOL {
+		data := binary.LittleEndian.AppendUint32(nil, systemTransferInstruction)
+		data = binary.LittleEndian.AppendUint64(data, units)
+		transfer := solana.NewInstruction(solana.SystemProgramID, solana.AccountMetaSlice{
+			solana.Meta(payer).WRITE().SIGNER(),
+			solana.Meta(receiver).WRITE(),
+			solana.Meta(reference),
+		}, data)
+		return []solana.Instruction{memo, transfer}, nil
+	}
+
+	mintAddr, ok := tokenMints[symbol]
+	if !ok {
+		return nil, fmt.Errorf("no mint configured for %s", symbol)
+	}
+	mint := solana.MustPublicKeyFromBase58(mintAddr)
+
+	source, _, err := solana.FindAssociatedTokenAddress(payer, mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive payer token account: %w", err)
+	}
+	destination, _, err := solana.FindAssociatedTokenAddress(receiver, mint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive receiver token account: %w", err)
+	}
+
+	createAccount := solana.NewInstruction(solana.SPLAssociatedTokenAccountProgramID, solana.AccountMetaSlice{
+		solana.Meta(payer).WRITE().SIGNER(),
+		solana.Meta(destination).WRITE(),
+		solana.Meta(receiver),
+		solana.Meta(mint),
+		solana.Meta(solana.SystemProgramID),
+		solana.Meta(solana.TokenProgramID),
+	}, []byte{ataCreateIdempotentInstruction})
+
+	data := binary.LittleEndian.AppendUint64([]byte{tokenTransferCheckedInstruction}, units)
+	data = append(data, byte(token.Decimals))
+	transfer := solana.NewInstruction(solana.TokenProgramID, solana.AccountMetaSlice{
+		solana.Meta(source).WRITE(),
+		solana.Meta(mint),
+		solana.Meta(destination).WRITE(),
+		solana.Meta(payer).SIGNER(),
+		solana.Meta(reference),
+	}, data)
+
+	return []solana.Instruction{createAccount, memo, transfer}, nil
+}
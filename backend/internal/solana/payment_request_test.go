@@ -0,0 +1,54 @@
+package solana
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+func TestPaymentInstructionsTransferQuotedTokenAmount(t *testing.T) {
+	payer := solana.NewWallet().PublicKey()
+	invoice := models.Invoice{
+		InvoiceNumber:    "INV-1",
+		LinkToken:        "link-token",
+		Amount:           100,
+		Currency:         "EUR",
+		ReceiverAddr:     testReceiver,
+		SettlementToken:  "USDC",
+		SettlementAmount: 108.25,
+	}
+
+	instructions, err := paymentInstructions(invoice, payer)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(instructions) != 3 {
+		t.Fatalf("Expected create account, memo and transfer instructions, got %d", len(instructions))
+	}
+
+	if !instructions[0].ProgramID().Equals(solana.SPLAssociatedTokenAccountProgramID) {
+		t.Errorf("Expected the receiver's token account to be created first, got program %s", instructions[0].ProgramID())
+	}
+
+	memo, _ := instructions[1].Data()
+	if string(memo) != "INV-1" {
+		t.Errorf("Expected the invoice number as memo, got %q", memo)
+	}
+
+	transfer := instructions[2]
+	data, _ := transfer.Data()
+	if data[0] != tokenTransferCheckedInstruction || binary.LittleEndian.Uint64(data[1:9]) != 108_250_000 || data[9] != 6 {
+		t.Errorf("Expected a checked transfer of 108250000 base units, got %v", data)
+	}
+
+	destination, _, _ := solana.FindAssociatedTokenAddress(solana.MustPublicKeyFromBase58(testReceiver), solana.MustPublicKeyFromBase58(USDCDevnetMint))
+	accounts := transfer.Accounts()
+	if !accounts[2].PublicKey.Equals(destination) {
+		t.Errorf("Expected the transfer to go to %s, got %s", destination, accounts[2].PublicKey)
+	}
+	if last := accounts[len(accounts)-1]; !last.PublicKey.Equals(PaymentReference(invoice)) || last.IsSigner || last.IsWritable {
+		t.Errorf("Expected the reference key as a read-only account, got %+v", last)
+	}
+}
@@ -2,9 +2,14 @@ package solana
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"math/big"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gagliardetto/solana-go"
@@ -20,61 +25,116 @@ const (
 	// This is the official USDC mint address for Solana devnet
 	USDCDevnetMint = "4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU"
 	
+	// EURC Token mint address on Solana devnet, issued by Circle like USDC
+	EURCDevnetMint = "HzwqbKZw8HxMN6bF2yFZNrht3c2iXXzpKcFu7uBEDKtr"
+	
 	// Polling interval for checking transactions
 	pollInterval = 15 * time.Second
+	
+	// Receivers are dispatched to workers for at most this long per poll; the
+	// ones not reached are the first to be checked on the next poll
+	passBudget = 30 * time.Second
+	
+	// Receivers stay watched this long after their last invoice was paid or
+	// canceled, so duplicate payments and overpayments sent later are recorded
+	settledReceiverGrace = 7 * 24 * time.Hour
+	
+	// Defaults for PAYMENT_WATCHER_WORKERS and PAYMENT_CHECK_TIMEOUT
+	defaultWorkers      = 4
+	defaultCheckTimeout = 10 * time.Second
 )
 
-// PaymentWatcher monitors Solana blockchain for USDC payments to specific addresses
+// PaymentWatcher monitors Solana blockchain for SOL and stablecoin payments to specific addresses
 type PaymentWatcher struct {
-	rpcClient  *rpc.Client
-	repository repository.InvoiceRepository
-	ctx        context.Context
-	cancel     context.CancelFunc
+	rpcClient    *rpc.Client
+	repository   repository.InvoiceRepository
+	creditNotes  repository.CreditNoteRepository
+	payments     repository.PaymentRepository
+	watchRefunds bool
+	workers      int
+	checkTimeout time.Duration
+	queue        *receiverQueue
+	events       EventPublisher
+	detections   *detectionTracker
+	ctx          context.Context
+	cancel       context.CancelFunc
 }
 
-// NewPaymentWatcher creates a new payment watcher for the Solana blockchain
-func NewPaymentWatcher() (*PaymentWatcher, error) {
-	// We're using devnet for development
-	endpoint := rpc.DevNet_RPC
-	
-	// Create RPC client
-	rpcClient := rpc.New(endpoint)
+// NewPaymentWatcher creates a new payment watcher for the Solana blockchain.
+// Events, when set, is told about payments as they are detected, confirmed
+// and recorded, so the payment page can follow them.
+func NewPaymentWatcher(events EventPublisher) (*PaymentWatcher, error) {
+	// Requests go to the configured RPC endpoints, devnet by default, failing
+	// over between them; all workers share one token bucket
+	rpcClient := newRateLimitedClient(DefaultRPCPool(), newRPCLimiter())
 	
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	
 	// Initialize repository
 	invoiceRepo := repository.NewInvoiceRepository(db.DB)
+	creditNoteRepo := repository.NewCreditNoteRepository(db.DB)
+	paymentRepo := repository.NewPaymentRepository(db.DB)
+	
+	// Detecting outbound refunds costs extra RPC calls, so it is opt-in
+	watchRefunds := os.Getenv("WATCH_REFUNDS") == "true"
+	
+	// Receivers are checked concurrently, each within its own timeout
+	workers := defaultWorkers
+	if v, err := strconv.Atoi(os.Getenv("PAYMENT_WATCHER_WORKERS")); err == nil && v > 0 {
+		workers = v
+	}
+	checkTimeout := defaultCheckTimeout
+	if v, err := time.ParseDuration(os.Getenv("PAYMENT_CHECK_TIMEOUT")); err == nil && v > 0 {
+		checkTimeout = v
+	}
 	
 	return &PaymentWatcher{
-		rpcClient:  rpcClient,
-		repository: invoiceRepo,
-		ctx:        ctx,
-		cancel:     cancel,
+		rpcClient:    rpcClient,
+		repository:   invoiceRepo,
+		creditNotes:  creditNoteRepo,
+		payments:     paymentRepo,
+		watchRefunds: watchRefunds,
+		workers:      workers,
+		checkTimeout: checkTimeout,
+		queue:        newReceiverQueue(),
+		events:       events,
+		detections:   newDetectionTracker(),
+		ctx:          ctx,
+		cancel:       cancel,
 	}, nil
 }
 
 // Start begins the payment watching process using polling approach
 func (pw *PaymentWatcher) Start() {
+	// Run the polling in a goroutine
+	go pw.Run(pw.ctx)
+}
+
+// Run polls for payments until ctx is done. It is used directly by callers
+// that decide when this process should watch, such as the leader elector.
+func (pw *PaymentWatcher) Run(ctx context.Context) {
 	log.Println("Starting Solana payment watcher")
 	
-	// Run the polling in a goroutine
-	go func() {
-		ticker := time.NewTicker(pollInterval)
-		defer ticker.Stop()
-		
-		for {
-			select {
-			case <-pw.ctx.Done():
-				log.Println("Payment watcher shutting down")
-				return
-			case <-ticker.C:
-				if err := pw.checkPendingInvoices(); err != nil {
-					log.Printf("Error checking pending invoices: %v", err)
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Payment watcher shutting down")
+			return
+		case <-ticker.C:
+			if err := pw.checkPendingInvoices(ctx); err != nil {
+				log.Printf("Error checking pending invoices: %v", err)
+			}
+			if pw.watchRefunds {
+				if err := pw.checkPendingRefunds(ctx); err != nil {
+					log.Printf("Error checking pending refunds: %v", err)
 				}
 			}
 		}
-	}()
+	}
 }
 
 // Stop halts the payment watcher
@@ -88,124 +148,381 @@ func (pw *PaymentWatcher) WatchForPayments() {
 	pw.Start()
 }
 
-// checkPendingInvoices looks for pending invoices and checks for payments
-func (pw *PaymentWatcher) checkPendingInvoices() error {
-	// Create a timeout context for this operation
-	ctx, cancel := context.WithTimeout(pw.ctx, 30*time.Second)
+// checkPendingInvoices looks for pending invoices and checks their receivers
+// for payments, along with the receivers of invoices settled within the last
+// settledReceiverGrace. Receivers are handed to a bounded pool of workers,
+// least recently checked first, and each check has its own timeout, so one
+// slow receiver cannot hold up the others. Receivers not reached within the
+// pass budget are first in line on the next poll, so every pending invoice is
+// checked within a bounded number of polls however large the backlog.
+func (pw *PaymentWatcher) checkPendingInvoices(ctx context.Context) error {
+	queryCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 	
 	// Find all pending invoices
-	pendingInvoices, err := pw.repository.FindPendingInvoices(ctx)
+	pendingInvoices, err := pw.repository.FindPendingInvoices(queryCtx)
 	if err != nil {
 		return fmt.Errorf("failed to fetch pending invoices: %v", err)
 	}
 	
-	// Check each invoice for payments
+	// Each receiver's transactions are fetched once for all of its invoices
+	byReceiver := make(map[string][]models.Invoice)
 	for _, invoice := range pendingInvoices {
-		// Skip checking if context is done
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			// Continue processing
-		}
-		
-		paid, err := pw.checkForPayment(ctx, invoice)
-		if err != nil {
-			log.Printf("Error checking payment for invoice %s: %v", invoice.InvoiceNumber, err)
-			continue
+		byReceiver[invoice.ReceiverAddr] = append(byReceiver[invoice.ReceiverAddr], invoice)
+	}
+	
+	// Receivers whose invoices were all settled recently are still checked, with
+	// nothing pending, so later transfers to them are recorded for review
+	pendingReceivers := len(byReceiver)
+	settledReceivers, err := pw.repository.FindRecentlySettledReceivers(queryCtx, time.Now().Add(-settledReceiverGrace))
+	if err != nil {
+		return fmt.Errorf("failed to fetch recently settled receivers: %v", err)
+	}
+	for _, receiver := range settledReceivers {
+		if _, ok := byReceiver[receiver]; !ok {
+			byReceiver[receiver] = nil
 		}
-		
-		if paid {
-			// Use database transaction to update the invoice status
-			err := db.DB.Transaction(func(tx *gorm.DB) error {
-				txCtx, txCancel := context.WithTimeout(context.Background(), 5*time.Second)
-				defer txCancel()
-				
-				txRepo := repository.NewInvoiceRepository(tx)
-				// Update invoice status to PAID
-				return txRepo.UpdateStatus(txCtx, invoice.ID, models.StatusPaid)
-			})
-			
-			if err != nil {
-				log.Printf("Failed to update invoice %s to PAID: %v", invoice.InvoiceNumber, err)
-			} else {
-				log.Printf("Invoice %s marked as PAID", invoice.InvoiceNumber)
+	}
+	
+	started := time.Now()
+	order := pw.queue.order(byReceiver, started)
+	watcherMetrics.pendingInvoices.Set(int64(len(pendingInvoices)))
+	watcherMetrics.pendingReceivers.Set(int64(pendingReceivers))
+	watcherMetrics.watchedReceivers.Set(int64(len(order)))
+	
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < pw.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for receiver := range jobs {
+				pw.checkReceiverInTime(ctx, receiver, byReceiver[receiver])
 			}
+		}()
+	}
+	
+	// Stop handing out receivers once the pass budget is spent
+	budget := time.NewTimer(passBudget)
+	defer budget.Stop()
+	
+	dispatched := 0
+dispatch:
+	for _, receiver := range order {
+		select {
+		case jobs <- receiver:
+			watcherMetrics.observeLag(started.Sub(pw.queue.waitingSince(receiver)))
+			dispatched++
+		case <-budget.C:
+			break dispatch
+		case <-ctx.Done():
+			break dispatch
 		}
 	}
+	close(jobs)
+	wg.Wait()
+	
+	watcherMetrics.passSeconds.Set(time.Since(started).Seconds())
+	if deferred := len(order) - dispatched; deferred > 0 {
+		watcherMetrics.deferredReceivers.Add(int64(deferred))
+		log.Printf("Checked %d of %d receivers within %s; the other %d go first next poll", dispatched, len(order), passBudget, deferred)
+	}
 	
 	return nil
 }
 
+// checkReceiverInTime checks one receiver within the per-check timeout and
+// moves it to the back of the queue once checked
+func (pw *PaymentWatcher) checkReceiverInTime(ctx context.Context, receiver string, pending []models.Invoice) {
+	ctx, cancel := context.WithTimeout(ctx, pw.checkTimeout)
+	defer cancel()
+	
+	err := pw.checkReceiver(ctx, receiver, pending)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		watcherMetrics.checkTimeouts.Add(1)
+		log.Printf("Checking payments to %s timed out after %s", receiver, pw.checkTimeout)
+	case err != nil:
+		log.Printf("Error checking payments to %s: %v", receiver, err)
+	}
+	
+	watcherMetrics.receiversChecked.Add(1)
+	pw.queue.checked(receiver, time.Now())
+}
+
 // ptr returns a pointer to the provided value
 func ptr[T any](v T) *T {
 	return &v
 }
 
-// checkForPayment checks if a specific invoice has been paid
-func (pw *PaymentWatcher) checkForPayment(ctx context.Context, invoice models.Invoice) (bool, error) {
+// checkReceiver matches the recent inbound transfers to a receiver against its
+// pending invoices. Only finalized transfers are recorded; when events are
+// enabled, transfers that are merely confirmed are announced to the payment
+// page while they wait to be finalized.
+func (pw *PaymentWatcher) checkReceiver(ctx context.Context, receiver string, pending []models.Invoice) error {
 	// Parse receiver address
-	receiverPubkey, err := solana.PublicKeyFromBase58(invoice.ReceiverAddr)
+	receiverPubkey, err := solana.PublicKeyFromBase58(receiver)
 	if err != nil {
-		return false, fmt.Errorf("invalid receiver address: %v", err)
+		return fmt.Errorf("invalid receiver address: %v", err)
+	}
+	
+	// Get recent signatures for the account, including confirmed ones if they are announced
+	opts := &rpc.GetSignaturesForAddressOpts{}
+	if pw.events != nil {
+		opts.Commitment = rpc.CommitmentConfirmed
+	}
+	signatures, err := pw.rpcClient.GetSignaturesForAddressWithOpts(ctx, receiverPubkey, opts)
+	if err != nil {
+		return fmt.Errorf("failed to get transaction signatures: %v", err)
+	}
+	
+	var finalized, unfinalized []*rpc.TransactionSignature
+	for _, sig := range signatures {
+		if isFinalized(sig) {
+			finalized = append(finalized, sig)
+		} else {
+			unfinalized = append(unfinalized, sig)
+		}
 	}
 	
-	// Get recent signatures for the account
-	signatures, err := pw.rpcClient.GetSignaturesForAddress(ctx, receiverPubkey)
+	var result models.RescanResult
+	pending, err = pw.processSignatures(ctx, receiver, finalized, pending, &result)
 	if err != nil {
-		return false, fmt.Errorf("failed to get transaction signatures: %v", err)
+		return err
 	}
 	
-	// Check each transaction
+	if pw.events != nil {
+		pw.announceUnfinalized(ctx, receiver, unfinalized, pending)
+	}
+	return nil
+}
+
+// processSignatures matches the inbound transfers among signatures, which come
+// newest first as returned by RPC, against the receiver's pending invoices,
+// oldest transfer first. Matched invoices are marked paid; transfers that name
+// an invoice they cannot settle, or carry a memo naming no invoice at all, are
+// flagged for review, and every other transfer is recorded as unmatched. Each
+// transfer is recorded once, so it is only examined once. It returns the
+// invoices still pending and adds the outcomes to result.
+func (pw *PaymentWatcher) processSignatures(ctx context.Context, receiver string, signatures []*rpc.TransactionSignature, pending []models.Invoice, result *models.RescanResult) ([]models.Invoice, error) {
+	// Transfers handled on an earlier poll are not fetched again
+	var candidates []string
 	for _, sig := range signatures {
+		candidates = append(candidates, sig.Signature.String())
+	}
+	known, err := pw.payments.KnownSignatures(ctx, receiver, candidates)
+	if err != nil {
+		return pending, fmt.Errorf("failed to look up recorded payments: %v", err)
+	}
+	
+	// Signatures come newest first; the earliest payment of an invoice is the one that counts
+	for i := len(signatures) - 1; i >= 0; i-- {
+		sig := signatures[i]
+		
 		// Check for context cancellation
 		select {
 		case <-ctx.Done():
-			return false, ctx.Err()
+			return pending, ctx.Err()
 		default:
 			// Continue processing
 		}
 		
-		// Skip failed transactions
+		// Skip failed and already recorded transactions
 		if sig.Err != nil {
 			continue
 		}
-		
-		// Get transaction details
-		txSig, err := solana.SignatureFromBase58(sig.Signature.String())
-		if err != nil {
-			// Log at debug level in production
-			if isDebugMode() {
-				log.Printf("Invalid signature format: %v", err)
-			}
+		if known[sig.Signature.String()] {
+			result.Recorded++
 			continue
 		}
 		
 		// Add maxSupportedTransactionVersion parameter to fix version error
-		tx, err := pw.rpcClient.GetTransaction(
+		tx, err := pw.rpcClient.GetParsedTransaction(
 			ctx, 
-			txSig,
-			&rpc.GetTransactionOpts{
-				Encoding: solana.EncodingJSONParsed,
+			sig.Signature,
+			&rpc.GetParsedTransactionOpts{
 				MaxSupportedTransactionVersion: ptr[uint64](0),
 			},
 		)
-		if err != nil {
+		if err != nil || tx == nil {
 			// Log at debug level in production to reduce noise
 			if isDebugMode() {
 				log.Printf("Failed to get transaction details: %v", err)
 			}
+			result.Unfetched++
 			continue
 		}
 		
-		// Check if this is a USDC payment to the receiver
-		if isPaymentForInvoice(tx, invoice, receiverPubkey) {
-			return true, nil
+		token, amount, ok := inboundTransfer(tx, receiver)
+		if !ok {
+			continue
+		}
+		result.Transfers++
+		
+		paidAt := time.Now()
+		if sig.BlockTime != nil {
+			paidAt = sig.BlockTime.Time()
+		}
+		
+		memos := transactionMemos(tx)
+		payment := models.Payment{
+			Signature:    sig.Signature.String(),
+			ReceiverAddr: receiver,
+			Token:        token,
+			Amount:       amount,
+			Memo:         strings.Join(memos, "\n"),
+			BlockTime:    paidAt,
+		}
+		
+		decision, matched := matchPayment(tx, memoCandidates(memos), pending, paidAt)
+		switch {
+		case matched && decision.FlagReason == "":
+			paid, flagged := pw.recordPaid(ctx, decision, payment)
+			if paid {
+				result.Paid = append(result.Paid, decision.Invoice.InvoiceNumber)
+			}
+			if flagged {
+				result.Flagged++
+			}
+			if paid || flagged {
+				pending = removeInvoice(pending, decision.Invoice.ID)
+			}
+		case matched:
+			payment.InvoiceID = &decision.Invoice.ID
+			payment.MatchedBy = decision.MatchedBy
+			if pw.flagPayment(ctx, payment, decision.FlagReason) {
+				result.Flagged++
+			}
+		case len(memos) > 0:
+			if pw.flagUnknownMemo(ctx, payment, memoCandidates(memos)) {
+				result.Flagged++
+			}
+		default:
+			if pw.recordUnmatched(ctx, payment) {
+				result.Unmatched++
+			}
+		}
+	}
+	
+	return pending, nil
+}
+
+// errInvoiceNotPending rolls back recording a payment for an invoice that was
+// paid or canceled after the pending invoices were read
+var errInvoiceNotPending = errors.New("invoice is no longer pending")
+
+// recordPaid marks the decided invoice as paid by the payment and records the
+// payment, in one database transaction, and reports whether both were saved.
+// When the invoice is no longer pending nothing is changed and the payment is
+// flagged for review instead, which it also reports.
+func (pw *PaymentWatcher) recordPaid(ctx context.Context, decision paymentDecision, payment models.Payment) (paid, flagged bool) {
+	invoice := decision.Invoice
+	payment.InvoiceID = &invoice.ID
+	payment.MatchedBy = decision.MatchedBy
+	payment.Status = models.PaymentStatusMatched
+	
+	err := db.DB.Transaction(func(tx *gorm.DB) error {
+		txCtx, txCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer txCancel()
+		
+		// Update invoice status to PAID and keep the payment reference
+		marked, err := repository.NewInvoiceRepository(tx).MarkPaid(txCtx, invoice.ID, payment.Signature, payment.BlockTime)
+		if err != nil {
+			return err
+		}
+		if !marked {
+			return errInvoiceNotPending
+		}
+		// Settle the checkout session the invoice was materialized for, if any
+		if _, err := repository.NewCheckoutRepository(tx).CompleteSession(txCtx, invoice.ID, payment.BlockTime); err != nil {
+			return err
 		}
+		return repository.NewPaymentRepository(tx).Create(txCtx, &payment)
+	})
+	
+	if errors.Is(err, errInvoiceNotPending) {
+		reason := fmt.Sprintf("Invoice %s was paid or canceled before this payment was recorded", invoice.InvoiceNumber)
+		return false, pw.flagPayment(ctx, payment, reason)
+	}
+	if err != nil {
+		log.Printf("Failed to update invoice %s to PAID: %v", invoice.InvoiceNumber, err)
+		return false, false
 	}
 	
-	return false, nil
+	log.Printf("Invoice %s marked as PAID by transaction %s (matched by %s)", invoice.InvoiceNumber, payment.Signature, decision.MatchedBy)
+	pw.publish(models.InvoiceEvent{
+		Type:      models.InvoiceEventPaid,
+		InvoiceID: invoice.ID,
+		Status:    models.StatusPaid,
+		Signature: payment.Signature,
+	})
+	return true, false
+}
+
+// flagPayment records a payment that needs review. It reports whether the
+// payment was saved.
+func (pw *PaymentWatcher) flagPayment(ctx context.Context, payment models.Payment, reason string) bool {
+	payment.Status = models.PaymentStatusFlagged
+	payment.FlagReason = reason
+	
+	if err := pw.payments.Create(ctx, &payment); err != nil {
+		log.Printf("Failed to flag payment %s: %v", payment.Signature, err)
+		return false
+	}
+	log.Printf("Flagged payment %s to %s: %s", payment.Signature, payment.ReceiverAddr, reason)
+	return true
+}
+
+// recordUnmatched records a transfer no invoice could be tied to, so an
+// operator can allocate it by hand. It reports whether the payment was saved.
+func (pw *PaymentWatcher) recordUnmatched(ctx context.Context, payment models.Payment) bool {
+	payment.Status = models.PaymentStatusUnmatched
+	
+	if err := pw.payments.Create(ctx, &payment); err != nil {
+		log.Printf("Failed to record unmatched payment %s: %v", payment.Signature, err)
+		return false
+	}
+	log.Printf("Recorded unmatched payment %s of %f %s to %s", payment.Signature, payment.Amount, payment.Token, payment.ReceiverAddr)
+	return true
+}
+
+// flagUnknownMemo flags a transfer whose memo did not settle any pending
+// invoice of its receiver. A memo naming an invoice this transaction already
+// paid is the invoice's own payment and is not flagged. It reports whether the
+// payment was flagged.
+func (pw *PaymentWatcher) flagUnknownMemo(ctx context.Context, payment models.Payment, candidates []string) bool {
+	invoices, err := pw.repository.FindByMemo(ctx, candidates)
+	if err != nil {
+		log.Printf("Failed to look up invoices named by memo of %s: %v", payment.Signature, err)
+		return false
+	}
+	
+	if len(invoices) == 0 {
+		return pw.flagPayment(ctx, payment, fmt.Sprintf("Memo %q does not name any invoice", payment.Memo))
+	}
+	
+	invoice := invoices[0]
+	if invoice.PaymentTxSignature == payment.Signature {
+		return false
+	}
+	
+	payment.InvoiceID = &invoice.ID
+	payment.MatchedBy = models.PaymentMatchMemo
+	if invoice.Status != models.StatusPending {
+		return pw.flagPayment(ctx, payment, fmt.Sprintf("Memo names invoice %s, which is %s", invoice.InvoiceNumber, invoice.Status))
+	}
+	return pw.flagPayment(ctx, payment, fmt.Sprintf("Memo names invoice %s, which is payable to %s", invoice.InvoiceNumber, invoice.ReceiverAddr))
+}
+
+// removeInvoice returns the invoices without the one with the given ID
+func removeInvoice(invoices []models.Invoice, id int) []models.Invoice {
+	remaining := invoices[:0:0]
+	for _, invoice := range invoices {
+		if invoice.ID != id {
+			remaining = append(remaining, invoice)
+		}
+	}
+	return remaining
 }
 
 // isDebugMode returns true if we're running in debug mode
@@ -216,80 +533,102 @@ func isDebugMode() bool {
 	return false // Set to false for production
 }
 
-// isPaymentForInvoice determines if a transaction represents payment for an invoice
-func isPaymentForInvoice(tx *rpc.GetTransactionResult, invoice models.Invoice, receiverPubkey solana.PublicKey) bool {
+// isPaymentForInvoice determines if a transaction pays the invoice's quoted
+// amount of its settlement token, SOL or an SPL token, to its receiver. A
+// payment against a quote locked at payment time only counts if it landed
+// before the quote expired.
+func isPaymentForInvoice(tx *rpc.GetParsedTransactionResult, invoice models.Invoice, paidAt time.Time) bool {
 	// Ensure we have transaction data
-	if tx == nil || tx.Meta == nil {
+	if tx == nil || tx.Meta == nil || tx.Meta.Err != nil {
 		return false
 	}
 	
-	// USDC uses 6 decimals
-	const usdcDecimals = 6
+	token, expected := invoice.Settlement()
+	received, ok := settlementDelta(tx, invoice.ReceiverAddr, token)
+	if !ok {
+		return false
+	}
 	
-	// Check for token transfers in the transaction
-	for _, postBalance := range tx.Meta.PostTokenBalances {
-		// Check if this is for the USDC token mint
-		// Use string comparison instead of direct type comparison
-		if postBalance.Mint.String() != USDCDevnetMint {
-			continue
-		}
-		
-		// Check if the owner is our receiver
-		if postBalance.Owner == nil {
-			continue
-		}
-		
-		if postBalance.Owner.String() != receiverPubkey.String() {
-			continue
-		}
-		
-		// Find the pre-balance for comparison
-		var preBalance *rpc.TokenBalance
-		for _, pre := range tx.Meta.PreTokenBalances {
-			if pre.AccountIndex == postBalance.AccountIndex {
-				preBalance = &pre
-				break
-			}
-		}
-		
-		if preBalance == nil {
-			continue
+	// Allow a small tolerance for floating point comparison
+	if math.Abs(received-expected) > models.SettlementTokens[token].Tolerance() {
+		return false
+	}
+	
+	if invoice.RateExpiresAt != nil && paidAt.After(*invoice.RateExpiresAt) {
+		log.Printf("Ignoring %s payment for invoice %s: quote expired at %s", token, invoice.InvoiceNumber, invoice.RateExpiresAt.Format(time.RFC3339))
+		return false
+	}
+	
+	log.Printf("Found matching %s payment: expected %f, received %f", token, expected, received)
+	return true
+}
+
+// paymentDecision ties a transfer to a pending invoice. An empty FlagReason
+// means the transfer pays the invoice; otherwise it explains why it cannot.
+type paymentDecision struct {
+	Invoice    models.Invoice
+	MatchedBy  models.PaymentMatch
+	FlagReason string
+}
+
+// matchPayment finds the pending invoice a transaction is for. The invoice's
+// Solana Pay reference key or a memo naming it identify the invoice outright,
+// even when the amount differs: paying at least the quoted amount settles it
+// and anything else is flagged. Failing both, a transfer of exactly an
+// invoice's quoted amount pays it.
+func matchPayment(tx *rpc.GetParsedTransactionResult, memoCandidates []string, pending []models.Invoice, paidAt time.Time) (paymentDecision, bool) {
+	for _, invoice := range pending {
+		if hasAccountKey(tx, PaymentReference(invoice)) {
+			return identifiedPayment(tx, invoice, models.PaymentMatchReference, paidAt), true
 		}
-		
-		// Calculate the amount received (post - pre)
-		preAmount := parseUiAmount(preBalance.UiTokenAmount.Amount)
-		postAmount := parseUiAmount(postBalance.UiTokenAmount.Amount)
-		
-		if preAmount == nil || postAmount == nil {
-			continue
+	}
+	
+	for _, invoice := range pending {
+		if memoNamesInvoice(memoCandidates, invoice) {
+			return identifiedPayment(tx, invoice, models.PaymentMatchMemo, paidAt), true
 		}
-		
-		diff := new(big.Float).Sub(postAmount, preAmount)
-		
-		// Convert to float64 for comparison
-		amountReceived, _ := diff.Float64()
-		
-		// Allow a small tolerance for floating point comparison
-		const tolerance = 0.001
-		if amountReceived >= invoice.Amount-tolerance && amountReceived <= invoice.Amount+tolerance {
-			log.Printf("Found matching USDC payment: expected %f, received %f", invoice.Amount, amountReceived)
-			return true
+	}
+	
+	for _, invoice := range pending {
+		if isPaymentForInvoice(tx, invoice, paidAt) {
+			return paymentDecision{Invoice: invoice, MatchedBy: models.PaymentMatchAmount}, true
 		}
 	}
 	
-	return false
+	return paymentDecision{}, false
 }
 
-// parseUiAmount converts a UI amount string to a big.Float
-func parseUiAmount(amount string) *big.Float {
-	if amount == "" {
-		return nil
-	}
+// identifiedPayment decides whether a transaction identified as being for the
+// invoice actually pays it
+func identifiedPayment(tx *rpc.GetParsedTransactionResult, invoice models.Invoice, matchedBy models.PaymentMatch, paidAt time.Time) paymentDecision {
+	decision := paymentDecision{Invoice: invoice, MatchedBy: matchedBy}
 	
-	result, success := new(big.Float).SetString(amount)
-	if !success {
-		return nil
+	token, expected := invoice.Settlement()
+	settlement := models.SettlementTokens[token]
+	received, ok := settlementDelta(tx, invoice.ReceiverAddr, token)
+	
+	switch {
+	case !ok || received <= settlement.Tolerance():
+		decision.FlagReason = fmt.Sprintf("Payment for invoice %s was not made in %s", invoice.InvoiceNumber, token)
+	case received < expected-settlement.Tolerance():
+		decision.FlagReason = fmt.Sprintf("Invoice %s underpaid: received %s of %s %s", invoice.InvoiceNumber,
+			strconv.FormatFloat(received, 'f', -1, 64), strconv.FormatFloat(expected, 'f', -1, 64), token)
+	case invoice.RateExpiresAt != nil && paidAt.After(*invoice.RateExpiresAt):
+		decision.FlagReason = fmt.Sprintf("Invoice %s paid after its quote expired at %s", invoice.InvoiceNumber, invoice.RateExpiresAt.Format(time.RFC3339))
 	}
 	
-	return result
-} 
\ No newline at end of file
+	return decision
+}
+
+// hasAccountKey reports whether a transaction references the account
+func hasAccountKey(tx *rpc.GetParsedTransactionResult, key solana.PublicKey) bool {
+	if tx == nil || tx.Transaction == nil {
+		return false
+	}
+	for _, account := range tx.Transaction.Message.AccountKeys {
+		if account.PublicKey.Equals(key) {
+			return true
+		}
+	}
+	return false
+}
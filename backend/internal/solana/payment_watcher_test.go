@@ -0,0 +1,103 @@
+package solana
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+const testReceiver = "8JQxYTKfELQhAJL4c3jQvnUuNwZWJxsJr7o8G6iTfEV9"
+
+func tokenBalance(index uint16, owner, mint, amount string) rpc.TokenBalance {
+	ownerKey := solana.MustPublicKeyFromBase58(owner)
+	return rpc.TokenBalance{
+		AccountIndex:  index,
+		Owner:         &ownerKey,
+		Mint:          solana.MustPublicKeyFromBase58(mint),
+		UiTokenAmount: &rpc.UiTokenAmount{UiAmountString: amount, Decimals: 6},
+	}
+}
+
+func TestIsPaymentForInvoiceMatchesQuotedTokenAmount(t *testing.T) {
+	invoice := models.Invoice{
+		InvoiceNumber:    "INV-1",
+		Amount:           100,
+		Currency:         "EUR",
+		ReceiverAddr:     testReceiver,
+		SettlementToken:  "USDC",
+		SettlementAmount: 108.25,
+	}
+
+	// The receiver's token account is created by the payment, so it has no pre-balance
+	tx := &rpc.GetParsedTransactionResult{Meta: &rpc.ParsedTransactionMeta{
+		PostTokenBalances: []rpc.TokenBalance{tokenBalance(1, testReceiver, USDCDevnetMint, "108.25")},
+	}}
+
+	if !isPaymentForInvoice(tx, invoice, time.Now()) {
+		t.Error("Expected the quoted USDC amount to match")
+	}
+
+	invoice.SettlementToken = "EURC"
+	if isPaymentForInvoice(tx, invoice, time.Now()) {
+		t.Error("Expected a USDC transfer not to pay an EURC invoice")
+	}
+}
+
+func TestIsPaymentForInvoiceRejectsExpiredQuote(t *testing.T) {
+	expiresAt := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	invoice := models.Invoice{
+		InvoiceNumber:    "INV-2",
+		ReceiverAddr:     testReceiver,
+		SettlementToken:  "USDC",
+		SettlementAmount: 5.5,
+		RateLock:         models.RateLockPayment,
+		RateExpiresAt:    &expiresAt,
+	}
+	tx := &rpc.GetParsedTransactionResult{Meta: &rpc.ParsedTransactionMeta{
+		PreTokenBalances:  []rpc.TokenBalance{tokenBalance(1, testReceiver, USDCDevnetMint, "10")},
+		PostTokenBalances: []rpc.TokenBalance{tokenBalance(1, testReceiver, USDCDevnetMint, "15.5")},
+	}}
+
+	if !isPaymentForInvoice(tx, invoice, expiresAt.Add(-time.Minute)) {
+		t.Error("Expected a payment before expiry to match")
+	}
+	if isPaymentForInvoice(tx, invoice, expiresAt.Add(time.Minute)) {
+		t.Error("Expected a payment after expiry not to match")
+	}
+}
+
+func TestIsPaymentForInvoiceMatchesLamportTransfer(t *testing.T) {
+	payer := solana.MustPublicKeyFromBase58("4zMMC9srt5Ri5X14GAgXhaHii3GnPAEERYPJgZJDncDU")
+	receiver := solana.MustPublicKeyFromBase58(testReceiver)
+
+	invoice := models.Invoice{
+		InvoiceNumber:    "INV-3",
+		Currency:         models.NativeSOL,
+		ReceiverAddr:     testReceiver,
+		SettlementToken:  models.NativeSOL,
+		SettlementAmount: 0.25,
+	}
+
+	// The payer also pays the 5000 lamport fee; the receiver gets exactly 0.25 SOL
+	tx := &rpc.GetParsedTransactionResult{
+		Transaction: &rpc.ParsedTransaction{Message: rpc.ParsedMessage{
+			AccountKeys: []rpc.ParsedMessageAccount{{PublicKey: payer}, {PublicKey: receiver}},
+		}},
+		Meta: &rpc.ParsedTransactionMeta{
+			PreBalances:  []uint64{1_000_000_000, 2_000_000_000},
+			PostBalances: []uint64{749_995_000, 2_250_000_000},
+		},
+	}
+
+	if !isPaymentForInvoice(tx, invoice, time.Now()) {
+		t.Error("Expected the lamport transfer to match")
+	}
+
+	invoice.SettlementAmount = 0.2501
+	if isPaymentForInvoice(tx, invoice, time.Now()) {
+		t.Error("Expected a different SOL amount not to match")
+	}
+}
@@ -0,0 +1,91 @@
+package solana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+var (
+	ErrReceiverIsTokenAccount = errors.New("receiver address is a token account, not a wallet; use the wallet that owns it")
+)
+
+// ReceiverInspector checks receiver wallets before invoices are issued to
+// them. It always derives the associated token account and flags addresses
+// that are off the ed25519 curve; with on-chain checks enabled it also looks
+// the accounts up over RPC.
+type ReceiverInspector struct {
+	rpcClient *rpc.Client
+	onChain   bool
+}
+
+// NewReceiverInspector creates a receiver inspector using the default RPC
+// endpoints. Looking up accounts costs RPC calls on every invoice created, so
+// it is opt-in through RECEIVER_ONCHAIN_CHECK=true.
+func NewReceiverInspector() *ReceiverInspector {
+	return &ReceiverInspector{
+		rpcClient: DefaultRPCPool().Client(),
+		onChain:   os.Getenv("RECEIVER_ONCHAIN_CHECK") == "true",
+	}
+}
+
+// InspectReceiver checks that receiver can be paid in token. A receiver that
+// is itself a token account is rejected, since payments are matched on the
+// owner of the receiving token account and would never be detected. RPC
+// failures only add a warning; they never block an invoice.
+func (ri *ReceiverInspector) InspectReceiver(ctx context.Context, receiver, token string) (models.ReceiverCheck, error) {
+	var check models.ReceiverCheck
+
+	owner, err := solana.PublicKeyFromBase58(receiver)
+	if err != nil {
+		return check, fmt.Errorf("invalid receiver address: %w", err)
+	}
+
+	if !owner.IsOnCurve() {
+		check.Warnings = append(check.Warnings, "Receiver address is off the ed25519 curve, so no private key can sign for it; make sure it is a program-controlled wallet such as a multisig vault")
+	}
+
+	if mint, ok := tokenMints[token]; ok {
+		ata, _, err := solana.FindAssociatedTokenAddress(owner, solana.MustPublicKeyFromBase58(mint))
+		if err != nil {
+			return check, fmt.Errorf("failed to derive associated token account: %w", err)
+		}
+		check.TokenAccount = ata.String()
+	}
+
+	if !ri.onChain || ri.rpcClient == nil {
+		return check, nil
+	}
+
+	account, err := ri.rpcClient.GetAccountInfo(ctx, owner)
+	switch {
+	case errors.Is(err, rpc.ErrNotFound):
+		// A wallet that has never held SOL has no account yet; it can still receive
+	case err != nil:
+		check.Warnings = append(check.Warnings, fmt.Sprintf("Could not look up the receiver on-chain: %v", err))
+		return check, nil
+	case account.Value.Owner.Equals(solana.TokenProgramID) || account.Value.Owner.Equals(solana.Token2022ProgramID):
+		return check, ErrReceiverIsTokenAccount
+	case !account.Value.Owner.Equals(solana.SystemProgramID):
+		check.Warnings = append(check.Warnings, fmt.Sprintf("Receiver address is an account owned by program %s rather than a wallet", account.Value.Owner))
+	}
+
+	if check.TokenAccount == "" {
+		return check, nil
+	}
+
+	_, err = ri.rpcClient.GetAccountInfo(ctx, solana.MustPublicKeyFromBase58(check.TokenAccount))
+	switch {
+	case errors.Is(err, rpc.ErrNotFound):
+		check.Warnings = append(check.Warnings, fmt.Sprintf("Receiver has no %s token account yet; the payer's wallet must create %s when paying", token, check.TokenAccount))
+	case err != nil:
+		check.Warnings = append(check.Warnings, fmt.Sprintf("Could not look up the receiver's %s token account: %v", token, err))
+	}
+
+	return check, nil
+}
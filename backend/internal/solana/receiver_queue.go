@@ -0,0 +1,118 @@
+package solana
+
+import (
+	"expvar"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// receiverQueue orders the watched receivers for checking. Each receiver
+// waits from its last check, or from when it was first seen, and the longest
+// waiting receiver goes first.
+type receiverQueue struct {
+	mu    sync.Mutex
+	since map[string]time.Time
+}
+
+func newReceiverQueue() *receiverQueue {
+	return &receiverQueue{since: make(map[string]time.Time)}
+}
+
+// order returns the receivers to check, longest waiting first. Receivers no
+// longer watched are forgotten and new ones join the back of the queue at now.
+func (q *receiverQueue) order(pending map[string][]models.Invoice, now time.Time) []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for receiver := range q.since {
+		if _, ok := pending[receiver]; !ok {
+			delete(q.since, receiver)
+		}
+	}
+
+	receivers := make([]string, 0, len(pending))
+	for receiver := range pending {
+		if _, ok := q.since[receiver]; !ok {
+			q.since[receiver] = now
+		}
+		receivers = append(receivers, receiver)
+	}
+
+	sort.Slice(receivers, func(i, j int) bool {
+		a, b := q.since[receivers[i]], q.since[receivers[j]]
+		if a.Equal(b) {
+			return receivers[i] < receivers[j]
+		}
+		return a.Before(b)
+	})
+	return receivers
+}
+
+// waitingSince returns when the receiver was last checked or first seen
+func (q *receiverQueue) waitingSince(receiver string) time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.since[receiver]
+}
+
+// checked moves the receiver to the back of the queue
+func (q *receiverQueue) checked(receiver string, at time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.since[receiver] = at
+}
+
+// watcherMetrics are the payment watcher's metrics, published with expvar
+// under "payment_watcher". Queue lag is how long a receiver waited between
+// checks when it was dispatched to a worker.
+var watcherMetrics = newWatcherMetrics()
+
+type paymentWatcherMetrics struct {
+	pendingInvoices   *expvar.Int
+	pendingReceivers  *expvar.Int
+	watchedReceivers  *expvar.Int
+	receiversChecked  *expvar.Int
+	checkTimeouts     *expvar.Int
+	deferredReceivers *expvar.Int
+	passSeconds       *expvar.Float
+	lastLagSeconds    *expvar.Float
+	maxLagSeconds     *expvar.Float
+}
+
+func newWatcherMetrics() *paymentWatcherMetrics {
+	m := &paymentWatcherMetrics{
+		pendingInvoices:   new(expvar.Int),
+		pendingReceivers:  new(expvar.Int),
+		watchedReceivers:  new(expvar.Int),
+		receiversChecked:  new(expvar.Int),
+		checkTimeouts:     new(expvar.Int),
+		deferredReceivers: new(expvar.Int),
+		passSeconds:       new(expvar.Float),
+		lastLagSeconds:    new(expvar.Float),
+		maxLagSeconds:     new(expvar.Float),
+	}
+
+	vars := expvar.NewMap("payment_watcher")
+	vars.Set("pending_invoices", m.pendingInvoices)
+	vars.Set("pending_receivers", m.pendingReceivers)
+	vars.Set("watched_receivers", m.watchedReceivers)
+	vars.Set("receivers_checked_total", m.receiversChecked)
+	vars.Set("check_timeouts_total", m.checkTimeouts)
+	vars.Set("receivers_deferred_total", m.deferredReceivers)
+	vars.Set("last_pass_seconds", m.passSeconds)
+	vars.Set("queue_lag_seconds", m.lastLagSeconds)
+	vars.Set("queue_lag_max_seconds", m.maxLagSeconds)
+	return m
+}
+
+// observeLag records the queue lag of a dispatched receiver
+func (m *paymentWatcherMetrics) observeLag(lag time.Duration) {
+	seconds := lag.Seconds()
+	m.lastLagSeconds.Set(seconds)
+	if seconds > m.maxLagSeconds.Value() {
+		m.maxLagSeconds.Set(seconds)
+	}
+}
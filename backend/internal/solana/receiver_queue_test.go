@@ -0,0 +1,36 @@
+package solana
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+func TestReceiverQueueRotatesLeastRecentlyChecked(t *testing.T) {
+	q := newReceiverQueue()
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	pending := map[string][]models.Invoice{"a": nil, "b": nil, "c": nil}
+
+	if got := q.order(pending, start); !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Fatalf("Expected new receivers in address order, got %v", got)
+	}
+
+	// Only a and b were reached before the pass budget ran out
+	q.checked("a", start.Add(time.Second))
+	q.checked("b", start.Add(2*time.Second))
+
+	if got := q.order(pending, start.Add(time.Minute)); !reflect.DeepEqual(got, []string{"c", "a", "b"}) {
+		t.Errorf("Expected the receiver left unchecked to go first, got %v", got)
+	}
+
+	// d joins the back of the queue; c is no longer pending and is forgotten
+	pending = map[string][]models.Invoice{"a": nil, "b": nil, "d": nil}
+	if got := q.order(pending, start.Add(time.Minute)); !reflect.DeepEqual(got, []string{"a", "b", "d"}) {
+		t.Errorf("Expected new receivers after those already waiting, got %v", got)
+	}
+	if _, ok := q.since["c"]; ok {
+		t.Error("Expected a receiver without pending invoices to be forgotten")
+	}
+}
@@ -0,0 +1,45 @@
+package solana
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+func TestInspectReceiverDerivesTokenAccount(t *testing.T) {
+	inspector := &ReceiverInspector{}
+	wallet := solana.NewWallet().PublicKey()
+
+	check, err := inspector.InspectReceiver(context.Background(), wallet.String(), "USDC")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ata, _, _ := solana.FindAssociatedTokenAddress(wallet, solana.MustPublicKeyFromBase58(USDCDevnetMint))
+	if check.TokenAccount != ata.String() {
+		t.Errorf("Expected token account %s, got %s", ata, check.TokenAccount)
+	}
+	if len(check.Warnings) != 0 {
+		t.Errorf("Expected no warnings for a wallet, got %v", check.Warnings)
+	}
+
+	check, err = inspector.InspectReceiver(context.Background(), wallet.String(), models.NativeSOL)
+	if err != nil || check.TokenAccount != "" {
+		t.Errorf("Expected no token account for native SOL, got %q (%v)", check.TokenAccount, err)
+	}
+}
+
+func TestInspectReceiverWarnsAboutOffCurveAddress(t *testing.T) {
+	// An associated token account is a program derived address
+	ata, _, _ := solana.FindAssociatedTokenAddress(solana.NewWallet().PublicKey(), solana.MustPublicKeyFromBase58(USDCDevnetMint))
+
+	check, err := (&ReceiverInspector{}).InspectReceiver(context.Background(), ata.String(), "USDC")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(check.Warnings) != 1 {
+		t.Errorf("Expected an off-curve warning, got %v", check.Warnings)
+	}
+}
@@ -0,0 +1,121 @@
+package solana
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// checkPendingRefunds looks for issued credit notes with a refund address and
+// records the outbound transfer from the invoice's receiver once it lands on chain
+func (pw *PaymentWatcher) checkPendingRefunds(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	notes, err := pw.creditNotes.FindAwaitingRefund(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to fetch credit notes awaiting refund: %v", err)
+	}
+
+	for _, note := range notes {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		invoice, err := pw.repository.FindByID(ctx, note.InvoiceID)
+		if err != nil || invoice == nil {
+			log.Printf("Skipping refund check for credit note %s: invoice %d not available", note.CreditNoteNumber, note.InvoiceID)
+			continue
+		}
+
+		signature, err := pw.findRefundTransfer(ctx, note, *invoice)
+		if err != nil {
+			log.Printf("Error checking refund for credit note %s: %v", note.CreditNoteNumber, err)
+			continue
+		}
+		if signature == "" {
+			continue
+		}
+
+		// The note may have been voided or refunded by hand since it was read
+		marked, err := pw.creditNotes.MarkRefunded(ctx, note.ID, signature, time.Now())
+		switch {
+		case err != nil:
+			log.Printf("Failed to mark credit note %s as REFUNDED: %v", note.CreditNoteNumber, err)
+		case !marked:
+			log.Printf("Credit note %s is no longer ISSUED; not recording transaction %s", note.CreditNoteNumber, signature)
+		default:
+			log.Printf("Credit note %s marked as REFUNDED by transaction %s", note.CreditNoteNumber, signature)
+		}
+	}
+
+	return nil
+}
+
+// findRefundTransfer scans recent transactions of the invoice receiver for a
+// transfer of the credit note amount to the refund address
+func (pw *PaymentWatcher) findRefundTransfer(ctx context.Context, note models.CreditNote, invoice models.Invoice) (string, error) {
+	receiverPubkey, err := solana.PublicKeyFromBase58(invoice.ReceiverAddr)
+	if err != nil {
+		return "", fmt.Errorf("invalid receiver address: %v", err)
+	}
+
+	// Refunds are sent in the token the invoice was paid in, at the invoice's rate
+	token, _ := invoice.Settlement()
+	settlement, ok := models.SettlementTokens[token]
+	if !ok {
+		return "", fmt.Errorf("unsupported settlement token %s", token)
+	}
+	expected := note.Amount
+	if invoice.ExchangeRate > 0 {
+		expected = settlement.Round(note.Amount * invoice.ExchangeRate)
+	}
+	tolerance := settlement.Tolerance()
+
+	signatures, err := pw.rpcClient.GetSignaturesForAddress(ctx, receiverPubkey)
+	if err != nil {
+		return "", fmt.Errorf("failed to get transaction signatures: %v", err)
+	}
+
+	for _, sig := range signatures {
+		if sig.Err != nil {
+			continue
+		}
+
+		// A transaction can only settle one credit note
+		claimed, err := pw.creditNotes.FindByRefundSignature(ctx, sig.Signature.String())
+		if err != nil {
+			return "", err
+		}
+		if claimed != nil {
+			continue
+		}
+
+		tx, err := pw.rpcClient.GetParsedTransaction(ctx, sig.Signature, &rpc.GetParsedTransactionOpts{
+			MaxSupportedTransactionVersion: ptr[uint64](0),
+		})
+		if err != nil || tx == nil || tx.Meta == nil {
+			if isDebugMode() {
+				log.Printf("Failed to get transaction details: %v", err)
+			}
+			continue
+		}
+
+		// The receiver may also have paid the fee, so it can have sent more than the refund
+		sent, _ := settlementDelta(tx, invoice.ReceiverAddr, token)
+		received, _ := settlementDelta(tx, note.RefundAddr, token)
+
+		if -sent >= expected-tolerance && received >= expected-tolerance && received <= expected+tolerance {
+			return sig.Signature.String(), nil
+		}
+	}
+
+	return "", nil
+}
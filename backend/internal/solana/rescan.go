@@ -0,0 +1,111 @@
+package solana
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/gagliardetto/solana-go"
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// rescanPageSize is the number of signatures requested per page, the most RPC
+// nodes return at once
+const rescanPageSize = 1000
+
+// Rescan re-scans the transactions of req.ReceiverAddr within the requested
+// range and applies them to the receiver's pending invoices exactly as polling
+// does. Unlike polling, which only sees the latest page of signatures, it pages
+// backwards with Before until it passes the start of the range, so payments
+// made while the watcher was down are picked up. Transfers already recorded are
+// skipped, so a range can be scanned any number of times.
+func (pw *PaymentWatcher) Rescan(ctx context.Context, req models.RescanRequest) (models.RescanResult, error) {
+	result := models.RescanResult{ReceiverAddr: req.ReceiverAddr, Paid: []string{}}
+
+	receiver, err := solana.PublicKeyFromBase58(req.ReceiverAddr)
+	if err != nil {
+		return result, fmt.Errorf("invalid receiver address: %w", err)
+	}
+
+	pages, err := pw.signaturesInRange(ctx, receiver, req)
+	if err != nil {
+		return result, err
+	}
+
+	invoices, err := pw.repository.FindPendingInvoices(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed to fetch pending invoices: %w", err)
+	}
+	var pending []models.Invoice
+	for _, invoice := range invoices {
+		if invoice.ReceiverAddr == req.ReceiverAddr {
+			pending = append(pending, invoice)
+		}
+	}
+
+	for _, page := range pages {
+		result.Signatures += len(page)
+		if result.NewestSlot == 0 {
+			result.NewestSlot = page[0].Slot
+		}
+		result.OldestSlot = page[len(page)-1].Slot
+	}
+
+	// Pages come newest first; the earliest payment of an invoice is the one that counts
+	for i := len(pages) - 1; i >= 0; i-- {
+		pending, err = pw.processSignatures(ctx, req.ReceiverAddr, pages[i], pending, &result)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	log.Printf("Rescanned %d transactions to %s: %d invoices paid, %d flagged, %d unmatched, %d already recorded",
+		result.Signatures, req.ReceiverAddr, len(result.Paid), result.Flagged, result.Unmatched, result.Recorded)
+	return result, nil
+}
+
+// signaturesInRange pages backwards through the receiver's signatures and
+// returns the pages of those within the requested range, newest first
+func (pw *PaymentWatcher) signaturesInRange(ctx context.Context, receiver solana.PublicKey, req models.RescanRequest) ([][]*rpc.TransactionSignature, error) {
+	var pages [][]*rpc.TransactionSignature
+	opts := &rpc.GetSignaturesForAddressOpts{Limit: ptr(rescanPageSize)}
+
+	for {
+		signatures, err := pw.rpcClient.GetSignaturesForAddressWithOpts(ctx, receiver, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get transaction signatures: %w", err)
+		}
+
+		var inRange []*rpc.TransactionSignature
+		done := len(signatures) < rescanPageSize
+		for _, sig := range signatures {
+			blockTime := signatureTime(sig)
+			if req.BeforeRange(sig.Slot, blockTime) {
+				done = true
+				break
+			}
+			if req.InRange(sig.Slot, blockTime) {
+				inRange = append(inRange, sig)
+			}
+		}
+		if len(inRange) > 0 {
+			pages = append(pages, inRange)
+		}
+
+		if done {
+			return pages, nil
+		}
+		opts.Before = signatures[len(signatures)-1].Signature
+	}
+}
+
+// signatureTime returns the block time of a signature, if the node knows it
+func signatureTime(sig *rpc.TransactionSignature) *time.Time {
+	if sig.BlockTime == nil {
+		return nil
+	}
+	t := sig.BlockTime.Time()
+	return &t
+}
@@ -0,0 +1,64 @@
+package solana
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"golang.org/x/time/rate"
+)
+
+// defaultRPCRateLimit is the number of RPC requests per second the payment
+// watcher sends by default, the public devnet limit of 100 per 10 seconds
+const defaultRPCRateLimit = 10
+
+// rateLimitedRPC is a JSON-RPC client that takes a token from a shared bucket
+// before every request, so concurrent workers together stay within the
+// node's rate limit
+type rateLimitedRPC struct {
+	client  rpc.JSONRPCClient
+	limiter *rate.Limiter
+}
+
+// newRPCLimiter returns a token bucket of SOLANA_RPC_RATE_LIMIT requests per
+// second (default 10), allowing bursts of as many
+func newRPCLimiter() *rate.Limiter {
+	perSecond := defaultRPCRateLimit
+	if v, err := strconv.Atoi(os.Getenv("SOLANA_RPC_RATE_LIMIT")); err == nil && v > 0 {
+		perSecond = v
+	}
+	return rate.NewLimiter(rate.Limit(perSecond), perSecond)
+}
+
+// newRateLimitedClient creates an RPC client that sends its requests through
+// client once limiter allows
+func newRateLimitedClient(client rpc.JSONRPCClient, limiter *rate.Limiter) *rpc.Client {
+	return rpc.NewWithCustomRPCClient(&rateLimitedRPC{
+		client:  client,
+		limiter: limiter,
+	})
+}
+
+func (c *rateLimitedRPC) CallForInto(ctx context.Context, out interface{}, method string, params []interface{}) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.client.CallForInto(ctx, out, method, params)
+}
+
+func (c *rateLimitedRPC) CallWithCallback(ctx context.Context, method string, params []interface{}, callback func(*http.Request, *http.Response) error) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+	return c.client.CallWithCallback(ctx, method, params, callback)
+}
+
+func (c *rateLimitedRPC) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return nil, err
+	}
+	return c.client.CallBatch(ctx, requests)
+}
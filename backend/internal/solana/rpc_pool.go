@@ -0,0 +1,462 @@
+package solana
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/gagliardetto/solana-go/rpc/jsonrpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+var (
+	ErrNoRPCEndpoint = errors.New("no healthy Solana RPC endpoint available")
+)
+
+const (
+	// Consecutive failures after which an endpoint's circuit opens
+	circuitFailureThreshold = 3
+
+	// How long an open circuit stays open at first; it doubles on every
+	// failed trial up to circuitMaxCooldown
+	circuitCooldown    = 15 * time.Second
+	circuitMaxCooldown = 2 * time.Minute
+
+	// How long a 429 without a usable Retry-After keeps an endpoint aside
+	defaultRetryAfter = 2 * time.Second
+	maxRetryAfter     = 5 * time.Minute
+
+	// Weights of the latest sample in the latency and error rate moving averages
+	latencySmoothing   = 0.2
+	errorRateSmoothing = 0.1
+)
+
+// RPCEndpointConfig is one Solana RPC endpoint and its share of the requests
+type RPCEndpointConfig struct {
+	URL    string
+	Weight int
+}
+
+// ParseRPCEndpoints parses a comma-separated list of RPC endpoint URLs, each
+// optionally followed by |weight, e.g. "https://a.example|3,https://b.example".
+// The weight defaults to 1.
+func ParseRPCEndpoints(value string) ([]RPCEndpointConfig, error) {
+	var configs []RPCEndpointConfig
+
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		config := RPCEndpointConfig{URL: item, Weight: 1}
+		if i := strings.LastIndex(item, "|"); i >= 0 {
+			weight, err := strconv.Atoi(strings.TrimSpace(item[i+1:]))
+			if err != nil || weight <= 0 {
+				return nil, fmt.Errorf("invalid weight in RPC endpoint %q", item)
+			}
+			config.URL, config.Weight = strings.TrimSpace(item[:i]), weight
+		}
+
+		parsed, err := url.Parse(config.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, fmt.Errorf("invalid RPC endpoint URL %q", redactURL(config.URL))
+		}
+		configs = append(configs, config)
+	}
+
+	if len(configs) == 0 {
+		return nil, errors.New("no RPC endpoints configured")
+	}
+	return configs, nil
+}
+
+// RPCPool is a JSON-RPC client that spreads requests over several Solana RPC
+// endpoints by weight and fails over to the next endpoint when one fails.
+// Each endpoint has a circuit breaker: after repeated failures it is skipped
+// for a cooldown, then a single trial request decides whether it is used
+// again. An endpoint answering 429 is skipped for as long as its Retry-After
+// asks. Errors returned by the RPC method itself are passed on without failing
+// over, since every endpoint would return them.
+type RPCPool struct {
+	endpoints []*rpcEndpoint
+}
+
+var (
+	defaultPool     *RPCPool
+	defaultPoolOnce sync.Once
+)
+
+// DefaultRPCPool returns the process-wide pool of the endpoints in
+// SOLANA_RPC_ENDPOINTS, or of devnet when it is not set. It is shared so the
+// health of each endpoint is learned once for all clients.
+func DefaultRPCPool() *RPCPool {
+	defaultPoolOnce.Do(func() {
+		configs := []RPCEndpointConfig{{URL: rpc.DevNet_RPC, Weight: 1}}
+		if value := os.Getenv("SOLANA_RPC_ENDPOINTS"); value != "" {
+			parsed, err := ParseRPCEndpoints(value)
+			if err != nil {
+				log.Printf("Warning: ignoring SOLANA_RPC_ENDPOINTS, using devnet: %v", err)
+			} else {
+				configs = parsed
+			}
+		}
+		defaultPool = NewRPCPool(configs)
+	})
+	return defaultPool
+}
+
+// NewRPCPool creates a pool of the given endpoints
+func NewRPCPool(configs []RPCEndpointConfig) *RPCPool {
+	pool := &RPCPool{}
+	for _, config := range configs {
+		endpoint := &rpcEndpoint{
+			name:     redactURL(config.URL),
+			weight:   config.Weight,
+			cooldown: circuitCooldown,
+		}
+		endpoint.client = jsonrpc.NewClientWithOpts(config.URL, &jsonrpc.RPCClientOpts{
+			HTTPClient: &http.Client{Transport: &retryAfterTransport{base: http.DefaultTransport, endpoint: endpoint}},
+		})
+		pool.endpoints = append(pool.endpoints, endpoint)
+	}
+	return pool
+}
+
+// Client returns a Solana RPC client that sends its requests through the pool
+func (p *RPCPool) Client() *rpc.Client {
+	return rpc.NewWithCustomRPCClient(p)
+}
+
+// Health reports the state of every endpoint
+func (p *RPCPool) Health() []models.RPCEndpointHealth {
+	now := time.Now()
+	health := make([]models.RPCEndpointHealth, 0, len(p.endpoints))
+	for _, endpoint := range p.endpoints {
+		health = append(health, endpoint.health(now))
+	}
+	return health
+}
+
+// Healthy reports whether at least one endpoint can take requests now
+func (p *RPCPool) Healthy() bool {
+	now := time.Now()
+	for _, endpoint := range p.endpoints {
+		switch endpoint.health(now).State {
+		case models.RPCCircuitClosed, models.RPCCircuitHalfOpen:
+			return true
+		}
+	}
+	return false
+}
+
+func (p *RPCPool) CallForInto(ctx context.Context, out interface{}, method string, params []interface{}) error {
+	return p.call(ctx, func(client jsonrpc.RPCClient) error {
+		return client.CallForInto(ctx, out, method, params)
+	})
+}
+
+func (p *RPCPool) CallWithCallback(ctx context.Context, method string, params []interface{}, callback func(*http.Request, *http.Response) error) error {
+	return p.call(ctx, func(client jsonrpc.RPCClient) error {
+		return client.CallWithCallback(ctx, method, params, callback)
+	})
+}
+
+func (p *RPCPool) CallBatch(ctx context.Context, requests jsonrpc.RPCRequests) (jsonrpc.RPCResponses, error) {
+	var responses jsonrpc.RPCResponses
+	err := p.call(ctx, func(client jsonrpc.RPCClient) error {
+		var err error
+		responses, err = client.CallBatch(ctx, requests)
+		return err
+	})
+	return responses, err
+}
+
+// call sends a request to one endpoint after another, in the order of
+// candidates, until one answers
+func (p *RPCPool) call(ctx context.Context, send func(jsonrpc.RPCClient) error) error {
+	lastErr := ErrNoRPCEndpoint
+
+	for _, endpoint := range p.candidates() {
+		admitted, trial := endpoint.admit(time.Now())
+		if !admitted {
+			continue
+		}
+
+		started := time.Now()
+		err := send(endpoint.client)
+		if err != nil && ctx.Err() != nil {
+			// The caller gave up; that says nothing about the endpoint
+			endpoint.abandon(trial)
+			return err
+		}
+
+		if !endpoint.record(time.Since(started), err, trial) {
+			return err
+		}
+		log.Printf("Solana RPC endpoint %s failed, trying the next one: %v", endpoint.name, err)
+		lastErr = err
+	}
+
+	return lastErr
+}
+
+// candidates returns the endpoints in the order to try them: one picked at
+// random by weight first, then the others by descending weight
+func (p *RPCPool) candidates() []*rpcEndpoint {
+	ordered := make([]*rpcEndpoint, len(p.endpoints))
+	copy(ordered, p.endpoints)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return ordered[i].weight > ordered[j].weight
+	})
+
+	total := 0
+	now := time.Now()
+	for _, endpoint := range ordered {
+		if endpoint.available(now) {
+			total += endpoint.weight
+		}
+	}
+	if total == 0 {
+		return ordered
+	}
+
+	pick := rand.Intn(total)
+	for i, endpoint := range ordered {
+		if !endpoint.available(now) {
+			continue
+		}
+		if pick < endpoint.weight {
+			return append([]*rpcEndpoint{endpoint}, append(ordered[:i:i], ordered[i+1:]...)...)
+		}
+		pick -= endpoint.weight
+	}
+	return ordered
+}
+
+// rpcEndpoint is one endpoint of the pool with its circuit breaker and statistics
+type rpcEndpoint struct {
+	name   string
+	weight int
+	client jsonrpc.RPCClient
+
+	mu           sync.Mutex
+	openUntil    time.Time
+	cooldown     time.Duration
+	failures     int
+	trial        bool
+	limitedUntil time.Time
+	latency      time.Duration
+	errorRate    float64
+	requests     int64
+	failed       int64
+	lastError    string
+}
+
+// available reports whether the endpoint would take a request at now,
+// without claiming a half-open trial
+func (e *rpcEndpoint) available(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.limitedUntil) && !now.Before(e.openUntil) && !e.trial
+}
+
+// admit reports whether a request may be sent to the endpoint now and whether
+// it is the half-open trial. Once the cooldown of an open circuit has passed,
+// only one trial request is admitted.
+func (e *rpcEndpoint) admit(now time.Time) (admitted, trial bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if now.Before(e.limitedUntil) || now.Before(e.openUntil) || e.trial {
+		return false, false
+	}
+	if e.failures >= circuitFailureThreshold {
+		e.trial = true
+	}
+	return true, e.trial
+}
+
+// abandon releases the trial, if the request the caller cancelled was it
+func (e *rpcEndpoint) abandon(trial bool) {
+	if !trial {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.trial = false
+}
+
+// record updates the statistics and circuit with the outcome of a request and
+// reports whether the request should be retried on another endpoint. Only the
+// trial request, which admit flagged, settles a half-open circuit; requests
+// admitted before the circuit opened leave the trial in flight alone.
+func (e *rpcEndpoint) record(latency time.Duration, err error, trial bool) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests++
+	if e.latency == 0 {
+		e.latency = latency
+	} else {
+		e.latency += time.Duration(latencySmoothing * float64(latency-e.latency))
+	}
+
+	if trial {
+		e.trial = false
+	}
+
+	failedOver := endpointFailure(err)
+	sample := 0.0
+	if failedOver {
+		sample = 1
+	}
+	e.errorRate += errorRateSmoothing * (sample - e.errorRate)
+
+	if !failedOver {
+		e.failures = 0
+		e.cooldown = circuitCooldown
+		return false
+	}
+
+	e.failed++
+	e.lastError = err.Error()
+
+	if isRateLimited(err) {
+		// Retry-After was recorded by the transport; only make sure the endpoint rests
+		if !time.Now().Before(e.limitedUntil) {
+			e.limitedUntil = time.Now().Add(defaultRetryAfter)
+		}
+		return true
+	}
+
+	e.failures++
+	if trial {
+		e.cooldown *= 2
+		if e.cooldown > circuitMaxCooldown {
+			e.cooldown = circuitMaxCooldown
+		}
+	}
+	if e.failures >= circuitFailureThreshold {
+		e.openUntil = time.Now().Add(e.cooldown)
+		log.Printf("Opened circuit of Solana RPC endpoint %s for %s after %d failures", e.name, e.cooldown, e.failures)
+	}
+	return true
+}
+
+// throttle keeps the endpoint aside until the time a 429 response asked for
+func (e *rpcEndpoint) throttle(until time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if until.After(e.limitedUntil) {
+		e.limitedUntil = until
+	}
+}
+
+func (e *rpcEndpoint) health(now time.Time) models.RPCEndpointHealth {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	health := models.RPCEndpointHealth{
+		Endpoint:  e.name,
+		Weight:    e.weight,
+		State:     models.RPCCircuitClosed,
+		LatencyMs: float64(e.latency.Microseconds()) / 1000,
+		ErrorRate: e.errorRate,
+		Requests:  e.requests,
+		Failures:  e.failed,
+		LastError: e.lastError,
+	}
+
+	switch {
+	case now.Before(e.openUntil):
+		retryAt := e.openUntil
+		health.State, health.RetryAt = models.RPCCircuitOpen, &retryAt
+	case now.Before(e.limitedUntil):
+		retryAt := e.limitedUntil
+		health.State, health.RetryAt = models.RPCCircuitRateLimited, &retryAt
+	case e.failures >= circuitFailureThreshold:
+		health.State = models.RPCCircuitHalfOpen
+	}
+	return health
+}
+
+// endpointFailure reports whether an error means the endpoint, rather than the
+// request, is at fault. JSON-RPC errors are answers from a working node, except
+// rate limiting, which some providers report as a JSON-RPC error.
+func endpointFailure(err error) bool {
+	if err == nil {
+		return false
+	}
+	var rpcErr *jsonrpc.RPCError
+	if errors.As(err, &rpcErr) {
+		return rpcErr.Code == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// isRateLimited reports whether an error is a 429 answer
+func isRateLimited(err error) bool {
+	var httpErr *jsonrpc.HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.Code == http.StatusTooManyRequests
+	}
+	var rpcErr *jsonrpc.RPCError
+	return errors.As(err, &rpcErr) && rpcErr.Code == http.StatusTooManyRequests
+}
+
+// retryAfterTransport records the Retry-After of 429 responses on the endpoint
+// that sent them, which the JSON-RPC client does not expose
+type retryAfterTransport struct {
+	base     http.RoundTripper
+	endpoint *rpcEndpoint
+}
+
+func (t *retryAfterTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusTooManyRequests {
+		now := time.Now()
+		t.endpoint.throttle(now.Add(parseRetryAfter(resp.Header.Get("Retry-After"), now)))
+	}
+	return resp, err
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP
+// date, bounded to maxRetryAfter
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	wait := defaultRetryAfter
+	if seconds, err := strconv.Atoi(strings.TrimSpace(value)); err == nil && seconds >= 0 {
+		wait = time.Duration(seconds) * time.Second
+	} else if at, err := http.ParseTime(value); err == nil {
+		wait = at.Sub(now)
+	}
+
+	if wait <= 0 {
+		return defaultRetryAfter
+	}
+	if wait > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return wait
+}
+
+// redactURL returns only the scheme and host of an endpoint URL
+func redactURL(raw string) string {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Host == "" {
+		return "invalid endpoint"
+	}
+	return parsed.Scheme + "://" + parsed.Host
+}
@@ -0,0 +1,175 @@
+package solana
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// rpcServer answers every JSON-RPC request with status and body and counts the requests
+func rpcServer(t *testing.T, status int, body string, header http.Header) (*httptest.Server, *int32) {
+	t.Helper()
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		for key, values := range header {
+			w.Header()[key] = values
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+const slotResponse = `{"jsonrpc":"2.0","id":1,"result":42}`
+
+func TestRPCPoolFailsOverAndOpensCircuit(t *testing.T) {
+	broken, brokenCalls := rpcServer(t, http.StatusBadGateway, "bad gateway", nil)
+	healthy, _ := rpcServer(t, http.StatusOK, slotResponse, nil)
+
+	// The broken endpoint is always picked first by weight
+	pool := NewRPCPool([]RPCEndpointConfig{{URL: broken.URL, Weight: 1 << 30}, {URL: healthy.URL, Weight: 1}})
+
+	for i := 0; i < circuitFailureThreshold+2; i++ {
+		var slot uint64
+		if err := pool.CallForInto(context.Background(), &slot, "getSlot", nil); err != nil {
+			t.Fatalf("Expected failover to the healthy endpoint, got %v", err)
+		}
+		if slot != 42 {
+			t.Fatalf("Expected slot 42, got %d", slot)
+		}
+	}
+
+	if got := atomic.LoadInt32(brokenCalls); got != circuitFailureThreshold {
+		t.Errorf("Expected the broken endpoint to be skipped once its circuit opened, got %d calls", got)
+	}
+	health := pool.Health()
+	if health[0].State != models.RPCCircuitOpen || health[0].RetryAt == nil {
+		t.Errorf("Expected the broken endpoint's circuit to be open, got %+v", health[0])
+	}
+	if health[1].State != models.RPCCircuitClosed || health[1].ErrorRate != 0 {
+		t.Errorf("Expected the healthy endpoint to stay closed, got %+v", health[1])
+	}
+	if !pool.Healthy() {
+		t.Error("Expected the pool to be healthy while one endpoint works")
+	}
+}
+
+func TestRPCPoolHonorsRetryAfter(t *testing.T) {
+	limited, limitedCalls := rpcServer(t, http.StatusTooManyRequests, "", http.Header{"Retry-After": {"120"}})
+	pool := NewRPCPool([]RPCEndpointConfig{{URL: limited.URL, Weight: 1}})
+
+	var slot uint64
+	if err := pool.CallForInto(context.Background(), &slot, "getSlot", nil); err == nil {
+		t.Fatal("Expected an error from a rate limited endpoint")
+	}
+	if err := pool.CallForInto(context.Background(), &slot, "getSlot", nil); err != ErrNoRPCEndpoint {
+		t.Errorf("Expected the endpoint to be skipped during Retry-After, got %v", err)
+	}
+	if got := atomic.LoadInt32(limitedCalls); got != 1 {
+		t.Errorf("Expected one request to the rate limited endpoint, got %d", got)
+	}
+
+	health := pool.Health()[0]
+	if health.State != models.RPCCircuitRateLimited || health.RetryAt == nil || time.Until(*health.RetryAt) < time.Minute {
+		t.Errorf("Expected the endpoint to rest for its Retry-After, got %+v", health)
+	}
+}
+
+func TestRPCPoolPassesOnMethodErrors(t *testing.T) {
+	invalid, _ := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0", "id": 1,
+		"error": map[string]interface{}{"code": -32602, "message": "Invalid params"},
+	})
+	first, _ := rpcServer(t, http.StatusOK, string(invalid), nil)
+	second, secondCalls := rpcServer(t, http.StatusOK, slotResponse, nil)
+	pool := NewRPCPool([]RPCEndpointConfig{{URL: first.URL, Weight: 1 << 30}, {URL: second.URL, Weight: 1}})
+
+	var slot uint64
+	if err := pool.CallForInto(context.Background(), &slot, "getSlot", nil); err == nil {
+		t.Fatal("Expected the method error to be returned")
+	}
+	if got := atomic.LoadInt32(secondCalls); got != 0 {
+		t.Errorf("Expected no failover on a method error, got %d calls to the second endpoint", got)
+	}
+	if pool.Health()[0].Failures != 0 {
+		t.Error("Expected a method error not to count against the endpoint")
+	}
+}
+
+func TestParseRPCEndpoints(t *testing.T) {
+	configs, err := ParseRPCEndpoints("https://a.example/key|3, https://b.example")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(configs) != 2 || configs[0].Weight != 3 || configs[0].URL != "https://a.example/key" || configs[1].Weight != 1 {
+		t.Errorf("Unexpected endpoints: %+v", configs)
+	}
+
+	for _, value := range []string{"", "https://a.example|0", "ftp://a.example", "not a url"} {
+		if _, err := ParseRPCEndpoints(value); err == nil {
+			t.Errorf("Expected an error for %q", value)
+		}
+	}
+
+	if got := redactURL("https://mainnet.helius-rpc.com/?api-key=secret"); got != "https://mainnet.helius-rpc.com" {
+		t.Errorf("Expected the API key to be redacted, got %q", got)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	if got := parseRetryAfter("7", now); got != 7*time.Second {
+		t.Errorf("Expected 7s, got %s", got)
+	}
+	if got := parseRetryAfter(now.Add(time.Minute).Format(http.TimeFormat), now); got != time.Minute {
+		t.Errorf("Expected 1m from an HTTP date, got %s", got)
+	}
+	if got := parseRetryAfter("", now); got != defaultRetryAfter {
+		t.Errorf("Expected the default without a header, got %s", got)
+	}
+	if got := parseRetryAfter("86400", now); got != maxRetryAfter {
+		t.Errorf("Expected the wait to be capped, got %s", got)
+	}
+}
+
+func TestRPCEndpointTrialSettledOnlyByTrialRequest(t *testing.T) {
+	e := &rpcEndpoint{name: "test", cooldown: circuitCooldown}
+	now := time.Now()
+
+	// A request admitted while the circuit was still closed
+	admitted, early := e.admit(now)
+	if !admitted || early {
+		t.Fatalf("Expected a regular request to be admitted, got %v, %v", admitted, early)
+	}
+
+	// The circuit opens meanwhile and its cooldown passes
+	e.failures = circuitFailureThreshold
+	e.openUntil = now.Add(-time.Second)
+
+	admitted, trial := e.admit(now)
+	if !admitted || !trial {
+		t.Fatalf("Expected the half-open trial to be admitted, got %v, %v", admitted, trial)
+	}
+
+	// The earlier request finishing or being cancelled must not release the trial
+	e.record(time.Millisecond, nil, early)
+	e.abandon(early)
+	if admitted, _ := e.admit(now); admitted {
+		t.Error("Expected no second request while the trial is in flight")
+	}
+
+	e.record(time.Millisecond, nil, trial)
+	if admitted, _ := e.admit(now); !admitted {
+		t.Error("Expected requests to be admitted once the trial succeeded")
+	}
+}
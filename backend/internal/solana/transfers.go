@@ -0,0 +1,128 @@
+package solana
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/gagliardetto/solana-go/rpc"
+	"github.com/ncapetillo/demo-fluida/internal/models"
+)
+
+// lamportsPerSOL is the number of lamports in one SOL
+const lamportsPerSOL = 1_000_000_000
+
+// tokenMints maps the SPL settlement tokens of models.SettlementTokens to
+// their mints; native SOL has no mint
+var tokenMints = map[string]string{
+	"USDC": USDCDevnetMint,
+	"EURC": EURCDevnetMint,
+}
+
+// inboundTransfer returns the settlement token the receiver gained in a
+// transaction and how much of it, checking tokens in alphabetical order
+func inboundTransfer(tx *rpc.GetParsedTransactionResult, receiver string) (string, float64, bool) {
+	symbols := make([]string, 0, len(models.SettlementTokens))
+	for symbol := range models.SettlementTokens {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		delta, ok := settlementDelta(tx, receiver, symbol)
+		if ok && delta > models.SettlementTokens[symbol].Tolerance() {
+			return symbol, delta, true
+		}
+	}
+	return "", 0, false
+}
+
+// settlementDelta returns how much of a settlement token the owner gained
+// (positive) or lost (negative) in a transaction, and false for a token the
+// watcher cannot track. Native SOL is read from the lamport balances, SPL
+// tokens from the token balances of accounts the owner holds.
+func settlementDelta(tx *rpc.GetParsedTransactionResult, owner, token string) (float64, bool) {
+	if tx == nil || tx.Meta == nil {
+		return 0, false
+	}
+
+	if token == models.NativeSOL {
+		return lamportDelta(tx, owner), true
+	}
+
+	mint, ok := tokenMints[token]
+	if !ok {
+		return 0, false
+	}
+	return ownerTokenDelta(tx.Meta.PreTokenBalances, tx.Meta.PostTokenBalances, owner, mint), true
+}
+
+// lamportDelta returns how many SOL an account gained or lost in a
+// transaction. This covers system program transfers as well as SOL moved by
+// any other program, and includes the fee when the account paid it.
+func lamportDelta(tx *rpc.GetParsedTransactionResult, owner string) float64 {
+	if tx.Transaction == nil {
+		return 0
+	}
+
+	pre, post := tx.Meta.PreBalances, tx.Meta.PostBalances
+	for i, account := range tx.Transaction.Message.AccountKeys {
+		if account.PublicKey.String() != owner {
+			continue
+		}
+		if i >= len(pre) || i >= len(post) {
+			return 0
+		}
+		delta := new(big.Int).Sub(new(big.Int).SetUint64(post[i]), new(big.Int).SetUint64(pre[i]))
+		sol, _ := new(big.Float).Quo(new(big.Float).SetInt(delta), big.NewFloat(lamportsPerSOL)).Float64()
+		return sol
+	}
+
+	return 0
+}
+
+// ownerTokenDelta returns how much of a mint the accounts owned by owner gained
+// (positive) or lost (negative) in a transaction. Accounts created by the
+// transaction have no pre-balance and are treated as starting from zero.
+func ownerTokenDelta(pre, post []rpc.TokenBalance, owner, mint string) float64 {
+	total := new(big.Float)
+
+	add := func(balances []rpc.TokenBalance, sign int) {
+		for _, balance := range balances {
+			if balance.Owner == nil || balance.Owner.String() != owner || balance.Mint.String() != mint {
+				continue
+			}
+			if balance.UiTokenAmount == nil {
+				continue
+			}
+			amount := parseUiAmount(balance.UiTokenAmount.UiAmountString)
+			if amount == nil {
+				continue
+			}
+			if sign < 0 {
+				total.Sub(total, amount)
+			} else {
+				total.Add(total, amount)
+			}
+		}
+	}
+
+	add(pre, -1)
+	add(post, 1)
+
+	delta, _ := total.Float64()
+	return delta
+}
+
+// parseUiAmount converts a UI amount string to a big.Float
+func parseUiAmount(amount string) *big.Float {
+	if amount == "" {
+		return nil
+	}
+
+	result, success := new(big.Float).SetString(amount)
+	if !success {
+		return nil
+	}
+
+	return result
+}
@@ -0,0 +1,188 @@
+'use client'
+
+import { ChangeEvent, FormEvent, useEffect, useState } from 'react'
+import { useParams, useRouter } from 'next/navigation'
+import Link from 'next/link'
+import apiService from '../../../services/api'
+import { PublicCheckoutLink } from '../../../types'
+import LoadingSpinner from '../../../components/ui/LoadingSpinner'
+import TextField from '../../../components/ui/TextField'
+import Button from '../../../components/ui/Button'
+
+/**
+ * Checkout page of a reusable checkout link. The visitor enters their details,
+ * and the amount or quantity when the link allows it; starting the checkout
+ * creates an invoice, which is then paid on its payment page.
+ */
+export default function CheckoutPage() {
+  const params = useParams()
+  const router = useRouter()
+  const { slug } = params as { slug: string }
+
+  const [link, setLink] = useState<PublicCheckoutLink | null>(null)
+  const [loading, setLoading] = useState(true)
+  const [error, setError] = useState<string | null>(null)
+  const [submitting, setSubmitting] = useState(false)
+  const [fieldErrors, setFieldErrors] = useState<Record<string, string>>({})
+  const [form, setForm] = useState({ name: '', email: '', amount: '', quantity: '1' })
+
+  useEffect(() => {
+    const fetchLink = async () => {
+      try {
+        setLink(await apiService.getCheckoutLink(slug))
+      } catch (err: any) {
+        if (err.response && err.response.status === 410) {
+          setError('This checkout link is no longer available.')
+        } else if (err.response && err.response.status === 404) {
+          setError('This checkout link does not exist.')
+        } else {
+          setError('Failed to load checkout. Please try again later.')
+        }
+      } finally {
+        setLoading(false)
+      }
+    }
+
+    fetchLink()
+  }, [slug])
+
+  const handleChange = (e: ChangeEvent<HTMLInputElement>) => {
+    setForm({ ...form, [e.target.name]: e.target.value })
+  }
+
+  const handleSubmit = async (e: FormEvent) => {
+    e.preventDefault()
+    if (!link) return
+
+    setSubmitting(true)
+    setFieldErrors({})
+    try {
+      const session = await apiService.startCheckoutSession(slug, {
+        name: form.name,
+        email: form.email,
+        amount: link.amount === undefined ? parseFloat(form.amount) : undefined,
+        quantity: parseInt(form.quantity, 10) || 1,
+      })
+      router.push(`/pay/${encodeURIComponent(session.linkToken)}`)
+    } catch (err: any) {
+      const apiError = err.response?.data?.error
+      if (apiError?.details) {
+        const errors: Record<string, string> = {}
+        apiError.details.forEach((detail: { field: string, message: string }) => {
+          errors[detail.field] = detail.message
+        })
+        setFieldErrors(errors)
+      } else {
+        setError(apiError?.message || 'Failed to start checkout. Please try again.')
+      }
+      setSubmitting(false)
+    }
+  }
+
+  if (loading) {
+    return (
+      <div className="min-h-screen flex items-center justify-center">
+        <LoadingSpinner text="Loading checkout..." />
+      </div>
+    )
+  }
+
+  if (error || !link) {
+    return (
+      <div className="max-w-lg mx-auto mt-12 p-6 bg-white rounded-lg shadow-md">
+        <h1 className="text-xl font-semibold text-red-600 mb-4">Error</h1>
+        <p>{error || 'This checkout link does not exist.'}</p>
+        <div className="mt-6">
+          <Link href="/" className="text-primary-600 hover:underline">Return to Home</Link>
+        </div>
+      </div>
+    )
+  }
+
+  const quantity = parseInt(form.quantity, 10) || 1
+  const total = link.amount !== undefined ? link.amount * quantity : undefined
+
+  return (
+    <div className="max-w-lg mx-auto py-8 px-4 sm:px-6 lg:px-8">
+      <div className="bg-white rounded-lg shadow overflow-hidden">
+        <div className="border-b border-gray-200 bg-gray-50 px-6 py-4">
+          <h1 className="text-xl font-semibold text-gray-900">{link.name}</h1>
+          <p className="text-sm text-gray-600">{link.seller}</p>
+        </div>
+
+        <form onSubmit={handleSubmit} className="px-6 py-4 space-y-4">
+          {link.description && (
+            <p className="text-sm text-gray-600 whitespace-pre-line">{link.description}</p>
+          )}
+
+          {link.amount === undefined ? (
+            <TextField
+              id="amount"
+              name="amount"
+              label={`Amount (${link.currency})`}
+              type="number"
+              step="any"
+              min={link.minAmount}
+              value={form.amount}
+              onChange={handleChange}
+              required
+              error={fieldErrors.amount}
+            />
+          ) : (
+            <div className="flex justify-between">
+              <span className="text-gray-600">Price:</span>
+              <span className="font-medium">{link.amount} {link.currency}</span>
+            </div>
+          )}
+
+          {link.maxQuantity > 1 && (
+            <TextField
+              id="quantity"
+              name="quantity"
+              label={`Quantity (up to ${link.maxQuantity})`}
+              type="number"
+              min={1}
+              value={form.quantity}
+              onChange={handleChange}
+              required
+              error={fieldErrors.quantity}
+            />
+          )}
+
+          <TextField
+            id="name"
+            name="name"
+            label="Your name"
+            value={form.name}
+            onChange={handleChange}
+            autoComplete="name"
+            required
+            error={fieldErrors.name}
+          />
+          <TextField
+            id="email"
+            name="email"
+            label="Your email"
+            type="email"
+            value={form.email}
+            onChange={handleChange}
+            autoComplete="email"
+            required
+            error={fieldErrors.email}
+          />
+
+          {total !== undefined && (
+            <div className="flex justify-between border-t border-gray-200 pt-4">
+              <span className="text-gray-600">Total:</span>
+              <span className="font-medium">{total} {link.currency}</span>
+            </div>
+          )}
+
+          <Button type="submit" fullWidth isLoading={submitting} disabled={submitting}>
+            Continue to payment
+          </Button>
+        </form>
+      </div>
+    </div>
+  )
+}
@@ -84,16 +84,13 @@ export default function PaymentPage() {
             <div>
               <h2 className="text-lg font-medium">From</h2>
               <div className="mt-2">
-                <p className="text-sm text-gray-800">{invoice.senderDetails.name}</p>
-                <p className="text-sm text-gray-600">{invoice.senderDetails.email}</p>
-                <p className="text-sm text-gray-600 whitespace-pre-line">{invoice.senderDetails.address}</p>
+                <p className="text-sm text-gray-800">{invoice.sender.name}</p>
+                <p className="text-sm text-gray-600">{invoice.sender.email}</p>
               </div>
 
               <h2 className="text-lg font-medium mt-6">To</h2>
               <div className="mt-2">
-                <p className="text-sm text-gray-800">{invoice.recipientDetails.name}</p>
-                <p className="text-sm text-gray-600">{invoice.recipientDetails.email}</p>
-                <p className="text-sm text-gray-600 whitespace-pre-line">{invoice.recipientDetails.address}</p>
+                <p className="text-sm text-gray-800">{invoice.recipient.name}</p>
               </div>
             </div>
 
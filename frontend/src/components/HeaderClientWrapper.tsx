@@ -8,9 +8,9 @@ export default function HeaderClientWrapper() {
   const pathname = usePathname()
   const { isAuthenticated } = useAuth()
   
-  // Don't show header on login page or payment pages
+  // Don't show header on login page or payment and checkout pages
   const isLoginPage = pathname === '/login'
-  const isPaymentPage = pathname?.startsWith('/pay/')
+  const isPaymentPage = pathname?.startsWith('/pay/') || pathname?.startsWith('/checkout/')
   
   if (isLoginPage || isPaymentPage) {
     return null
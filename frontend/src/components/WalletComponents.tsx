@@ -5,12 +5,13 @@ import { useWallet } from '@solana/wallet-adapter-react'
 import { WalletMultiButton } from '@solana/wallet-adapter-react-ui'
 import { PublicKey, Transaction, Connection, clusterApiUrl } from '@solana/web3.js'
 import { createTransferCheckedInstruction, getAssociatedTokenAddressSync, getMint } from '@solana/spl-token'
-import { Invoice } from '../types'
+import { createMemoInstruction } from '@solana/spl-memo'
+import { PublicInvoice } from '../types'
 import Button from './ui/Button'
 
 // Interface for component props
 interface WalletComponentsProps {
-  invoice: Invoice
+  invoice: PublicInvoice
   onPaymentStart: () => void
   onPaymentSuccess: () => void
   onPaymentError: () => void
@@ -95,8 +96,11 @@ const WalletComponents: FC<WalletComponentsProps> = ({
         mintInfo.decimals
       )
       
+      // Tag the payment with the invoice number so it can be matched by memo
+      const memoInstruction = createMemoInstruction(invoice.invoiceNumber)
+      
       // Create transaction
-      const transaction = new Transaction().add(transferInstruction)
+      const transaction = new Transaction().add(memoInstruction, transferInstruction)
       
       // Set recent blockhash and fee payer
       transaction.feePayer = publicKey
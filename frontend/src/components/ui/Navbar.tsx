@@ -13,9 +13,9 @@ export const Navbar = () => {
   const pathname = usePathname()
   const { isAuthenticated, logout } = useAuth()
 
-  // Don't show navbar on login page or payment pages
+  // Don't show navbar on login page or payment and checkout pages
   const isLoginPage = pathname === '/login'
-  const isPaymentPage = pathname?.startsWith('/pay/')
+  const isPaymentPage = pathname?.startsWith('/pay/') || pathname?.startsWith('/checkout/')
   
   if (isLoginPage || isPaymentPage || !isAuthenticated) {
     return null
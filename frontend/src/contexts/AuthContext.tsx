@@ -109,7 +109,7 @@ export const AuthProvider: React.FC<AuthProviderProps> = ({ children }) => {
   const isPublicRoute = (path: string) => {
     // Add any public routes here
     const publicRoutes = ['/login', '/api/health', '/health']
-    return publicRoutes.includes(path) || path.startsWith('/pay/')
+    return publicRoutes.includes(path) || path.startsWith('/pay/') || path.startsWith('/checkout/')
   }
 
   return (
@@ -1,14 +1,14 @@
 'use client'
 
 import { useState, useEffect } from 'react'
-import { Invoice } from '../types'
+import { PublicInvoice } from '../types'
 import apiService from '../services/api'
 
 /**
  * Custom hook for handling invoice payment functionality
  */
 export const useInvoicePayment = (token: string) => {
-  const [invoice, setInvoice] = useState<Invoice | null>(null)
+  const [invoice, setInvoice] = useState<PublicInvoice | null>(null)
   const [loading, setLoading] = useState(true)
   const [error, setError] = useState<string | null>(null)
   const [paymentStatus, setPaymentStatus] = useState<'pending' | 'processing' | 'success' | 'error'>('pending')
@@ -26,9 +26,13 @@ export const useInvoicePayment = (token: string) => {
         if (data.status === 'PAID') {
           setPaymentStatus('success')
         }
-      } catch (err) {
+      } catch (err: any) {
         console.error('Error fetching invoice:', err)
-        setError('Failed to load invoice. Please check the payment link and try again.')
+        if (err.response && err.response.status === 410) {
+          setError('This payment link is no longer valid. Please ask the sender for a new one.')
+        } else {
+          setError('Failed to load invoice. Please check the payment link and try again.')
+        }
       } finally {
         setLoading(false)
       }
@@ -39,28 +43,51 @@ export const useInvoicePayment = (token: string) => {
     }
   }, [token])
 
-  // Check payment status periodically during processing
+  // Follow the payment through the invoice's event stream instead of polling
+  const invoiceLoaded = invoice !== null
   useEffect(() => {
-    if (!invoice || paymentStatus !== 'processing') {
+    if (!invoiceLoaded || paymentStatus === 'success') {
       return
     }
 
-    const checkPaymentStatus = async () => {
+    const refreshInvoice = async () => {
       try {
         const data = await apiService.getInvoiceByToken(token)
+        setInvoice(data)
         if (data.status === 'PAID') {
           setPaymentStatus('success')
-          setInvoice(data)
         }
       } catch (err) {
         console.error('Error checking payment status:', err)
       }
     }
 
-    // Check every 5 seconds while processing
-    const interval = setInterval(checkPaymentStatus, 5000)
-    return () => clearInterval(interval)
-  }, [invoice, token, paymentStatus])
+    // Browsers without EventSource check every 5 seconds while processing
+    if (typeof EventSource === 'undefined') {
+      if (paymentStatus !== 'processing') {
+        return
+      }
+      const interval = setInterval(refreshInvoice, 5000)
+      return () => clearInterval(interval)
+    }
+
+    // The browser reconnects on its own if the stream drops
+    const events = new EventSource(apiService.getInvoiceEventsUrl(token))
+    events.addEventListener('detected', () => setPaymentStatus('processing'))
+    events.addEventListener('confirming', () => setPaymentStatus('processing'))
+    events.addEventListener('paid', () => {
+      events.close()
+      refreshInvoice()
+    })
+    events.addEventListener('status', (event) => {
+      const { status } = JSON.parse((event as MessageEvent).data)
+      if (status !== 'PENDING') {
+        events.close()
+        refreshInvoice()
+      }
+    })
+    return () => events.close()
+  }, [invoiceLoaded, token, paymentStatus])
 
   // Format date for display
   const formatDate = (dateString: string) => {
@@ -74,27 +101,11 @@ export const useInvoicePayment = (token: string) => {
     setPaymentStatus('processing')
   }
 
+  // The payment watcher marks the invoice paid once it sees the transfer
+  // on-chain; payers have no credentials to update the invoice themselves
   const handlePaymentSuccess = async () => {
-    try {
-      // Update invoice status to PAID
-      if (invoice) {
-        // Set local state first for better user experience
-        setPaymentStatus('success')
-        setInvoice(prev => prev ? { ...prev, status: 'PAID' } : null)
-        
-        // Then try to update the backend
-        await apiService.updateInvoiceStatus(invoice.id, 'PAID')
-        console.log("Successfully updated invoice status in backend")
-      } else {
-        setPaymentStatus('success')
-      }
-    } catch (error) {
-      console.error('Error updating invoice status in backend:', error)
-      // We still show success to the user since the blockchain transaction succeeded
-      // even if the backend update failed
-      setPaymentStatus('success')
-      throw error // Propagate error for the wallet component to handle
-    }
+    setPaymentStatus('success')
+    setInvoice(prev => prev ? { ...prev, status: 'PAID' } : null)
   }
 
   const handlePaymentError = () => {
@@ -1,12 +1,13 @@
 import axios from 'axios'
-import { Invoice, InvoiceFormData } from '../types'
+import { CheckoutFormData, CheckoutSession, Invoice, InvoiceFormData, PublicCheckoutLink, PublicInvoice } from '../types'
 
 // TEMPORARY FIX: Hardcode the production API URL
 // const API_URL = process.env.NEXT_PUBLIC_API_URL || 'http://localhost:8080'
 const API_URL = 'https://serene-radiance-production.up.railway.app'
 
-// Get authentication token from localStorage if available, fallback to environment variables
-const getAuthToken = () => {
+// Get the authentication token stored at login, if any. Credentials are never
+// built into the bundle: the payment page is public and admins log in.
+const getAuthToken = (): string | null => {
   // Check if we're in a browser environment
   if (typeof window !== 'undefined') {
     const storedToken = localStorage.getItem('auth_token')
@@ -29,10 +30,7 @@ const getAuthToken = () => {
     }
   }
   
-  // Fallback to environment variables
-  const username = process.env.NEXT_PUBLIC_AUTH_USERNAME || 'admin'
-  const password = process.env.NEXT_PUBLIC_AUTH_PASSWORD || 'fluida'
-  return btoa(`${username}:${password}`)
+  return null
 }
 
 // Get the auth token
@@ -43,11 +41,20 @@ const api = axios.create({
   baseURL: `${API_URL}/api/v1`,
   headers: {
     'Content-Type': 'application/json',
-    'Authorization': `Basic ${authToken}`
+    ...(authToken ? { 'Authorization': `Basic ${authToken}` } : {})
   },
   withCredentials: true
 })
 
+// Payers open the payment page without credentials, so its public endpoints
+// are called without the admin Authorization header
+const publicApi = axios.create({
+  baseURL: `${API_URL}/api/v1/public`,
+  headers: {
+    'Content-Type': 'application/json'
+  }
+})
+
 // Add an interceptor to update auth token if it changes
 if (typeof window !== 'undefined') {
   // Check for token changes on every request
@@ -55,7 +62,7 @@ if (typeof window !== 'undefined') {
     const currentToken = getAuthToken()
     
     // Update the Authorization header if token has changed
-    if (config.headers && currentToken !== authToken) {
+    if (config.headers && currentToken && currentToken !== authToken) {
       config.headers.Authorization = `Basic ${currentToken}`
     }
     
@@ -96,19 +103,41 @@ export const apiService = {
   },
 
   /**
-   * Get an invoice by token
+   * Get the public view of an invoice by its payment link token
    */
-  getInvoiceByToken: async (token: string): Promise<Invoice> => {
+  getInvoiceByToken: async (token: string): Promise<PublicInvoice> => {
     // In case the token might need URL encoding
     const encodedToken = encodeURIComponent(token)
     
-    // The API endpoint expects /{linkToken}
-    const response = await api.get(`/invoices/${encodedToken}`)
+    const response = await publicApi.get(`/invoices/${encodedToken}`)
     
     // Handle both wrapped and unwrapped responses
     return response.data.data || response.data
   },
 
+  /**
+   * URL of the public Server-Sent Events stream of an invoice's payment events
+   */
+  getInvoiceEventsUrl: (token: string): string => {
+    return `${API_URL}/api/v1/public/invoices/${encodeURIComponent(token)}/events`
+  },
+
+  /**
+   * Get the public view of a checkout link by its slug
+   */
+  getCheckoutLink: async (slug: string): Promise<PublicCheckoutLink> => {
+    const response = await publicApi.get(`/checkout/${encodeURIComponent(slug)}`)
+    return response.data.data || response.data
+  },
+
+  /**
+   * Start a checkout session, which creates the invoice the visitor pays
+   */
+  startCheckoutSession: async (slug: string, data: CheckoutFormData): Promise<CheckoutSession> => {
+    const response = await publicApi.post(`/checkout/${encodeURIComponent(slug)}/sessions`, data)
+    return response.data.data || response.data
+  },
+
   /**
    * Create a new invoice
    */
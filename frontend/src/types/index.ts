@@ -26,6 +26,58 @@ export interface Invoice {
   createdAt: string
 }
 
+// Reduced invoice served to payers by the public payment link endpoints
+export interface PublicInvoice {
+  invoiceNumber: string
+  amount: number
+  currency: string
+  description: string
+  dueDate: string
+  status: string
+  receiverAddr: string
+  sender: {
+    name: string
+    email?: string
+  }
+  recipient: {
+    name: string
+  }
+  settlementToken: string
+  settlementAmount: number
+  exchangeRate?: number
+  rateExpiresAt?: string
+  paymentTxSignature?: string
+  paidAt?: string
+}
+
+// Public view of a checkout link, shown on its checkout page
+export interface PublicCheckoutLink {
+  name: string
+  description: string
+  amount?: number
+  minAmount?: number
+  maxAmount?: number
+  currency: string
+  maxQuantity: number
+  seller: string
+}
+
+// A started checkout session; the visitor pays its invoice on /pay/{linkToken}
+export interface CheckoutSession {
+  linkToken: string
+  quantity: number
+  expiresAt: string
+  invoice: PublicInvoice
+}
+
+// Form data for starting a checkout session
+export interface CheckoutFormData {
+  name: string
+  email: string
+  amount?: number
+  quantity: number
+}
+
 // Form data for creating a new invoice
 export interface InvoiceFormData {
   invoiceNumber: string